@@ -5,3 +5,21 @@ type Account struct {
 	PrivateKey         string
 	SecurityPassphrase string
 }
+
+// AccountState is the balance of an account on chain. Assets maps the asset
+// ids the account opted in to the units it holds. CreatedAssets are the assets
+// the account created that have not been destroyed.
+type AccountState struct {
+	Address       string            `json:"address"`
+	MicroAlgos    uint64            `json:"micro_algos"`
+	Assets        map[uint64]uint64 `json:"assets"`
+	CreatedAssets []uint64          `json:"created_assets,omitempty"`
+}
+
+// AssetMetadata overrides the name, metadata hash and note of a minted asset.
+// MetadataHash has to be exactly 32 bytes long.
+type AssetMetadata struct {
+	AssetName    string
+	MetadataHash string
+	Note         []byte
+}
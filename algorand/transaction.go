@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"eventers-marketplace-backend/logger"
 	"fmt"
+	"sort"
 
 	"github.com/algorand/go-algorand-sdk/client/algod"
 	"github.com/algorand/go-algorand-sdk/crypto"
@@ -15,9 +16,13 @@ import (
 type Algo interface {
 	GenerateAccount() (*Account, error)
 	Send(context.Context, *Account, uint64) error
-	CreateAsset(context.Context, *Account) (uint64, error)
+	CreateAsset(context.Context, *Account, *AssetMetadata) (uint64, error)
 	OptIn(context.Context, *Account, uint64) error
 	SendAsset(context.Context, *Account, *Account, uint64) error
+	RevokeAsset(context.Context, *Account, *Account, uint64) error
+	DestroyAsset(context.Context, uint64) error
+	AssetBalance(context.Context, string, uint64) (uint64, error)
+	AccountState(context.Context, string) (*AccountState, error)
 }
 
 type algo struct {
@@ -102,7 +107,9 @@ func (a *algo) GenerateAccount() (*Account, error) {
 	}, nil
 }
 
-func (a *algo) CreateAsset(ctx context.Context, ac *Account) (uint64, error) {
+// CreateAsset mints a single unit asset owned by ac. When md is nil the asset
+// carries the default eventers name and metadata.
+func (a *algo) CreateAsset(ctx context.Context, ac *Account, md *AssetMetadata) (uint64, error) {
 
 	var headers []*algod.Header
 	headers = append(headers, &algod.Header{Key: "X-API-Key", Value: a.apiKey})
@@ -136,6 +143,11 @@ func (a *algo) CreateAsset(ctx context.Context, ac *Account) (uint64, error) {
 	freeze := ""
 	clawback := a.from.AccountAddress
 	note := []byte(nil)
+	if md != nil {
+		assetName = md.AssetName
+		assetMetadataHash = md.MetadataHash
+		note = md.Note
+	}
 	txn, err := transaction.MakeAssetCreateTxn(creator, a.minFee, firstValidRound, lastValidRound, note,
 		genID, base64.StdEncoding.EncodeToString(genHash), totalIssuance, decimals, defaultFrozen, manager, reserve, freeze, clawback,
 		unitName, assetName, assetURL, assetMetadataHash)
@@ -310,6 +322,146 @@ func (a *algo) SendAsset(ctx context.Context, from, to *Account, assetID uint64)
 	return nil
 }
 
+// RevokeAsset claws back the asset held by target into to. The transaction is
+// signed by the platform account, which is set as clawback on every asset minted
+// by CreateAsset.
+func (a *algo) RevokeAsset(ctx context.Context, target, to *Account, assetID uint64) error {
+	var headers []*algod.Header
+	headers = append(headers, &algod.Header{Key: "X-API-Key", Value: a.apiKey})
+	algodClient, err := algod.MakeClientWithHeaders(a.apiAddress, "", headers)
+	if err != nil {
+		return fmt.Errorf("revokeAsset: error connecting to algo: %w", err)
+	}
+
+	txParams, err := algodClient.SuggestedParams()
+	if err != nil {
+		return fmt.Errorf("revokeAsset: error getting suggested tx params: %w", err)
+	}
+
+	note := []byte(fmt.Sprintf("Revoking asset from %s", target.AccountAddress))
+	genID := txParams.GenesisID
+	genHash := txParams.GenesisHash
+	firstValidRound := txParams.LastRound
+	lastValidRound := firstValidRound + 1000
+
+	txn, err := transaction.MakeAssetRevocationTxn(a.from.AccountAddress, target.AccountAddress, to.AccountAddress,
+		uint64(1), a.minFee, firstValidRound, lastValidRound, note,
+		genID, base64.StdEncoding.EncodeToString(genHash), assetID)
+	if err != nil {
+		return fmt.Errorf("revokeAsset: failed to make asset revocation txn: %w", err)
+	}
+
+	privateKey, err := mnemonic.ToPrivateKey(a.from.SecurityPassphrase)
+	if err != nil {
+		return fmt.Errorf("revokeAsset: error getting private key from mnemonic: %w", err)
+	}
+
+	txid, stx, err := crypto.SignTransaction(privateKey, txn)
+	if err != nil {
+		return fmt.Errorf("revokeAsset: failed to sign transaction: %w", err)
+	}
+	logger.Infof(ctx, "revokeAsset: signed txid: %s", txid)
+
+	txHeaders := append([]*algod.Header{}, &algod.Header{Key: "Content-Type", Value: "application/x-binary"})
+	sendResponse, err := algodClient.SendRawTransaction(stx, txHeaders...)
+	if err != nil {
+		return fmt.Errorf("revokeAsset: failed to send transaction: %w", err)
+	}
+
+	waitForConfirmation(ctx, algodClient, sendResponse.TxID)
+
+	return nil
+}
+
+// DestroyAsset removes the asset from the chain. The transaction is signed by
+// the platform account, which is set as manager on every asset minted by
+// CreateAsset, and only succeeds while the creator holds the asset.
+func (a *algo) DestroyAsset(ctx context.Context, assetID uint64) error {
+	var headers []*algod.Header
+	headers = append(headers, &algod.Header{Key: "X-API-Key", Value: a.apiKey})
+	algodClient, err := algod.MakeClientWithHeaders(a.apiAddress, "", headers)
+	if err != nil {
+		return fmt.Errorf("destroyAsset: error connecting to algo: %w", err)
+	}
+
+	txParams, err := algodClient.SuggestedParams()
+	if err != nil {
+		return fmt.Errorf("destroyAsset: error getting suggested tx params: %w", err)
+	}
+
+	genID := txParams.GenesisID
+	genHash := txParams.GenesisHash
+	firstValidRound := txParams.LastRound
+	lastValidRound := firstValidRound + 1000
+
+	txn, err := transaction.MakeAssetDestroyTxn(a.from.AccountAddress, a.minFee, firstValidRound, lastValidRound, nil,
+		genID, base64.StdEncoding.EncodeToString(genHash), assetID)
+	if err != nil {
+		return fmt.Errorf("destroyAsset: failed to make asset destroy txn: %w", err)
+	}
+
+	privateKey, err := mnemonic.ToPrivateKey(a.from.SecurityPassphrase)
+	if err != nil {
+		return fmt.Errorf("destroyAsset: error getting private key from mnemonic: %w", err)
+	}
+
+	txid, stx, err := crypto.SignTransaction(privateKey, txn)
+	if err != nil {
+		return fmt.Errorf("destroyAsset: failed to sign transaction: %w", err)
+	}
+	logger.Infof(ctx, "destroyAsset: signed txid: %s", txid)
+
+	txHeaders := append([]*algod.Header{}, &algod.Header{Key: "Content-Type", Value: "application/x-binary"})
+	sendResponse, err := algodClient.SendRawTransaction(stx, txHeaders...)
+	if err != nil {
+		return fmt.Errorf("destroyAsset: failed to send transaction: %w", err)
+	}
+
+	waitForConfirmation(ctx, algodClient, sendResponse.TxID)
+
+	return nil
+}
+
+// AssetBalance returns the units of the asset held by the address.
+func (a *algo) AssetBalance(ctx context.Context, address string, assetID uint64) (uint64, error) {
+	st, err := a.AccountState(ctx, address)
+	if err != nil {
+		return 0, fmt.Errorf("assetBalance: %w", err)
+	}
+
+	return st.Assets[assetID], nil
+}
+
+// AccountState returns the microalgos and asset holdings of the address.
+func (a *algo) AccountState(ctx context.Context, address string) (*AccountState, error) {
+	var headers []*algod.Header
+	headers = append(headers, &algod.Header{Key: "X-API-Key", Value: a.apiKey})
+	algodClient, err := algod.MakeClientWithHeaders(a.apiAddress, "", headers)
+	if err != nil {
+		return nil, fmt.Errorf("accountState: error connecting to algo: %w", err)
+	}
+
+	act, err := algodClient.AccountInformation(address)
+	if err != nil {
+		return nil, fmt.Errorf("accountState: failed to get account information: %w", err)
+	}
+
+	st := &AccountState{
+		Address:    address,
+		MicroAlgos: act.Amount,
+		Assets:     make(map[uint64]uint64),
+	}
+	for id, h := range act.Assets {
+		st.Assets[id] = h.Amount
+	}
+	for id := range act.AssetParams {
+		st.CreatedAssets = append(st.CreatedAssets, id)
+	}
+	sort.Slice(st.CreatedAssets, func(i, j int) bool { return st.CreatedAssets[i] < st.CreatedAssets[j] })
+
+	return st, nil
+}
+
 // Function that waits for a given txId to be confirmed by the network
 func waitForConfirmation(ctx context.Context, algodClient algod.Client, txID string) {
 	for {
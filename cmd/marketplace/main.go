@@ -4,10 +4,13 @@ import (
 	"context"
 	"eventers-marketplace-backend/config"
 	c "eventers-marketplace-backend/context"
+	"eventers-marketplace-backend/db/migrations"
+	"eventers-marketplace-backend/factory"
 	"eventers-marketplace-backend/router"
 	"flag"
 	"fmt"
 	l "log"
+	"strconv"
 
 	"github.com/codegangsta/negroni"
 	"github.com/spf13/viper"
@@ -37,9 +40,79 @@ func main() {
 		l.Fatalln("error reading config")
 	}
 
+	if flag.Arg(0) == "migrate" {
+		err = migrate(flag.Args()[1:])
+		if err != nil {
+			l.Fatalln(err)
+		}
+		return
+	}
+
 	muxRouter := router.Router(ctx)
 
 	n := negroni.New()
 	n.UseHandler(muxRouter)
 	n.Run(fmt.Sprintf("%s", viper.GetString(config.Port)))
 }
+
+// migrate runs the migrate subcommand: up, down [steps] or status.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: marketplace migrate up|down [steps]|status")
+	}
+
+	db := factory.NewFactory().DB(ctx)
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("no change")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("migrate down: invalid steps: %s", args[1])
+			}
+			steps = n
+		}
+
+		reverted, err := migrations.Down(ctx, db, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		state, ms, err := migrations.Status(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range ms {
+			status := "pending"
+			if m.Version <= state.Version {
+				status = "applied"
+			}
+			if m.Version == state.Version && state.Dirty {
+				status = "dirty"
+			}
+			fmt.Printf("%06d_%s\t%s\n", m.Version, m.Name, status)
+		}
+	default:
+		return fmt.Errorf("migrate: unknown command: %s", args[0])
+	}
+
+	return nil
+}
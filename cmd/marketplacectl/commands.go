@@ -0,0 +1,511 @@
+package main
+
+import (
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/config"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func (a *cli) marketplaceCreate(args []string) error {
+	fs := flag.NewFlagSet("marketplace create", flag.ContinueOnError)
+	name := fs.String("name", "", "Name of the marketplace")
+	accessType := fs.String("access-type", "", "INTEROP or NON_INTEROP, defaults to INTEROP")
+	displayName := fs.String("display-name", "", "Name shown to users")
+	callbackURL := fs.String("callback-url", "", "https URL notified of events")
+	origins := fs.String("origins", "", "Comma separated allowed origins")
+	allowBodyKey := fs.Bool("allow-body-key", false, "Accept the access key in the request body instead of signed requests")
+	publicKey := fs.String("public-key", "", "Base64 X25519 key wallet material is sealed to")
+	otpChannels := fs.String("otp-channels", "", "Comma separated channels OTPs are sent over, in order")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	m := &model.Marketplace{MarketPlaceName: *name, AccessType: *accessType, AllowBodyKey: allowBodyKey}
+	if *displayName != "" {
+		m.DisplayName = displayName
+	}
+	if *callbackURL != "" {
+		m.CallbackURL = callbackURL
+	}
+	if *origins != "" {
+		m.AllowedOrigins = splitList(*origins)
+	}
+	if *publicKey != "" {
+		m.PublicKey = publicKey
+	}
+	if *otpChannels != "" {
+		m.OTPChannels = splitList(*otpChannels)
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	m, err = service.Create(a.ctx, m)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(m, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "market_place_id\t%d\n", m.MarketPlaceID)
+		fmt.Fprintf(w, "market_place_name\t%s\n", m.MarketPlaceName)
+		fmt.Fprintf(w, "access_type\t%s\n", m.AccessType)
+		fmt.Fprintf(w, "public_key_id\t%s\n", value(m.PublicKeyID))
+		printKey(w, m.Key)
+	})
+}
+
+func (a *cli) marketplaceSetPublicKey(args []string) error {
+	fs := flag.NewFlagSet("marketplace set-public-key", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Marketplace id")
+	key := fs.String("key", "", "Base64 X25519 public key")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	m, err := service.SetPublicKey(a.ctx, *id, *key)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(m, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "market_place_id\t%d\n", m.MarketPlaceID)
+		fmt.Fprintf(w, "public_key_id\t%s\n", value(m.PublicKeyID))
+		fmt.Fprintf(w, "public_key\t%s\n", value(m.PublicKey))
+	})
+}
+
+func (a *cli) marketplaceRotateKey(args []string) error {
+	fs := flag.NewFlagSet("marketplace rotate-key", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Marketplace id")
+	scopes := fs.String("scopes", "", "Comma separated scopes of the new key, defaults to every scope")
+	grace := fs.Duration("grace", time.Duration(viper.GetInt(config.KeyRotationGrace))*time.Second, "How long the other keys keep working")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	k, err := service.RotateAccessKey(a.ctx, *id, splitList(*scopes), *grace)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(k, func(w *tabwriter.Writer) {
+		printKey(w, k)
+	})
+}
+
+func (a *cli) marketplaceKeys(args []string) error {
+	fs := flag.NewFlagSet("marketplace keys", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Marketplace id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	ks, err := service.ListKeys(a.ctx, *id)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(ks, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "KEY ID\tSCOPES\tEXPIRES\tREVOKED")
+		for _, k := range ks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.KeyID, strings.Join(k.Scopes, ","), timeValue(k.ExpiresAt), timeValue(k.RevokedAt))
+		}
+	})
+}
+
+func (a *cli) marketplaceRevokeKey(args []string) error {
+	fs := flag.NewFlagSet("marketplace revoke-key", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Marketplace id")
+	keyID := fs.String("key", "", "Key id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	err = service.RevokeKey(a.ctx, *id, *keyID)
+	if err != nil {
+		return describe(err)
+	}
+
+	result := struct {
+		KeyID   string `json:"key_id"`
+		Revoked bool   `json:"revoked"`
+	}{*keyID, true}
+
+	return a.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "revoked\t%s\n", *keyID)
+	})
+}
+
+func (a *cli) marketplaceDeactivate(args []string) error {
+	fs := flag.NewFlagSet("marketplace deactivate", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Marketplace id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	m, err := service.Deactivate(a.ctx, *id)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(m, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "market_place_id\t%d\n", m.MarketPlaceID)
+		fmt.Fprintf(w, "is_active\t%t\n", m.IsActive)
+	})
+}
+
+func (a *cli) marketplaceList(args []string) error {
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	ms, err := service.List(a.ctx)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(ms, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tDISPLAY NAME\tACCESS TYPE\tPUBLIC KEY\tACTIVE")
+		for _, m := range ms {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%t\n", m.MarketPlaceID, m.MarketPlaceName, value(m.DisplayName), m.AccessType, value(m.PublicKeyID), m.IsActive)
+		}
+	})
+}
+
+func (a *cli) marketplaceRewrapKeys(args []string) error {
+	service, err := a.marketplaces()
+	if err != nil {
+		return err
+	}
+
+	n, err := service.RewrapKeys(a.ctx)
+	if err != nil {
+		return describe(err)
+	}
+
+	result := struct {
+		Rewrapped int `json:"rewrapped"`
+	}{n}
+
+	return a.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "rewrapped\t%d\n", n)
+	})
+}
+
+func (a *cli) eventList(args []string) error {
+	fs := flag.NewFlagSet("event list", flag.ContinueOnError)
+	status := fs.String("status", "", "Only list events in this status")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	pes, err := a.store.Events.List(a.ctx, *status)
+	if err != nil {
+		return err
+	}
+
+	return a.print(pes, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tTITLE\tSTATUS\tTOTAL TICKETS\tORGANIZER")
+		for _, pe := range pes {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", pe.PublicEventID, value(pe.EventTitle), value(pe.Status), pe.TotalTickets, pe.BusinessUserID)
+		}
+	})
+}
+
+func (a *cli) eventShow(args []string) error {
+	fs := flag.NewFlagSet("event show", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Public event id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	events, err := a.events()
+	if err != nil {
+		return err
+	}
+
+	pe, err := events.InspectPublicEvent(a.ctx, *id)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(pe, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "public_event_id\t%d\n", pe.PublicEventID)
+		fmt.Fprintf(w, "event_title\t%s\n", value(pe.EventTitle))
+		fmt.Fprintf(w, "status\t%s\n", value(pe.Status))
+		fmt.Fprintf(w, "business_user_id\t%d\n", pe.BusinessUserID)
+		fmt.Fprintf(w, "venue_id\t%d\n", pe.VenueID)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "TIER ID\tTIER\tTOTAL\tAVAILABLE\tPRICE")
+		for _, tt := range pe.TicketTiers {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\n", tt.TicketTierID, value(tt.TierName), tt.TotalTickets, tt.AvailableTickets, tt.TicketPrice)
+		}
+	})
+}
+
+func (a *cli) eventRemint(args []string) error {
+	fs := flag.NewFlagSet("event remint", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Public event id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	events, err := a.events()
+	if err != nil {
+		return err
+	}
+
+	minted, err := events.Remint(a.ctx, *id)
+	result := struct {
+		PublicEventID int64 `json:"public_event_id"`
+		Minted        int   `json:"minted"`
+	}{*id, minted}
+
+	printErr := a.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "minted\t%d\n", minted)
+	})
+	if err != nil {
+		return describe(err)
+	}
+
+	return printErr
+}
+
+func (a *cli) ticketList(args []string) error {
+	fs := flag.NewFlagSet("ticket list", flag.ContinueOnError)
+	eventID := fs.Int64("event", 0, "Public event id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	ets, err := a.store.Tickets.ListByEvent(a.ctx, *eventID)
+	if err != nil {
+		return err
+	}
+
+	return a.print(ets, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tASSET ID\tTIER ID\tSEAT ID\tHOLDER\tSTATUS\tPRICE")
+		for _, et := range ets {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%s\t%d\n", et.EventTicketID, et.AssetID, et.TicketTierID, et.SeatID, et.CurrentHolderID, value(et.Status), et.Price)
+		}
+	})
+}
+
+func (a *cli) ticketShow(args []string) error {
+	fs := flag.NewFlagSet("ticket show", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Event ticket id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	et, ok, err := a.store.Tickets.Get(a.ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("event ticket: %d not found", *id)
+	}
+
+	return a.print(et, func(w *tabwriter.Writer) {
+		printTicket(w, et)
+	})
+}
+
+func (a *cli) ticketReconcile(args []string) error {
+	fs := flag.NewFlagSet("ticket reconcile", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "Event ticket id")
+	staleAfter := fs.Duration("stale-after", time.Duration(viper.GetInt(config.MoveRecoveryAge))*time.Second, "How long a move must be unchanged before it is driven forward")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	events, err := a.events()
+	if err != nil {
+		return err
+	}
+
+	r, err := events.ReconcileTicket(a.ctx, *id, *staleAfter)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(r, func(w *tabwriter.Writer) {
+		printTicket(w, &r.EventTicket)
+		fmt.Fprintf(w, "holder_address\t%s\n", r.HolderAddress)
+		fmt.Fprintf(w, "holder_balance\t%d\n", r.HolderBalance)
+		fmt.Fprintf(w, "in_sync\t%t\n", r.InSync)
+		if r.LatestMove != nil {
+			fmt.Fprintf(w, "latest_move\t%d %s %d -> %d %s\n", r.LatestMove.MoveID, r.LatestMove.Kind, r.LatestMove.FromUserID, r.LatestMove.ToUserID, r.LatestMove.State)
+			if r.LatestMove.LastError != nil {
+				fmt.Fprintf(w, "last_error\t%s\n", *r.LatestMove.LastError)
+			}
+		}
+	})
+}
+
+func (a *cli) accountTopUp(args []string) error {
+	fs := flag.NewFlagSet("account topup", flag.ContinueOnError)
+	address := fs.String("address", "", "Account address")
+	algos := fs.Uint64("algos", 0, "Algos to send from the funding account")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *address == "" || *algos == 0 {
+		return fmt.Errorf("-address and -algos are required")
+	}
+
+	algo := a.algoClient()
+	err = algo.Send(a.ctx, &algorand.Account{AccountAddress: *address}, *algos)
+	if err != nil {
+		return err
+	}
+
+	state, err := algo.AccountState(a.ctx, *address)
+	if err != nil {
+		return err
+	}
+
+	return a.print(state, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "address\t%s\n", state.Address)
+		fmt.Fprintf(w, "micro_algos\t%d\n", state.MicroAlgos)
+	})
+}
+
+func (a *cli) userCustody(args []string) error {
+	fs := flag.NewFlagSet("user custody", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "User id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	events, err := a.events()
+	if err != nil {
+		return err
+	}
+
+	cu, err := events.Custody(a.ctx, *id)
+	if err != nil {
+		return describe(err)
+	}
+
+	return a.print(cu, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "user_id\t%d\n", cu.UserID)
+		fmt.Fprintf(w, "address\t%s\n", cu.Address)
+		fmt.Fprintf(w, "micro_algos\t%d\n", cu.MicroAlgos)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "TICKET ID\tEVENT ID\tASSET ID\tSTATUS\tON CHAIN")
+		for _, t := range cu.Tickets {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%t\n", t.EventTicketID, t.PublicEventID, t.AssetID, value(t.Status), t.OnChain)
+		}
+		if len(cu.UntrackedAssets) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "UNTRACKED ASSET ID\tAMOUNT")
+			for assetID, amount := range cu.UntrackedAssets {
+				fmt.Fprintf(w, "%d\t%d\n", assetID, amount)
+			}
+		}
+	})
+}
+
+func printKey(w *tabwriter.Writer, k *model.MarketplaceKey) {
+	if k == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "key_id\t%s\n", k.KeyID)
+	fmt.Fprintf(w, "scopes\t%s\n", strings.Join(k.Scopes, ","))
+	fmt.Fprintf(w, "access_key\t%s\n", k.AccessKey)
+	fmt.Fprintf(w, "signing_key\t%s\n", k.SigningKey)
+}
+
+func printTicket(w *tabwriter.Writer, et *model.EventTicket) {
+	fmt.Fprintf(w, "event_ticket_id\t%d\n", et.EventTicketID)
+	fmt.Fprintf(w, "public_event_id\t%d\n", et.PublicEventID)
+	fmt.Fprintf(w, "asset_id\t%d\n", et.AssetID)
+	fmt.Fprintf(w, "ticket_tier_id\t%d\n", et.TicketTierID)
+	fmt.Fprintf(w, "seat_id\t%d\n", et.SeatID)
+	fmt.Fprintf(w, "current_holder_id\t%d\n", et.CurrentHolderID)
+	fmt.Fprintf(w, "status\t%s\n", value(et.Status))
+	fmt.Fprintf(w, "price\t%d\n", et.Price)
+}
+
+// describe turns the client-facing errors of the services into plain errors.
+func describe(err error) error {
+	if e, ok := err.(response.ErrorResponse); ok {
+		return fmt.Errorf("%s: %s", e.Message, e.Description)
+	}
+	return err
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func timeValue(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
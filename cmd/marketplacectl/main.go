@@ -0,0 +1,212 @@
+// Command marketplacectl operates the marketplace backend from a shell. It
+// reads the same config file as the service and talks to the same database,
+// vault and algod node.
+//
+//	marketplacectl [-CONFIG_PATH path] [-json] <resource> <command> [flags]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/config"
+	c "eventers-marketplace-backend/context"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/factory"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/vault"
+	"eventers-marketplace-backend/webhook"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+const defaultCorrelationID = "00000000.00000000"
+
+const usage = `usage: marketplacectl [-CONFIG_PATH path] [-json] <command>
+
+commands:
+  marketplace create -name <name> [-access-type INTEROP|NON_INTEROP] [-display-name <name>]
+                     [-callback-url <url>] [-origins <origin,...>] [-allow-body-key]
+                     [-public-key <base64 X25519 key>] [-otp-channels <channel,...>]
+  marketplace rotate-key -id <marketplace_id> [-scopes <scope,...>] [-grace <duration>]
+  marketplace set-public-key -id <marketplace_id> -key <base64 X25519 key>
+  marketplace keys -id <marketplace_id>
+  marketplace revoke-key -id <marketplace_id> -key <key_id>
+  marketplace deactivate -id <marketplace_id>
+  marketplace list
+  marketplace rewrap-keys
+  event list [-status <status>]
+  event show -id <public_event_id>
+  event remint -id <public_event_id>
+  ticket list -event <public_event_id>
+  ticket show -id <event_ticket_id>
+  ticket reconcile -id <event_ticket_id> [-stale-after <duration>]
+  account topup -address <address> -algos <algos>
+  user custody -id <user_id>
+`
+
+// cli holds the clients shared by the commands. The chain and vault clients
+// are only built for the commands that need them.
+type cli struct {
+	ctx   context.Context
+	store *store.Store
+	json  bool
+	vault *vault.Vault
+	algo  algorand.Algo
+}
+
+func main() {
+	cfgPath := flag.String("CONFIG_PATH", "./config.yaml", "Path to config file")
+	asJSON := flag.Bool("json", false, "Print the result as JSON")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	viper.SetConfigFile(*cfgPath)
+	err := viper.ReadInConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := c.SetContextWithValue(context.Background(), c.ContextKeyCorrelationID, defaultCorrelationID)
+	db := factory.NewFactory().DB(ctx)
+	defer db.Close()
+
+	app := &cli{
+		ctx:   ctx,
+		store: store.NewMySQL(db),
+		json:  *asJSON,
+	}
+
+	err = app.run(flag.Arg(0), flag.Arg(1), flag.Args()[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", flag.Arg(0), flag.Arg(1), err)
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func (a *cli) run(resource, command string, args []string) error {
+	switch resource + " " + command {
+	case "marketplace create":
+		return a.marketplaceCreate(args)
+	case "marketplace rotate-key":
+		return a.marketplaceRotateKey(args)
+	case "marketplace set-public-key":
+		return a.marketplaceSetPublicKey(args)
+	case "marketplace keys":
+		return a.marketplaceKeys(args)
+	case "marketplace revoke-key":
+		return a.marketplaceRevokeKey(args)
+	case "marketplace deactivate":
+		return a.marketplaceDeactivate(args)
+	case "marketplace list":
+		return a.marketplaceList(args)
+	case "marketplace rewrap-keys":
+		return a.marketplaceRewrapKeys(args)
+	case "event list":
+		return a.eventList(args)
+	case "event show":
+		return a.eventShow(args)
+	case "event remint":
+		return a.eventRemint(args)
+	case "ticket list":
+		return a.ticketList(args)
+	case "ticket show":
+		return a.ticketShow(args)
+	case "ticket reconcile":
+		return a.ticketReconcile(args)
+	case "account topup":
+		return a.accountTopUp(args)
+	case "user custody":
+		return a.userCustody(args)
+	default:
+		return fmt.Errorf("unknown command\n%s", usage)
+	}
+}
+
+func (a *cli) marketplaces() (*marketplace.Marketplace, error) {
+	kek, err := marketplace.ParseKeyEncryptionKey(viper.GetString(config.MarketplaceKeyEncryptionKey))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.MarketplaceKeyEncryptionKey, err)
+	}
+
+	return marketplace.NewMarketplace(a.store, kek), nil
+}
+
+func (a *cli) events() (*event.Event, error) {
+	v, err := a.vaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Webhooks are only enqueued here, the server delivers them.
+	return event.NewEvent(a.algoClient(), *v, a.store, webhook.New(a.store, http.DefaultClient, webhook.Policy{}), nil), nil
+}
+
+func (a *cli) vaultClient() (*vault.Vault, error) {
+	if a.vault != nil {
+		return a.vault, nil
+	}
+
+	v, err := vault.New(
+		viper.GetString(config.VaultToken),
+		viper.GetString(config.VaultUnSealKey),
+		viper.GetString(config.VaultAddress),
+		viper.GetString(config.UserPath),
+		viper.GetString(config.TempPath))
+	if err != nil {
+		return nil, fmt.Errorf("error creating vault client: %w", err)
+	}
+
+	a.vault = v
+	return a.vault, nil
+}
+
+func (a *cli) algoClient() algorand.Algo {
+	if a.algo != nil {
+		return a.algo
+	}
+
+	fromAccount := &algorand.Account{
+		AccountAddress:     viper.GetString(config.FromAddress),
+		SecurityPassphrase: viper.GetString(config.FromSecurityParaphrase),
+	}
+
+	a.algo = algorand.New(
+		fromAccount,
+		viper.GetString(config.ApiAddress),
+		viper.GetString(config.ApiKey),
+		viper.GetUint64(config.AmountFactor),
+		viper.GetUint64(config.MinFee),
+		viper.GetUint64(config.SeedAlgo),
+	)
+	return a.algo
+}
+
+// print writes v as indented JSON when -json is set and calls table otherwise.
+func (a *cli) print(v interface{}, table func(w *tabwriter.Writer)) error {
+	if a.json {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding json: %w", err)
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
@@ -1,31 +1,192 @@
 package codec
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
 )
 
-func Encrypt(key, text []byte) (string, error) {
-	block, err := aes.NewCipher(key)
+// Envelopes are written as
+//
+//	v1.<algorithm>.<key id>.<aad>.<nonce>.<ciphertext>[.<ephemeral key>]
+//
+// with aad, nonce, ciphertext (which ends in the GCM tag) and the ephemeral key
+// base64url encoded without padding. Only envelopes sealed to a public key carry
+// an ephemeral key. Payloads without the version prefix are legacy AES-CFB.
+const (
+	Version1         = "v1"
+	AlgA256GCM       = "A256GCM"
+	AlgX25519A256GCM = "X25519-A256GCM"
+
+	envelopeSeparator = "."
+	envelopeParts     = 6
+)
+
+var envelopeEncoding = base64.RawURLEncoding
+
+// Envelope is a parsed envelope.
+type Envelope struct {
+	Version      string
+	Algorithm    string
+	KeyID        string
+	AAD          []byte
+	Nonce        []byte
+	Ciphertext   []byte
+	EphemeralKey []byte
+}
+
+// String returns the wire form of the envelope.
+func (e *Envelope) String() string {
+	parts := []string{
+		e.Version,
+		e.Algorithm,
+		e.KeyID,
+		envelopeEncoding.EncodeToString(e.AAD),
+		envelopeEncoding.EncodeToString(e.Nonce),
+		envelopeEncoding.EncodeToString(e.Ciphertext),
+	}
+	if len(e.EphemeralKey) > 0 {
+		parts = append(parts, envelopeEncoding.EncodeToString(e.EphemeralKey))
+	}
+	return strings.Join(parts, envelopeSeparator)
+}
+
+// Encrypt seals text with AES-256-GCM under key and returns the envelope. keyID
+// names the key so the reader knows which one to open it with, aad binds the
+// envelope to its context and has to be passed again to Decrypt.
+func Encrypt(key []byte, keyID string, text, aad []byte) (string, error) {
+	if strings.Contains(keyID, envelopeSeparator) {
+		return "", fmt.Errorf("encrypt: invalid key id: %s", keyID)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", fmt.Errorf("encrypt: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("encrypt: error reading nonce: %w", err)
+	}
+
+	e := &Envelope{
+		Version:    Version1,
+		Algorithm:  AlgA256GCM,
+		KeyID:      keyID,
+		AAD:        aad,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, text, aad),
+	}
+	return e.String(), nil
+}
+
+// Decrypt opens an envelope written by Encrypt, which only succeeds when aad is
+// the one it was sealed with. Legacy AES-CFB payloads carry no AAD and are
+// decrypted as before.
+func Decrypt(key []byte, text string, aad []byte) ([]byte, error) {
+	if !strings.HasPrefix(text, Version1+envelopeSeparator) {
+		return decryptLegacy(key, text)
+	}
+
+	e, err := Parse(text)
 	if err != nil {
-		return "", fmt.Errorf("encrypt: could not encrypt: %w", err)
+		return nil, fmt.Errorf("decrypt: %w", err)
 	}
-	b := base64.StdEncoding.EncodeToString(text)
-	ciphertext := make([]byte, aes.BlockSize+len(b))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", err
+
+	if e.Algorithm != AlgA256GCM {
+		return nil, fmt.Errorf("decrypt: envelope is sealed to a public key")
+	}
+
+	data, err := e.open(key, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
 	}
-	cfb := cipher.NewCFBEncrypter(block, iv)
-	cfb.XORKeyStream(ciphertext[aes.BlockSize:], []byte(b))
-	return base64.URLEncoding.EncodeToString(ciphertext), nil
+	return data, nil
 }
 
-func Decrypt(key []byte, text string) ([]byte, error) {
+// Parse reads an envelope without opening it, to find the key it was sealed
+// with.
+func Parse(text string) (*Envelope, error) {
+	parts := strings.Split(text, envelopeSeparator)
+	if len(parts) < envelopeParts {
+		return nil, fmt.Errorf("parse: malformed envelope")
+	}
+
+	if parts[0] != Version1 {
+		return nil, fmt.Errorf("parse: unsupported version: %s", parts[0])
+	}
+
+	switch {
+	case parts[1] == AlgA256GCM && len(parts) == envelopeParts:
+	case parts[1] == AlgX25519A256GCM && len(parts) == envelopeParts+1:
+	default:
+		return nil, fmt.Errorf("parse: unsupported algorithm: %s", parts[1])
+	}
+
+	e := &Envelope{Version: parts[0], Algorithm: parts[1], KeyID: parts[2]}
+	var err error
+	for _, f := range []struct {
+		dst *[]byte
+		src string
+	}{{&e.AAD, parts[3]}, {&e.Nonce, parts[4]}, {&e.Ciphertext, parts[5]}} {
+		*f.dst, err = envelopeEncoding.DecodeString(f.src)
+		if err != nil {
+			return nil, fmt.Errorf("parse: error decoding envelope: %w", err)
+		}
+	}
+	if len(parts) > envelopeParts {
+		e.EphemeralKey, err = envelopeEncoding.DecodeString(parts[envelopeParts])
+		if err != nil {
+			return nil, fmt.Errorf("parse: error decoding ephemeral key: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// open decrypts the ciphertext with the AES-256-GCM key once aad matches the
+// one the envelope carries.
+func (e *Envelope) open(key, aad []byte) ([]byte, error) {
+	if !bytes.Equal(e.AAD, aad) {
+		return nil, fmt.Errorf("open: envelope is bound to another context")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	if len(e.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("open: invalid nonce size: %d", len(e.Nonce))
+	}
+
+	data, err := gcm.Open(nil, e.Nonce, e.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("open: could not open envelope: %w", err)
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("newGCM: %s needs a 32 byte key, got %d", AlgA256GCM, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("newGCM: could not create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacy reads the AES-CFB payloads Encrypt wrote before envelopes: the
+// base64url IV and ciphertext of the base64 encoded plaintext.
+func decryptLegacy(key []byte, text string) ([]byte, error) {
 	cipherText, err := base64.URLEncoding.DecodeString(text)
 	if err != nil {
 		return nil, fmt.Errorf("decode: error decoding into base64: %w", err)
@@ -35,7 +196,7 @@ func Decrypt(key []byte, text string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("decode: could not create cipher: %w", err)
 	}
-	if len(text) < aes.BlockSize {
+	if len(cipherText) < aes.BlockSize {
 		return nil, fmt.Errorf("decrypt: ciphertext too short")
 	}
 	iv := cipherText[:aes.BlockSize]
@@ -47,4 +208,4 @@ func Decrypt(key []byte, text string) ([]byte, error) {
 		return nil, fmt.Errorf("decode: error decoding string: %w", err)
 	}
 	return data, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.Nil(t, err)
+	aad := []byte("marketplace:1/user:2")
+
+	text, err := Encrypt(key, "mk_0123456789abcdef", []byte("passphrase"), aad)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(text, "v1.A256GCM.mk_0123456789abcdef."))
+
+	e, err := Parse(text)
+	require.Nil(t, err)
+	assert.Equal(t, "mk_0123456789abcdef", e.KeyID)
+	assert.Equal(t, aad, e.AAD)
+
+	data, err := Decrypt(key, text, aad)
+	require.Nil(t, err)
+	assert.Equal(t, "passphrase", string(data))
+
+	_, err = Decrypt(key, text, []byte("marketplace:1/user:3"))
+	assert.NotNil(t, err)
+
+	e.AAD = []byte("marketplace:1/user:3")
+	_, err = Decrypt(key, e.String(), e.AAD)
+	assert.NotNil(t, err)
+
+	e.AAD = aad
+	e.Ciphertext[0] ^= 1
+	_, err = Decrypt(key, e.String(), aad)
+	assert.NotNil(t, err)
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.Nil(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.Nil(t, err)
+	b := base64.StdEncoding.EncodeToString([]byte("passphrase"))
+	ciphertext := make([]byte, aes.BlockSize+len(b))
+	_, err = io.ReadFull(rand.Reader, ciphertext[:aes.BlockSize])
+	require.Nil(t, err)
+	cipher.NewCFBEncrypter(block, ciphertext[:aes.BlockSize]).XORKeyStream(ciphertext[aes.BlockSize:], []byte(b))
+
+	data, err := Decrypt(key, base64.URLEncoding.EncodeToString(ciphertext), nil)
+	require.Nil(t, err)
+	assert.Equal(t, "passphrase", string(data))
+
+	_, err = Decrypt(key, base64.URLEncoding.EncodeToString(ciphertext[:4]), nil)
+	assert.NotNil(t, err)
+}
+
+func TestSealOpen(t *testing.T) {
+	pub, priv, err := GenerateKey()
+	require.Nil(t, err)
+	aad := []byte("marketplace:1/user:2")
+
+	text, err := Seal(pub, "pk_0123456789abcdef", []byte("passphrase"), aad)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(text, "v1.X25519-A256GCM.pk_0123456789abcdef."))
+
+	data, err := Open(priv, text, aad)
+	require.Nil(t, err)
+	assert.Equal(t, "passphrase", string(data))
+
+	_, err = Open(priv, text, []byte("marketplace:1/user:3"))
+	assert.NotNil(t, err)
+
+	_, other, err := GenerateKey()
+	require.Nil(t, err)
+	_, err = Open(other, text, aad)
+	assert.NotNil(t, err)
+
+	_, err = Decrypt(priv, text, aad)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// KeySize is the size of X25519 public and private keys.
+const KeySize = 32
+
+// sealInfo labels the AES-256-GCM key derived from the X25519 shared secret.
+// Recipients derive it as
+//
+//	key = HKDF-SHA256(X25519(private key, ephemeral key), salt = ephemeral key || public key, info = sealInfo)
+const sealInfo = "eventers-marketplace/wallet-seal/v1"
+
+// GenerateKey returns a new X25519 key pair.
+func GenerateKey() (publicKey, privateKey []byte, err error) {
+	var priv, pub [KeySize]byte
+	if _, err := io.ReadFull(rand.Reader, priv[:]); err != nil {
+		return nil, nil, fmt.Errorf("generateKey: error reading private key: %w", err)
+	}
+	curve25519.ScalarBaseMult(&pub, &priv)
+	return pub[:], priv[:], nil
+}
+
+// Seal encrypts text to the X25519 public key so that only the holder of the
+// private key can read it. keyID names the public key, aad binds the envelope
+// to its context and has to be passed again to Open.
+func Seal(publicKey []byte, keyID string, text, aad []byte) (string, error) {
+	if strings.Contains(keyID, envelopeSeparator) {
+		return "", fmt.Errorf("seal: invalid key id: %s", keyID)
+	}
+
+	if len(publicKey) != KeySize {
+		return "", fmt.Errorf("seal: invalid public key size: %d", len(publicKey))
+	}
+
+	ephemeralKey, ephemeralPrivate, err := GenerateKey()
+	if err != nil {
+		return "", fmt.Errorf("seal: %w", err)
+	}
+
+	key, err := sealKey(ephemeralPrivate, publicKey, ephemeralKey, publicKey)
+	if err != nil {
+		return "", fmt.Errorf("seal: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", fmt.Errorf("seal: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("seal: error reading nonce: %w", err)
+	}
+
+	e := &Envelope{
+		Version:      Version1,
+		Algorithm:    AlgX25519A256GCM,
+		KeyID:        keyID,
+		AAD:          aad,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, text, aad),
+		EphemeralKey: ephemeralKey,
+	}
+	return e.String(), nil
+}
+
+// Open decrypts an envelope written by Seal with the private key, which only
+// succeeds when aad is the one it was sealed with.
+func Open(privateKey []byte, text string, aad []byte) ([]byte, error) {
+	if len(privateKey) != KeySize {
+		return nil, fmt.Errorf("open: invalid private key size: %d", len(privateKey))
+	}
+
+	e, err := Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	if e.Algorithm != AlgX25519A256GCM {
+		return nil, fmt.Errorf("open: envelope is not sealed to a public key")
+	}
+
+	var priv, pub [KeySize]byte
+	copy(priv[:], privateKey)
+	curve25519.ScalarBaseMult(&pub, &priv)
+
+	key, err := sealKey(privateKey, e.EphemeralKey, e.EphemeralKey, pub[:])
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	data, err := e.open(key, aad)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	return data, nil
+}
+
+// sealKey derives the AES-256-GCM key from the X25519 shared secret of
+// privateKey and peerKey, binding it to the ephemeral and the public key of the
+// envelope.
+func sealKey(privateKey, peerKey, ephemeralKey, publicKey []byte) ([]byte, error) {
+	if len(peerKey) != KeySize {
+		return nil, fmt.Errorf("sealKey: invalid key size: %d", len(peerKey))
+	}
+
+	var priv, peer, shared [KeySize]byte
+	copy(priv[:], privateKey)
+	copy(peer[:], peerKey)
+	curve25519.ScalarMult(&shared, &priv, &peer)
+
+	var zero [KeySize]byte
+	if subtle.ConstantTimeCompare(shared[:], zero[:]) == 1 {
+		return nil, fmt.Errorf("sealKey: low order public key")
+	}
+
+	salt := append(append([]byte(nil), ephemeralKey...), publicKey...)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared[:], salt, []byte(sealInfo)), key); err != nil {
+		return nil, fmt.Errorf("sealKey: error deriving key: %w", err)
+	}
+	return key, nil
+}
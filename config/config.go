@@ -26,7 +26,44 @@ const (
 
 	Port               = "server.port"
 	JWTOfflineInterval = "server.jwt_offline_interval"
-	Secret             = "server.secret"
+	SeatHoldTTL        = "server.seat_hold_ttl"
+	ReservationTTL     = "server.reservation_ttl"
+	ReservationSweep   = "server.reservation_sweep_interval"
+	ListingTTL         = "server.listing_ttl"
+	ListingSweep       = "server.listing_sweep_interval"
+	AuctionSweep       = "server.auction_sweep_interval"
+	IdempotencyTTL     = "server.idempotency_ttl"
+	IdempotencyLockTTL = "server.idempotency_lock_ttl"
+	MoveRecoveryAge    = "server.move_recovery_age"
+	MoveRecoverySweep  = "server.move_recovery_interval"
+	AdminToken         = "server.admin_token"
+	KeyRotationGrace   = "server.key_rotation_grace"
+	SignatureWindow    = "server.signature_window"
+
+	OTPLength      = "otp.length"
+	OTPTTL         = "otp.ttl"
+	OTPMaxAttempts = "otp.max_attempts"
+	OTPLockout     = "otp.lockout"
+	OTPCooldown    = "otp.resend_cooldown"
+	OTPMaxCooldown = "otp.max_resend_cooldown"
+	OTPPhoneQuota  = "otp.phone_quota"
+	OTPUserQuota   = "otp.user_quota"
+	OTPIPQuota     = "otp.ip_quota"
+	OTPQuotaWindow = "otp.quota_window"
+
+	MarketplaceKeyEncryptionKey = "marketplace.key_encryption_key"
+
+	SessionSigningKey = "session.signing_key"
+	SessionIssuer     = "session.issuer"
+	SessionAccessTTL  = "session.access_ttl"
+	SessionRefreshTTL = "session.refresh_ttl"
+
+	WebhookMaxAttempts = "webhook.max_attempts"
+	WebhookBackoff     = "webhook.backoff"
+	WebhookMaxBackoff  = "webhook.max_backoff"
+	WebhookTimeout     = "webhook.timeout"
+	WebhookBatchSize   = "webhook.batch_size"
+	WebhookSweep       = "webhook.interval"
 
 	RedisAddress  = "redis.address"
 	RedisPassword = "redis.password"
@@ -36,10 +73,51 @@ const (
 	TwilioAuthToken  = "twilio.auth_token"
 	TwilioURL        = "twilio.url"
 	TwilioFrom       = "twilio.from"
+	TwilioWhatsApp   = "twilio.whatsapp_from"
+
+	SMTPAddress  = "smtp.address"
+	SMTPUsername = "smtp.username"
+	SMTPPassword = "smtp.password"
+	SMTPFrom     = "smtp.from"
+
+	NotifyChannels = "notify.default_channels"
+	NotifyLogPath  = "notify.log_path"
 )
 
 func init() {
 	viper.AutomaticEnv()
 	viper.SetDefault(Port, "9000")
 	viper.SetDefault(JWTOfflineInterval, 120)
+	viper.SetDefault(SeatHoldTTL, 300)
+	viper.SetDefault(ReservationTTL, 600)
+	viper.SetDefault(ReservationSweep, 60)
+	viper.SetDefault(ListingTTL, 604800)
+	viper.SetDefault(ListingSweep, 60)
+	viper.SetDefault(AuctionSweep, 30)
+	viper.SetDefault(IdempotencyTTL, 86400)
+	viper.SetDefault(IdempotencyLockTTL, 120)
+	viper.SetDefault(MoveRecoveryAge, 300)
+	viper.SetDefault(MoveRecoverySweep, 60)
+	viper.SetDefault(KeyRotationGrace, 86400)
+	viper.SetDefault(SignatureWindow, 300)
+	viper.SetDefault(NotifyChannels, []string{"sms"})
+	viper.SetDefault(OTPLength, 6)
+	viper.SetDefault(OTPTTL, 300)
+	viper.SetDefault(OTPMaxAttempts, 5)
+	viper.SetDefault(OTPLockout, 900)
+	viper.SetDefault(OTPCooldown, 30)
+	viper.SetDefault(OTPMaxCooldown, 900)
+	viper.SetDefault(OTPPhoneQuota, 10)
+	viper.SetDefault(OTPUserQuota, 10)
+	viper.SetDefault(OTPIPQuota, 50)
+	viper.SetDefault(OTPQuotaWindow, 86400)
+	viper.SetDefault(SessionIssuer, "eventers-marketplace")
+	viper.SetDefault(SessionAccessTTL, 900)
+	viper.SetDefault(SessionRefreshTTL, 2592000)
+	viper.SetDefault(WebhookMaxAttempts, 8)
+	viper.SetDefault(WebhookBackoff, 30)
+	viper.SetDefault(WebhookMaxBackoff, 21600)
+	viper.SetDefault(WebhookTimeout, 10)
+	viper.SetDefault(WebhookBatchSize, 50)
+	viper.SetDefault(WebhookSweep, 10)
 }
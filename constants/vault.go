@@ -5,3 +5,23 @@ const (
 	PrivateKey         = "private_key"
 	SecurityPassphrase = "security_passphrase"
 )
+
+// Marketplace access types. INTEROP marketplaces share the wallet of a user
+// across marketplaces, NON_INTEROP ones get a wallet of their own.
+const (
+	Interop    = "INTEROP"
+	NonInterop = "NON_INTEROP"
+)
+
+// OTP audit events.
+const (
+	OTPSent          = "SENT"
+	OTPSendFailed    = "SEND_FAILED"
+	OTPCooldown      = "COOLDOWN"
+	OTPQuotaExceeded = "QUOTA_EXCEEDED"
+	OTPVerified      = "VERIFIED"
+	OTPMismatch      = "MISMATCH"
+	OTPExpired       = "EXPIRED"
+	OTPLockedOut     = "LOCKED_OUT"
+	OTPLocked        = "LOCKED"
+)
@@ -0,0 +1,5 @@
+Drop table Ticket_Refund;
+alter table Public_Event
+    drop column business_user_id,
+    drop column status,
+    drop column updated_date;
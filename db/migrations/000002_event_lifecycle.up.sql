@@ -0,0 +1,18 @@
+alter table Public_Event
+    add business_user_id int(21) null,
+    add status varchar(20) default 'DRAFT' not null,
+    add updated_date datetime default CURRENT_TIMESTAMP null;
+
+create table Ticket_Refund
+(
+    ticket_refund_id int(21) auto_increment
+        primary key,
+    event_ticket_id int(21) not null,
+    public_event_id int(21) not null,
+    user_id int(21) not null,
+    amount int null,
+    status varchar(20) default 'PENDING' not null,
+    created_date datetime default CURRENT_TIMESTAMP not null
+);
+
+update Public_Event set status = 'PUBLISHED';
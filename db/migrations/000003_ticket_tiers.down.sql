@@ -0,0 +1,3 @@
+alter table Event_Tickets
+    drop column ticket_tier_id;
+Drop table Ticket_Tier;
@@ -0,0 +1,23 @@
+create table Ticket_Tier
+(
+    ticket_tier_id int(21) auto_increment
+        primary key,
+    public_event_id int(21) not null,
+    tier_name varchar(100) not null,
+    total_tickets int(100) not null,
+    ticket_price int(100) not null,
+    sale_start datetime null,
+    sale_end datetime null,
+    per_user_limit int default 0 not null,
+    created_date datetime default CURRENT_TIMESTAMP not null
+);
+
+alter table Event_Tickets
+    add ticket_tier_id int(21) null;
+
+insert into Ticket_Tier (public_event_id, tier_name, total_tickets, ticket_price)
+select public_event_id, 'General', ifnull(total_tickets, 0), ifnull(ticket_price, 0) from Public_Event;
+
+update Event_Tickets et
+    inner join Ticket_Tier tt on tt.public_event_id = et.public_event_id
+set et.ticket_tier_id = tt.ticket_tier_id;
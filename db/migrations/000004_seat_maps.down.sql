@@ -0,0 +1,7 @@
+alter table Event_Tickets
+    drop index event_tickets_seat_uindex,
+    drop column seat_id;
+alter table Public_Event
+    drop column venue_id;
+Drop table Venue_Seat;
+Drop table Venue;
@@ -0,0 +1,28 @@
+create table Venue
+(
+    venue_id int(21) auto_increment
+        primary key,
+    venue_name varchar(200) null,
+    created_date datetime default CURRENT_TIMESTAMP not null
+);
+
+create table Venue_Seat
+(
+    seat_id int(21) auto_increment
+        primary key,
+    venue_id int(21) not null,
+    section_name varchar(50) not null,
+    row_label varchar(20) not null,
+    seat_number varchar(20) not null,
+    tier_name varchar(100) null,
+    constraint venue_seat_position_uindex
+        unique (venue_id, section_name, row_label, seat_number)
+);
+
+alter table Public_Event
+    add venue_id int(21) null;
+
+alter table Event_Tickets
+    add seat_id int(21) null,
+    add constraint event_tickets_seat_uindex
+        unique (public_event_id, seat_id);
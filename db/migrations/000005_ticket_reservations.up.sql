@@ -0,0 +1,15 @@
+create table Ticket_Reservation
+(
+    reservation_id varchar(64) not null
+        primary key,
+    event_ticket_id int(21) not null,
+    public_event_id int(21) not null,
+    user_id int(21) not null,
+    status varchar(20) default 'ACTIVE' not null,
+    expires_at datetime not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP null
+);
+
+create index ticket_reservation_ticket_index
+    on Ticket_Reservation (event_ticket_id, status, expires_at);
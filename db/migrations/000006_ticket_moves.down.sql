@@ -0,0 +1 @@
+Drop table Ticket_Move;
@@ -0,0 +1,23 @@
+create table Ticket_Move
+(
+    move_id int(21) auto_increment
+        primary key,
+    kind varchar(20) not null,
+    event_ticket_id int(21) not null,
+    asset_id varchar(100) not null,
+    from_user_id int(21) not null,
+    to_user_id int(21) not null,
+    expected_status varchar(50) not null,
+    new_status varchar(50) not null,
+    reservation_id varchar(64) null,
+    state varchar(20) default 'PENDING' not null,
+    attempts int default 0 not null,
+    version int default 0 not null,
+    last_error varchar(1024) null,
+    submitted_date datetime null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null
+);
+
+create index ticket_move_state_index
+    on Ticket_Move (state, updated_date);
@@ -0,0 +1,47 @@
+alter table Ticket_Move
+    drop foreign key ticket_move_event_ticket_fk,
+    drop foreign key ticket_move_reservation_fk;
+
+alter table Ticket_Reservation
+    drop foreign key ticket_reservation_event_ticket_fk,
+    drop foreign key ticket_reservation_public_event_fk;
+
+alter table Ticket_Refund
+    drop foreign key ticket_refund_event_ticket_fk,
+    drop foreign key ticket_refund_public_event_fk;
+
+drop index event_tickets_holder_index on Event_Tickets;
+
+alter table Event_Tickets
+    drop foreign key event_tickets_public_event_fk,
+    drop foreign key event_tickets_ticket_tier_fk,
+    drop foreign key event_tickets_seat_fk;
+
+alter table Venue_Seat
+    drop foreign key venue_seat_venue_fk;
+
+alter table Ticket_Tier
+    drop foreign key ticket_tier_public_event_fk,
+    drop index ticket_tier_name_uindex;
+
+drop index public_event_status_index on Public_Event;
+
+alter table Public_Event
+    drop foreign key public_event_business_user_fk,
+    drop foreign key public_event_venue_fk;
+
+alter table Users
+    drop index users_active_phone_uindex,
+    drop index users_active_phone_firebase_id_uindex,
+    drop index users_active_a_firebase_id_uindex,
+    drop index users_active_fb_firebase_id_uindex,
+    drop index users_active_g_firebase_id_uindex,
+    drop column active_phone,
+    drop column active_phone_firebase_id,
+    drop column active_a_firebase_id,
+    drop column active_fb_firebase_id,
+    drop column active_g_firebase_id;
+
+drop table if exists User_Device;
+drop table if exists User_Marketplace;
+drop table if exists Marketplace;
@@ -0,0 +1,107 @@
+create table if not exists Marketplace
+(
+    marketplace_id int(21) auto_increment
+        primary key,
+    marketplace_name varchar(200) not null,
+    access_key varchar(200) not null,
+    access_type varchar(20) default 'INTEROP' not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint marketplace_access_key_uindex
+        unique (access_key)
+);
+
+create table if not exists User_Marketplace
+(
+    user_marketplace_id int(21) auto_increment
+        primary key,
+    marketplace_id int(21) not null,
+    user_phone_number varchar(20) not null,
+    is_valid tinyint(1) default 0 not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint user_marketplace_phone_uindex
+        unique (marketplace_id, user_phone_number),
+    constraint user_marketplace_marketplace_fk
+        foreign key (marketplace_id) references Marketplace (marketplace_id)
+);
+
+create table if not exists User_Device
+(
+    user_device_id int(21) auto_increment
+        primary key,
+    user_id int(21) not null,
+    device_id varchar(200) not null,
+    fcm_token varchar(400) null,
+    ip_address varchar(50) null,
+    login_time datetime null,
+    device_provider varchar(20) null,
+    is_valid tinyint(1) default 1 not null,
+    constraint user_device_uindex
+        unique (user_id, device_id),
+    constraint user_device_user_fk
+        foreign key (user_id) references Users (user_id)
+);
+
+-- A phone number or firebase id belongs to at most one active user. Merged and
+-- deactivated users keep their rows, so the keys are only set while active.
+-- Databases holding several active users with the same one are refused before
+-- the migration runs, see uniqueActiveUsers, and have to be merged by hand.
+alter table Users
+    add active_phone varchar(25) as (if(is_active = 1, concat(phone_country_code, phone_number), null)) stored,
+    add active_phone_firebase_id varchar(100) as (if(is_active = 1, phone_firebase_id, null)) stored,
+    add active_a_firebase_id varchar(100) as (if(is_active = 1, a_firebase_id, null)) stored,
+    add active_fb_firebase_id varchar(100) as (if(is_active = 1, fb_firebase_id, null)) stored,
+    add active_g_firebase_id varchar(100) as (if(is_active = 1, g_firebase_id, null)) stored,
+    add constraint users_active_phone_uindex unique (active_phone),
+    add constraint users_active_phone_firebase_id_uindex unique (active_phone_firebase_id),
+    add constraint users_active_a_firebase_id_uindex unique (active_a_firebase_id),
+    add constraint users_active_fb_firebase_id_uindex unique (active_fb_firebase_id),
+    add constraint users_active_g_firebase_id_uindex unique (active_g_firebase_id);
+
+alter table Public_Event
+    add constraint public_event_business_user_fk
+        foreign key (business_user_id) references Users (user_id),
+    add constraint public_event_venue_fk
+        foreign key (venue_id) references Venue (venue_id);
+
+create index public_event_status_index
+    on Public_Event (status, date_time);
+
+alter table Ticket_Tier
+    add constraint ticket_tier_public_event_fk
+        foreign key (public_event_id) references Public_Event (public_event_id)
+            on delete cascade,
+    add constraint ticket_tier_name_uindex
+        unique (public_event_id, tier_name);
+
+alter table Venue_Seat
+    add constraint venue_seat_venue_fk
+        foreign key (venue_id) references Venue (venue_id);
+
+alter table Event_Tickets
+    add constraint event_tickets_public_event_fk
+        foreign key (public_event_id) references Public_Event (public_event_id),
+    add constraint event_tickets_ticket_tier_fk
+        foreign key (ticket_tier_id) references Ticket_Tier (ticket_tier_id),
+    add constraint event_tickets_seat_fk
+        foreign key (seat_id) references Venue_Seat (seat_id);
+
+create index event_tickets_holder_index
+    on Event_Tickets (current_holder_id, status);
+
+alter table Ticket_Refund
+    add constraint ticket_refund_event_ticket_fk
+        foreign key (event_ticket_id) references Event_Tickets (event_ticket_id),
+    add constraint ticket_refund_public_event_fk
+        foreign key (public_event_id) references Public_Event (public_event_id);
+
+alter table Ticket_Reservation
+    add constraint ticket_reservation_event_ticket_fk
+        foreign key (event_ticket_id) references Event_Tickets (event_ticket_id),
+    add constraint ticket_reservation_public_event_fk
+        foreign key (public_event_id) references Public_Event (public_event_id);
+
+alter table Ticket_Move
+    add constraint ticket_move_event_ticket_fk
+        foreign key (event_ticket_id) references Event_Tickets (event_ticket_id),
+    add constraint ticket_move_reservation_fk
+        foreign key (reservation_id) references Ticket_Reservation (reservation_id);
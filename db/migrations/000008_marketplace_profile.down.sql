@@ -0,0 +1,6 @@
+alter table Marketplace
+    drop column display_name,
+    drop column callback_url,
+    drop column allowed_origins,
+    drop column is_active,
+    drop column updated_date;
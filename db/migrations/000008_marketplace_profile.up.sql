@@ -0,0 +1,6 @@
+alter table Marketplace
+    add display_name varchar(200) null,
+    add callback_url varchar(2048) null,
+    add allowed_origins text null,
+    add is_active tinyint(1) default 1 not null,
+    add updated_date datetime default CURRENT_TIMESTAMP null;
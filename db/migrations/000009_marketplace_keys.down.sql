@@ -0,0 +1,6 @@
+-- Only hashes of the access keys are stored, so the plaintext keys cannot be
+-- restored. Every marketplace needs a new access_key after this.
+alter table Marketplace
+    add access_key varchar(200) null;
+
+drop table Marketplace_Key;
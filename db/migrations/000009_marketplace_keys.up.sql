@@ -0,0 +1,25 @@
+create table Marketplace_Key
+(
+    key_id varchar(40) not null
+        primary key,
+    marketplace_id int(21) not null,
+    salt varchar(64) not null,
+    secret_hash varchar(64) not null,
+    scopes varchar(200) not null,
+    expires_date datetime null,
+    revoked_date datetime null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint marketplace_key_marketplace_fk
+        foreign key (marketplace_id) references Marketplace (marketplace_id)
+);
+
+create index marketplace_key_marketplace_index
+    on Marketplace_Key (marketplace_id);
+
+insert into Marketplace_Key (key_id, marketplace_id, salt, secret_hash, scopes)
+select concat('legacy_', left(sha2(access_key, 256), 16)), marketplace_id, '', sha2(access_key, 256), 'users'
+from Marketplace;
+
+alter table Marketplace
+    drop index marketplace_access_key_uindex,
+    drop column access_key;
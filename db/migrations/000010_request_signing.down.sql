@@ -0,0 +1,5 @@
+alter table Marketplace_Key
+    drop column signing_key;
+
+alter table Marketplace
+    drop column allow_body_key;
@@ -0,0 +1,6 @@
+alter table Marketplace
+    add allow_body_key tinyint(1) default 1 not null;
+
+-- Signing keys are stored encrypted with the key encryption key.
+alter table Marketplace_Key
+    add signing_key varchar(255) null;
@@ -0,0 +1,3 @@
+alter table Marketplace
+    drop column public_key_id,
+    drop column public_key;
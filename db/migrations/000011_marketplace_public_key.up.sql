@@ -0,0 +1,3 @@
+alter table Marketplace
+    add public_key varchar(64) null,
+    add public_key_id varchar(40) null;
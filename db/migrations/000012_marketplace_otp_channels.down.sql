@@ -0,0 +1,2 @@
+alter table Marketplace
+    drop column otp_channels;
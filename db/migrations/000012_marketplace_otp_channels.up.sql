@@ -0,0 +1,2 @@
+alter table Marketplace
+    add otp_channels varchar(100) null;
@@ -0,0 +1 @@
+drop table OTP_Audit;
@@ -0,0 +1,17 @@
+create table OTP_Audit
+(
+    otp_audit_id int(21) auto_increment
+        primary key,
+    marketplace_id int(21) not null,
+    user_marketplace_id int(21) null,
+    phone_number varchar(20) null,
+    ip_address varchar(45) null,
+    event varchar(30) not null,
+    channel varchar(20) null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint otp_audit_marketplace_fk
+        foreign key (marketplace_id) references Marketplace (marketplace_id)
+);
+
+create index otp_audit_user_index
+    on OTP_Audit (marketplace_id, user_marketplace_id, created_date);
@@ -0,0 +1,13 @@
+alter table Ticket_Refund
+    drop column wallet;
+
+alter table Ticket_Reservation
+    drop column wallet;
+
+alter table Ticket_Move
+    drop column from_wallet,
+    drop column to_wallet;
+
+alter table Event_Tickets
+    drop index event_tickets_holder_wallet_index,
+    drop column holder_wallet;
@@ -0,0 +1,15 @@
+alter table Event_Tickets
+    add holder_wallet varchar(60) default '' not null;
+
+create index event_tickets_holder_wallet_index
+    on Event_Tickets (holder_wallet);
+
+alter table Ticket_Move
+    add from_wallet varchar(60) default '' not null,
+    add to_wallet varchar(60) default '' not null;
+
+alter table Ticket_Reservation
+    add wallet varchar(60) default '' not null;
+
+alter table Ticket_Refund
+    add wallet varchar(60) default '' not null;
@@ -0,0 +1,8 @@
+drop table Marketplace_Ticket_Move;
+
+alter table Ticket_Move
+    drop column from_marketplace_id,
+    drop column to_marketplace_id;
+
+alter table Event_Tickets
+    drop column holder_marketplace_id;
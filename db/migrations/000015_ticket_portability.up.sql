@@ -0,0 +1,29 @@
+alter table Event_Tickets
+    add holder_marketplace_id int(21) null;
+
+alter table Ticket_Move
+    add from_marketplace_id int(21) null,
+    add to_marketplace_id int(21) null;
+
+create table Marketplace_Ticket_Move
+(
+    marketplace_move_id int(21) auto_increment
+        primary key,
+    move_id int(21) not null,
+    event_ticket_id int(21) not null,
+    kind varchar(20) not null,
+    from_marketplace_id int(21) null,
+    to_marketplace_id int(21) null,
+    from_wallet varchar(60) default '' not null,
+    to_wallet varchar(60) default '' not null,
+    amount int null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint marketplace_ticket_move_move_fk
+        foreign key (move_id) references Ticket_Move (move_id)
+);
+
+create index marketplace_ticket_move_from_index
+    on Marketplace_Ticket_Move (from_marketplace_id, created_date);
+
+create index marketplace_ticket_move_to_index
+    on Marketplace_Ticket_Move (to_marketplace_id, created_date);
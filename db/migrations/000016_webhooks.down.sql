@@ -0,0 +1,5 @@
+drop table Webhook_Delivery;
+
+alter table Marketplace
+    drop column webhook_events,
+    drop column webhook_secret;
@@ -0,0 +1,29 @@
+alter table Marketplace
+    add webhook_events varchar(255) null,
+    add webhook_secret varchar(64) null;
+
+create table Webhook_Delivery
+(
+    delivery_id int(21) auto_increment
+        primary key,
+    marketplace_id int(21) not null,
+    event_id varchar(40) not null,
+    event_type varchar(40) not null,
+    payload text not null,
+    status varchar(20) not null,
+    attempts int default 0 not null,
+    next_attempt_date datetime not null,
+    last_status_code int null,
+    last_error varchar(1024) null,
+    delivered_date datetime null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint webhook_delivery_marketplace_fk
+        foreign key (marketplace_id) references Marketplace (marketplace_id)
+);
+
+create index webhook_delivery_due_index
+    on Webhook_Delivery (status, next_attempt_date);
+
+create index webhook_delivery_marketplace_index
+    on Webhook_Delivery (marketplace_id, status, delivery_id);
@@ -0,0 +1,6 @@
+drop table Resale_Payout;
+
+alter table Event_Tickets
+    drop column transfer_count;
+
+drop table Resale_Policy;
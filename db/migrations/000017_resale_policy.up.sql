@@ -0,0 +1,42 @@
+create table Resale_Policy
+(
+    public_event_id int(21) not null
+        primary key,
+    max_markup_bps int null,
+    royalty_bps int default 0 not null,
+    platform_fee_bps int default 0 not null,
+    cutoff_minutes int default 0 not null,
+    max_transfers int null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint resale_policy_public_event_fk
+        foreign key (public_event_id) references Public_Event (public_event_id)
+            on delete cascade
+);
+
+alter table Event_Tickets
+    add transfer_count int default 0 not null;
+
+create table Resale_Payout
+(
+    resale_payout_id int(21) auto_increment
+        primary key,
+    move_id int(21) not null,
+    event_ticket_id int(21) not null,
+    public_event_id int(21) not null,
+    seller_user_id int(21) not null,
+    seller_wallet varchar(60) default '' not null,
+    organizer_user_id int(21) not null,
+    gross int not null,
+    royalty int not null,
+    platform_fee int not null,
+    seller_amount int not null,
+    status varchar(20) default 'PENDING' not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    constraint resale_payout_move_uindex
+        unique (move_id),
+    constraint resale_payout_move_fk
+        foreign key (move_id) references Ticket_Move (move_id)
+);
+
+create index resale_payout_public_event_index
+    on Resale_Payout (public_event_id, created_date);
@@ -0,0 +1 @@
+drop table Resale_Listings;
@@ -0,0 +1,31 @@
+create table Resale_Listings
+(
+    listing_id int(21) auto_increment
+        primary key,
+    event_ticket_id int(21) not null,
+    public_event_id int(21) not null,
+    seller_user_id int(21) not null,
+    seller_wallet varchar(60) default '' not null,
+    ask_price int not null,
+    status varchar(20) default 'OPEN' not null,
+    expires_at datetime not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint resale_listings_event_ticket_fk
+        foreign key (event_ticket_id) references Event_Tickets (event_ticket_id)
+);
+
+create index resale_listings_event_ticket_index
+    on Resale_Listings (event_ticket_id, status);
+
+create index resale_listings_expiry_index
+    on Resale_Listings (status, expires_at);
+
+insert into Resale_Listings (event_ticket_id, public_event_id, seller_user_id, seller_wallet, ask_price, expires_at)
+select et.event_ticket_id, et.public_event_id, et.current_holder_id, et.holder_wallet, ifnull(et.price, 0),
+       ifnull(pe.date_time, date_add(now(), interval 7 day))
+from Event_Tickets et
+    inner join Public_Event pe on pe.public_event_id = et.public_event_id
+where et.status = 'RESELL';
+
+update Event_Tickets set available_to_resell = (status = 'RESELL');
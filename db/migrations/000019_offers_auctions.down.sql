@@ -0,0 +1,8 @@
+alter table Ticket_Move
+    drop column amount;
+
+drop table Auction_Bids;
+
+drop table Auctions;
+
+drop table Resale_Offers;
@@ -0,0 +1,73 @@
+create table Resale_Offers
+(
+    offer_id int(21) auto_increment
+        primary key,
+    listing_id int(21) not null,
+    event_ticket_id int(21) not null,
+    buyer_user_id int(21) default 0 not null,
+    buyer_wallet varchar(60) default '' not null,
+    buyer_marketplace_id int(21) null,
+    buyer_user_marketplace_id int(21) null,
+    amount int not null,
+    status varchar(20) default 'PENDING' not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint resale_offers_listing_fk
+        foreign key (listing_id) references Resale_Listings (listing_id)
+);
+
+create index resale_offers_listing_index
+    on Resale_Offers (listing_id, status);
+
+create index resale_offers_event_ticket_index
+    on Resale_Offers (event_ticket_id, status);
+
+create table Auctions
+(
+    auction_id int(21) auto_increment
+        primary key,
+    public_event_id int(21) not null,
+    event_ticket_id int(21) not null,
+    reservation_id varchar(64) not null,
+    reserve_price int not null,
+    min_increment int not null,
+    starts_at datetime not null,
+    ends_at datetime not null,
+    status varchar(20) default 'OPEN' not null,
+    leading_bid_id int(21) null,
+    leading_amount int null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint auctions_event_ticket_fk
+        foreign key (event_ticket_id) references Event_Tickets (event_ticket_id),
+    constraint auctions_reservation_fk
+        foreign key (reservation_id) references Ticket_Reservation (reservation_id)
+);
+
+create index auctions_status_index
+    on Auctions (status, ends_at);
+
+create table Auction_Bids
+(
+    bid_id int(21) auto_increment
+        primary key,
+    auction_id int(21) not null,
+    buyer_user_id int(21) default 0 not null,
+    buyer_wallet varchar(60) default '' not null,
+    buyer_marketplace_id int(21) null,
+    buyer_user_marketplace_id int(21) null,
+    amount int not null,
+    status varchar(20) default 'LEADING' not null,
+    created_date datetime default CURRENT_TIMESTAMP not null,
+    updated_date datetime default CURRENT_TIMESTAMP not null,
+    constraint auction_bids_auction_fk
+        foreign key (auction_id) references Auctions (auction_id)
+);
+
+create index auction_bids_auction_index
+    on Auction_Bids (auction_id, status);
+
+-- The amount an accepted offer sells the ticket at. Other moves leave it NULL
+-- and resales settle at the price the ticket is listed at.
+alter table Ticket_Move
+    add amount int null;
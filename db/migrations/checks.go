@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// checks run before the migration of their version is applied and refuse it
+// while the data does not fit the new schema. A refused migration leaves the
+// database clean at the version before, so it can be fixed by hand and
+// migrated again.
+var checks = map[uint64]func(ctx context.Context, conn *sql.Conn) error{
+	7: uniqueActiveUsers,
+}
+
+// activeUserKeys are the identities migration 7 makes unique among active
+// users.
+var activeUserKeys = []struct {
+	name string
+	expr string
+}{
+	{"phone", "concat(phone_country_code, phone_number)"},
+	{"phone_firebase_id", "phone_firebase_id"},
+	{"a_firebase_id", "a_firebase_id"},
+	{"fb_firebase_id", "fb_firebase_id"},
+	{"g_firebase_id", "g_firebase_id"},
+}
+
+// uniqueActiveUsers lists the active users sharing a phone number or firebase
+// id. Each of them may own tickets, events and a wallet, so they are not
+// deactivated here but have to be merged by hand.
+func uniqueActiveUsers(ctx context.Context, conn *sql.Conn) error {
+	var dups []string
+	for _, k := range activeUserKeys {
+		q := fmt.Sprintf(`SELECT k, GROUP_CONCAT(user_id ORDER BY user_id) FROM
+			(SELECT %s AS k, user_id FROM Users WHERE is_active = 1) u
+			WHERE k IS NOT NULL GROUP BY k HAVING COUNT(*) > 1 ORDER BY k`, k.expr)
+
+		rows, err := conn.QueryContext(ctx, q)
+		if err != nil {
+			return fmt.Errorf("uniqueActiveUsers: error querying users by %s: %w", k.name, err)
+		}
+
+		for rows.Next() {
+			var value, userIDs string
+			err = rows.Scan(&value, &userIDs)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("uniqueActiveUsers: error scanning users by %s: %w", k.name, err)
+			}
+			dups = append(dups, fmt.Sprintf("%s %s: users %s", k.name, value, userIDs))
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("uniqueActiveUsers: error reading users by %s: %w", k.name, err)
+		}
+	}
+
+	if len(dups) > 0 {
+		return fmt.Errorf("active users share an identity, merge them first: %s", strings.Join(dups, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+// Package migrations carries the schema migrations of the service inside the
+// binary and applies them. Versions are tracked in the schema_migrations table
+// in the same layout golang-migrate uses, so databases migrated with its CLI can
+// be taken over.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+const lockName = "eventers-marketplace-migrate"
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is the version the database is at. A dirty state means a migration
+// failed halfway and has to be fixed by hand before migrating again.
+type State struct {
+	Version uint64
+	Dirty   bool
+}
+
+// Load returns the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("load: error reading migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("load: invalid migration file name: %s", name)
+		}
+
+		version, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("load: invalid version of migration: %s: %w", name, err)
+		}
+
+		b, err := files.ReadFile(path.Join(".", name))
+		if err != nil {
+			return nil, fmt.Errorf("load: error reading migration: %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+
+		if m.Name != parts[1] {
+			return nil, fmt.Errorf("load: version %d is used by %s and %s", version, m.Name, parts[1])
+		}
+
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	var ms []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("load: migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// Up applies every migration newer than the current version and returns the
+// ones it applied. It stops before a migration whose check fails, see checks.
+func Up(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	ms, err := Load()
+	if err != nil {
+		return nil, fmt.Errorf("up: %w", err)
+	}
+
+	var applied []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		state, err := current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if state.Dirty {
+			return fmt.Errorf("database is dirty at version %d, fix it by hand first", state.Version)
+		}
+
+		for _, m := range ms {
+			if m.Version <= state.Version {
+				continue
+			}
+
+			if check, ok := checks[m.Version]; ok {
+				err = check(ctx, conn)
+				if err != nil {
+					return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+				}
+			}
+
+			err = run(ctx, conn, m.Version, m.Up, m.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("up: %w", err)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the newest steps migrations and returns the ones it reverted.
+func Down(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	ms, err := Load()
+	if err != nil {
+		return nil, fmt.Errorf("down: %w", err)
+	}
+
+	var reverted []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		state, err := current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if state.Dirty {
+			return fmt.Errorf("database is dirty at version %d, fix it by hand first", state.Version)
+		}
+
+		for i := len(ms) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := ms[i]
+			if m.Version > state.Version {
+				continue
+			}
+
+			var previous uint64
+			if i > 0 {
+				previous = ms[i-1].Version
+			}
+
+			err = run(ctx, conn, m.Version, m.Down, previous)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return reverted, fmt.Errorf("down: %w", err)
+	}
+
+	return reverted, nil
+}
+
+// Status returns the state of the database along with the embedded migrations.
+func Status(ctx context.Context, db *sql.DB) (*State, []Migration, error) {
+	ms, err := Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("status: %w", err)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("status: error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	state, err := current(ctx, conn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("status: %w", err)
+	}
+
+	return state, ms, nil
+}
+
+// run marks the database dirty at version, executes the statements of the
+// migration and records target as the clean version. MySQL commits DDL
+// implicitly, so a failed migration leaves the database dirty.
+func run(ctx context.Context, conn *sql.Conn, version uint64, script string, target uint64) error {
+	err := setVersion(ctx, conn, version, true)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range statements(script) {
+		_, err = conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("error executing %q: %w", firstLine(stmt), err)
+		}
+	}
+
+	return setVersion(ctx, conn, target, false)
+}
+
+func withLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 10)`, lockName).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+
+	if locked.Int64 != 1 {
+		return fmt.Errorf("another migration is running")
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName)
+
+	_, err = conn.ExecContext(ctx, `create table if not exists schema_migrations
+		(
+			version bigint not null
+				primary key,
+			dirty tinyint(1) not null
+		)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func current(ctx context.Context, conn *sql.Conn) (*State, error) {
+	var state State
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&state.Version, &state.Dirty)
+	if err == sql.ErrNoRows {
+		return &state, nil
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "doesn't exist") {
+			return &state, nil
+		}
+		return nil, fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	return &state, nil
+}
+
+func setVersion(ctx context.Context, conn *sql.Conn, version uint64, dirty bool) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("error clearing schema version: %w", err)
+	}
+
+	if version == 0 && !dirty {
+		return nil
+	}
+
+	_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)`, version, dirty)
+	if err != nil {
+		return fmt.Errorf("error recording schema version: %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// statements splits a migration into the statements it is made of. Comment
+// lines are dropped and statements end with a semicolon at the end of a line.
+func statements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(b.String()))
+			b.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+
+	return stmts
+}
+
+func firstLine(stmt string) string {
+	return strings.SplitN(stmt, "\n", 2)[0]
+}
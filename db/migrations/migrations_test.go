@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	ms, err := Load()
+	require.Nil(t, err)
+
+	require.NotEmpty(t, ms)
+	for i, m := range ms {
+		assert.Equal(t, uint64(i+1), m.Version)
+		assert.NotEmpty(t, statements(m.Up))
+		assert.NotEmpty(t, statements(m.Down))
+	}
+}
+
+func TestChecksHaveMigrations(t *testing.T) {
+	ms, err := Load()
+	require.Nil(t, err)
+
+	versions := make(map[uint64]bool)
+	for _, m := range ms {
+		versions[m.Version] = true
+	}
+
+	for version := range checks {
+		assert.True(t, versions[version], "check of missing migration %d", version)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- users\ncreate table A\n(\n    id int\n);\n\nupdate A set id = 1;\ninsert into A values (2)"
+
+	stmts := statements(script)
+	require.Len(t, stmts, 3)
+	assert.Equal(t, "create table A\n(\n    id int\n);", stmts[0])
+	assert.Equal(t, "update A set id = 1;", stmts[1])
+	assert.Equal(t, "insert into A values (2)", stmts[2])
+}
@@ -0,0 +1,332 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"time"
+)
+
+// InspectPublicEvent returns the public event with its ticket tiers.
+func (u *Event) InspectPublicEvent(ctx context.Context, publicEventID int64) (*model.PublicEvent, error) {
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("inspectPublicEvent: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("public event not found", fmt.Sprintf("inspectPublicEvent: public_event_id: %d", publicEventID))
+	}
+
+	pe.TicketTiers, err = u.store.Tiers.List(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("inspectPublicEvent: %w", err)
+	}
+
+	return pe, nil
+}
+
+// Remint mints the tickets of a published event that are missing because
+// minting failed halfway. Seated events get a ticket for every seat that has
+// none, other events get as many tickets as each tier falls short of. Assets
+// the temp account minted that no ticket records are reused for events without
+// seats and destroyed otherwise, as their seat cannot be told from the chain.
+// Tickets are minted and handed over one at a time, so it must not run while
+// the original minting is still in progress. It returns the number of tickets
+// minted or recovered.
+func (u *Event) Remint(ctx context.Context, publicEventID int64) (int, error) {
+	pe, err := u.InspectPublicEvent(ctx, publicEventID)
+	if err != nil {
+		return 0, err
+	}
+
+	if *pe.Status != Published {
+		return 0, response.InvalidStateTransition(fmt.Sprintf("remint: public event: %d is %s", publicEventID, *pe.Status))
+	}
+
+	a, err := u.fetchTempAccount(publicEventID)
+	if err != nil {
+		return 0, fmt.Errorf("remint: %w", err)
+	}
+
+	ua, ok, err := u.fetchUserAddress(pe.BusinessUserID)
+	if err != nil {
+		return 0, fmt.Errorf("remint: %w", err)
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("remint: business_user_id: %d not found", pe.BusinessUserID)
+	}
+
+	ets, err := u.store.Tickets.ListByEvent(ctx, publicEventID)
+	if err != nil {
+		return 0, fmt.Errorf("remint: %w", err)
+	}
+
+	minted := make(map[int64]uint64)
+	seated := make(map[int64]bool)
+	for _, et := range ets {
+		minted[et.TicketTierID]++
+		if et.SeatID > 0 {
+			seated[et.SeatID] = true
+		}
+	}
+
+	var seats []model.Seat
+	if pe.VenueID > 0 {
+		seats, err = u.store.Venues.Seats(ctx, pe.VenueID)
+		if err != nil {
+			return 0, fmt.Errorf("remint: %w", err)
+		}
+	}
+
+	orphans, err := u.orphanedAssets(ctx, a, ua, ets)
+	if err != nil {
+		return 0, fmt.Errorf("remint: %w", err)
+	}
+
+	count := 0
+	handOver := func(tt *model.TicketTier, seat *model.Seat) error {
+		if seat == nil && len(orphans) > 0 {
+			o := orphans[0]
+			orphans = orphans[1:]
+
+			et := newEventTicket(pe, tt, nil, pe.BusinessUserID, o.assetID)
+			var err error
+			if o.withOrganizer {
+				et.EventTicketID, err = u.store.Tickets.Create(ctx, et)
+			} else {
+				err = u.start(ctx, et, a, ua)
+			}
+			if err != nil {
+				return err
+			}
+
+			count++
+			return nil
+		}
+
+		et, err := u.mint(ctx, a, pe, tt, seat, pe.BusinessUserID)
+		if err != nil {
+			return err
+		}
+
+		err = u.start(ctx, et, a, ua)
+		if err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	}
+
+	for i := range pe.TicketTiers {
+		tt := &pe.TicketTiers[i]
+		if pe.VenueID == 0 {
+			for n := minted[tt.TicketTierID]; n < tt.TotalTickets; n++ {
+				err := handOver(tt, nil)
+				if err != nil {
+					return count, fmt.Errorf("remint: %w", err)
+				}
+			}
+			continue
+		}
+
+		for j := range seats {
+			if seats[j].Tier != *tt.TierName || seated[seats[j].SeatID] {
+				continue
+			}
+
+			err := handOver(tt, &seats[j])
+			if err != nil {
+				return count, fmt.Errorf("remint: %w", err)
+			}
+		}
+	}
+
+	for _, o := range orphans {
+		err := u.closeOrphan(ctx, a, ua, o)
+		if err != nil {
+			return count, fmt.Errorf("remint: %w", err)
+		}
+	}
+
+	return count, nil
+}
+
+// orphan is an asset minted for an event that no ticket records.
+type orphan struct {
+	assetID       uint64
+	withOrganizer bool
+}
+
+// orphanedAssets returns the assets created by the temp account of the event
+// that no ticket records, left behind when minting stopped between creating
+// the asset and recording its ticket. Assets held by anyone but the temp
+// account or the organizer are logged and left alone.
+func (u *Event) orphanedAssets(ctx context.Context, a, ua *algorand.Account, ets []model.EventTicket) ([]orphan, error) {
+	st, err := u.algo.AccountState(ctx, a.AccountAddress)
+	if err != nil {
+		return nil, fmt.Errorf("orphanedAssets: %w", err)
+	}
+
+	recorded := make(map[uint64]bool)
+	for _, et := range ets {
+		recorded[et.AssetID] = true
+	}
+
+	var orphans []orphan
+	for _, assetID := range st.CreatedAssets {
+		if recorded[assetID] {
+			continue
+		}
+
+		if st.Assets[assetID] > 0 {
+			orphans = append(orphans, orphan{assetID: assetID})
+			continue
+		}
+
+		balance, err := u.algo.AssetBalance(ctx, ua.AccountAddress, assetID)
+		if err != nil {
+			return nil, fmt.Errorf("orphanedAssets: %w", err)
+		}
+
+		if balance > 0 {
+			orphans = append(orphans, orphan{assetID: assetID, withOrganizer: true})
+			continue
+		}
+
+		logger.Errorf(ctx, "orphanedAssets: asset: %d has no ticket and is held by neither the temp account nor the organizer", assetID)
+	}
+
+	return orphans, nil
+}
+
+// closeOrphan takes the asset back from the organizer when needed and destroys
+// it.
+func (u *Event) closeOrphan(ctx context.Context, a, ua *algorand.Account, o orphan) error {
+	if o.withOrganizer {
+		err := u.algo.RevokeAsset(ctx, ua, a, o.assetID)
+		if err != nil {
+			return fmt.Errorf("closeOrphan: error revoking asset: %d: %w", o.assetID, err)
+		}
+	}
+
+	err := u.algo.DestroyAsset(ctx, o.assetID)
+	if err != nil {
+		return fmt.Errorf("closeOrphan: error destroying asset: %d: %w", o.assetID, err)
+	}
+
+	return nil
+}
+
+// ReconcileTicket compares the holder of the ticket with the chain. A move of
+// the ticket that has not finished and has not changed for staleAfter is
+// driven forward first. Younger moves may still be running in a request and
+// are only reported.
+func (u *Event) ReconcileTicket(ctx context.Context, eventTicketID int64, staleAfter time.Duration) (*model.TicketReconciliation, error) {
+	mv, ok, err := u.store.Moves.Latest(ctx, eventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcileTicket: %w", err)
+	}
+
+	stale := ok && mv.UpdatedDate != nil && mv.UpdatedDate.Before(time.Now().Add(-staleAfter))
+	if stale && mv.State != MoveApplied && mv.State != MoveFailed {
+		claimed, err := u.claimMove(ctx, mv)
+		if err != nil {
+			return nil, fmt.Errorf("reconcileTicket: %w", err)
+		}
+
+		if claimed {
+			err = u.advanceMove(ctx, mv)
+			if err != nil {
+				return nil, fmt.Errorf("reconcileTicket: %w", err)
+			}
+		}
+	}
+
+	et, found, err := u.store.Tickets.Get(ctx, eventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcileTicket: %w", err)
+	}
+
+	if !found {
+		return nil, response.ResourceNotFound("event ticket not found", fmt.Sprintf("reconcileTicket: event_ticket_id: %d", eventTicketID))
+	}
+
+	holder, found, err := u.holderAccount(et.CurrentHolderID, et.HolderWallet)
+	if err != nil {
+		return nil, fmt.Errorf("reconcileTicket: %w", err)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("reconcileTicket: current_holder_id: %d wallet: %s not found", et.CurrentHolderID, et.HolderWallet)
+	}
+
+	balance, err := u.algo.AssetBalance(ctx, holder.AccountAddress, et.AssetID)
+	if err != nil {
+		return nil, fmt.Errorf("reconcileTicket: %w", err)
+	}
+
+	r := &model.TicketReconciliation{
+		EventTicket:   *et,
+		HolderAddress: holder.AccountAddress,
+		HolderBalance: balance,
+		InSync:        balance > 0,
+	}
+	if ok {
+		r.LatestMove = mv
+	}
+
+	return r, nil
+}
+
+// Custody returns the tickets the user holds according to the database and
+// whether the chain agrees.
+func (u *Event) Custody(ctx context.Context, userID int64) (*model.Custody, error) {
+	ua, ok, err := u.fetchUserAddress(userID)
+	if err != nil {
+		return nil, fmt.Errorf("custody: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("user not found", fmt.Sprintf("custody: user_id: %d", userID))
+	}
+
+	state, err := u.algo.AccountState(ctx, ua.AccountAddress)
+	if err != nil {
+		return nil, fmt.Errorf("custody: %w", err)
+	}
+
+	ets, err := u.store.Tickets.ListByHolder(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("custody: %w", err)
+	}
+
+	c := &model.Custody{
+		UserID:          userID,
+		Address:         state.Address,
+		MicroAlgos:      state.MicroAlgos,
+		UntrackedAssets: make(map[uint64]uint64),
+	}
+
+	tracked := make(map[uint64]bool)
+	for _, et := range ets {
+		tracked[et.AssetID] = true
+		c.Tickets = append(c.Tickets, model.CustodyTicket{
+			EventTicket: et,
+			OnChain:     state.Assets[et.AssetID] > 0,
+		})
+	}
+
+	for assetID, amount := range state.Assets {
+		if amount > 0 && !tracked[assetID] {
+			c.UntrackedAssets[assetID] = amount
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,521 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/notify"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/webhook"
+	"fmt"
+	"time"
+)
+
+// Auction statuses. An auction is SETTLING from the time it is claimed by the
+// sweeper until the sale of its ticket to the winner is applied or fails.
+const (
+	AuctionOpen     = model.AuctionOpen
+	AuctionSettling = model.AuctionSettling
+	AuctionSettled  = model.AuctionSettled
+	AuctionUnsold   = model.AuctionUnsold
+)
+
+// Auction bid statuses.
+const (
+	BidLeading = model.BidLeading
+	BidOutbid  = model.BidOutbid
+	BidWon     = model.BidWon
+	BidLost    = model.BidLost
+)
+
+// auctionSettleWindow is how long the ticket stays reserved after the auction
+// ends, for the sweeper to sell it to the winner. Every sweep that finds the
+// auction still settling holds the reservation for another window.
+const auctionSettleWindow = time.Hour
+
+// CreateAuction puts a ticket of the tier and seat of a published event of the
+// organizer up for auction. The ticket is held with a reservation from now
+// until the auction is settled, so it cannot be sold in the meantime. The
+// reservation is held by the leading bidder as bids come in.
+func (u *Event) CreateAuction(ctx context.Context, a *model.Auction, userID int64) (*model.Auction, error) {
+	pe, ok, err := u.store.Events.Get(ctx, a.PublicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("createAuction: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("public event not found", fmt.Sprintf("createAuction: public_event_id: %d", a.PublicEventID))
+	}
+
+	if pe.BusinessUserID != userID {
+		return nil, response.Forbidden(fmt.Sprintf("createAuction: user: %d does not own public event: %d", userID, a.PublicEventID))
+	}
+
+	if *pe.Status != Published {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("createAuction: public event: %d is %s", a.PublicEventID, *pe.Status))
+	}
+
+	if pe.VenueID > 0 && a.SeatID == 0 {
+		return nil, response.InvalidData(fmt.Sprintf("createAuction: seat_id is required for seated public event: %d", a.PublicEventID))
+	}
+
+	now := time.Now()
+	if a.StartsAt == nil {
+		a.StartsAt = &now
+	}
+
+	err = validateAuction(a, pe.DateTime, now)
+	if err != nil {
+		return nil, response.InvalidData(err.Error())
+	}
+
+	reservationID, err := newReservationID()
+	if err != nil {
+		return nil, fmt.Errorf("createAuction: %w", err)
+	}
+
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		et, ok, err := tx.Tickets.Claim(ctx, a.PublicEventID, a.TicketTierID, a.SeatID, now)
+		if err != nil {
+			return fmt.Errorf("createAuction: error claiming event ticket: %w", err)
+		}
+
+		if !ok {
+			return response.Conflict("no ticket available", fmt.Sprintf("createAuction: public event: %d", a.PublicEventID))
+		}
+
+		expiresAt := a.EndsAt.Add(auctionSettleWindow)
+		err = tx.Reservations.Create(ctx, &model.Reservation{
+			ReservationID: reservationID,
+			EventTicketID: et.EventTicketID,
+			PublicEventID: a.PublicEventID,
+			UserID:        userID,
+			Status:        ReservationActive,
+			ExpiresAt:     &expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("createAuction: error inserting reservation: %w", err)
+		}
+
+		a.EventTicketID = et.EventTicketID
+		a.TicketTierID = et.TicketTierID
+		a.SeatID = et.SeatID
+		a.ReservationID = reservationID
+		a.Status = AuctionOpen
+		a.LeadingBidID = 0
+		a.LeadingAmount = 0
+
+		a.AuctionID, err = tx.Auctions.Create(ctx, a)
+		if err != nil {
+			return fmt.Errorf("createAuction: error inserting auction: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof(ctx, "createAuction: auction: %d of ticket: %d ends at %s", a.AuctionID, a.EventTicketID, a.EndsAt)
+	return a, nil
+}
+
+// validateAuction checks the auction runs within the time left before the
+// event starts at startsAt.
+func validateAuction(a *model.Auction, startsAt *time.Time, now time.Time) error {
+	if a.ReservePrice == 0 || a.MinIncrement == 0 {
+		return fmt.Errorf("validateAuction: reserve_price and min_increment have to be positive")
+	}
+
+	if a.EndsAt == nil || !a.EndsAt.After(now) || !a.EndsAt.After(*a.StartsAt) {
+		return fmt.Errorf("validateAuction: ends_at has to be in the future and after starts_at")
+	}
+
+	if startsAt != nil && a.EndsAt.After(*startsAt) {
+		return fmt.Errorf("validateAuction: ends_at has to be before the event starts")
+	}
+
+	return nil
+}
+
+// minimumBid is the lowest amount a bid on the auction can be placed at.
+func minimumBid(a *model.Auction) uint64 {
+	if a.LeadingBidID == 0 {
+		return a.ReservePrice
+	}
+
+	return a.LeadingAmount + a.MinIncrement
+}
+
+// GetAuction returns the auction with its leading amount. Bidders are not
+// disclosed.
+func (u *Event) GetAuction(ctx context.Context, auctionID int64) (*model.Auction, error) {
+	a, ok, err := u.store.Auctions.Get(ctx, auctionID)
+	if err != nil {
+		return nil, fmt.Errorf("getAuction: error fetching auction: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("auction not found", fmt.Sprintf("getAuction: auction: %d", auctionID))
+	}
+
+	return a, nil
+}
+
+// EventAuctions returns the auctions of the public event, latest first.
+func (u *Event) EventAuctions(ctx context.Context, publicEventID int64) ([]model.Auction, error) {
+	as, err := u.store.Auctions.ListByEvent(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("eventAuctions: error fetching auctions: %w", err)
+	}
+
+	return as, nil
+}
+
+// PlaceBid bids amount on the open auction on behalf of the buyer. The auction
+// row is locked while the bid is placed, so bids are ordered and the leading
+// one always beats the one before by the minimum increment. The outbid buyer,
+// if any, is notified once the bid is placed.
+func (u *Event) PlaceBid(ctx context.Context, auctionID int64, b *model.Buyer, amount int64) (*model.AuctionBid, error) {
+	if amount <= 0 {
+		return nil, response.InvalidData("placeBid: amount has to be positive")
+	}
+
+	var a *model.Auction
+	var pe *model.PublicEvent
+	var outbid *model.AuctionBid
+	now := time.Now()
+	bid := model.AuctionBid{
+		AuctionID: auctionID,
+		Buyer:     *b,
+		Amount:    uint64(amount),
+		Status:    BidLeading,
+		CreatedAt: &now,
+	}
+
+	err := u.store.Tx(ctx, func(tx *store.Store) error {
+		var ok bool
+		var err error
+		a, ok, err = tx.Auctions.Lock(ctx, auctionID)
+		if err != nil {
+			return fmt.Errorf("placeBid: error locking auction: %w", err)
+		}
+
+		if !ok {
+			return response.ResourceNotFound("auction not found", fmt.Sprintf("placeBid: auction: %d", auctionID))
+		}
+
+		if a.Status != AuctionOpen || now.Before(*a.StartsAt) || !now.Before(*a.EndsAt) {
+			return response.InvalidStateTransition(fmt.Sprintf("placeBid: auction: %d is not taking bids", auctionID))
+		}
+
+		if uint64(amount) < minimumBid(a) {
+			return response.InvalidData(fmt.Sprintf("placeBid: amount: %d is below the minimum bid: %d", amount, minimumBid(a)))
+		}
+
+		pe, ok, err = tx.Events.Get(ctx, a.PublicEventID)
+		if err != nil || !ok {
+			return fmt.Errorf("placeBid: error fetching public event: %d: %v", a.PublicEventID, err)
+		}
+
+		if b.UserID == pe.BusinessUserID && b.Wallet == "" {
+			return response.Forbidden(fmt.Sprintf("placeBid: user: %d organizes public event: %d", b.UserID, a.PublicEventID))
+		}
+
+		if a.TicketTierID > 0 {
+			err = checkTierPurchase(ctx, tx, a.PublicEventID, a.TicketTierID, b.UserID, b.Wallet, a.ReservationID)
+			if err != nil {
+				return response.Forbidden(err.Error())
+			}
+		}
+
+		if a.LeadingBidID > 0 {
+			outbid, ok, err = tx.Auctions.GetBid(ctx, a.LeadingBidID)
+			if err != nil || !ok {
+				return fmt.Errorf("placeBid: error fetching bid: %d: %v", a.LeadingBidID, err)
+			}
+
+			err = tx.Auctions.SetBidStatus(ctx, outbid.BidID, BidOutbid, now)
+			if err != nil {
+				return fmt.Errorf("placeBid: error updating outbid bid: %w", err)
+			}
+		}
+
+		bid.BidID, err = tx.Auctions.CreateBid(ctx, &bid)
+		if err != nil {
+			return fmt.Errorf("placeBid: error inserting bid: %w", err)
+		}
+
+		err = tx.Auctions.Lead(ctx, &bid, now)
+		if err != nil {
+			return fmt.Errorf("placeBid: error updating auction: %w", err)
+		}
+
+		err = tx.Reservations.Reassign(ctx, a.ReservationID, b.UserID, b.Wallet, now)
+		if err != nil {
+			return fmt.Errorf("placeBid: error updating reservation: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if outbid != nil && outbid.Buyer != *b {
+		a.LeadingBidID = bid.BidID
+		a.LeadingAmount = bid.Amount
+		u.notifyBuyer(ctx, &outbid.Buyer, notify.TemplateAuctionOutbid, struct {
+			EventTitle string
+			MinimumBid uint64
+		}{eventTitle(pe), minimumBid(a)})
+
+		outbid.Status = BidOutbid
+		data := *outbid
+		data.Wallet = ""
+		u.publish(ctx, webhook.AuctionOutbid, &data, outbid.MarketplaceID)
+	}
+
+	return &bid, nil
+}
+
+// SettleAuctions settles auctions that have ended every interval until ctx is
+// done, see settleAuction.
+func (u *Event) SettleAuctions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			as, err := u.store.Auctions.Due(ctx, time.Now())
+			if err != nil {
+				logger.Errorf(ctx, "settleAuctions: %+v", err)
+				continue
+			}
+
+			for i := range as {
+				err := u.settleAuction(ctx, &as[i])
+				if err != nil {
+					logger.Errorf(ctx, "settleAuctions: auction: %d: %+v", as[i].AuctionID, err)
+				}
+			}
+		}
+	}
+}
+
+// settleAuction sells the ticket of the ended auction to the leading bidder
+// through the reservation it holds, like any other sale by the organizer. The
+// auction is SETTLED once the sale is applied and UNSOLD when it had no bids
+// or the sale failed. Sales still moving are left to RecoverMoves and the
+// auction is looked at again on the next sweep.
+func (u *Event) settleAuction(ctx context.Context, a *model.Auction) error {
+	if a.Status == AuctionOpen {
+		claimed, err := u.store.Auctions.SetStatus(ctx, a.AuctionID, AuctionOpen, AuctionSettling, time.Now())
+		if err != nil {
+			return fmt.Errorf("settleAuction: error updating auction: %w", err)
+		}
+
+		if !claimed {
+			return nil
+		}
+
+		// Bids placed before the auction was claimed are only seen now.
+		claimedAuction, ok, err := u.store.Auctions.Get(ctx, a.AuctionID)
+		if err != nil || !ok {
+			return fmt.Errorf("settleAuction: error fetching auction: %v", err)
+		}
+		*a = *claimedAuction
+	}
+
+	if a.LeadingBidID == 0 {
+		return u.finishAuction(ctx, a, false)
+	}
+
+	// Reservations that already lapsed are left alone, the ticket may have
+	// been reserved by someone else since.
+	now := time.Now()
+	err := u.store.Reservations.Extend(ctx, a.ReservationID, now.Add(auctionSettleWindow), now)
+	if err != nil {
+		return fmt.Errorf("settleAuction: error holding reservation: %w", err)
+	}
+
+	mv, ok, err := u.store.Moves.LatestByReservation(ctx, a.ReservationID)
+	if err != nil {
+		return fmt.Errorf("settleAuction: error fetching ticket move: %w", err)
+	}
+
+	if !ok {
+		mv, err = u.sellAuction(ctx, a)
+		if err != nil {
+			return fmt.Errorf("settleAuction: %w", err)
+		}
+	}
+
+	switch {
+	case mv == nil || mv.State == MoveFailed:
+		return u.finishAuction(ctx, a, false)
+	case mv.State == MoveApplied:
+		return u.finishAuction(ctx, a, true)
+	default:
+		return nil
+	}
+}
+
+// sellAuction prices the ticket at the winning bid and moves it to the winner.
+// It returns nil when the ticket is no longer held by the organizer.
+func (u *Event) sellAuction(ctx context.Context, a *model.Auction) (*model.TicketMove, error) {
+	bid, ok, err := u.store.Auctions.GetBid(ctx, a.LeadingBidID)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("sellAuction: error fetching bid: %d: %v", a.LeadingBidID, err)
+	}
+
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, a.EventTicketID)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("sellAuction: error fetching event ticket: %d: %v", a.EventTicketID, err)
+	}
+
+	ok, err = u.store.Tickets.SetOrganizerPrice(ctx, eventTicket, bid.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("sellAuction: error updating event ticket: %w", err)
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	mv := model.TicketMove{
+		Kind:            moveBuy,
+		EventTicketID:   eventTicket.EventTicketID,
+		AssetID:         eventTicket.AssetID,
+		FromUserID:      eventTicket.BusinessUserID,
+		ToUserID:        bid.UserID,
+		ToWallet:        bid.Wallet,
+		ToMarketplaceID: bid.MarketplaceID,
+		ExpectedStatus:  active,
+		NewStatus:       active,
+		ReservationID:   &a.ReservationID,
+	}
+	err = u.moveTicket(ctx, &mv)
+	if err != nil && mv.MoveID == 0 {
+		return nil, fmt.Errorf("sellAuction: %w", err)
+	}
+
+	return &mv, nil
+}
+
+// finishAuction closes the settling auction as SETTLED when sold and UNSOLD
+// otherwise, settles its bids and notifies the bidders. Unsold tickets get
+// their reservation released and their face value back.
+func (u *Event) finishAuction(ctx context.Context, a *model.Auction, sold bool) error {
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, a.EventTicketID)
+	if err != nil || !ok {
+		return fmt.Errorf("finishAuction: error fetching event ticket: %d: %v", a.EventTicketID, err)
+	}
+
+	var face uint64
+	if !sold {
+		face, ok, err = u.store.Tickets.FaceValue(ctx, a.EventTicketID)
+		if err != nil || !ok {
+			return fmt.Errorf("finishAuction: error fetching face value: %d: %v", a.EventTicketID, err)
+		}
+	}
+
+	status := AuctionUnsold
+	if sold {
+		status = AuctionSettled
+	}
+
+	now := time.Now()
+	var finished bool
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		var err error
+		finished, err = tx.Auctions.SetStatus(ctx, a.AuctionID, AuctionSettling, status, now)
+		if err != nil {
+			return fmt.Errorf("finishAuction: error updating auction: %w", err)
+		}
+
+		if !finished {
+			return nil
+		}
+
+		err = tx.Auctions.SetBidsStatus(ctx, a.AuctionID, BidLost, now)
+		if err != nil {
+			return fmt.Errorf("finishAuction: error updating bids: %w", err)
+		}
+
+		if sold {
+			err = tx.Auctions.SetBidStatus(ctx, a.LeadingBidID, BidWon, now)
+			if err != nil {
+				return fmt.Errorf("finishAuction: error updating winning bid: %w", err)
+			}
+			return nil
+		}
+
+		_, err = tx.Reservations.SetStatus(ctx, a.ReservationID, ReservationActive, ReservationReleased, now)
+		if err != nil {
+			return fmt.Errorf("finishAuction: error releasing reservation: %w", err)
+		}
+
+		_, err = tx.Tickets.SetOrganizerPrice(ctx, eventTicket, face)
+		if err != nil {
+			return fmt.Errorf("finishAuction: error updating event ticket: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil || !finished {
+		return err
+	}
+
+	a.Status = status
+	a.UpdatedAt = &now
+	logger.Infof(ctx, "finishAuction: auction: %d is %s", a.AuctionID, status)
+
+	u.notifyBidders(ctx, a, sold)
+	u.publish(ctx, webhook.AuctionEnded, a)
+	return nil
+}
+
+// notifyBidders tells every bidder of the finished auction whether it won.
+func (u *Event) notifyBidders(ctx context.Context, a *model.Auction, sold bool) {
+	if u.notifier == nil {
+		return
+	}
+
+	bs, err := u.store.Auctions.Bids(ctx, a.AuctionID)
+	if err != nil {
+		logger.Errorf(ctx, "notifyBidders: auction: %d: %+v", a.AuctionID, err)
+		return
+	}
+
+	pe, ok, err := u.store.Events.Get(ctx, a.PublicEventID)
+	if err != nil || !ok {
+		logger.Errorf(ctx, "notifyBidders: public event: %d not found: %v", a.PublicEventID, err)
+		return
+	}
+
+	seen := make(map[model.Buyer]bool)
+	for i := range bs {
+		if seen[bs[i].Buyer] {
+			continue
+		}
+		seen[bs[i].Buyer] = true
+
+		u.notifyBuyer(ctx, &bs[i].Buyer, notify.TemplateAuctionEnded, struct {
+			EventTitle string
+			Amount     uint64
+			Won        bool
+		}{eventTitle(pe), a.LeadingAmount, sold && bs[i].BidID == a.LeadingBidID})
+	}
+}
+
+func eventTitle(pe *model.PublicEvent) string {
+	if pe.EventTitle == nil {
+		return ""
+	}
+	return *pe.EventTitle
+}
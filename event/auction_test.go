@@ -0,0 +1,31 @@
+package event
+
+import (
+	"eventers-marketplace-backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinimumBid(t *testing.T) {
+	a := &model.Auction{ReservePrice: 500, MinIncrement: 25}
+	assert.Equal(t, uint64(500), minimumBid(a))
+
+	a.LeadingBidID = 3
+	a.LeadingAmount = 540
+	assert.Equal(t, uint64(565), minimumBid(a))
+}
+
+func TestValidateAuction(t *testing.T) {
+	now := time.Date(2026, 10, 1, 18, 0, 0, 0, time.UTC)
+	startsAt := now.Add(24 * time.Hour)
+	endsAt := now.Add(time.Hour)
+
+	assert.Nil(t, validateAuction(&model.Auction{ReservePrice: 500, MinIncrement: 25, StartsAt: &now, EndsAt: &endsAt}, &startsAt, now))
+	assert.NotNil(t, validateAuction(&model.Auction{ReservePrice: 500, StartsAt: &now, EndsAt: &endsAt}, &startsAt, now))
+	assert.NotNil(t, validateAuction(&model.Auction{ReservePrice: 500, MinIncrement: 25, StartsAt: &now, EndsAt: &now}, &startsAt, now))
+
+	late := startsAt.Add(time.Minute)
+	assert.NotNil(t, validateAuction(&model.Auction{ReservePrice: 500, MinIncrement: 25, StartsAt: &now, EndsAt: &late}, &startsAt, now))
+}
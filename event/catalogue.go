@@ -0,0 +1,170 @@
+package event
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+)
+
+// Catalogue sort orders. A leading minus sorts descending.
+const (
+	SortDate      = "date"
+	SortDateDesc  = "-date"
+	SortPrice     = "price"
+	SortPriceDesc = "-price"
+)
+
+// catalogueSorts maps a sort order to whether it sorts on the lowest ticket
+// price rather than the date and whether it is descending. Ties are broken on
+// public_event_id.
+var catalogueSorts = map[string]struct {
+	byPrice bool
+	desc    bool
+}{
+	SortDate:      {false, false},
+	SortDateDesc:  {false, true},
+	SortPrice:     {true, false},
+	SortPriceDesc: {true, true},
+}
+
+// catalogueCursor is where a page of the catalogue ends: the sort value and id
+// of its last event.
+type catalogueCursor struct {
+	Sort  string     `json:"s"`
+	Date  *time.Time `json:"d,omitempty"`
+	Price uint64     `json:"p,omitempty"`
+	ID    int64      `json:"id"`
+}
+
+// GetCatalogue returns a page of the published events matching the filter,
+// each with its tier availability and the tickets on resale. FromPrice is the
+// lowest ticket price of the event and AvailableTickets the tickets the
+// organizer still holds. Tiers and resale tickets of the whole page are each
+// read in a single query.
+func (u *Event) GetCatalogue(ctx context.Context, f *model.PublicEventFilter) (*model.PublicEventPage, error) {
+	if f.Sort == "" {
+		f.Sort = SortDate
+	}
+
+	sort, ok := catalogueSorts[f.Sort]
+	if !ok {
+		return nil, response.InvalidData(fmt.Sprintf("getCatalogue: unknown sort: %s", f.Sort))
+	}
+
+	q := &store.CatalogueQuery{Filter: f, ByPrice: sort.byPrice, Desc: sort.desc, Limit: f.Limit + 1}
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, response.InvalidData(err.Error())
+		}
+
+		q.After = &model.PublicEvent{PublicEventID: c.ID, DateTime: c.Date, FromPrice: c.Price}
+	}
+
+	pes, total, err := u.store.Events.Catalogue(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("getCatalogue: %w", err)
+	}
+
+	page := model.PublicEventPage{TotalCount: total, PublicEvents: []model.PublicEvents{}}
+	if len(pes) > f.Limit {
+		pes = pes[:f.Limit]
+		page.NextCursor = encodeCursor(f.Sort, &pes[len(pes)-1])
+	}
+
+	if len(pes) == 0 {
+		return &page, nil
+	}
+
+	tiers, err := u.tierAvailability(ctx, pes)
+	if err != nil {
+		return nil, fmt.Errorf("getCatalogue: error getting ticket tiers: %w", err)
+	}
+
+	ids := make([]int64, len(pes))
+	for i := range pes {
+		ids[i] = pes[i].PublicEventID
+	}
+
+	ets, err := u.store.Tickets.ListResale(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("getCatalogue: %w", err)
+	}
+
+	resale := make(map[int64][]model.EventTicket)
+	for _, et := range ets {
+		resale[et.PublicEventID] = append(resale[et.PublicEventID], listedTicket(&et))
+	}
+
+	for i := range pes {
+		pes[i].TicketTiers = tiers[pes[i].PublicEventID]
+		page.PublicEvents = append(page.PublicEvents, model.PublicEvents{
+			PublicEvent: pes[i],
+			EventTicket: resale[pes[i].PublicEventID],
+		})
+	}
+
+	return &page, nil
+}
+
+// listedTicket returns the fields of the ticket the catalogue shows, leaving
+// out its holder.
+func listedTicket(et *model.EventTicket) model.EventTicket {
+	return model.EventTicket{
+		EventTicketID: et.EventTicketID,
+		PublicEventID: et.PublicEventID,
+		Status:        et.Status,
+		Price:         et.Price,
+	}
+}
+
+func encodeCursor(sort string, pe *model.PublicEvent) string {
+	c := catalogueCursor{Sort: sort, ID: pe.PublicEventID}
+	switch {
+	case catalogueSorts[sort].byPrice:
+		c.Price = pe.FromPrice
+	case pe.DateTime == nil:
+		c.Date = &store.Undated
+	default:
+		c.Date = pe.DateTime
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor issued for the same sort order.
+func decodeCursor(cursor, sort string) (*catalogueCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("decodeCursor: invalid cursor: %s", cursor)
+	}
+
+	var c catalogueCursor
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.ID == 0 {
+		return nil, fmt.Errorf("decodeCursor: invalid cursor: %s", cursor)
+	}
+
+	if c.Sort != sort {
+		return nil, fmt.Errorf("decodeCursor: cursor was issued for sort: %s", c.Sort)
+	}
+
+	if !catalogueSorts[sort].byPrice && c.Date == nil {
+		return nil, fmt.Errorf("decodeCursor: invalid cursor: %s", cursor)
+	}
+
+	return &c, nil
+}
+
+func cursorValue(c *catalogueCursor) interface{} {
+	if catalogueSorts[c.Sort].byPrice {
+		return c.Price
+	}
+	return c.Date
+}
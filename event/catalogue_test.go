@@ -0,0 +1,35 @@
+package event
+
+import (
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogueCursor(t *testing.T) {
+	startsAt := time.Date(2026, 11, 20, 19, 30, 0, 0, time.UTC)
+	pe := &model.PublicEvent{PublicEventID: 42, DateTime: &startsAt, FromPrice: 1500}
+
+	c, err := decodeCursor(encodeCursor(SortDateDesc, pe), SortDateDesc)
+	require.Nil(t, err)
+	assert.Equal(t, int64(42), c.ID)
+	assert.True(t, startsAt.Equal(cursorValue(c).(*time.Time).UTC()))
+
+	c, err = decodeCursor(encodeCursor(SortPrice, pe), SortPrice)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1500), cursorValue(c))
+
+	_, err = decodeCursor(encodeCursor(SortPrice, pe), SortDate)
+	assert.NotNil(t, err)
+
+	c, err = decodeCursor(encodeCursor(SortDate, &model.PublicEvent{PublicEventID: 43}), SortDate)
+	require.Nil(t, err)
+	assert.True(t, store.Undated.Equal(cursorValue(c).(*time.Time).UTC()))
+
+	_, err = decodeCursor("not a cursor", SortDate)
+	assert.NotNil(t, err)
+}
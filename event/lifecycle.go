@@ -0,0 +1,340 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/webhook"
+	"fmt"
+)
+
+// Public event statuses.
+const (
+	Draft       = model.EventDraft
+	Published   = model.EventPublished
+	SalesClosed = model.EventSalesClosed
+	Cancelled   = model.EventCancelled
+	Completed   = model.EventCompleted
+)
+
+var cancelled = model.TicketCancelled
+
+// transitions lists the statuses a public event may move to from a given status.
+var transitions = map[string][]string{
+	Draft:       {Published, Cancelled},
+	Published:   {SalesClosed, Cancelled, Completed},
+	SalesClosed: {Published, Cancelled, Completed},
+}
+
+func canTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// EditPublicEvent updates the details and the resale policy of a public event
+// and, when a status is passed, moves the event to that status. Publishing starts minting the tickets
+// and cancelling starts the refund and clawback of the tickets already sold.
+func (u *Event) EditPublicEvent(ctx context.Context, pe *model.PublicEvent, userID int64) (*model.PublicEvent, error) {
+	existing, ok, err := u.store.Events.Get(ctx, pe.PublicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("editPublicEvent: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("public event not found", fmt.Sprintf("editPublicEvent: public_event_id: %d", pe.PublicEventID))
+	}
+
+	if existing.BusinessUserID != userID {
+		return nil, response.Forbidden(fmt.Sprintf("editPublicEvent: user: %d does not own public event: %d", userID, pe.PublicEventID))
+	}
+
+	from := *existing.Status
+	to := from
+	if pe.Status != nil && *pe.Status != from {
+		to = *pe.Status
+		if !canTransition(from, to) {
+			return nil, response.InvalidStateTransition(fmt.Sprintf("editPublicEvent: %s to %s", from, to))
+		}
+	}
+
+	if from == Cancelled || from == Completed {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("editPublicEvent: public event is %s", from))
+	}
+
+	if len(pe.TicketTiers) > 0 {
+		if from != Draft {
+			return nil, response.InvalidData("editPublicEvent: tiers can only be changed on a draft event")
+		}
+
+		// Seats are minted by tier name, tiers of a seated event follow its
+		// seat map instead.
+		if existing.VenueID > 0 {
+			return nil, response.InvalidData("editPublicEvent: tiers of an event with a seat map cannot be changed")
+		}
+
+		err = normalizeTiers(pe)
+		if err != nil {
+			return nil, response.InvalidData(err.Error())
+		}
+	} else if pe.TotalTickets > 0 || pe.TicketPrice > 0 {
+		tts, err := u.store.Tiers.List(ctx, pe.PublicEventID)
+		if err != nil {
+			return nil, fmt.Errorf("editPublicEvent: error fetching ticket tiers: %w", err)
+		}
+
+		// Tickets are minted from the tiers, the ticket count and price of
+		// the event only follow them.
+		if len(tts) > 0 {
+			return nil, response.InvalidData("editPublicEvent: total_tickets and ticket_price follow the ticket tiers, edit ticket_tiers instead")
+		}
+	}
+
+	if pe.ResalePolicy != nil {
+		err = validateResalePolicy(pe.ResalePolicy)
+		if err != nil {
+			return nil, response.InvalidData(err.Error())
+		}
+	}
+
+	err = checkTicketEdit(pe, from)
+	if err != nil {
+		return nil, response.InvalidData(err.Error())
+	}
+
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		err := tx.Events.Update(ctx, pe, from, to)
+		if errors.Is(err, store.ErrNotFound) {
+			return response.InvalidStateTransition(fmt.Sprintf("editPublicEvent: public event: %d changed concurrently", pe.PublicEventID))
+		}
+
+		if err != nil {
+			return fmt.Errorf("editPublicEvent: error updating public event: %w", err)
+		}
+
+		if len(pe.TicketTiers) > 0 {
+			err = tx.Tiers.Replace(ctx, pe.PublicEventID, pe.TicketTiers)
+			if err != nil {
+				return fmt.Errorf("editPublicEvent: error replacing ticket tiers: %w", err)
+			}
+		}
+
+		if pe.ResalePolicy != nil {
+			err = tx.ResalePolicies.Save(ctx, pe.PublicEventID, pe.ResalePolicy)
+			if err != nil {
+				return fmt.Errorf("editPublicEvent: error saving resale policy: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated, _, err := u.store.Events.Get(ctx, pe.PublicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("editPublicEvent: error fetching updated public event: %w", err)
+	}
+
+	updated.TicketTiers, err = u.store.Tiers.List(ctx, pe.PublicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("editPublicEvent: error fetching ticket tiers: %w", err)
+	}
+
+	updated.ResalePolicy, _, err = u.store.ResalePolicies.Get(ctx, pe.PublicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("editPublicEvent: error fetching resale policy: %w", err)
+	}
+
+	if from == to {
+		return updated, nil
+	}
+
+	switch {
+	case from == Draft && to == Published:
+		a, err := u.fetchTempAccount(updated.PublicEventID)
+		if err != nil {
+			return nil, fmt.Errorf("editPublicEvent: error fetching temp account: %w", err)
+		}
+		u.publish(ctx, webhook.EventPublished, updated)
+		go u.processEvent(context.Background(), a, updated, updated.BusinessUserID)
+	case to == Cancelled:
+		u.publish(ctx, webhook.EventCancelled, updated)
+		go u.cancelEvent(context.Background(), updated)
+	}
+
+	return updated, nil
+}
+
+// DeletePublicEvent removes a public event that is still a draft. Events that
+// have been published have to be cancelled instead.
+func (u *Event) DeletePublicEvent(ctx context.Context, publicEventID, userID int64) error {
+	existing, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return fmt.Errorf("deletePublicEvent: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return response.ResourceNotFound("public event not found", fmt.Sprintf("deletePublicEvent: public_event_id: %d", publicEventID))
+	}
+
+	if existing.BusinessUserID != userID {
+		return response.Forbidden(fmt.Sprintf("deletePublicEvent: user: %d does not own public event: %d", userID, publicEventID))
+	}
+
+	if *existing.Status != Draft {
+		return response.InvalidStateTransition(fmt.Sprintf("deletePublicEvent: public event is %s, cancel it instead", *existing.Status))
+	}
+
+	err = u.store.Events.Delete(ctx, publicEventID, Draft)
+	if errors.Is(err, store.ErrNotFound) {
+		return response.InvalidStateTransition(fmt.Sprintf("deletePublicEvent: public event: %d changed concurrently", publicEventID))
+	}
+
+	if err != nil {
+		return fmt.Errorf("deletePublicEvent: error deleting public event: %w", err)
+	}
+
+	_, err = u.vault.Logical().Delete(fmt.Sprintf("%s/%v", u.vault.TempPath, publicEventID))
+	if err != nil {
+		logger.Errorf(ctx, "deletePublicEvent: unable to delete temp account from vault: %+v", err)
+	}
+
+	return nil
+}
+
+// cancelEvent records a refund for every ticket sold to a buyer, claws the
+// asset back to the organizer and marks all the tickets of the event cancelled.
+func (u *Event) cancelEvent(ctx context.Context, pe *model.PublicEvent) {
+	ets, err := u.store.Tickets.ListByEvent(ctx, pe.PublicEventID)
+	if err != nil {
+		logger.Errorf(ctx, "cancelEvent: error fetching event tickets: %d: err: %+v", pe.PublicEventID, err)
+		return
+	}
+
+	organizer, ok, err := u.fetchUserAddress(pe.BusinessUserID)
+	if err != nil || !ok {
+		logger.Errorf(ctx, "cancelEvent: could not fetch organizer address: %d: err: %+v", pe.BusinessUserID, err)
+		return
+	}
+
+	for i := range ets {
+		err := u.cancelTicket(ctx, &ets[i], organizer)
+		if err != nil {
+			logger.Errorf(ctx, "cancelEvent: could not cancel ticket: %d: err: %+v", ets[i].EventTicketID, err)
+		}
+	}
+}
+
+func (u *Event) cancelTicket(ctx context.Context, et *model.EventTicket, organizer *algorand.Account) error {
+	if et.Status != nil && (*et.Status == cancelled || *et.Status == model.TicketRedeemed) {
+		return nil
+	}
+
+	sold := et.CurrentHolderID != et.BusinessUserID || et.HolderWallet != ""
+	if sold {
+		holder, ok, err := u.holderAccount(et.CurrentHolderID, et.HolderWallet)
+		if err != nil {
+			return fmt.Errorf("cancelTicket: error fetching holder address: %w", err)
+		}
+
+		if !ok {
+			return fmt.Errorf("cancelTicket: holder: %d wallet: %s not found", et.CurrentHolderID, et.HolderWallet)
+		}
+
+		err = u.algo.RevokeAsset(ctx, holder, organizer, et.AssetID)
+		if err != nil {
+			return fmt.Errorf("cancelTicket: error revoking asset: %d: %w", et.AssetID, err)
+		}
+	}
+
+	return u.store.Tx(ctx, func(tx *store.Store) error {
+		if sold {
+			_, err := tx.Refunds.Create(ctx, &model.TicketRefund{
+				EventTicketID: et.EventTicketID,
+				PublicEventID: et.PublicEventID,
+				UserID:        et.CurrentHolderID,
+				Wallet:        et.HolderWallet,
+				Amount:        et.Price,
+				Status:        "PENDING",
+			})
+			if err != nil {
+				return fmt.Errorf("cancelTicket: error recording refund: %w", err)
+			}
+		}
+
+		err := tx.Tickets.Cancel(ctx, et)
+		if err != nil {
+			return fmt.Errorf("cancelTicket: error updating event ticket: %w", err)
+		}
+
+		err = closeListing(ctx, tx, et.EventTicketID, ListingCancelled)
+		if err != nil {
+			return fmt.Errorf("cancelTicket: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// ensurePublished returns an error unless tickets of the public event can be sold.
+func (u *Event) ensurePublished(ctx context.Context, publicEventID int64) (*model.PublicEvent, error) {
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("ensurePublished: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("ensurePublished: public event: %d not found", publicEventID)
+	}
+
+	if *pe.Status != Published {
+		return nil, fmt.Errorf("ensurePublished: public event: %d is %s", publicEventID, *pe.Status)
+	}
+
+	return pe, nil
+}
+
+func (u *Event) fetchTempAccount(publicEventID int64) (*algorand.Account, error) {
+	path := fmt.Sprintf("%s/%v", u.vault.TempPath, publicEventID)
+	secret, err := u.vault.Logical().Read(path)
+	if err != nil {
+		return nil, fmt.Errorf("fetchTempAccount: could not read temp account of event: %d: %w", publicEventID, err)
+	}
+
+	if secret == nil {
+		return nil, fmt.Errorf("fetchTempAccount: temp account of event: %d not found", publicEventID)
+	}
+
+	accountAddress, accountAddressOK := secret.Data[constants.AccountAddress].(string)
+	privateKey, privateKeyOK := secret.Data[constants.PrivateKey].(string)
+	securityPassphrase, securityPassphraseOK := secret.Data[constants.SecurityPassphrase].(string)
+	if !accountAddressOK || !privateKeyOK || !securityPassphraseOK {
+		return nil, fmt.Errorf("fetchTempAccount: incomplete temp account of event: %d", publicEventID)
+	}
+
+	return &algorand.Account{
+		AccountAddress:     accountAddress,
+		PrivateKey:         privateKey,
+		SecurityPassphrase: securityPassphrase,
+	}, nil
+}
+
+// checkTicketEdit returns an error when the ticket count or price of pe is
+// set on an event that is no longer a draft, as they drive minting.
+func checkTicketEdit(pe *model.PublicEvent, status string) error {
+	if (pe.TotalTickets > 0 || pe.TicketPrice > 0) && status != Draft {
+		return fmt.Errorf("checkTicketEdit: tickets can only be changed on a draft event")
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/vault"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditPublicEventKeepsSeatedTiers(t *testing.T) {
+	mem := store.NewMemory()
+	draft := Draft
+	mem.PutPublicEvent(model.PublicEvent{PublicEventID: 7, BusinessUserID: 3, Status: &draft, VenueID: 11})
+	u := NewEvent(nil, vault.Vault{}, mem.Store(), nil, nil)
+
+	vip := "VIP"
+	_, err := u.EditPublicEvent(context.Background(), &model.PublicEvent{
+		PublicEventID: 7,
+		TicketTiers:   []model.TicketTier{{TierName: &vip, TotalTickets: 10, TicketPrice: 100}},
+	}, 3)
+	assert.IsType(t, response.ErrorResponse{}, err)
+	assert.Equal(t, "INVALID_DATA", err.(response.ErrorResponse).Status)
+}
@@ -0,0 +1,338 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/webhook"
+	"fmt"
+	"time"
+)
+
+// Resale listing statuses.
+const (
+	ListingOpen      = model.ListingOpen
+	ListingAccepted  = model.ListingAccepted
+	ListingSold      = model.ListingSold
+	ListingCancelled = model.ListingCancelled
+	ListingExpired   = model.ListingExpired
+)
+
+// listTicket puts the active ticket up for resale at price. The listing ends
+// at expiresAt when passed and after ttl otherwise, but never after resale of
+// the event closes.
+func (u *Event) listTicket(ctx context.Context, et *model.EventTicket, price uint64, expiresAt *time.Time, ttl time.Duration) (*model.ResaleListing, error) {
+	err := u.checkResale(ctx, et, price)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := u.listingExpiry(ctx, et.PublicEventID, expiresAt, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	l := model.ResaleListing{
+		EventTicketID: et.EventTicketID,
+		PublicEventID: et.PublicEventID,
+		SellerUserID:  et.CurrentHolderID,
+		SellerWallet:  et.HolderWallet,
+		AskPrice:      price,
+		Status:        ListingOpen,
+		ExpiresAt:     &expiry,
+	}
+
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		ok, err := tx.Tickets.PutOnResale(ctx, et, price)
+		if err != nil {
+			return fmt.Errorf("listTicket: error updating event ticket: %w", err)
+		}
+
+		if !ok {
+			return response.Conflict("ticket cannot be resold", fmt.Sprintf("listTicket: event_ticket_id: %d is not %s", et.EventTicketID, active))
+		}
+
+		l.ListingID, err = tx.Listings.Create(ctx, &l)
+		if err != nil {
+			return fmt.Errorf("listTicket: error inserting resale listing: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.publishTicket(ctx, webhook.TicketListed, et.EventTicketID)
+	return &l, nil
+}
+
+// listingExpiry returns when a listing made now ends.
+func (u *Event) listingExpiry(ctx context.Context, publicEventID int64, requested *time.Time, ttl time.Duration) (time.Time, error) {
+	now := time.Now()
+	expiry := now.Add(ttl)
+	if requested != nil {
+		if !requested.After(now) {
+			return time.Time{}, response.InvalidData("listingExpiry: listing_expires_at has to be in the future")
+		}
+		expiry = *requested
+	}
+
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("listingExpiry: error fetching public event: %w", err)
+	}
+
+	if !ok || pe.DateTime == nil {
+		return expiry, nil
+	}
+
+	p, _, err := u.store.ResalePolicies.Get(ctx, publicEventID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("listingExpiry: error fetching resale policy: %w", err)
+	}
+
+	closes := *pe.DateTime
+	if p != nil {
+		closes = closes.Add(-time.Duration(p.CutoffMinutes) * time.Minute)
+	}
+
+	if closes.Before(expiry) {
+		expiry = closes
+	}
+
+	return expiry, nil
+}
+
+// RepriceListing changes the ask price of an open listing of the seller, the
+// user or the marketplace wallet.
+func (u *Event) RepriceListing(ctx context.Context, listingID, userID int64, wallet string, price int64) (*model.ResaleListing, error) {
+	if price <= 0 {
+		return nil, response.InvalidData("repriceListing: ask_price has to be positive")
+	}
+
+	l, err := u.sellerListing(ctx, listingID, userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	et, ok, err := u.store.Tickets.Get(ctx, l.EventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("repriceListing: error fetching event ticket: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("repriceListing: event ticket: %d not found", l.EventTicketID)
+	}
+
+	err = u.checkResale(ctx, et, uint64(price))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		ok, err := tx.Listings.SetPrice(ctx, listingID, uint64(price), now)
+		if err != nil {
+			return fmt.Errorf("repriceListing: error updating resale listing: %w", err)
+		}
+
+		if !ok {
+			return response.InvalidStateTransition(fmt.Sprintf("repriceListing: listing: %d is no longer %s", listingID, ListingOpen))
+		}
+
+		ok, err = tx.Tickets.SetResalePrice(ctx, l.EventTicketID, uint64(price))
+		if err != nil {
+			return fmt.Errorf("repriceListing: error updating event ticket: %w", err)
+		}
+
+		if !ok {
+			return response.Conflict("ticket is no longer listed", fmt.Sprintf("repriceListing: event_ticket_id: %d is not %s", l.EventTicketID, resale))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l.AskPrice = uint64(price)
+	l.UpdatedAt = &now
+	u.publishTicket(ctx, webhook.TicketListed, l.EventTicketID)
+	return l, nil
+}
+
+// CancelListing withdraws an open listing of the seller, the user or the
+// marketplace wallet, and makes the ticket active again.
+func (u *Event) CancelListing(ctx context.Context, listingID, userID int64, wallet string) (*model.ResaleListing, error) {
+	l, err := u.sellerListing(ctx, listingID, userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.endListing(ctx, l, ListingCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// ExpireListings ends open listings past their expiry every interval until
+// ctx is done and makes their tickets active again. Accepted listings whose
+// sale failed, or never started, are put back on sale first.
+func (u *Event) ExpireListings(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.reopenFailedListings(ctx, interval)
+
+			ls, err := u.store.Listings.Expired(ctx, time.Now())
+			if err != nil {
+				logger.Errorf(ctx, "expireListings: %+v", err)
+				continue
+			}
+
+			var n int
+			for i := range ls {
+				err := u.endListing(ctx, &ls[i], ListingExpired)
+				if err != nil {
+					logger.Errorf(ctx, "expireListings: listing: %d: %+v", ls[i].ListingID, err)
+					continue
+				}
+				n++
+			}
+
+			if n > 0 {
+				logger.Infof(ctx, "expireListings: expired %d listings", n)
+			}
+		}
+	}
+}
+
+// reopenFailedListings reopens the listings accepted at least interval ago
+// whose sale to the offer failed or was never recorded. Sales still moving are
+// left to RecoverMoves.
+func (u *Event) reopenFailedListings(ctx context.Context, interval time.Duration) {
+	ls, err := u.store.Listings.Accepted(ctx, time.Now().Add(-interval))
+	if err != nil {
+		logger.Errorf(ctx, "reopenFailedListings: %+v", err)
+		return
+	}
+
+	for i := range ls {
+		var since time.Time
+		if ls[i].UpdatedAt != nil {
+			since = *ls[i].UpdatedAt
+		}
+
+		mv, ok, err := u.store.Moves.LatestOfferSale(ctx, ls[i].EventTicketID, since)
+		if err != nil {
+			logger.Errorf(ctx, "reopenFailedListings: listing: %d: %+v", ls[i].ListingID, err)
+			continue
+		}
+
+		if ok && mv.State != MoveFailed {
+			continue
+		}
+
+		err = u.reopenListing(ctx, ls[i].ListingID)
+		if err != nil {
+			logger.Errorf(ctx, "reopenFailedListings: listing: %d: %+v", ls[i].ListingID, err)
+			continue
+		}
+		logger.Infof(ctx, "reopenFailedListings: listing: %d is %s again", ls[i].ListingID, ListingOpen)
+	}
+}
+
+// endListing moves the open listing to status, expires its pending offers and
+// restores its ticket to ACTIVE, unless the ticket left RESELL in the meantime.
+func (u *Event) endListing(ctx context.Context, l *model.ResaleListing, status string) error {
+	now := time.Now()
+	err := u.store.Tx(ctx, func(tx *store.Store) error {
+		ok, err := tx.Listings.SetStatus(ctx, l.ListingID, ListingOpen, status, now)
+		if err != nil {
+			return fmt.Errorf("endListing: error updating resale listing: %w", err)
+		}
+
+		if !ok {
+			return response.InvalidStateTransition(fmt.Sprintf("endListing: listing: %d is no longer %s", l.ListingID, ListingOpen))
+		}
+
+		err = tx.Tickets.TakeOffResale(ctx, l.EventTicketID)
+		if err != nil {
+			return fmt.Errorf("endListing: error updating event ticket: %w", err)
+		}
+
+		err = tx.Offers.Expire(ctx, l.EventTicketID, now)
+		if err != nil {
+			return fmt.Errorf("endListing: error expiring offers: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.Status = status
+	l.UpdatedAt = &now
+	return nil
+}
+
+// closeListing ends the open or accepted listing of the ticket, if any, and
+// its pending offers as part of tx.
+func closeListing(ctx context.Context, tx *store.Store, eventTicketID int64, status string) error {
+	now := time.Now()
+	err := tx.Listings.Close(ctx, eventTicketID, status, now)
+	if err != nil {
+		return fmt.Errorf("closeListing: error updating resale listing: %w", err)
+	}
+
+	err = tx.Offers.Expire(ctx, eventTicketID, now)
+	if err != nil {
+		return fmt.Errorf("closeListing: error expiring offers: %w", err)
+	}
+
+	return nil
+}
+
+// openListing returns the open listing of the ticket when it has not expired.
+func (u *Event) openListing(ctx context.Context, eventTicketID int64) (*model.ResaleListing, error) {
+	l, ok, err := u.store.Listings.Open(ctx, eventTicketID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("openListing: error fetching resale listing: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("ticket for resale not found", fmt.Sprintf("openListing: event_ticket_id: %d has no open listing", eventTicketID))
+	}
+
+	return l, nil
+}
+
+// sellerListing returns the open listing when it belongs to the user and
+// wallet. App users list with an empty wallet, marketplace wallets with no user.
+func (u *Event) sellerListing(ctx context.Context, listingID, userID int64, wallet string) (*model.ResaleListing, error) {
+	l, ok, err := u.store.Listings.Get(ctx, listingID)
+	if err != nil {
+		return nil, fmt.Errorf("sellerListing: error fetching resale listing: %w", err)
+	}
+
+	if !ok || l.SellerUserID != userID || l.SellerWallet != wallet {
+		return nil, response.ResourceNotFound("resale listing not found", fmt.Sprintf("sellerListing: listing: %d", listingID))
+	}
+
+	if l.Status != ListingOpen {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("sellerListing: listing: %d is %s", listingID, l.Status))
+	}
+
+	return l, nil
+}
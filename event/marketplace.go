@@ -0,0 +1,302 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// MarketplaceEvents returns the published events along with the tickets still
+// available in each of their tiers.
+func (u *Event) MarketplaceEvents(ctx context.Context) ([]model.PublicEvent, error) {
+	pes, err := u.store.Events.List(ctx, Published)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaceEvents: %w", err)
+	}
+
+	tiers, err := u.tierAvailability(ctx, pes)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaceEvents: %w", err)
+	}
+
+	for i := range pes {
+		pes[i].TicketTiers = tiers[pes[i].PublicEventID]
+	}
+
+	return pes, nil
+}
+
+// WalletTickets returns the tickets held by the marketplace wallet. Tickets
+// bought through a marketplace are held by the wallet of its user, see
+// marketplace.WalletPath, rather than by a user id. A userID, see
+// marketplace.LinkedUser, adds the tickets the user holds in the app.
+func (u *Event) WalletTickets(ctx context.Context, wallet string, userID int64) ([]model.EventTicket, error) {
+	ets, err := u.store.Tickets.ListByWallet(ctx, wallet, userID)
+	if err != nil {
+		return nil, fmt.Errorf("walletTickets: %w", err)
+	}
+
+	return ets, nil
+}
+
+// BuyForWallet buys a ticket into the wallet of a user of the marketplace m.
+// Naming an event ticket buys it from its reseller, otherwise a ticket of the
+// event and tier is reserved for ttl and bought from the organizer.
+func (u *Event) BuyForWallet(ctx context.Context, client *redis.Client, m *model.Marketplace, wallet string, t *model.Ticket, ttl time.Duration) (*model.EventTicket, error) {
+	if t.EventTicketID > 0 {
+		return u.buyResaleForWallet(ctx, m, wallet, t)
+	}
+
+	if t.PublicEventID == 0 {
+		return nil, response.InvalidData("buyForWallet: public_event_id or event_ticket_id is required")
+	}
+
+	r, err := u.Reserve(ctx, client, &model.Reservation{
+		PublicEventID: t.PublicEventID,
+		TicketTierID:  t.TicketTierID,
+		SeatID:        t.SeatID,
+		Wallet:        wallet,
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, r.EventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("buyForWallet: error fetching reserved event ticket: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("buyForWallet: reserved ticket: %d not found", r.EventTicketID)
+	}
+
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:            moveBuy,
+		EventTicketID:   eventTicket.EventTicketID,
+		AssetID:         eventTicket.AssetID,
+		FromUserID:      eventTicket.BusinessUserID,
+		ToWallet:        wallet,
+		ToMarketplaceID: m.MarketPlaceID,
+		ExpectedStatus:  active,
+		NewStatus:       active,
+		ReservationID:   &r.ReservationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("buyForWallet: %w", err)
+	}
+
+	return u.walletTicket(ctx, wallet, eventTicket.EventTicketID)
+}
+
+// buyResaleForWallet buys a listed ticket when the marketplace the seller
+// listed it through trades with m, see marketplace.CanTrade.
+func (u *Event) buyResaleForWallet(ctx context.Context, m *model.Marketplace, wallet string, t *model.Ticket) (*model.EventTicket, error) {
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, t.EventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("buyResaleForWallet: error fetching event ticket: %w", err)
+	}
+
+	if !ok || *eventTicket.Status != resale || (t.PublicEventID > 0 && eventTicket.PublicEventID != t.PublicEventID) {
+		return nil, response.ResourceNotFound("ticket for resale not found", fmt.Sprintf("buyResaleForWallet: event_ticket_id: %d", t.EventTicketID))
+	}
+
+	if eventTicket.HolderWallet == wallet {
+		return nil, response.Conflict("ticket is already held by the user", fmt.Sprintf("buyResaleForWallet: event_ticket_id: %d", t.EventTicketID))
+	}
+
+	seller, err := u.holderMarketplace(ctx, eventTicket)
+	if err != nil {
+		return nil, fmt.Errorf("buyResaleForWallet: %w", err)
+	}
+
+	err = marketplace.CanTrade(m, seller)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = u.ensurePublished(ctx, eventTicket.PublicEventID)
+	if err != nil {
+		return nil, response.InvalidStateTransition(err.Error())
+	}
+
+	_, err = u.openListing(ctx, eventTicket.EventTicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.checkResale(ctx, eventTicket, eventTicket.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:              moveBuyResell,
+		EventTicketID:     eventTicket.EventTicketID,
+		AssetID:           eventTicket.AssetID,
+		FromUserID:        eventTicket.CurrentHolderID,
+		FromWallet:        eventTicket.HolderWallet,
+		ToWallet:          wallet,
+		FromMarketplaceID: eventTicket.HolderMarketplaceID,
+		ToMarketplaceID:   m.MarketPlaceID,
+		ExpectedStatus:    resale,
+		NewStatus:         active,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("buyResaleForWallet: %w", err)
+	}
+
+	return u.walletTicket(ctx, wallet, eventTicket.EventTicketID)
+}
+
+// ResellForWallet lists a ticket held by the marketplace wallet for resale at
+// price, within the resale policy of its event. The listing ends at expiresAt
+// when passed and after ttl otherwise.
+func (u *Event) ResellForWallet(ctx context.Context, wallet string, eventTicketID, price int64, expiresAt *time.Time, ttl time.Duration) (*model.EventTicket, *model.ResaleListing, error) {
+	if price <= 0 {
+		return nil, nil, response.InvalidData("resellForWallet: price_to_resell has to be positive")
+	}
+
+	eventTicket, err := u.walletTicket(ctx, wallet, eventTicketID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l, err := u.listTicket(ctx, eventTicket, uint64(price), expiresAt, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventTicket, err = u.walletTicket(ctx, wallet, eventTicketID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return eventTicket, l, nil
+}
+
+// TransferForWallet moves a ticket held by the wallet of a user of the
+// marketplace m to the wallet toWallet of another of its users.
+func (u *Event) TransferForWallet(ctx context.Context, m *model.Marketplace, wallet string, eventTicketID int64, toWallet string) (*model.EventTicket, error) {
+	if toWallet == wallet {
+		return nil, response.InvalidData("transferForWallet: ticket is already held by the receiver")
+	}
+
+	eventTicket, err := u.walletTicket(ctx, wallet, eventTicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if *eventTicket.Status != active {
+		return nil, response.Conflict("ticket cannot be transferred", fmt.Sprintf("transferForWallet: event_ticket_id: %d is %s", eventTicketID, *eventTicket.Status))
+	}
+
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:              moveSend,
+		EventTicketID:     eventTicket.EventTicketID,
+		AssetID:           eventTicket.AssetID,
+		FromWallet:        wallet,
+		ToWallet:          toWallet,
+		FromMarketplaceID: eventTicket.HolderMarketplaceID,
+		ToMarketplaceID:   m.MarketPlaceID,
+		ExpectedStatus:    active,
+		NewStatus:         active,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("transferForWallet: %w", err)
+	}
+
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, eventTicketID)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("transferForWallet: error fetching event ticket: %d: %v", eventTicketID, err)
+	}
+
+	return eventTicket, nil
+}
+
+// BridgeToSharedWallet moves a ticket held by the wallet of a user of the
+// NON_INTEROP marketplace m to sharedWallet, the wallet the user shares with
+// INTEROP marketplaces, with an on-chain transfer. Once bridged the ticket is
+// no longer held through m and any INTEROP marketplace may trade it.
+func (u *Event) BridgeToSharedWallet(ctx context.Context, m *model.Marketplace, wallet, sharedWallet string, eventTicketID int64) (*model.EventTicket, error) {
+	err := marketplace.CanBridge(m)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTicket, err := u.walletTicket(ctx, wallet, eventTicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if *eventTicket.Status != active {
+		return nil, response.Conflict("ticket cannot be bridged", fmt.Sprintf("bridgeToSharedWallet: event_ticket_id: %d is %s", eventTicketID, *eventTicket.Status))
+	}
+
+	_, ok, err := u.fetchAddress(sharedWallet)
+	if err != nil {
+		return nil, fmt.Errorf("bridgeToSharedWallet: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("shared wallet not found", "bridgeToSharedWallet: the user has not connected to an INTEROP marketplace")
+	}
+
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:              moveBridge,
+		EventTicketID:     eventTicket.EventTicketID,
+		AssetID:           eventTicket.AssetID,
+		FromWallet:        wallet,
+		ToWallet:          sharedWallet,
+		FromMarketplaceID: m.MarketPlaceID,
+		ExpectedStatus:    active,
+		NewStatus:         active,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bridgeToSharedWallet: %w", err)
+	}
+
+	eventTicket, ok, err = u.store.Tickets.Get(ctx, eventTicketID)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("bridgeToSharedWallet: error fetching event ticket: %d: %v", eventTicketID, err)
+	}
+
+	return eventTicket, nil
+}
+
+// holderMarketplace returns the marketplace the ticket is held through, nil
+// when it is held in the app.
+func (u *Event) holderMarketplace(ctx context.Context, et *model.EventTicket) (*model.Marketplace, error) {
+	if et.HolderMarketplaceID == 0 {
+		return nil, nil
+	}
+
+	m, ok, err := u.store.Marketplaces.Get(ctx, et.HolderMarketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("holderMarketplace: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("holderMarketplace: marketplace: %d not found", et.HolderMarketplaceID)
+	}
+
+	return m, nil
+}
+
+// walletTicket returns the ticket when the marketplace wallet holds it.
+func (u *Event) walletTicket(ctx context.Context, wallet string, eventTicketID int64) (*model.EventTicket, error) {
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, eventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("walletTicket: error fetching event ticket: %w", err)
+	}
+
+	if !ok || eventTicket.HolderWallet != wallet {
+		return nil, response.ResourceNotFound("event ticket not found", fmt.Sprintf("walletTicket: event_ticket_id: %d", eventTicketID))
+	}
+
+	return eventTicket, nil
+}
@@ -0,0 +1,66 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/notify"
+)
+
+// notifyBuyer sends the template to the buyer, over the channels of the
+// marketplace of its user for marketplace buyers. Like webhooks, notifications
+// report changes already made, so failures are only logged.
+func (u *Event) notifyBuyer(ctx context.Context, b *model.Buyer, template string, params interface{}) {
+	if u.notifier == nil {
+		return
+	}
+
+	to, order, ok := u.recipient(ctx, b)
+	if !ok {
+		return
+	}
+
+	_, _, err := u.notifier.Send(ctx, order, to, template, params)
+	if err != nil {
+		logger.Errorf(ctx, "notifyBuyer: %s: user: %d user_marketplace: %d: %+v", template, b.UserID, b.UserMarketplaceID, err)
+	}
+}
+
+// recipient returns the addresses of the buyer and the channel order to reach
+// it in, nil for the default order.
+func (u *Event) recipient(ctx context.Context, b *model.Buyer) (notify.Recipient, []string, bool) {
+	if b.UserMarketplaceID > 0 {
+		mu, ok, err := u.store.Marketplaces.GetUserByID(ctx, b.UserMarketplaceID)
+		if err != nil || !ok {
+			logger.Errorf(ctx, "recipient: user_marketplace: %d not found: %v", b.UserMarketplaceID, err)
+			return notify.Recipient{}, nil, false
+		}
+
+		var order []string
+		m, ok, err := u.store.Marketplaces.Get(ctx, b.MarketplaceID)
+		if err == nil && ok {
+			order = m.OTPChannels
+		}
+
+		return notify.Recipient{Phone: mu.PhoneNumber, Email: mu.EmailAddress, Locale: mu.Locale}, order, true
+	}
+
+	user, ok, err := u.store.Users.Get(ctx, b.UserID)
+	if err != nil || !ok {
+		logger.Errorf(ctx, "recipient: user: %d not found: %v", b.UserID, err)
+		return notify.Recipient{}, nil, false
+	}
+
+	var to notify.Recipient
+	if user.PhoneNumber != nil {
+		if user.PhoneCountryCode != nil {
+			to.Phone = *user.PhoneCountryCode
+		}
+		to.Phone += *user.PhoneNumber
+	}
+	if user.EmailAddress != nil {
+		to.Email = *user.EmailAddress
+	}
+
+	return to, nil, true
+}
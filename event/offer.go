@@ -0,0 +1,264 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+)
+
+// Resale offer statuses.
+const (
+	OfferPending  = model.OfferPending
+	OfferAccepted = model.OfferAccepted
+	OfferRejected = model.OfferRejected
+	OfferExpired  = model.OfferExpired
+)
+
+// PlaceOffer offers amount for the ticket of an open listing on behalf of the
+// buyer. Buyers of the marketplace m can only make offers on tickets listed
+// through marketplaces it trades with, see marketplace.CanTrade.
+func (u *Event) PlaceOffer(ctx context.Context, m *model.Marketplace, b *model.Buyer, listingID, amount int64) (*model.ResaleOffer, error) {
+	if amount <= 0 {
+		return nil, response.InvalidData("placeOffer: amount has to be positive")
+	}
+
+	l, ok, err := u.store.Listings.Get(ctx, listingID)
+	if err != nil {
+		return nil, fmt.Errorf("placeOffer: error fetching resale listing: %w", err)
+	}
+
+	if !ok || l.Status != ListingOpen || l.ExpiresAt == nil || !l.ExpiresAt.After(time.Now()) {
+		return nil, response.ResourceNotFound("resale listing not found", fmt.Sprintf("placeOffer: listing: %d is not open", listingID))
+	}
+
+	if l.SellerUserID == b.UserID && l.SellerWallet == b.Wallet {
+		return nil, response.Conflict("ticket is already held by the user", fmt.Sprintf("placeOffer: listing: %d", listingID))
+	}
+
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, l.EventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("placeOffer: error fetching event ticket: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("placeOffer: event ticket: %d not found", l.EventTicketID)
+	}
+
+	if m != nil {
+		seller, err := u.holderMarketplace(ctx, eventTicket)
+		if err != nil {
+			return nil, fmt.Errorf("placeOffer: %w", err)
+		}
+
+		err = marketplace.CanTrade(m, seller)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = u.checkResale(ctx, eventTicket, uint64(amount))
+	if err != nil {
+		return nil, err
+	}
+
+	o := model.ResaleOffer{
+		ListingID:     l.ListingID,
+		EventTicketID: l.EventTicketID,
+		Buyer:         *b,
+		Amount:        uint64(amount),
+		Status:        OfferPending,
+	}
+
+	o.OfferID, err = u.store.Offers.Create(ctx, &o)
+	if err != nil {
+		return nil, fmt.Errorf("placeOffer: error inserting resale offer: %w", err)
+	}
+
+	return &o, nil
+}
+
+// ListingOffers returns the pending offers on an open listing of the seller,
+// the user or the marketplace wallet, highest first.
+func (u *Event) ListingOffers(ctx context.Context, listingID, userID int64, wallet string) ([]model.ResaleOffer, error) {
+	_, err := u.sellerListing(ctx, listingID, userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	os, err := u.store.Offers.Pending(ctx, listingID)
+	if err != nil {
+		return nil, fmt.Errorf("listingOffers: error fetching resale offers: %w", err)
+	}
+
+	return os, nil
+}
+
+// RejectOffer turns down a pending offer on an open listing of the seller.
+func (u *Event) RejectOffer(ctx context.Context, listingID, offerID, userID int64, wallet string) (*model.ResaleOffer, error) {
+	_, err := u.sellerListing(ctx, listingID, userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := u.pendingOffer(ctx, listingID, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setOfferStatus(ctx, u.store, o, OfferPending, OfferRejected)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// AcceptOffer sells the ticket of an open listing of the seller to the buyer
+// of a pending offer, at the offered amount. The listing is taken off sale as
+// ACCEPTED before the ticket moves, so its ask price stays what other buyers
+// see, and the move carries the offered amount for the payout. Once applied
+// the listing closes as SOLD and the other offers expire. If the move fails
+// the listing is open again and the offer pending.
+func (u *Event) AcceptOffer(ctx context.Context, listingID, offerID, userID int64, wallet string) (*model.ResaleOffer, error) {
+	l, err := u.sellerListing(ctx, listingID, userID, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := u.pendingOffer(ctx, listingID, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, l.EventTicketID)
+	if err != nil {
+		return nil, fmt.Errorf("acceptOffer: error fetching event ticket: %w", err)
+	}
+
+	if !ok || *eventTicket.Status != resale {
+		return nil, response.Conflict("ticket is no longer listed", fmt.Sprintf("acceptOffer: event_ticket_id: %d", l.EventTicketID))
+	}
+
+	err = u.checkResale(ctx, eventTicket, o.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.takeListing(ctx, l, o)
+	if err != nil {
+		return nil, err
+	}
+
+	mv := model.TicketMove{
+		Kind:              moveBuyResell,
+		EventTicketID:     eventTicket.EventTicketID,
+		AssetID:           eventTicket.AssetID,
+		FromUserID:        eventTicket.CurrentHolderID,
+		FromWallet:        eventTicket.HolderWallet,
+		ToUserID:          o.UserID,
+		ToWallet:          o.Wallet,
+		FromMarketplaceID: eventTicket.HolderMarketplaceID,
+		ToMarketplaceID:   o.MarketplaceID,
+		ExpectedStatus:    resale,
+		NewStatus:         active,
+		Amount:            &o.Amount,
+	}
+	err = u.moveTicket(ctx, &mv)
+	if err != nil {
+		if mv.MoveID == 0 || mv.State == MoveFailed {
+			rerr := u.reopenListing(ctx, l.ListingID)
+			if rerr != nil {
+				return nil, fmt.Errorf("acceptOffer: %v: %w", rerr, err)
+			}
+		}
+		return nil, fmt.Errorf("acceptOffer: %w", err)
+	}
+
+	return o, nil
+}
+
+// takeListing moves the open listing to ACCEPTED and the offer to ACCEPTED in
+// one transaction, so no other buyer or offer can take the ticket meanwhile.
+func (u *Event) takeListing(ctx context.Context, l *model.ResaleListing, o *model.ResaleOffer) error {
+	now := time.Now()
+	err := u.store.Tx(ctx, func(tx *store.Store) error {
+		ok, err := tx.Listings.SetStatus(ctx, l.ListingID, ListingOpen, ListingAccepted, now)
+		if err != nil {
+			return fmt.Errorf("takeListing: error updating resale listing: %w", err)
+		}
+
+		if !ok {
+			return response.InvalidStateTransition(fmt.Sprintf("takeListing: listing: %d is no longer %s", l.ListingID, ListingOpen))
+		}
+
+		return setOfferStatus(ctx, tx, o, OfferPending, OfferAccepted)
+	})
+	if err != nil {
+		return err
+	}
+
+	l.Status = ListingAccepted
+	l.UpdatedAt = &now
+	return nil
+}
+
+// reopenListing puts the accepted listing back on sale and its accepted offer
+// back to pending, after the sale to the offer failed.
+func (u *Event) reopenListing(ctx context.Context, listingID int64) error {
+	return u.store.Tx(ctx, func(tx *store.Store) error {
+		now := time.Now()
+		ok, err := tx.Listings.SetStatus(ctx, listingID, ListingAccepted, ListingOpen, now)
+		if err != nil {
+			return fmt.Errorf("reopenListing: error updating resale listing: %w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		err = tx.Offers.Reopen(ctx, listingID, now)
+		if err != nil {
+			return fmt.Errorf("reopenListing: error updating resale offer: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func setOfferStatus(ctx context.Context, tx *store.Store, o *model.ResaleOffer, from, to string) error {
+	now := time.Now()
+	ok, err := tx.Offers.SetStatus(ctx, o.OfferID, from, to, now)
+	if err != nil {
+		return fmt.Errorf("setOfferStatus: error updating resale offer: %w", err)
+	}
+
+	if !ok {
+		return response.InvalidStateTransition(fmt.Sprintf("setOfferStatus: offer: %d is no longer %s", o.OfferID, from))
+	}
+
+	o.Status = to
+	o.UpdatedAt = &now
+	return nil
+}
+
+// pendingOffer returns the offer when it is a pending offer on the listing.
+func (u *Event) pendingOffer(ctx context.Context, listingID, offerID int64) (*model.ResaleOffer, error) {
+	o, ok, err := u.store.Offers.Get(ctx, offerID)
+	if err != nil {
+		return nil, fmt.Errorf("pendingOffer: error fetching resale offer: %w", err)
+	}
+
+	if !ok || o.ListingID != listingID {
+		return nil, response.ResourceNotFound("resale offer not found", fmt.Sprintf("pendingOffer: offer: %d on listing: %d", offerID, listingID))
+	}
+
+	if o.Status != OfferPending {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("pendingOffer: offer: %d is %s", offerID, o.Status))
+	}
+
+	return o, nil
+}
@@ -2,75 +2,110 @@ package event
 
 import (
 	"context"
-	"database/sql"
 	"eventers-marketplace-backend/algorand"
 	"eventers-marketplace-backend/constants"
 	"eventers-marketplace-backend/logger"
 	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/notify"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
 	"eventers-marketplace-backend/vault"
+	"eventers-marketplace-backend/webhook"
 	"fmt"
-	"strings"
-)
+	"sync"
+	"time"
 
-const (
-	publicEventTable = "Public_Event"
-	eventTicketTable = "Event_Tickets"
-	seedAlgo         = 10
+	"github.com/go-redis/redis"
 )
 
-var active = "ACTIVE"
+const seedAlgo = 10
 
-var publicEventCols = []string{"date_time", "event_title", "event_description", "event_image", "total_tickets", "ticket_price", "temp_account_address", "temp_security_paraphrase"}
-var eventTicketCols = []string{"business_user_id", "public_event_id", "asset_id", "current_holder_id", "status", "price"}
+var active = model.TicketActive
+var resale = model.TicketResale
 
-// NewEvent returns a new event database instance
-func NewEvent(algo algorand.Algo, vault vault.Vault) *Event {
+// NewEvent returns a new event database instance. Changes are reported to
+// marketplaces through hooks unless it is nil.
+func NewEvent(algo algorand.Algo, vault vault.Vault, st *store.Store, hooks *webhook.Webhooks, notifier *notify.Notifier) *Event {
 	return &Event{
-		algo:  algo,
-		vault: vault,
+		algo:     algo,
+		vault:    vault,
+		store:    st,
+		hooks:    hooks,
+		notifier: notifier,
 	}
 }
 
 // Event represents the client for event table
 type Event struct {
-	algo  algorand.Algo
-	vault vault.Vault
+	algo     algorand.Algo
+	vault    vault.Vault
+	store    *store.Store
+	hooks    *webhook.Webhooks
+	notifier *notify.Notifier
 }
 
-func (u *Event) PublicEvent(ctx context.Context, db *sql.DB, pe *model.PublicEvent, addedBy int64) (*model.PublicEvent, error) {
-
-	tx, err := db.Begin()
+// AppUserID returns the id of the app user with the firebase uid. Unknown uids
+// are unauthorized.
+func (u *Event) AppUserID(ctx context.Context, firebaseID string) (int64, error) {
+	id, ok, err := u.store.Users.IDByFirebaseID(ctx, firebaseID)
 	if err != nil {
-		return nil, fmt.Errorf("publicEvent: error begining db transaction: %s", err)
+		return 0, fmt.Errorf("appUserID: %w", err)
 	}
 
-	a, err := u.algo.GenerateAccount()
+	if !ok {
+		return 0, response.Unauthorized()
+	}
+
+	return id, nil
+}
+
+// PublicEvent creates a draft public event. Tickets are minted once the event
+// is published through EditPublicEvent.
+func (u *Event) PublicEvent(ctx context.Context, pe *model.PublicEvent, addedBy int64) (*model.PublicEvent, error) {
+	err := normalizeTiers(pe)
 	if err != nil {
-		return nil, fmt.Errorf("publicEvent: error generating account: %w", err)
+		return nil, response.InvalidData(err.Error())
 	}
 
-	values := []interface{}{
-		pe.DateTime,
-		pe.EventTitle,
-		pe.EventDescription,
-		pe.EventImage,
-		pe.TotalTickets,
-		pe.TicketPrice,
-		a.AccountAddress,
-		a.SecurityPassphrase,
+	if pe.ResalePolicy != nil {
+		err = validateResalePolicy(pe.ResalePolicy)
+		if err != nil {
+			return nil, response.InvalidData(err.Error())
+		}
 	}
 
-	id, err := create(tx, publicEventTable, publicEventCols, values)
+	a, err := u.algo.GenerateAccount()
 	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("publicEvent: error inserting public events by: %d: err: %w", addedBy, err)
+		return nil, fmt.Errorf("publicEvent: error generating account: %w", err)
 	}
 
-	pe.PublicEventID = id
+	status := Draft
+	pe.Status = &status
+	pe.BusinessUserID = addedBy
 
-	err = tx.Commit()
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		id, err := tx.Events.Create(ctx, pe, a.AccountAddress, a.SecurityPassphrase)
+		if err != nil {
+			return fmt.Errorf("publicEvent: error inserting public events by: %d: err: %w", addedBy, err)
+		}
+		pe.PublicEventID = id
+
+		err = tx.Tiers.Create(ctx, pe.PublicEventID, pe.TicketTiers)
+		if err != nil {
+			return fmt.Errorf("publicEvent: error inserting ticket tiers by: %d: err: %w", addedBy, err)
+		}
+
+		if pe.ResalePolicy != nil {
+			err = tx.ResalePolicies.Save(ctx, pe.PublicEventID, pe.ResalePolicy)
+			if err != nil {
+				return fmt.Errorf("publicEvent: error inserting resale policy by: %d: err: %w", addedBy, err)
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("publicEvent: error commiting public event to db by: %d: err: %s", addedBy, err)
+		return nil, err
 	}
 
 	path := fmt.Sprintf("%s/%v", u.vault.TempPath, pe.PublicEventID)
@@ -84,141 +119,99 @@ func (u *Event) PublicEvent(ctx context.Context, db *sql.DB, pe *model.PublicEve
 		return nil, fmt.Errorf("PublicEvent: unable to write to vault: %w", err)
 	}
 
-	go u.processEvent(ctx, db, a, pe, addedBy)
 	return pe, nil
 }
 
-// Update Event_Tickets
-func (u *Event) UpdatePublicEvent(ctx context.Context, db *sql.DB, et *model.Ticket) error {
+// UpdatePublicEvent resells, sends, redeems or buys the ticket. Resold tickets
+// are listed until listing_expires_at of the ticket or for listingTTL, and the
+// listing is returned.
+func (u *Event) UpdatePublicEvent(ctx context.Context, client *redis.Client, et *model.Ticket, listingTTL time.Duration) (*model.ResaleListing, error) {
 	if et.PriceToResell > 0 {
-		err := u.resell(db, et)
+		l, err := u.resell(ctx, et, listingTTL)
 		if err != nil {
-			return fmt.Errorf("updatePublicEvent: error in reselling: %w", err)
+			return nil, fmt.Errorf("updatePublicEvent: error in reselling: %w", err)
 		}
-		return nil
+		return l, nil
 	}
 
 	if et.FromUserID > 0 && et.ToUserID > 0 {
-		err := u.send(ctx, db, et)
+		err := u.send(ctx, et)
 		if err != nil {
-			return fmt.Errorf("updatePublicEvent: error in sending: %w", err)
+			return nil, fmt.Errorf("updatePublicEvent: error in sending: %w", err)
 		}
-		return nil
+		return nil, nil
 	}
 
-	if et.Status != nil && *et.Status == "REDEEM" {
-		err := u.redeem(db, et)
+	if et.Status != nil && *et.Status == model.TicketRedeemed {
+		err := u.redeem(ctx, et)
 		if err != nil {
-			return fmt.Errorf("updatePublicEvent: error in redeeming: %w", err)
+			return nil, fmt.Errorf("updatePublicEvent: error in redeeming: %w", err)
 		}
-		return nil
+		return nil, nil
 	}
 
 	if et.PublicEventID > 0 && et.EventTicketID == 0 {
-		err := u.buy(ctx, db, et)
+		err := u.buy(ctx, client, et)
 		if err != nil {
-			return fmt.Errorf("updatePublicEvent: error in buying: %w", err)
+			return nil, fmt.Errorf("updatePublicEvent: error in buying: %w", err)
 		}
-		return nil
+		return nil, nil
 	}
 
 	if et.EventTicketID > 0 && et.PublicEventID > 0 {
-		err := u.buyResell(ctx, db, et)
+		err := u.buyResell(ctx, et)
 		if err != nil {
-			return fmt.Errorf("updatePublicEvent: error in buyresell: %w", err)
+			return nil, fmt.Errorf("updatePublicEvent: error in buyresell: %w", err)
 		}
-		return nil
+		return nil, nil
 	}
 
-	return fmt.Errorf("updatePublicEvent: no matching action found")
+	return nil, fmt.Errorf("updatePublicEvent: no matching action found")
 }
 
-func (u *Event) resell(db *sql.DB, et *model.Ticket) error {
-	tx, err := db.Begin()
+func (u *Event) resell(ctx context.Context, et *model.Ticket, ttl time.Duration) (*model.ResaleListing, error) {
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, et.EventTicketID)
 	if err != nil {
-		return fmt.Errorf("resell: error begining db transaction: %s", err)
+		return nil, fmt.Errorf("resell: error fetching event ticket: %w", err)
 	}
-	updatedRows, err := update(
-		tx,
-		eventTicketTable,
-		[]string{"price", "status"},
-		[]interface{}{et.PriceToResell, "RESELL"},
-		[]string{"event_ticket_id"},
-		[]interface{}{et.EventTicketID},
-	)
 
-	if err != nil {
-		return fmt.Errorf("resell: error updating event_ticket for resell: %w", err)
-	}
-
-	if updatedRows == 0 {
-		tx.Rollback()
-		return fmt.Errorf("resell: no row updated")
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("resell: could not commit transaction for resell: err: %w", err)
+	if !ok {
+		return nil, fmt.Errorf("resell: event_ticket_id not found")
 	}
 
-	return nil
+	return u.listTicket(ctx, eventTicket, uint64(et.PriceToResell), et.ListingExpiresAt, ttl)
 }
 
-func (u *Event) redeem(db *sql.DB, et *model.Ticket) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("resell: error begining db transaction: %s", err)
-	}
-	updatedRows, err := update(
-		tx,
-		eventTicketTable,
-		[]string{"status"},
-		[]interface{}{"REDEEM"},
-		[]string{"event_ticket_id"},
-		[]interface{}{et.EventTicketID},
-	)
+func (u *Event) redeem(ctx context.Context, et *model.Ticket) error {
+	err := u.store.Tx(ctx, func(tx *store.Store) error {
+		// Only a ticket that is held or on resale can be redeemed, one being
+		// sold or moved on chain is left alone.
+		ok, err := tx.Tickets.Redeem(ctx, et.EventTicketID)
+		if err != nil {
+			return fmt.Errorf("redeem: error updating event_ticket for redeem: %w", err)
+		}
 
-	if err != nil {
-		return fmt.Errorf("redeem: error updating event_ticket for redeem: %w", err)
-	}
+		if !ok {
+			return response.InvalidStateTransition("redeem: ticket is not active or on resale")
+		}
 
-	if updatedRows == 0 {
-		tx.Rollback()
-		return fmt.Errorf("redeem: no row updated")
-	}
+		err = closeListing(ctx, tx, et.EventTicketID, ListingCancelled)
+		if err != nil {
+			return fmt.Errorf("redeem: %w", err)
+		}
 
-	err = tx.Commit()
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("redeem: could not commit transaction for redeem: err: %w", err)
+		return err
 	}
 
+	u.publishTicket(ctx, webhook.TicketRedeemed, et.EventTicketID)
 	return nil
 }
 
-func (u *Event) send(ctx context.Context, db *sql.DB, et *model.Ticket) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("send: error begining db transaction: %s", err)
-	}
-	to, ok, err := u.fetchUserAddress(et.ToUserID)
-	if err != nil {
-		return fmt.Errorf("send: error fetching to_user_id: %w", err)
-	}
-
-	if !ok {
-		return fmt.Errorf("send: to_user_id not found")
-	}
-
-	from, ok, err := u.fetchUserAddress(et.FromUserID)
-	if err != nil {
-		return fmt.Errorf("send: error fetching from_user_id: %w", err)
-	}
-
-	if !ok {
-		return fmt.Errorf("send: from_user_id not found")
-	}
-
-	eventTicket, ok, err := fetchEventTicket(db, et.EventTicketID)
+func (u *Event) send(ctx context.Context, et *model.Ticket) error {
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, et.EventTicketID)
 	if err != nil {
 		return fmt.Errorf("send: error fetching event ticket: %w", err)
 	}
@@ -227,319 +220,306 @@ func (u *Event) send(ctx context.Context, db *sql.DB, et *model.Ticket) error {
 		return fmt.Errorf("send: event_ticket_id not found")
 	}
 
-	toAccount := algorand.Account{
-		AccountAddress:     to.AccountAddress,
-		PrivateKey:         to.PrivateKey,
-		SecurityPassphrase: to.SecurityPassphrase,
-	}
-
-	fromAccount := algorand.Account{
-		AccountAddress:     from.AccountAddress,
-		PrivateKey:         from.PrivateKey,
-		SecurityPassphrase: from.SecurityPassphrase,
-	}
-
-	err = u.algo.OptIn(ctx, &toAccount, eventTicket.AssetID)
-	if err != nil {
-		return fmt.Errorf("send: error opting in: %w", err)
+	if eventTicket.CurrentHolderID != et.FromUserID || eventTicket.HolderWallet != "" {
+		return fmt.Errorf("send: ticket: %d is not held by from_user_id: %d", et.EventTicketID, et.FromUserID)
 	}
 
-	err = u.algo.SendAsset(ctx, &fromAccount, &toAccount, eventTicket.AssetID)
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:           moveSend,
+		EventTicketID:  eventTicket.EventTicketID,
+		AssetID:        eventTicket.AssetID,
+		FromUserID:     et.FromUserID,
+		ToUserID:       et.ToUserID,
+		ExpectedStatus: *eventTicket.Status,
+		NewStatus:      *eventTicket.Status,
+	})
 	if err != nil {
-		return fmt.Errorf("send: error sending asset: %w", err)
-	}
-
-	updatedRows, err := update(
-		tx,
-		eventTicketTable,
-		[]string{"current_holder_id"},
-		[]interface{}{et.ToUserID},
-		[]string{"event_ticket_id"},
-		[]interface{}{et.EventTicketID},
-	)
-
-	if err != nil {
-		return fmt.Errorf("send: error updating event_ticket for send: %w", err)
-	}
-
-	if updatedRows == 0 {
-		tx.Rollback()
-		return fmt.Errorf("send: no row updated")
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("resell: could not commit transaction for resell: err: %w", err)
+		return fmt.Errorf("send: %w", err)
 	}
 
 	return nil
 }
 
-func (u *Event) buy(ctx context.Context, db *sql.DB, et *model.Ticket) error {
-	tx, err := db.Begin()
+func (u *Event) buy(ctx context.Context, client *redis.Client, et *model.Ticket) error {
+	_, err := u.ensurePublished(ctx, et.PublicEventID)
 	if err != nil {
-		return fmt.Errorf("buy: error begining db transaction: %s", err)
+		return fmt.Errorf("buy: %w", err)
 	}
 
-	to, ok, err := u.fetchUserAddress(et.ToUserID)
-	if err != nil {
-		return fmt.Errorf("buy: error fetching to_user_id: %w", err)
+	if et.ReservationID == "" {
+		return fmt.Errorf("buy: reservation_id is required")
 	}
 
-	if !ok {
-		return fmt.Errorf("buy: to_user_id not found")
+	reservation, err := u.validReservation(ctx, et.ReservationID, et.PublicEventID, et.ToUserID, "")
+	if err != nil {
+		return fmt.Errorf("buy: %w", err)
 	}
 
-	eventTicket, ok, err := pickEventTicket(db, et.PublicEventID)
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, reservation.EventTicketID)
 	if err != nil {
-		return fmt.Errorf("buy: error picking event ticket: %w", err)
+		return fmt.Errorf("buy: error fetching reserved event ticket: %w", err)
 	}
 
-	if !ok {
-		return fmt.Errorf("buy: no active ticket found")
+	if !ok || eventTicket.CurrentHolderID != eventTicket.BusinessUserID || eventTicket.HolderWallet != "" {
+		return fmt.Errorf("buy: reserved ticket: %d is no longer available", reservation.EventTicketID)
 	}
 
-	from, ok, err := u.fetchUserAddress(eventTicket.BusinessUserID)
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:           moveBuy,
+		EventTicketID:  eventTicket.EventTicketID,
+		AssetID:        eventTicket.AssetID,
+		FromUserID:     eventTicket.BusinessUserID,
+		ToUserID:       et.ToUserID,
+		ExpectedStatus: active,
+		NewStatus:      active,
+		ReservationID:  &reservation.ReservationID,
+	})
 	if err != nil {
-		return fmt.Errorf("buy: error fetching from_user_id: %w", err)
-	}
-
-	if !ok {
-		return fmt.Errorf("buy: from_user_id not found")
+		return fmt.Errorf("buy: %w", err)
 	}
 
-	toAccount := algorand.Account{
-		AccountAddress:     to.AccountAddress,
-		PrivateKey:         to.PrivateKey,
-		SecurityPassphrase: to.SecurityPassphrase,
+	if eventTicket.SeatID > 0 {
+		client.Del(seatHoldKey(et.PublicEventID, eventTicket.SeatID))
 	}
 
-	fromAccount := algorand.Account{
-		AccountAddress:     from.AccountAddress,
-		PrivateKey:         from.PrivateKey,
-		SecurityPassphrase: from.SecurityPassphrase,
-	}
+	return nil
+}
 
-	err = u.algo.OptIn(ctx, &toAccount, eventTicket.AssetID)
+func (u *Event) buyResell(ctx context.Context, et *model.Ticket) error {
+	_, err := u.ensurePublished(ctx, et.PublicEventID)
 	if err != nil {
-		return fmt.Errorf("buy: error opting in: %w", err)
+		return fmt.Errorf("buyResell: %w", err)
 	}
 
-	err = u.algo.SendAsset(ctx, &fromAccount, &toAccount, eventTicket.AssetID)
+	eventTicket, ok, err := u.store.Tickets.Get(ctx, et.EventTicketID)
 	if err != nil {
-		return fmt.Errorf("buy: error buying asset: %w", err)
+		return fmt.Errorf("buyResell: error fetching event ticket: %w", err)
 	}
 
-	updatedRows, err := update(
-		tx,
-		eventTicketTable,
-		[]string{"current_holder_id"},
-		[]interface{}{et.ToUserID},
-		[]string{"event_ticket_id"},
-		[]interface{}{eventTicket.EventTicketID},
-	)
+	if !ok || *eventTicket.Status != resale {
+		return fmt.Errorf("buyResell: no ticket for resale found")
+	}
 
+	_, err = u.openListing(ctx, eventTicket.EventTicketID)
 	if err != nil {
-		return fmt.Errorf("buy: error updating event_ticket for buy: %w", err)
+		return err
 	}
 
-	if updatedRows == 0 {
-		tx.Rollback()
-		return fmt.Errorf("buy: no row updated")
+	err = u.checkResale(ctx, eventTicket, eventTicket.Price)
+	if err != nil {
+		return err
 	}
 
-	err = tx.Commit()
+	err = u.moveTicket(ctx, &model.TicketMove{
+		Kind:              moveBuyResell,
+		EventTicketID:     eventTicket.EventTicketID,
+		AssetID:           eventTicket.AssetID,
+		FromUserID:        eventTicket.CurrentHolderID,
+		FromWallet:        eventTicket.HolderWallet,
+		ToUserID:          et.ToUserID,
+		FromMarketplaceID: eventTicket.HolderMarketplaceID,
+		ExpectedStatus:    resale,
+		NewStatus:         active,
+	})
 	if err != nil {
-		return fmt.Errorf("buy: could not commit transaction for resell: err: %w", err)
+		return fmt.Errorf("buyResell: %w", err)
 	}
 
 	return nil
 }
 
-func (u *Event) buyResell(ctx context.Context, db *sql.DB, et *model.Ticket) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("buyResell: error begining db transaction: %s", err)
-	}
-
-	to, ok, err := u.fetchUserAddress(et.ToUserID)
+func (u *Event) processEvent(ctx context.Context, a *algorand.Account, pe *model.PublicEvent, userID int64) {
+	err := u.algo.Send(ctx, a, seedAlgo)
 	if err != nil {
-		return fmt.Errorf("buyResell: error fetching to_user_id: %w", err)
-	}
-
-	if !ok {
-		return fmt.Errorf("buyResell: to_user_id not found")
+		logger.Errorf(ctx, "processEvent: error sending 10 algos to temp account: %s: err: %+v", a.AccountAddress, err)
+		return
 	}
 
-	eventTicket, ok, err := fetchEventTicket(db, et.EventTicketID)
+	ua, ok, err := u.fetchUserAddress(userID)
 	if err != nil {
-		return fmt.Errorf("buyResell: error fetching event ticket: %w", err)
+		logger.Errorf(ctx, "processEvent: could not fetchUserAddress user, err: %+v", err)
+		return
 	}
 
 	if !ok {
-		return fmt.Errorf("buyResell: no active ticket found")
+		logger.Errorf(ctx, "processEvent: user not found")
+		return
 	}
 
-	from, ok, err := u.fetchUserAddress(eventTicket.BusinessUserID)
+	tts, err := u.store.Tiers.List(ctx, pe.PublicEventID)
 	if err != nil {
-		return fmt.Errorf("buyResell: error fetching from_user_id: %w", err)
-	}
-
-	if !ok {
-		return fmt.Errorf("buyResell: from_user_id not found")
+		logger.Errorf(ctx, "processEvent: could not fetch ticket tiers, err: %+v", err)
+		return
 	}
 
-	toAccount := algorand.Account{
-		AccountAddress:     to.AccountAddress,
-		PrivateKey:         to.PrivateKey,
-		SecurityPassphrase: to.SecurityPassphrase,
+	var seats []model.Seat
+	if pe.VenueID > 0 {
+		seats, err = u.store.Venues.Seats(ctx, pe.VenueID)
+		if err != nil {
+			logger.Errorf(ctx, "processEvent: could not fetch seats, err: %+v", err)
+			return
+		}
 	}
 
-	fromAccount := algorand.Account{
-		AccountAddress:     from.AccountAddress,
-		PrivateKey:         from.PrivateKey,
-		SecurityPassphrase: from.SecurityPassphrase,
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	summary := model.MintSummary{PublicEventID: pe.PublicEventID}
+	count := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			logger.Errorf(ctx, "processEvent: %+v", err)
+			summary.Failed++
+			return
+		}
+		summary.Minted++
 	}
 
-	err = u.algo.OptIn(ctx, &toAccount, eventTicket.AssetID)
-	if err != nil {
-		return fmt.Errorf("buyResell: error opting in: %w", err)
-	}
+	// Cancelling the event stops the run before the next mint. Tickets minted
+	// while the event was being cancelled are cancelled once the run ends.
+	var stopped bool
+	handOver := func(tt *model.TicketTier, seat *model.Seat) bool {
+		if stopped || u.isCancelled(ctx, pe.PublicEventID) {
+			stopped = true
+			return false
+		}
 
-	err = u.algo.SendAsset(ctx, &fromAccount, &toAccount, eventTicket.AssetID)
-	if err != nil {
-		return fmt.Errorf("buyResell: error buying asset: %w", err)
-	}
+		et, err := u.mint(ctx, a, pe, tt, seat, userID)
+		if err != nil {
+			count(err)
+			return true
+		}
 
-	updatedRows, err := update(
-		tx,
-		eventTicketTable,
-		[]string{"current_holder_id", "status"},
-		[]interface{}{et.ToUserID, active},
-		[]string{"event_ticket_id"},
-		[]interface{}{et.EventTicketID},
-	)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count(u.start(ctx, et, a, ua))
+		}()
+		return true
+	}
+
+	for _, tt := range tts {
+		if pe.VenueID == 0 {
+			var i uint64 = 0
+			for ; i < tt.TotalTickets; i++ {
+				if !handOver(&tt, nil) {
+					break
+				}
+			}
+			continue
+		}
 
-	if err != nil {
-		return fmt.Errorf("buyResell: error updating event_ticket for buyResell: %w", err)
+		for i := range seats {
+			if seats[i].Tier == *tt.TierName && !handOver(&tt, &seats[i]) {
+				break
+			}
+		}
 	}
 
-	if updatedRows == 0 {
-		tx.Rollback()
-		return fmt.Errorf("buyResell: no row updated")
+	wg.Wait()
+	if stopped {
+		logger.Infof(ctx, "processEvent: public event: %d was cancelled, minted: %d", pe.PublicEventID, summary.Minted)
+		u.cancelEvent(ctx, pe)
+		return
 	}
+	u.publish(ctx, webhook.EventMinted, &summary)
+}
 
-	err = tx.Commit()
+// isCancelled reports whether the event was cancelled, or is gone, since its
+// tickets started minting.
+func (u *Event) isCancelled(ctx context.Context, publicEventID int64) bool {
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
 	if err != nil {
-		return fmt.Errorf("buyResell: could not commit transaction for resell: err: %w", err)
+		logger.Errorf(ctx, "isCancelled: public event: %d: %+v", publicEventID, err)
+		return false
 	}
 
-	return nil
+	return !ok || (pe.Status != nil && *pe.Status == Cancelled)
 }
 
-func (u *Event) processEvent(ctx context.Context, db *sql.DB, a *algorand.Account, pe *model.PublicEvent, userID int64) {
-	err := u.algo.Send(ctx, a, seedAlgo)
-	if err != nil {
-		logger.Errorf(ctx, "processEvent: error sending 10 algos to temp account: %s: err: %+v", a.AccountAddress, err)
-		return
+// mint creates the asset of a single ticket of the tier and returns the ticket
+// it backs. Seated tickets carry their seat in the asset metadata.
+func (u *Event) mint(ctx context.Context, a *algorand.Account, pe *model.PublicEvent, tt *model.TicketTier, seat *model.Seat, userID int64) (*model.EventTicket, error) {
+	var md *algorand.AssetMetadata
+	if seat != nil {
+		md = seatMetadata(pe, seat)
 	}
 
-	ua, ok, err := u.fetchUserAddress(userID)
+	assetID, err := u.algo.CreateAsset(ctx, a, md)
 	if err != nil {
-		logger.Errorf(ctx, "processEvent: could not fetchUserAddress user, err: %+v", err)
-		return
-	}
-
-	if !ok {
-		logger.Errorf(ctx, "processEvent: user not found")
-		return
+		return nil, fmt.Errorf("mint: error creating asset: %w", err)
 	}
 
-	var et []model.EventTicket
-	var assets []uint64
-	var i uint64 = 0
-	for ; i < pe.TotalTickets; i++ {
-		assetID, err := u.algo.CreateAsset(ctx, a)
-		if err != nil {
-			logger.Errorf(ctx, "processEvent: error creating asset: %+v", err)
-		}
-		eventTicket := model.EventTicket{
-			BusinessUserID:  userID,
-			PublicEventID:   pe.PublicEventID,
-			AssetID:         assetID,
-			CurrentHolderID: userID,
-			Status:          &active,
-			Price:           pe.TicketPrice,
-		}
-
-		assets = append(assets, assetID)
-		et = append(et, eventTicket)
+	return newEventTicket(pe, tt, seat, userID, assetID), nil
+}
 
-		go u.start(ctx, db, &eventTicket, a, *ua, assetID, userID)
+// newEventTicket returns the ticket of the tier backed by the asset.
+func newEventTicket(pe *model.PublicEvent, tt *model.TicketTier, seat *model.Seat, userID int64, assetID uint64) *model.EventTicket {
+	eventTicket := model.EventTicket{
+		BusinessUserID:  userID,
+		PublicEventID:   pe.PublicEventID,
+		AssetID:         assetID,
+		CurrentHolderID: userID,
+		Status:          &active,
+		Price:           tt.TicketPrice,
+		TicketTierID:    tt.TicketTierID,
+	}
+	if seat != nil {
+		eventTicket.SeatID = seat.SeatID
 	}
 
+	return &eventTicket
 }
 
-func (u *Event) start(ctx context.Context, db *sql.DB, et *model.EventTicket, from *algorand.Account, ua algorand.Account, assetID uint64, userID int64) {
+// start hands the minted asset over to the organizer and records the ticket.
+func (u *Event) start(ctx context.Context, et *model.EventTicket, from, ua *algorand.Account) error {
 	ac := algorand.Account{
 		AccountAddress:     ua.AccountAddress,
 		PrivateKey:         ua.PrivateKey,
 		SecurityPassphrase: ua.SecurityPassphrase,
 	}
 
-	err := u.algo.OptIn(ctx, &ac, assetID)
-	if err != nil {
-		logger.Errorf(ctx, "start: error opting in for the asset: ID: %d, err: %+v", assetID, err)
-		return
-	}
-
-	err = u.algo.SendAsset(ctx, from, &ac, assetID)
+	err := u.algo.OptIn(ctx, &ac, et.AssetID)
 	if err != nil {
-		logger.Errorf(ctx, "start: could not transfer asset: ID: %d, err: %+v", assetID, err)
-		return
+		return fmt.Errorf("start: error opting in for the asset: ID: %d, err: %w", et.AssetID, err)
 	}
 
-	err = u.createTicket(db, et, userID)
+	err = u.algo.SendAsset(ctx, from, &ac, et.AssetID)
 	if err != nil {
-		logger.Errorf(ctx, "start: could not insert ticket: ID: %d, err: %+v", assetID, err)
+		return fmt.Errorf("start: could not transfer asset: ID: %d, err: %w", et.AssetID, err)
 	}
-}
 
-func (u *Event) createTicket(db *sql.DB, event *model.EventTicket, sharedBy int64) error {
-	tx, err := db.Begin()
+	et.EventTicketID, err = u.store.Tickets.Create(ctx, et)
 	if err != nil {
-		return fmt.Errorf("createTicket: error begining db transaction: %s", err)
+		return fmt.Errorf("start: could not insert ticket: ID: %d, err: %w", et.AssetID, err)
 	}
 
-	values := []interface{}{
-		event.BusinessUserID,
-		event.PublicEventID,
-		event.AssetID,
-		event.CurrentHolderID,
-		event.Status,
-		event.Price,
-	}
+	return nil
+}
 
-	id, err := create(tx, eventTicketTable, eventTicketCols, values)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("createTicket: error inserting event ticket: err: %w", err)
-	}
-	event.EventTicketID = id
+func (u *Event) fetchUserAddress(userID int64) (*algorand.Account, bool, error) {
+	return u.fetchAddress(fmt.Sprintf("%v", userID))
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("createTicket: error commiting event tickets:err: %w", err)
+// holderAccount returns the account of a ticket holder: the marketplace wallet
+// when one is set and the account of the user otherwise.
+func (u *Event) holderAccount(userID int64, wallet string) (*algorand.Account, bool, error) {
+	if wallet != "" {
+		return u.fetchAddress(wallet)
 	}
-
-	return nil
+	return u.fetchUserAddress(userID)
 }
 
-func (u *Event) fetchUserAddress(userID int64) (*algorand.Account, bool, error) {
-	path := fmt.Sprintf("%s/%v", u.vault.UserPath, userID)
+// fetchAddress reads the account stored at the path below the user path.
+func (u *Event) fetchAddress(addressPath string) (*algorand.Account, bool, error) {
+	path := fmt.Sprintf("%s/%s", u.vault.UserPath, addressPath)
 	secret, err := u.vault.Logical().Read(path)
 	if err != nil {
-		return nil, false, fmt.Errorf("fetchUserAddress: could not fetchUserAddress of user: %d", userID)
+		return nil, false, fmt.Errorf("fetchUserAddress: could not fetchUserAddress of user: %s", addressPath)
+	}
+
+	if secret == nil {
+		return nil, false, nil
 	}
 
 	accountAddress, accountAddressOK := secret.Data[constants.AccountAddress]
@@ -563,146 +543,3 @@ func (u *Event) fetchUserAddress(userID int64) (*algorand.Account, bool, error)
 
 	return &ua, true, nil
 }
-
-func fetchEventTicket(db *sql.DB, eventTicketID int64) (*model.EventTicket, bool, error) {
-	query := fmt.Sprintf(
-		`SELECT event_ticket_id, business_user_id, public_event_id, asset_id, current_holder_id, status,
-				available_to_resell, price FROM Event_Tickets WHERE event_ticket_id = ?`,
-	)
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return nil, false, fmt.Errorf("fetchEventTicket: error preparing query: %w", err)
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(eventTicketID)
-	if err != nil {
-		return nil, false, fmt.Errorf("fetchEventTicket: error executing query: %w", err)
-	}
-
-	var ua model.EventTicket
-	if rows.Next() {
-		err := rows.Scan(
-			&ua.EventTicketID,
-			&ua.BusinessUserID,
-			&ua.PublicEventID,
-			&ua.AssetID,
-			&ua.CurrentHolderID,
-			&ua.Status,
-			&ua.AvailableToResell,
-			&ua.Price,
-		)
-		if err != nil {
-			return nil, false, fmt.Errorf("fetchEventTicket: error while scanning row: %s", err)
-		}
-		return &ua, true, nil
-	}
-	defer rows.Close()
-
-	return nil, false, nil
-}
-
-func pickEventTicket(db *sql.DB, publicEventID int64) (*model.EventTicket, bool, error) {
-	query := fmt.Sprintf(
-		`SELECT event_ticket_id, business_user_id, public_event_id, asset_id, current_holder_id, status,
-				available_to_resell, price FROM Event_Tickets 
-				WHERE business_user_id = current_holder_id AND status=? AND public_event_id = ?`,
-	)
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return nil, false, fmt.Errorf("pickEventTicket: error preparing query: %w", err)
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(active, publicEventID)
-	if err != nil {
-		return nil, false, fmt.Errorf("pickEventTicket: error executing query: %w", err)
-	}
-
-	var ua model.EventTicket
-	if rows.Next() {
-		err := rows.Scan(
-			&ua.EventTicketID,
-			&ua.BusinessUserID,
-			&ua.PublicEventID,
-			&ua.AssetID,
-			&ua.CurrentHolderID,
-			&ua.Status,
-			&ua.AvailableToResell,
-			&ua.Price,
-		)
-		if err != nil {
-			return nil, false, fmt.Errorf("pickEventTicket: error while scanning row: %s", err)
-		}
-		return &ua, true, nil
-	}
-	defer rows.Close()
-
-	return nil, false, nil
-}
-
-func create(tx *sql.Tx, table string, cols []string, values []interface{}) (int64, error) {
-	var params []string
-
-	for range cols {
-		params = append(params, "?")
-	}
-
-	tsql := fmt.Sprintf(`INSERT INTO %s(%s) VALUES (%s);`, table, strings.Join(cols, ", "), strings.Join(params, ", "))
-
-	// Execute query
-	stmt, err := tx.Prepare(tsql)
-	if err != nil {
-		return -1, fmt.Errorf("create: error preparing sql query: %s", err)
-	}
-	defer stmt.Close()
-
-	result, err := stmt.Exec(values...)
-
-	if err != nil {
-		return -1, fmt.Errorf("create: unable to insert record in %s: %s", table, err)
-	}
-
-	return result.LastInsertId()
-
-}
-
-func update(tx *sql.Tx, table string, cols []string, values []interface{}, column []string, value []interface{}) (int64, error) {
-	values = append(values, value...)
-	var set []string
-
-	for _, col := range cols {
-		set = append(set, fmt.Sprintf("%s = ?", col))
-	}
-
-	var params []string
-
-	for range cols {
-		params = append(params, "?")
-	}
-
-	var conds []string
-
-	for _, c := range column {
-		conds = append(conds, fmt.Sprintf("%s = ?", c))
-	}
-
-	tsql := fmt.Sprintf(`UPDATE %s SET %s WHERE  %s;`, table, strings.Join(set, ","), strings.Join(conds, " AND "))
-
-	// Execute query
-	stmt, err := tx.Prepare(tsql)
-	if err != nil {
-		return -1, fmt.Errorf("update: error preparing sql query: %s", err)
-	}
-	defer stmt.Close()
-
-	result, err := stmt.Exec(values...)
-
-	if err != nil {
-		return -1, fmt.Errorf("update: unable to insert record in %s: %s", table, err)
-	}
-
-	return result.RowsAffected()
-}
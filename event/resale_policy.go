@@ -0,0 +1,130 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+)
+
+// bpsScale is 100% in basis points.
+const bpsScale = 10000
+
+func validateResalePolicy(p *model.ResalePolicy) error {
+	if p.RoyaltyBps+p.PlatformFeeBps > bpsScale {
+		return fmt.Errorf("validateResalePolicy: royalty_bps and platform_fee_bps exceed %d", bpsScale)
+	}
+
+	return nil
+}
+
+// checkResale returns an error unless the ticket may be resold at price now,
+// under the resale policy of its event.
+func (u *Event) checkResale(ctx context.Context, et *model.EventTicket, price uint64) error {
+	p, ok, err := u.store.ResalePolicies.Get(ctx, et.PublicEventID)
+	if err != nil {
+		return fmt.Errorf("checkResale: error fetching resale policy: %w", err)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	pe, ok, err := u.store.Events.Get(ctx, et.PublicEventID)
+	if err != nil {
+		return fmt.Errorf("checkResale: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return fmt.Errorf("checkResale: public event: %d not found", et.PublicEventID)
+	}
+
+	face, ok, err := u.store.Tickets.FaceValue(ctx, et.EventTicketID)
+	if err != nil {
+		return fmt.Errorf("checkResale: error fetching face value: %w", err)
+	}
+
+	if !ok {
+		return fmt.Errorf("checkResale: event ticket: %d not found", et.EventTicketID)
+	}
+
+	return allowResale(p, face, price, pe.DateTime, et.TransferCount, time.Now())
+}
+
+// allowResale applies the policy to a ticket of face value that changed hands
+// transfers times, resold at price for an event starting at startsAt.
+func allowResale(p *model.ResalePolicy, face, price uint64, startsAt *time.Time, transfers uint64, now time.Time) error {
+	if p.MaxMarkupBps != nil {
+		limit := face + face*(*p.MaxMarkupBps)/bpsScale
+		if price > limit {
+			return response.InvalidData(fmt.Sprintf("allowResale: price_to_resell: %d is above the resale cap: %d", price, limit))
+		}
+	}
+
+	if startsAt != nil && !now.Before(startsAt.Add(-time.Duration(p.CutoffMinutes)*time.Minute)) {
+		return response.InvalidStateTransition("allowResale: resale of the event has closed")
+	}
+
+	if p.MaxTransfers != nil && transfers >= *p.MaxTransfers {
+		return response.Forbidden(fmt.Sprintf("allowResale: ticket changed hands %d times, the limit is %d", transfers, *p.MaxTransfers))
+	}
+
+	return nil
+}
+
+// splitPayout splits the resale price gross into the royalty of the organizer,
+// the platform fee and what is left for the seller. Shares are rounded down in
+// favour of the seller.
+func splitPayout(p *model.ResalePolicy, gross uint64) (royalty, fee, seller uint64) {
+	if p != nil {
+		royalty = gross * p.RoyaltyBps / bpsScale
+		fee = gross * p.PlatformFeeBps / bpsScale
+	}
+
+	return royalty, fee, gross - royalty - fee
+}
+
+// recordResalePayout records how the price of the resold ticket, or the amount
+// of the accepted offer it was sold for, is split as the move settles, under
+// the resale policy in force at that time.
+func recordResalePayout(ctx context.Context, tx *store.Store, mv *model.TicketMove) error {
+	et, ok, err := tx.Tickets.Get(ctx, mv.EventTicketID)
+	if err != nil {
+		return fmt.Errorf("recordResalePayout: error fetching event ticket: %d: %w", mv.EventTicketID, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("recordResalePayout: event ticket: %d not found", mv.EventTicketID)
+	}
+
+	gross := et.Price
+	if mv.Amount != nil {
+		gross = *mv.Amount
+	}
+
+	p, _, err := tx.ResalePolicies.Get(ctx, et.PublicEventID)
+	if err != nil {
+		return fmt.Errorf("recordResalePayout: error fetching resale policy: %w", err)
+	}
+	royalty, fee, seller := splitPayout(p, gross)
+
+	_, err = tx.Payouts.Create(ctx, &model.ResalePayout{
+		MoveID:          mv.MoveID,
+		EventTicketID:   mv.EventTicketID,
+		PublicEventID:   et.PublicEventID,
+		SellerUserID:    mv.FromUserID,
+		SellerWallet:    mv.FromWallet,
+		OrganizerUserID: et.BusinessUserID,
+		Gross:           gross,
+		Royalty:         royalty,
+		PlatformFee:     fee,
+		SellerAmount:    seller,
+	})
+	if err != nil {
+		return fmt.Errorf("recordResalePayout: error inserting resale payout: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package event
+
+import (
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowResale(t *testing.T) {
+	markup := uint64(2000)
+	transfers := uint64(2)
+	p := &model.ResalePolicy{MaxMarkupBps: &markup, CutoffMinutes: 60, MaxTransfers: &transfers}
+	now := time.Date(2026, 10, 1, 18, 0, 0, 0, time.UTC)
+	startsAt := now.Add(2 * time.Hour)
+
+	assert.Nil(t, allowResale(p, 100, 120, &startsAt, 1, now))
+
+	cases := []struct {
+		name      string
+		price     uint64
+		startsAt  time.Time
+		transfers uint64
+		status    int
+	}{
+		{"above cap", 121, startsAt, 1, http.StatusBadRequest},
+		{"after cutoff", 120, now.Add(30 * time.Minute), 1, http.StatusConflict},
+		{"transfer limit", 120, startsAt, 2, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		err := allowResale(p, 100, c.price, &c.startsAt, c.transfers, now)
+		e, ok := err.(response.ErrorResponse)
+		require.True(t, ok, c.name)
+		assert.Equal(t, c.status, e.StatusCode, c.name)
+	}
+}
+
+func TestSplitPayout(t *testing.T) {
+	royalty, fee, seller := splitPayout(&model.ResalePolicy{RoyaltyBps: 1000, PlatformFeeBps: 250}, 1999)
+	assert.Equal(t, uint64(199), royalty)
+	assert.Equal(t, uint64(49), fee)
+	assert.Equal(t, uint64(1751), seller)
+
+	royalty, fee, seller = splitPayout(nil, 500)
+	assert.Equal(t, []uint64{0, 0, 500}, []uint64{royalty, fee, seller})
+
+	assert.NotNil(t, validateResalePolicy(&model.ResalePolicy{RoyaltyBps: 9000, PlatformFeeBps: 1001}))
+}
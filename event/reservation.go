@@ -0,0 +1,193 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Reservation statuses.
+const (
+	ReservationActive    = model.ReservationActive
+	ReservationCompleted = model.ReservationCompleted
+	ReservationReleased  = model.ReservationReleased
+	ReservationExpired   = model.ReservationExpired
+)
+
+// Reserve claims a ticket of the event for the user, or the marketplace wallet
+// when r.Wallet is set, for ttl. The ticket row is locked with SKIP LOCKED so
+// that concurrent buyers never claim the same ticket, and tickets with an
+// unexpired reservation are left out. Events with tiers are reserved by tier,
+// and the sale window and per-user limit of the tier of the claimed ticket are
+// checked in the same transaction. Tickets of seated events are reserved by
+// seat, and only by the user holding the seat.
+func (u *Event) Reserve(ctx context.Context, client *redis.Client, r *model.Reservation, ttl time.Duration) (*model.Reservation, error) {
+	pe, err := u.ensurePublished(ctx, r.PublicEventID)
+	if err != nil {
+		return nil, response.InvalidStateTransition(err.Error())
+	}
+
+	if pe.VenueID > 0 && r.SeatID == 0 {
+		return nil, response.InvalidData(fmt.Sprintf("reserve: seat_id is required for seated public event: %d", r.PublicEventID))
+	}
+
+	if r.TicketTierID == 0 {
+		tts, err := u.store.Tiers.List(ctx, r.PublicEventID)
+		if err != nil {
+			return nil, fmt.Errorf("reserve: error fetching ticket tiers: %w", err)
+		}
+
+		if len(tts) > 0 {
+			return nil, response.InvalidData(fmt.Sprintf("reserve: ticket_tier_id is required for public event: %d", r.PublicEventID))
+		}
+	}
+
+	if r.SeatID > 0 && r.Wallet != "" {
+		return nil, response.InvalidData("reserve: seats cannot be reserved for marketplace wallets")
+	}
+
+	if r.SeatID > 0 {
+		err = checkSeatHold(client, r.PublicEventID, r.SeatID, r.UserID)
+		if err != nil {
+			return nil, response.Conflict("seat is not held by the user", err.Error())
+		}
+	}
+
+	reservationID, err := newReservationID()
+	if err != nil {
+		return nil, fmt.Errorf("reserve: %w", err)
+	}
+
+	var et *model.EventTicket
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		var ok bool
+		et, ok, err = tx.Tickets.Claim(ctx, r.PublicEventID, r.TicketTierID, r.SeatID, now)
+		if err != nil {
+			return fmt.Errorf("reserve: error claiming event ticket: %w", err)
+		}
+
+		if !ok {
+			return response.Conflict("no ticket available", fmt.Sprintf("reserve: public event: %d", r.PublicEventID))
+		}
+
+		if et.TicketTierID > 0 {
+			err = checkTierPurchase(ctx, tx, r.PublicEventID, et.TicketTierID, r.UserID, r.Wallet, "")
+			if err != nil {
+				return response.Forbidden(err.Error())
+			}
+		}
+
+		err = tx.Reservations.Create(ctx, &model.Reservation{
+			ReservationID: reservationID,
+			EventTicketID: et.EventTicketID,
+			PublicEventID: r.PublicEventID,
+			UserID:        r.UserID,
+			Wallet:        r.Wallet,
+			Status:        ReservationActive,
+			ExpiresAt:     &expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("reserve: error inserting reservation: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof(ctx, "reserve: ticket: %d reserved for user: %d until %s", et.EventTicketID, r.UserID, expiresAt)
+
+	return &model.Reservation{
+		ReservationID: reservationID,
+		EventTicketID: et.EventTicketID,
+		PublicEventID: r.PublicEventID,
+		TicketTierID:  et.TicketTierID,
+		SeatID:        et.SeatID,
+		UserID:        r.UserID,
+		Wallet:        r.Wallet,
+		Status:        ReservationActive,
+		ExpiresAt:     &expiresAt,
+	}, nil
+}
+
+// ReleaseReservation gives up an active reservation of the user before it expires.
+func (u *Event) ReleaseReservation(ctx context.Context, reservationID string, userID int64) error {
+	ok, err := u.store.Reservations.Release(ctx, reservationID, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("releaseReservation: error updating reservation: %w", err)
+	}
+
+	if !ok {
+		return response.ResourceNotFound("reservation not found", fmt.Sprintf("releaseReservation: reservation: %s", reservationID))
+	}
+
+	return nil
+}
+
+// ReleaseExpiredReservations marks expired reservations every interval until
+// ctx is done. Expired reservations already stop blocking their ticket, this
+// only keeps the status of the rows accurate.
+func (u *Event) ReleaseExpiredReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := u.store.Reservations.Expire(ctx, time.Now())
+			if err != nil {
+				logger.Errorf(ctx, "releaseExpiredReservations: %+v", err)
+				continue
+			}
+
+			if n > 0 {
+				logger.Infof(ctx, "releaseExpiredReservations: expired %d reservations", n)
+			}
+		}
+	}
+}
+
+// validReservation returns the reservation when it is active, unexpired and
+// belongs to the user, or the marketplace wallet, and event.
+func (u *Event) validReservation(ctx context.Context, reservationID string, publicEventID, userID int64, wallet string) (*model.Reservation, error) {
+	r, ok, err := u.store.Reservations.Get(ctx, reservationID)
+	if err != nil {
+		return nil, fmt.Errorf("validReservation: error fetching reservation: %w", err)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("validReservation: reservation: %s not found", reservationID)
+	}
+
+	if r.UserID != userID || r.Wallet != wallet || r.PublicEventID != publicEventID {
+		return nil, fmt.Errorf("validReservation: reservation: %s does not belong to user: %d and event: %d", reservationID, userID, publicEventID)
+	}
+
+	if r.Status != ReservationActive || !r.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("validReservation: reservation: %s is no longer active", reservationID)
+	}
+
+	return r, nil
+}
+
+func newReservationID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("newReservationID: error reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,266 @@
+package event
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/seatmap"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	seatAvailable = "AVAILABLE"
+	seatHeld      = "HELD"
+	seatSold      = "SOLD"
+	seatPending   = "PENDING"
+	maxAssetName  = 32
+)
+
+// AttachSeatMap imports a seat map as a new venue and attaches it to a draft
+// public event. Every seat belongs to a tier, seats without one go to the first
+// tier of the event, and the ticket count of each tier becomes its seat count.
+func (u *Event) AttachSeatMap(ctx context.Context, publicEventID, userID int64, f *model.SeatMapFile) (*model.Venue, error) {
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("attachSeatMap: error fetching public event: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("public event not found", fmt.Sprintf("attachSeatMap: public_event_id: %d", publicEventID))
+	}
+
+	if pe.BusinessUserID != userID {
+		return nil, response.Forbidden(fmt.Sprintf("attachSeatMap: user: %d does not own public event: %d", userID, publicEventID))
+	}
+
+	if *pe.Status != Draft {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("attachSeatMap: public event is %s", *pe.Status))
+	}
+
+	seats, err := seatmap.Parse(strings.NewReader(f.Content), f.Format)
+	if err != nil {
+		return nil, response.InvalidData(err.Error())
+	}
+
+	tts, err := u.store.Tiers.List(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("attachSeatMap: error fetching ticket tiers: %w", err)
+	}
+
+	if len(tts) == 0 {
+		return nil, response.InvalidData("attachSeatMap: public event has no ticket tiers")
+	}
+
+	counts := make(map[string]uint64)
+	for _, tt := range tts {
+		counts[*tt.TierName] = 0
+	}
+
+	for i := range seats {
+		if seats[i].Tier == "" {
+			seats[i].Tier = *tts[0].TierName
+		}
+
+		if _, ok := counts[seats[i].Tier]; !ok {
+			return nil, response.InvalidData(fmt.Sprintf("attachSeatMap: seat %s has unknown tier: %s", seats[i].Label(), seats[i].Tier))
+		}
+		counts[seats[i].Tier]++
+	}
+
+	venueName := f.VenueName
+	var venueID int64
+	err = u.store.Tx(ctx, func(tx *store.Store) error {
+		venueID, err = tx.Venues.Create(ctx, venueName, seats)
+		if err != nil {
+			return fmt.Errorf("attachSeatMap: error inserting venue: %w", err)
+		}
+
+		err = tx.Events.SetVenue(ctx, publicEventID, venueID, uint64(len(seats)), Draft)
+		if errors.Is(err, store.ErrNotFound) {
+			return response.InvalidStateTransition("attachSeatMap: public event changed concurrently")
+		}
+
+		if err != nil {
+			return fmt.Errorf("attachSeatMap: error attaching venue: %w", err)
+		}
+
+		for _, tt := range tts {
+			err = tx.Tiers.SetTotal(ctx, tt.TicketTierID, counts[*tt.TierName])
+			if err != nil {
+				return fmt.Errorf("attachSeatMap: error updating tier: %d: %w", tt.TicketTierID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seats, err = u.store.Venues.Seats(ctx, venueID)
+	if err != nil {
+		return nil, fmt.Errorf("attachSeatMap: error fetching seats: %w", err)
+	}
+
+	return &model.Venue{VenueID: venueID, VenueName: &venueName, Seats: seats}, nil
+}
+
+// GetSeatAvailability returns every seat of the event with its status so that
+// clients can render the seat map.
+func (u *Event) GetSeatAvailability(ctx context.Context, client *redis.Client, publicEventID int64) ([]model.Seat, error) {
+	pe, ok, err := u.store.Events.Get(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("getSeatAvailability: error fetching public event: %w", err)
+	}
+
+	if !ok || pe.VenueID == 0 {
+		return nil, response.ResourceNotFound("seat map not found", fmt.Sprintf("getSeatAvailability: public_event_id: %d", publicEventID))
+	}
+
+	seats, err := u.store.Venues.Seats(ctx, pe.VenueID)
+	if err != nil {
+		return nil, fmt.Errorf("getSeatAvailability: error fetching seats: %w", err)
+	}
+
+	ets, err := u.store.Tickets.ListByEvent(ctx, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("getSeatAvailability: %w", err)
+	}
+
+	tickets := make(map[int64]model.EventTicket)
+	for _, et := range ets {
+		if et.SeatID > 0 {
+			tickets[et.SeatID] = et
+		}
+	}
+
+	keys := make([]string, len(seats))
+	for i := range seats {
+		keys[i] = seatHoldKey(publicEventID, seats[i].SeatID)
+	}
+
+	holds, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("getSeatAvailability: error fetching seat holds: %w", err)
+	}
+
+	for i := range seats {
+		et, minted := tickets[seats[i].SeatID]
+		switch {
+		case !minted:
+			seats[i].Status = seatPending
+		case et.CurrentHolderID != et.BusinessUserID || *et.Status != active:
+			seats[i].Status = seatSold
+		case holds[i] != nil:
+			seats[i].Status = seatHeld
+		default:
+			seats[i].Status = seatAvailable
+		}
+		seats[i].EventTicketID = et.EventTicketID
+	}
+
+	return seats, nil
+}
+
+// HoldSeat holds an available seat for the user for ttl while they check out.
+// Holding a seat again before the hold expires extends it.
+func (u *Event) HoldSeat(ctx context.Context, client *redis.Client, publicEventID, seatID, userID int64, ttl time.Duration) (*model.SeatHold, error) {
+	_, err := u.ensurePublished(ctx, publicEventID)
+	if err != nil {
+		return nil, response.InvalidStateTransition(err.Error())
+	}
+
+	_, ok, err := u.store.Tickets.Available(ctx, publicEventID, 0, seatID)
+	if err != nil {
+		return nil, fmt.Errorf("holdSeat: error picking event ticket: %w", err)
+	}
+
+	if !ok {
+		return nil, response.Conflict("seat is not available", fmt.Sprintf("holdSeat: seat: %d of event: %d", seatID, publicEventID))
+	}
+
+	key := seatHoldKey(publicEventID, seatID)
+	held, err := client.SetNX(key, userID, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("holdSeat: error holding seat: %w", err)
+	}
+
+	if !held {
+		holder, err := client.Get(key).Int64()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("holdSeat: error fetching seat hold: %w", err)
+		}
+
+		if holder != userID {
+			return nil, response.Conflict("seat is held by another user", fmt.Sprintf("holdSeat: seat: %d of event: %d", seatID, publicEventID))
+		}
+
+		err = client.Expire(key, ttl).Err()
+		if err != nil {
+			return nil, fmt.Errorf("holdSeat: error extending seat hold: %w", err)
+		}
+	}
+
+	return &model.SeatHold{
+		PublicEventID: publicEventID,
+		SeatID:        seatID,
+		UserID:        userID,
+		ExpiresIn:     int64(ttl.Seconds()),
+	}, nil
+}
+
+// checkSeatHold returns an error unless the seat is currently held by the user.
+func checkSeatHold(client *redis.Client, publicEventID, seatID, userID int64) error {
+	holder, err := client.Get(seatHoldKey(publicEventID, seatID)).Result()
+	if err == redis.Nil {
+		return fmt.Errorf("checkSeatHold: seat: %d is not held", seatID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("checkSeatHold: error fetching seat hold: %w", err)
+	}
+
+	if holder != strconv.FormatInt(userID, 10) {
+		return fmt.Errorf("checkSeatHold: seat: %d is held by another user", seatID)
+	}
+
+	return nil
+}
+
+func seatHoldKey(publicEventID, seatID int64) string {
+	return fmt.Sprintf("seat-hold-%d-%d", publicEventID, seatID)
+}
+
+// seatMetadata describes the seat a ticket is bound to in the metadata of its
+// asset. The note carries the seat as JSON and the metadata hash commits to it.
+func seatMetadata(pe *model.PublicEvent, seat *model.Seat) *algorand.AssetMetadata {
+	note, _ := json.Marshal(map[string]interface{}{
+		"public_event_id": pe.PublicEventID,
+		"seat_id":         seat.SeatID,
+		"section":         seat.Section,
+		"row":             seat.Row,
+		"seat":            seat.Number,
+	})
+	hash := sha256.Sum256(note)
+
+	name := fmt.Sprintf("eventers %s", seat.Label())
+	if len(name) > maxAssetName {
+		name = name[:maxAssetName]
+	}
+
+	return &algorand.AssetMetadata{
+		AssetName:    name,
+		MetadataHash: string(hash[:]),
+		Note:         note,
+	}
+}
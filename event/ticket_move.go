@@ -0,0 +1,416 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+)
+
+// Ticket move states. A move is PENDING until its asset transfer is handed to
+// the chain, CHAIN_SUBMITTED until the receiver is seen holding the asset,
+// CONFIRMED until the database is updated and APPLIED once it is. FAILED moves
+// left the chain and the database as they were before the move.
+const (
+	MovePending        = model.MovePending
+	MoveChainSubmitted = model.MoveChainSubmitted
+	MoveConfirmed      = model.MoveConfirmed
+	MoveApplied        = model.MoveApplied
+	MoveFailed         = model.MoveFailed
+)
+
+// Ticket move kinds.
+const (
+	moveSend      = model.MoveSend
+	moveBuy       = model.MoveBuy
+	moveBuyResell = model.MoveBuyResell
+	moveBridge    = model.MoveBridge
+)
+
+// chainValidity is how long after submission a transfer could still land on
+// chain. Algorand transactions are valid for 1000 rounds.
+const chainValidity = 2 * time.Hour
+
+const maxLastError = 1024
+
+// errTicketChanged is returned from the transaction of applyMove when the
+// ticket is no longer held by the sender as the move expects.
+var errTicketChanged = errors.New("ticket changed during the move")
+
+// moveTicket persists the move of a ticket from one holder to another and drives
+// it to APPLIED. No database transaction is held open while the asset is moved
+// on chain, every step is recorded in Ticket_Move instead so that RecoverMoves
+// can resume the move if the process stops halfway.
+func (u *Event) moveTicket(ctx context.Context, mv *model.TicketMove) error {
+	mv.State = MovePending
+	id, err := u.store.Moves.Create(ctx, mv)
+	if err != nil {
+		return fmt.Errorf("moveTicket: error inserting ticket move: %w", err)
+	}
+	mv.MoveID = id
+
+	err = u.advanceMove(ctx, mv)
+	if err != nil {
+		return fmt.Errorf("moveTicket: move: %d: %w", mv.MoveID, err)
+	}
+
+	switch mv.State {
+	case MoveApplied:
+		return nil
+	case MoveFailed:
+		return fmt.Errorf("moveTicket: move: %d failed: %s", mv.MoveID, *mv.LastError)
+	default:
+		return fmt.Errorf("moveTicket: move: %d is %s and will be recovered", mv.MoveID, mv.State)
+	}
+}
+
+// RecoverMoves resumes moves that have not changed for staleAfter every
+// interval until ctx is done. Moves still running in a request are younger than
+// staleAfter and are left alone.
+func (u *Event) RecoverMoves(ctx context.Context, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mvs, err := u.store.Moves.Stale(ctx, time.Now().Add(-staleAfter))
+			if err != nil {
+				logger.Errorf(ctx, "recoverMoves: %+v", err)
+				continue
+			}
+
+			for i := range mvs {
+				mv := &mvs[i]
+				ok, err := u.claimMove(ctx, mv)
+				if err != nil {
+					logger.Errorf(ctx, "recoverMoves: %+v", err)
+					continue
+				}
+
+				if !ok {
+					continue
+				}
+
+				err = u.advanceMove(ctx, mv)
+				if err != nil {
+					logger.Errorf(ctx, "recoverMoves: move: %d: %+v", mv.MoveID, err)
+					continue
+				}
+
+				logger.Infof(ctx, "recoverMoves: move: %d is %s", mv.MoveID, mv.State)
+			}
+		}
+	}
+}
+
+// advanceMove runs the move from its current state until it is APPLIED, FAILED
+// or cannot make progress yet.
+func (u *Event) advanceMove(ctx context.Context, mv *model.TicketMove) error {
+	for {
+		var err error
+		var done bool
+
+		switch mv.State {
+		case MovePending:
+			err = u.submitMove(ctx, mv)
+		case MoveChainSubmitted:
+			done, err = u.reconcileMove(ctx, mv)
+		case MoveConfirmed:
+			err = u.applyMove(ctx, mv)
+		default:
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("advanceMove: %w", err)
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// submitMove opts the receiver in and transfers the asset. A move that fails
+// before the transfer is handed to the chain is marked FAILED, a transfer whose
+// outcome is unknown stays CHAIN_SUBMITTED for reconcileMove.
+func (u *Event) submitMove(ctx context.Context, mv *model.TicketMove) error {
+	from, ok, err := u.holderAccount(mv.FromUserID, mv.FromWallet)
+	if err != nil || !ok {
+		return u.setMoveState(ctx, mv, MoveFailed, fmt.Sprintf("submitMove: from_user_id: %d wallet: %s not found: %v", mv.FromUserID, mv.FromWallet, err))
+	}
+
+	to, ok, err := u.holderAccount(mv.ToUserID, mv.ToWallet)
+	if err != nil || !ok {
+		return u.setMoveState(ctx, mv, MoveFailed, fmt.Sprintf("submitMove: to_user_id: %d wallet: %s not found: %v", mv.ToUserID, mv.ToWallet, err))
+	}
+
+	err = u.algo.OptIn(ctx, to, mv.AssetID)
+	if err != nil {
+		return u.setMoveState(ctx, mv, MoveFailed, fmt.Sprintf("submitMove: error opting in: %v", err))
+	}
+
+	err = u.setMoveState(ctx, mv, MoveChainSubmitted, "")
+	if err != nil {
+		return fmt.Errorf("submitMove: %w", err)
+	}
+
+	err = u.algo.SendAsset(ctx, from, to, mv.AssetID)
+	if err != nil {
+		logger.Errorf(ctx, "submitMove: move: %d: error sending asset: %+v", mv.MoveID, err)
+		return u.setMoveState(ctx, mv, MoveChainSubmitted, fmt.Sprintf("submitMove: error sending asset: %v", err))
+	}
+
+	return u.setMoveState(ctx, mv, MoveConfirmed, "")
+}
+
+// reconcileMove looks at the chain to settle a submitted transfer. The move is
+// CONFIRMED once the receiver holds the asset and FAILED once the transfer can
+// no longer land. It reports done when neither is known yet.
+func (u *Event) reconcileMove(ctx context.Context, mv *model.TicketMove) (bool, error) {
+	to, ok, err := u.holderAccount(mv.ToUserID, mv.ToWallet)
+	if err != nil || !ok {
+		return false, fmt.Errorf("reconcileMove: to_user_id: %d wallet: %s not found: %v", mv.ToUserID, mv.ToWallet, err)
+	}
+
+	balance, err := u.algo.AssetBalance(ctx, to.AccountAddress, mv.AssetID)
+	if err != nil {
+		return false, fmt.Errorf("reconcileMove: %w", err)
+	}
+
+	if balance > 0 {
+		return false, u.setMoveState(ctx, mv, MoveConfirmed, "")
+	}
+
+	if mv.SubmittedDate == nil || time.Since(*mv.SubmittedDate) > chainValidity {
+		return false, u.setMoveState(ctx, mv, MoveFailed, "reconcileMove: transfer did not land on chain")
+	}
+
+	return true, nil
+}
+
+// applyMove records the new holder of the ticket. If the ticket changed while
+// the asset was moving the transfer is compensated by revoking the asset back
+// to the sender and the move is marked FAILED.
+func (u *Event) applyMove(ctx context.Context, mv *model.TicketMove) error {
+	err := u.store.Tx(ctx, func(tx *store.Store) error {
+		ok, err := tx.Tickets.Move(ctx, mv)
+		if err != nil {
+			return fmt.Errorf("applyMove: error updating event ticket: %w", err)
+		}
+
+		if !ok {
+			return errTicketChanged
+		}
+
+		now := time.Now()
+		if mv.ReservationID != nil {
+			err = tx.Reservations.Complete(ctx, *mv.ReservationID, now)
+			if err != nil {
+				return fmt.Errorf("applyMove: error completing reservation: %w", err)
+			}
+		}
+
+		if changesHands(mv) {
+			err = tx.Tickets.CountTransfer(ctx, mv.EventTicketID)
+			if err != nil {
+				return fmt.Errorf("applyMove: error counting transfer: %w", err)
+			}
+		}
+
+		if mv.Kind == moveBuyResell {
+			err = closeListing(ctx, tx, mv.EventTicketID, ListingSold)
+			if err != nil {
+				return fmt.Errorf("applyMove: %w", err)
+			}
+
+			err = recordResalePayout(ctx, tx, mv)
+			if err != nil {
+				return fmt.Errorf("applyMove: %w", err)
+			}
+		}
+
+		if crossesMarketplaces(mv) {
+			err = recordMarketplaceMove(ctx, tx, mv)
+			if err != nil {
+				return fmt.Errorf("applyMove: %w", err)
+			}
+		}
+
+		ok, err = tx.Moves.SetState(ctx, mv, MoveApplied, mv.LastError, mv.SubmittedDate, now)
+		if err != nil {
+			return fmt.Errorf("applyMove: error updating ticket move: %w", err)
+		}
+
+		if !ok {
+			return fmt.Errorf("applyMove: move: %d is no longer %s at version %d", mv.MoveID, MoveConfirmed, mv.Version)
+		}
+
+		mv.UpdatedDate = &now
+		return nil
+	})
+	if err == errTicketChanged {
+		return u.compensateMove(ctx, mv)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	mv.State = MoveApplied
+	mv.Version++
+	u.publishMove(ctx, mv)
+	return nil
+}
+
+// crossesMarketplaces reports whether the move takes a ticket from one
+// marketplace to another, to or from the app or, for bridges, to the shared
+// wallet. Primary sales are settled with the organizer and are left out.
+func crossesMarketplaces(mv *model.TicketMove) bool {
+	return mv.Kind != moveBuy && mv.FromMarketplaceID != mv.ToMarketplaceID
+}
+
+// changesHands reports whether the move passes the ticket on to someone else
+// after its primary sale. Bridges keep the ticket with the same user.
+func changesHands(mv *model.TicketMove) bool {
+	return mv.Kind == moveSend || mv.Kind == moveBuyResell
+}
+
+// recordMarketplaceMove records the move for partner settlement. Resales carry
+// the amount of the accepted offer, or the price the ticket was listed at.
+func recordMarketplaceMove(ctx context.Context, tx *store.Store, mv *model.TicketMove) error {
+	var amount uint64
+	if mv.Kind == moveBuyResell {
+		et, ok, err := tx.Tickets.Get(ctx, mv.EventTicketID)
+		if err != nil {
+			return fmt.Errorf("recordMarketplaceMove: error fetching event ticket: %d: %w", mv.EventTicketID, err)
+		}
+
+		if !ok {
+			return fmt.Errorf("recordMarketplaceMove: event ticket: %d not found", mv.EventTicketID)
+		}
+
+		amount = et.Price
+		if mv.Amount != nil {
+			amount = *mv.Amount
+		}
+	}
+
+	err := tx.MarketplaceMoves.Record(ctx, &model.MarketplaceTicketMove{
+		MoveID:            mv.MoveID,
+		EventTicketID:     mv.EventTicketID,
+		Kind:              mv.Kind,
+		FromMarketplaceID: mv.FromMarketplaceID,
+		ToMarketplaceID:   mv.ToMarketplaceID,
+		FromWallet:        mv.FromWallet,
+		ToWallet:          mv.ToWallet,
+		Amount:            amount,
+	})
+	if err != nil {
+		return fmt.Errorf("recordMarketplaceMove: error inserting marketplace move: %w", err)
+	}
+
+	return nil
+}
+
+// compensateMove claims the move before revoking the asset, so that a move
+// another worker took over in the meantime is not revoked twice.
+func (u *Event) compensateMove(ctx context.Context, mv *model.TicketMove) error {
+	claimed, err := u.claimMove(ctx, mv)
+	if err != nil {
+		return fmt.Errorf("compensateMove: %w", err)
+	}
+
+	if !claimed {
+		return fmt.Errorf("compensateMove: move: %d was taken over by another worker", mv.MoveID)
+	}
+
+	from, ok, err := u.holderAccount(mv.FromUserID, mv.FromWallet)
+	if err != nil || !ok {
+		return fmt.Errorf("compensateMove: from_user_id: %d wallet: %s not found: %v", mv.FromUserID, mv.FromWallet, err)
+	}
+
+	to, ok, err := u.holderAccount(mv.ToUserID, mv.ToWallet)
+	if err != nil || !ok {
+		return fmt.Errorf("compensateMove: to_user_id: %d wallet: %s not found: %v", mv.ToUserID, mv.ToWallet, err)
+	}
+
+	balance, err := u.algo.AssetBalance(ctx, to.AccountAddress, mv.AssetID)
+	if err != nil {
+		return fmt.Errorf("compensateMove: %w", err)
+	}
+
+	if balance > 0 {
+		err = u.algo.RevokeAsset(ctx, to, from, mv.AssetID)
+		if err != nil {
+			return fmt.Errorf("compensateMove: error revoking asset: %w", err)
+		}
+	}
+
+	logger.Infof(ctx, "compensateMove: move: %d reverted, ticket: %d changed during the move", mv.MoveID, mv.EventTicketID)
+
+	return u.setMoveState(ctx, mv, MoveFailed, "compensateMove: ticket changed during the move, asset returned to sender")
+}
+
+// setMoveState moves mv to state if it is still in the state and at the
+// version mv was read in, so a worker that lost its claim on the move cannot
+// overwrite the worker that took it over. Entering CHAIN_SUBMITTED records
+// when the transfer was handed to the chain.
+func (u *Event) setMoveState(ctx context.Context, mv *model.TicketMove, state, lastError string) error {
+	if len(lastError) > maxLastError {
+		lastError = lastError[:maxLastError]
+	}
+
+	var lastErr *string
+	if lastError != "" {
+		lastErr = &lastError
+	}
+
+	now := time.Now()
+	submittedDate := mv.SubmittedDate
+	if state == MoveChainSubmitted && mv.State != MoveChainSubmitted {
+		submittedDate = &now
+	}
+
+	ok, err := u.store.Moves.SetState(ctx, mv, state, lastErr, submittedDate, now)
+	if err != nil {
+		return fmt.Errorf("setMoveState: error updating ticket move: %w", err)
+	}
+
+	if !ok {
+		return fmt.Errorf("setMoveState: move: %d is no longer %s at version %d", mv.MoveID, mv.State, mv.Version)
+	}
+
+	mv.State = state
+	mv.Version++
+	mv.SubmittedDate = submittedDate
+	mv.UpdatedDate = &now
+	mv.LastError = lastErr
+	return nil
+}
+
+// claimMove takes the move over from whichever worker ran it before. Only one
+// worker wins the claim as it bumps the version of the row it read, which
+// fences off every later transition of the previous owner.
+func (u *Event) claimMove(ctx context.Context, mv *model.TicketMove) (bool, error) {
+	now := time.Now()
+	ok, err := u.store.Moves.Claim(ctx, mv, now)
+	if err != nil {
+		return false, fmt.Errorf("claimMove: error updating ticket move: %w", err)
+	}
+
+	if !ok {
+		return false, nil
+	}
+
+	mv.Attempts++
+	mv.Version++
+	mv.UpdatedDate = &now
+	return true, nil
+}
@@ -0,0 +1,282 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/vault"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAlgo holds asset balances in memory. A set sendErr fails SendAsset
+// without moving the asset, the way a transfer whose outcome is unknown does.
+type fakeAlgo struct {
+	algorand.Algo
+	mu       sync.Mutex
+	balances map[string]uint64
+	optInErr error
+	sendErr  error
+	onSend   func()
+	sent     int
+	revoked  int
+}
+
+func (f *fakeAlgo) OptIn(context.Context, *algorand.Account, uint64) error {
+	return f.optInErr
+}
+
+func (f *fakeAlgo) SendAsset(_ context.Context, from, to *algorand.Account, _ uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent++
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+
+	f.balances[from.AccountAddress]--
+	f.balances[to.AccountAddress]++
+	if f.onSend != nil {
+		f.onSend()
+	}
+	return nil
+}
+
+func (f *fakeAlgo) RevokeAsset(_ context.Context, from, to *algorand.Account, _ uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.revoked++
+	f.balances[from.AccountAddress]--
+	f.balances[to.AccountAddress]++
+	return nil
+}
+
+func (f *fakeAlgo) AssetBalance(_ context.Context, address string, _ uint64) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.balances[address], nil
+}
+
+func (f *fakeAlgo) balance(address string) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.balances[address]
+}
+
+// fakeVault serves the account of each user, at the address ADDRESS<id>.
+func fakeVault(t *testing.T, userIDs ...string) vault.Vault {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v1/users/")
+		for _, userID := range userIDs {
+			if id == userID {
+				json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+					constants.AccountAddress:     "ADDRESS" + id,
+					constants.PrivateKey:         "key" + id,
+					constants.SecurityPassphrase: "passphrase" + id,
+				}})
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.Nil(t, err)
+	return vault.Vault{UserPath: "users", Client: client}
+}
+
+// newMoveTest returns an event service whose store holds the active ticket 1
+// of the organizer 3, along with the store and the chain the organizer holds
+// the asset of the ticket on.
+func newMoveTest(t *testing.T) (*Event, *store.Memory, *fakeAlgo) {
+	mem := store.NewMemory()
+	status := active
+	mem.PutEventTicket(model.EventTicket{EventTicketID: 1, BusinessUserID: 3, PublicEventID: 7, AssetID: 100, CurrentHolderID: 3, Status: &status, Price: 50})
+
+	algo := &fakeAlgo{balances: map[string]uint64{"ADDRESS3": 1}}
+	return NewEvent(algo, fakeVault(t, "3", "5"), mem.Store(), nil, nil), mem, algo
+}
+
+// sendMove sends the ticket 1 from the organizer 3 to the user 5.
+func sendMove() *model.TicketMove {
+	return &model.TicketMove{
+		Kind:           moveSend,
+		EventTicketID:  1,
+		AssetID:        100,
+		FromUserID:     3,
+		ToUserID:       5,
+		ExpectedStatus: active,
+		NewStatus:      active,
+	}
+}
+
+func latestMove(t *testing.T, u *Event) *model.TicketMove {
+	mv, ok, err := u.store.Moves.Latest(context.Background(), 1)
+	require.Nil(t, err)
+	require.True(t, ok)
+	return mv
+}
+
+func holder(t *testing.T, u *Event) int64 {
+	et, ok, err := u.store.Tickets.Get(context.Background(), 1)
+	require.Nil(t, err)
+	require.True(t, ok)
+	return et.CurrentHolderID
+}
+
+func TestMoveTicketApplied(t *testing.T) {
+	u, _, algo := newMoveTest(t)
+
+	err := u.moveTicket(context.Background(), sendMove())
+	require.Nil(t, err)
+
+	mv := latestMove(t, u)
+	assert.Equal(t, MoveApplied, mv.State)
+	assert.NotNil(t, mv.SubmittedDate)
+	assert.Equal(t, int64(5), holder(t, u))
+	assert.Equal(t, uint64(1), algo.balance("ADDRESS5"))
+}
+
+func TestMoveTicketPendingToFailed(t *testing.T) {
+	u, _, algo := newMoveTest(t)
+	algo.optInErr = errors.New("opt-in rejected")
+
+	err := u.moveTicket(context.Background(), sendMove())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed")
+
+	mv := latestMove(t, u)
+	assert.Equal(t, MoveFailed, mv.State)
+	require.NotNil(t, mv.LastError)
+	assert.Contains(t, *mv.LastError, "opt-in rejected")
+	assert.Nil(t, mv.SubmittedDate)
+	assert.Equal(t, 0, algo.sent)
+	assert.Equal(t, int64(3), holder(t, u))
+}
+
+func TestMoveTicketRecoveredFromChainSubmitted(t *testing.T) {
+	u, _, algo := newMoveTest(t)
+	ctx := context.Background()
+
+	// The process loses track of the transfer after handing it to the chain.
+	algo.sendErr = errors.New("connection reset")
+	err := u.moveTicket(ctx, sendMove())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "will be recovered")
+
+	mv := latestMove(t, u)
+	assert.Equal(t, MoveChainSubmitted, mv.State)
+	assert.NotNil(t, mv.SubmittedDate)
+	assert.Equal(t, int64(3), holder(t, u))
+
+	// The transfer landed after all. Recovery sees it on chain and applies the
+	// move without sending the asset again.
+	algo.balances["ADDRESS3"], algo.balances["ADDRESS5"] = 0, 1
+	r, err := u.ReconcileTicket(ctx, 1, 0)
+	require.Nil(t, err)
+	assert.True(t, r.InSync)
+	assert.Equal(t, MoveApplied, latestMove(t, u).State)
+	assert.Equal(t, int64(5), holder(t, u))
+	assert.Equal(t, 1, algo.sent)
+}
+
+func TestMoveTicketChainSubmittedExpires(t *testing.T) {
+	u, _, algo := newMoveTest(t)
+	ctx := context.Background()
+
+	algo.sendErr = errors.New("connection reset")
+	require.NotNil(t, u.moveTicket(ctx, sendMove()))
+
+	// The transfer never landed and can no longer land.
+	mv := latestMove(t, u)
+	submitted := time.Now().Add(-chainValidity - time.Minute)
+	ok, err := u.store.Moves.SetState(ctx, mv, MoveChainSubmitted, mv.LastError, &submitted, time.Now())
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	_, err = u.ReconcileTicket(ctx, 1, 0)
+	require.Nil(t, err)
+
+	mv = latestMove(t, u)
+	assert.Equal(t, MoveFailed, mv.State)
+	assert.Equal(t, int64(3), holder(t, u))
+}
+
+func TestMoveStaleVersion(t *testing.T) {
+	u, _, algo := newMoveTest(t)
+	ctx := context.Background()
+
+	mv := sendMove()
+	mv.State = MovePending
+	id, err := u.store.Moves.Create(ctx, mv)
+	require.Nil(t, err)
+
+	// Two workers read the move, the second one claims it.
+	first, second := latestMove(t, u), latestMove(t, u)
+	require.Equal(t, id, first.MoveID)
+	claimed, err := u.claimMove(ctx, second)
+	require.Nil(t, err)
+	require.True(t, claimed)
+
+	// The first worker is fenced off: it can neither claim the move nor change
+	// its state.
+	claimed, err = u.claimMove(ctx, first)
+	require.Nil(t, err)
+	assert.False(t, claimed)
+
+	err = u.setMoveState(ctx, first, MoveFailed, "stale worker")
+	assert.NotNil(t, err)
+
+	err = u.advanceMove(ctx, first)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, algo.sent)
+
+	stored := latestMove(t, u)
+	assert.Equal(t, MovePending, stored.State)
+	assert.Nil(t, stored.LastError)
+
+	err = u.advanceMove(ctx, second)
+	require.Nil(t, err)
+	assert.Equal(t, MoveApplied, latestMove(t, u).State)
+	assert.Equal(t, int64(5), holder(t, u))
+}
+
+func TestMoveTicketCompensatedByRevoke(t *testing.T) {
+	u, mem, algo := newMoveTest(t)
+
+	// The ticket is cancelled while its asset is moving.
+	status := cancelled
+	algo.onSend = func() {
+		mem.PutEventTicket(model.EventTicket{EventTicketID: 1, BusinessUserID: 3, PublicEventID: 7, AssetID: 100, CurrentHolderID: 3, Status: &status})
+	}
+
+	err := u.moveTicket(context.Background(), sendMove())
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed")
+
+	mv := latestMove(t, u)
+	assert.Equal(t, MoveFailed, mv.State)
+	require.NotNil(t, mv.LastError)
+	assert.Contains(t, *mv.LastError, "asset returned to sender")
+	assert.Equal(t, 1, algo.revoked)
+	assert.Equal(t, uint64(1), algo.balance("ADDRESS3"))
+	assert.Equal(t, uint64(0), algo.balance("ADDRESS5"))
+	assert.Equal(t, int64(3), holder(t, u))
+}
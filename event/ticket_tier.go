@@ -0,0 +1,101 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"time"
+)
+
+const generalTier = "General"
+
+// normalizeTiers makes sure the public event has at least one tier and keeps
+// total_tickets and ticket_price of the event in line with its tiers. An event
+// created without tiers gets a single general tier.
+func normalizeTiers(pe *model.PublicEvent) error {
+	if len(pe.TicketTiers) == 0 {
+		name := generalTier
+		pe.TicketTiers = []model.TicketTier{{
+			TierName:     &name,
+			TotalTickets: pe.TotalTickets,
+			TicketPrice:  pe.TicketPrice,
+		}}
+	}
+
+	var total, price uint64
+	for i, tt := range pe.TicketTiers {
+		if tt.TierName == nil || *tt.TierName == "" {
+			return fmt.Errorf("normalizeTiers: tier %d has no name", i)
+		}
+
+		if tt.SaleStart != nil && tt.SaleEnd != nil && !tt.SaleStart.Before(*tt.SaleEnd) {
+			return fmt.Errorf("normalizeTiers: tier %s sale window is invalid", *tt.TierName)
+		}
+
+		total += tt.TotalTickets
+		if i == 0 || tt.TicketPrice < price {
+			price = tt.TicketPrice
+		}
+	}
+
+	pe.TotalTickets = total
+	pe.TicketPrice = price
+	return nil
+}
+
+// tierAvailability returns the tiers of the events along with the number of
+// tickets the organizer still holds, keyed by public_event_id.
+func (u *Event) tierAvailability(ctx context.Context, pes []model.PublicEvent) (map[int64][]model.TicketTier, error) {
+	ids := make([]int64, len(pes))
+	for i := range pes {
+		ids[i] = pes[i].PublicEventID
+	}
+
+	tiers, err := u.store.Tiers.Availability(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("tierAvailability: error fetching ticket tiers: %w", err)
+	}
+
+	return tiers, nil
+}
+
+// checkTierPurchase returns an error when the tier is outside its sale window
+// or the user, or the marketplace wallet, already holds or has reserved as many
+// tickets of the tier as allowed. The tier row stays locked until tx ends, so
+// concurrent purchases of the tier are counted one after the other. The
+// reservation being replaced, if any, is left out of the count.
+func checkTierPurchase(ctx context.Context, tx *store.Store, publicEventID, ticketTierID, userID int64, wallet, reservationID string) error {
+	tier, ok, err := tx.Tiers.Lock(ctx, publicEventID, ticketTierID)
+	if err != nil {
+		return fmt.Errorf("checkTierPurchase: error locking tier: %w", err)
+	}
+
+	if !ok {
+		return fmt.Errorf("checkTierPurchase: tier: %d not found for event: %d", ticketTierID, publicEventID)
+	}
+
+	now := time.Now()
+	if tier.SaleStart != nil && now.Before(*tier.SaleStart) {
+		return fmt.Errorf("checkTierPurchase: sale of tier: %d has not started", ticketTierID)
+	}
+
+	if tier.SaleEnd != nil && now.After(*tier.SaleEnd) {
+		return fmt.Errorf("checkTierPurchase: sale of tier: %d has ended", ticketTierID)
+	}
+
+	if tier.PerUserLimit == 0 {
+		return nil
+	}
+
+	held, err := tx.Tiers.Purchases(ctx, ticketTierID, userID, wallet, reservationID, now)
+	if err != nil {
+		return fmt.Errorf("checkTierPurchase: error counting held tickets: %w", err)
+	}
+
+	if held >= tier.PerUserLimit {
+		return fmt.Errorf("checkTierPurchase: user: %d reached the limit of tier: %d", userID, ticketTierID)
+	}
+
+	return nil
+}
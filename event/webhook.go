@@ -0,0 +1,60 @@
+package event
+
+import (
+	"context"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/webhook"
+)
+
+// moveEvents are the webhooks fired once a move of the kind is applied.
+var moveEvents = map[string]string{
+	moveBuy:       webhook.TicketBought,
+	moveBuyResell: webhook.TicketResold,
+	moveSend:      webhook.TicketTransferred,
+	moveBridge:    webhook.TicketBridged,
+}
+
+// publish enqueues a webhook for the marketplaces, every subscribed one when
+// none are given. The change it reports is already made, so failures are only
+// logged.
+func (u *Event) publish(ctx context.Context, eventType string, data interface{}, marketplaceIDs ...int64) {
+	if u.hooks == nil {
+		return
+	}
+
+	err := u.hooks.Publish(ctx, eventType, data, marketplaceIDs...)
+	if err != nil {
+		logger.Errorf(ctx, "publish: %s: %+v", eventType, err)
+	}
+}
+
+// publishTicket enqueues a webhook about the ticket for the marketplace holding
+// it and the marketplaces of marketplaceIDs. The holder wallet is left out as it
+// names the phone number of the holder.
+func (u *Event) publishTicket(ctx context.Context, eventType string, eventTicketID int64, marketplaceIDs ...int64) {
+	if u.hooks == nil {
+		return
+	}
+
+	et, ok, err := u.store.Tickets.Get(ctx, eventTicketID)
+	if err != nil || !ok {
+		logger.Errorf(ctx, "publishTicket: %s: event ticket: %d not found: %v", eventType, eventTicketID, err)
+		return
+	}
+
+	data := *et
+	data.HolderWallet = ""
+	u.publish(ctx, eventType, &data, append(marketplaceIDs, et.HolderMarketplaceID)...)
+}
+
+// publishMove enqueues the webhook of an applied move for the marketplaces it
+// moved the ticket between.
+func (u *Event) publishMove(ctx context.Context, mv *model.TicketMove) {
+	eventType, ok := moveEvents[mv.Kind]
+	if !ok {
+		return
+	}
+
+	u.publishTicket(ctx, eventType, mv.EventTicketID, mv.FromMarketplaceID, mv.ToMarketplaceID)
+}
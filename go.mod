@@ -1,6 +1,6 @@
 module eventers-marketplace-backend
 
-go 1.14
+go 1.16
 
 require (
 	firebase.google.com/go v3.13.0+incompatible
@@ -11,9 +11,9 @@ require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gorilla/mux v1.8.0
 	github.com/hashicorp/vault/api v1.0.4
-	github.com/pquerna/otp v1.2.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 	google.golang.org/api v0.13.0
 )
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/factory"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func CreateAuction(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		publicEventID, ok := publicEventIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := auctionRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		if req.Data.Auction == nil {
+			response.InvalidData("createAuction: auction is required").Send(ctx, w)
+			return
+		}
+		req.Data.Auction.PublicEventID = publicEventID
+
+		a, err := service.CreateAuction(ctx, req.Data.Auction, userID)
+		if err != nil {
+			sendAppError(ctx, w, "createAuction: unable to create auction", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Auction: a, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func GetEventAuctions(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		publicEventID, ok := publicEventIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		as, err := service.EventAuctions(ctx, publicEventID)
+		if err != nil {
+			sendAppError(ctx, w, "getEventAuctions: unable to list auctions", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Auctions: as},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func GetAuction(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		auctionID, ok := auctionIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		a, err := service.GetAuction(ctx, auctionID)
+		if err != nil {
+			sendAppError(ctx, w, "getAuction: unable to get auction", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Auction: a},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func PlaceBid(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		auctionID, ok := auctionIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := auctionRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		bid, err := service.PlaceBid(ctx, auctionID, &model.Buyer{UserID: userID}, bidAmount(req))
+		if err != nil {
+			sendAppError(ctx, w, "placeBid: unable to place bid", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{AuctionBid: bid, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func PlaceMarketplaceBid(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		auctionID, ok := auctionIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := auctionRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, b, ok := sessionBuyer(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		bid, err := service.PlaceBid(ctx, auctionID, b, bidAmount(req))
+		if err != nil {
+			sendMarketplaceError(ctx, w, "placeMarketplaceBid: unable to place bid", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{AuctionBid: bid},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func auctionRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.AuctionRequest, bool) {
+	var req model.AuctionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		response.BadRequest("invalid request body", fmt.Sprintf("auctionRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func bidAmount(req *model.AuctionRequest) int64 {
+	if req.Data.Bid == nil {
+		return 0
+	}
+	return int64(req.Data.Bid.Amount)
+}
+
+func auctionIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	auctionIDString := mux.Vars(r)["auctionID"]
+
+	auctionID, err := strconv.ParseInt(auctionIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid auction id: %v", auctionIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return auctionID, true
+}
+
+func publicEventIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	publicEventIDString := mux.Vars(r)["publicEventID"]
+
+	publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return publicEventID, true
+}
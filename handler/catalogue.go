@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"eventers-marketplace-backend/model"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCatalogueLimit = 20
+	maxCatalogueLimit     = 100
+)
+
+// publicEventFilter reads the catalogue filter from the query parameters:
+// from and to as RFC 3339 times, q, min_price, max_price, available, sort,
+// cursor and limit.
+func publicEventFilter(v url.Values) (*model.PublicEventFilter, error) {
+	f := model.PublicEventFilter{
+		Search: v.Get("q"),
+		Sort:   v.Get("sort"),
+		Cursor: v.Get("cursor"),
+		Limit:  defaultCatalogueLimit,
+	}
+
+	var err error
+	f.From, err = timeParam(v, "from")
+	if err != nil {
+		return nil, err
+	}
+
+	f.To, err = timeParam(v, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	f.MinPrice, err = priceParam(v, "min_price")
+	if err != nil {
+		return nil, err
+	}
+
+	f.MaxPrice, err = priceParam(v, "max_price")
+	if err != nil {
+		return nil, err
+	}
+
+	if a := v.Get("available"); a != "" {
+		available, err := strconv.ParseBool(a)
+		if err != nil {
+			return nil, fmt.Errorf("publicEventFilter: invalid available: %s", a)
+		}
+		f.Available = &available
+	}
+
+	if l := v.Get("limit"); l != "" {
+		f.Limit, err = strconv.Atoi(l)
+		if err != nil || f.Limit <= 0 || f.Limit > maxCatalogueLimit {
+			return nil, fmt.Errorf("publicEventFilter: invalid limit: %s, at most %d", l, maxCatalogueLimit)
+		}
+	}
+
+	return &f, nil
+}
+
+func timeParam(v url.Values, name string) (*time.Time, error) {
+	s := v.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("timeParam: invalid %s: %s", name, s)
+	}
+
+	return &t, nil
+}
+
+func priceParam(v url.Values, name string) (*uint64, error) {
+	s := v.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+
+	p, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("priceParam: invalid %s: %s", name, s)
+	}
+
+	return &p, nil
+}
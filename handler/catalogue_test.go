@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"eventers-marketplace-backend/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogueRoute(t *testing.T) {
+	token := testTokens(t)
+	service, _ := appService(false)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/public_event", GetCatalogue(service, testFactory{})).Methods(http.MethodGet)
+
+	send := func(query, authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/public_event"+query, nil)
+		if authorization != "" {
+			req.Header.Set(middleware.AuthorizationHeader, authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("", ""))
+	assert.Equal(t, http.StatusUnauthorized, send("", token("uid-5")))
+	assert.Equal(t, http.StatusUnauthorized, send("", middleware.BearerPrefix+"not a token"))
+
+	// The query parameters are only read once the token is verified.
+	assert.Equal(t, http.StatusBadRequest, send("?limit=0", middleware.BearerPrefix+token("uid-5")))
+	assert.Equal(t, http.StatusBadRequest, send("?sort=title", middleware.BearerPrefix+token("uid-5")))
+
+	// A valid request reaches the catalogue.
+	assert.Equal(t, http.StatusOK, send("?sort=-price&limit=5", middleware.BearerPrefix+token("uid-5")))
+}
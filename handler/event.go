@@ -1,19 +1,24 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"eventers-marketplace-backend/config"
 	"eventers-marketplace-backend/event"
 	"eventers-marketplace-backend/factory"
 	"eventers-marketplace-backend/firebase"
 	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/middleware"
 	"eventers-marketplace-backend/model"
 	"eventers-marketplace-backend/response"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
 	"github.com/spf13/viper"
 )
@@ -36,11 +41,15 @@ func PublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
 			return
 		}
 
-		publicEvent, err := service.PublicEvent(ctx, f.DB(ctx), req.Data.PublicEvent, req.Data.Auth.UserID)
+		publicEvent, err := service.PublicEvent(ctx, req.Data.PublicEvent, req.Data.Auth.UserID)
 
 		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
 			response.SomethingWrong().Send(ctx, w)
-			logger.Errorf(ctx, "publicEvent: unable to create public event: %w", err)
+			logger.Errorf(ctx, "publicEvent: unable to create public event: %+v", err)
 			return
 		}
 
@@ -52,7 +61,7 @@ func PublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
 	}
 }
 
-func UpdatePublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
+func UpdatePublicEvent(service *event.Event, f factory.Factory, client *redis.Client, listingTTL time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -70,50 +79,165 @@ func UpdatePublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc
 			return
 		}
 
-		err = service.UpdatePublicEvent(ctx, f.DB(ctx), req.Data.Ticket)
+		l, err := service.UpdatePublicEvent(ctx, client, req.Data.Ticket, listingTTL)
 
 		if err != nil {
+			var e response.ErrorResponse
+			if errors.As(err, &e) {
+				e.Send(ctx, w)
+				return
+			}
 			response.SomethingWrong().Send(ctx, w)
-			logger.Errorf(ctx, "updatePublicEvent: unable to update public event: %w", err)
+			logger.Errorf(ctx, "updatePublicEvent: unable to update public event: %+v", err)
 			return
 		}
 
 		auth := &model.Auth{PushKey: req.Data.Auth.PushKey}
 		response.SuccessResponse{
-			Data:       &response.Data{Auth: auth},
+			Data:       &response.Data{ResaleListing: l, Auth: auth},
 			StatusCode: http.StatusOK,
 		}.Send(w)
 	}
 }
 
-func GetPublicEvents(service *event.Event, f factory.Factory) http.HandlerFunc {
+func EditPublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		publicEventIDString := mux.Vars(r)["publicEventID"]
 
-		var req model.PublicEventUpdateReq
-		err := json.NewDecoder(r.Body).Decode(&req)
+		publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
 		if err != nil {
-			logger.Errorf(ctx, "createUser: error unmarshalling request body: %+v", err)
+			response.InvalidData(fmt.Sprintf("editPublicEvent: invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+			return
+		}
+
+		var req model.PublicEventRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.PublicEvent == nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "editPublicEvent: error unmarshalling request body: %+v", err)
 			response.BadRequest("invalid request body", "").Send(ctx, w)
 			return
 		}
 
-		_, ok := firebase.VerifyJWTIDToken(viper.GetString(config.FirebaseProjectID), req.Data.Auth.TokenID, time.Duration(viper.GetInt(config.JWTOfflineInterval)))
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
 		if !ok {
-			response.Unauthorized().Send(ctx, w)
 			return
 		}
 
-		publicEvents, err := service.GetPublicEvents(f.DB(ctx))
+		req.Data.PublicEvent.PublicEventID = publicEventID
+		publicEvent, err := service.EditPublicEvent(ctx, req.Data.PublicEvent, userID)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "editPublicEvent: unable to edit public event: %+v", err)
+			return
+		}
+
+		auth := &model.Auth{PushKey: req.Data.Auth.PushKey}
+		response.SuccessResponse{
+			Data:       &response.Data{PublicEvent: publicEvent, Auth: auth},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// appUser verifies the firebase token of the request and returns the id of the
+// app user it was issued to. Ids sent by the client are never trusted.
+func appUser(ctx context.Context, w http.ResponseWriter, service *event.Event, auth *model.Auth) (int64, bool) {
+	uid, ok := firebase.VerifyJWTIDToken(auth.TokenID, viper.GetString(config.FirebaseProjectID), time.Duration(viper.GetInt(config.JWTOfflineInterval)))
+	if !ok {
+		response.Unauthorized().Send(ctx, w)
+		return 0, false
+	}
+
+	userID, err := service.AppUserID(ctx, uid)
+	if err != nil {
+		sendAppError(ctx, w, "appUser: unable to get user", err)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func DeletePublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		publicEventIDString := mux.Vars(r)["publicEventID"]
+
+		publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("deletePublicEvent: invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+			return
+		}
+
+		var req model.PublicEventRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "deletePublicEvent: error unmarshalling request body: %+v", err)
+			response.BadRequest("invalid request body", "").Send(ctx, w)
+			return
+		}
+
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
 
+		err = service.DeletePublicEvent(ctx, publicEventID, userID)
 		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
 			response.SomethingWrong().Send(ctx, w)
-			logger.Errorf(ctx, "getPublicEvents: unable to get public events: %w", err)
+			logger.Errorf(ctx, "deletePublicEvent: unable to delete public event: %+v", err)
 			return
 		}
 
+		auth := &model.Auth{PushKey: req.Data.Auth.PushKey}
 		response.SuccessResponse{
-			Data:       publicEvents,
+			Data:       &response.Data{Auth: auth},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// GetCatalogue serves a page of the published events, see publicEventFilter
+// for the query parameters. The app authenticates with its ID token as a
+// bearer token, GET requests carry no body.
+func GetCatalogue(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		authorization := r.Header.Get(middleware.AuthorizationHeader)
+		if !strings.HasPrefix(authorization, middleware.BearerPrefix) {
+			response.Unauthorized().Send(ctx, w)
+			return
+		}
+
+		_, ok := firebase.VerifyJWTIDToken(strings.TrimPrefix(authorization, middleware.BearerPrefix), viper.GetString(config.FirebaseProjectID), time.Duration(viper.GetInt(config.JWTOfflineInterval)))
+		if !ok {
+			response.Unauthorized().Send(ctx, w)
+			return
+		}
+
+		filter, err := publicEventFilter(r.URL.Query())
+		if err != nil {
+			response.InvalidData(err.Error()).Send(ctx, w)
+			return
+		}
+
+		page, err := service.GetCatalogue(ctx, filter)
+		if err != nil {
+			sendAppError(ctx, w, "getCatalogue: unable to get public events", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       page,
 			StatusCode: http.StatusOK,
 		}.Send(w)
 	}
@@ -128,15 +252,15 @@ func GetPublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
 		userID, err := strconv.ParseInt(userIDString, 10, 64)
 		if err != nil {
 			response.InvalidData(fmt.Sprintf("getPublicEvent: invalid user id: %v", userIDString))
-			logger.Errorf(ctx, "getPublicEvent: unable to parse userID: %s: %w", userIDString, err)
+			logger.Errorf(ctx, "getPublicEvent: unable to parse userID: %s: %+v", userIDString, err)
 			return
 		}
 
-		publicEvents, err := service.GetPublicEvent(f.DB(ctx), userID)
+		publicEvents, err := service.GetPublicEvent(ctx, userID)
 
 		if err != nil {
 			response.SomethingWrong().Send(ctx, w)
-			logger.Errorf(ctx, "getPublicEvent: unable to get public event: %w", err)
+			logger.Errorf(ctx, "getPublicEvent: unable to get public event: %+v", err)
 			return
 		}
 
@@ -146,3 +270,209 @@ func GetPublicEvent(service *event.Event, f factory.Factory) http.HandlerFunc {
 		}.Send(w)
 	}
 }
+
+func AttachSeatMap(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		publicEventIDString := mux.Vars(r)["publicEventID"]
+
+		publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("attachSeatMap: invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+			return
+		}
+
+		var req model.SeatMapRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.SeatMap == nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "attachSeatMap: error unmarshalling request body: %+v", err)
+			response.BadRequest("invalid request body", "").Send(ctx, w)
+			return
+		}
+
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		venue, err := service.AttachSeatMap(ctx, publicEventID, userID, req.Data.SeatMap)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "attachSeatMap: unable to attach seat map: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Venue: venue},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func GetSeats(service *event.Event, f factory.Factory, client *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		publicEventIDString := mux.Vars(r)["publicEventID"]
+
+		publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("getSeats: invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+			return
+		}
+
+		seats, err := service.GetSeatAvailability(ctx, client, publicEventID)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "getSeats: unable to get seats: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       seats,
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func HoldSeat(service *event.Event, f factory.Factory, client *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		vars := mux.Vars(r)
+
+		publicEventID, err := strconv.ParseInt(vars["publicEventID"], 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("holdSeat: invalid public event id: %v", vars["publicEventID"])).Send(ctx, w)
+			return
+		}
+
+		seatID, err := strconv.ParseInt(vars["seatID"], 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("holdSeat: invalid seat id: %v", vars["seatID"])).Send(ctx, w)
+			return
+		}
+
+		var req model.SeatHoldRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "holdSeat: error unmarshalling request body: %+v", err)
+			response.BadRequest("invalid request body", "").Send(ctx, w)
+			return
+		}
+
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		ttl := time.Duration(viper.GetInt(config.SeatHoldTTL)) * time.Second
+		hold, err := service.HoldSeat(ctx, client, publicEventID, seatID, userID, ttl)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "holdSeat: unable to hold seat: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{SeatHold: hold},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func CreateReservation(service *event.Event, f factory.Factory, client *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		publicEventIDString := mux.Vars(r)["publicEventID"]
+
+		publicEventID, err := strconv.ParseInt(publicEventIDString, 10, 64)
+		if err != nil {
+			response.InvalidData(fmt.Sprintf("createReservation: invalid public event id: %v", publicEventIDString)).Send(ctx, w)
+			return
+		}
+
+		var req model.ReservationRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "createReservation: error unmarshalling request body: %+v", err)
+			response.BadRequest("invalid request body", "").Send(ctx, w)
+			return
+		}
+
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		reservation := req.Data.Reservation
+		if reservation == nil {
+			reservation = &model.Reservation{}
+		}
+		reservation.PublicEventID = publicEventID
+		reservation.UserID = userID
+
+		ttl := time.Duration(viper.GetInt(config.ReservationTTL)) * time.Second
+		reservation, err = service.Reserve(ctx, client, reservation, ttl)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "createReservation: unable to reserve ticket: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Reservation: reservation},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func DeleteReservation(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		reservationID := mux.Vars(r)["reservationID"]
+
+		var req model.ReservationRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Auth == nil {
+			logger.Errorf(ctx, "deleteReservation: error unmarshalling request body: %+v", err)
+			response.BadRequest("invalid request body", "").Send(ctx, w)
+			return
+		}
+
+		userID, ok := appUser(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		err = service.ReleaseReservation(ctx, reservationID, userID)
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "deleteReservation: unable to release reservation: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"eventers-marketplace-backend/config"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/firebase"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/vault"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fb "firebase.google.com/go"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testProjectID = "eventers-test"
+
+// testTokens signs firebase ID tokens with a key whose certificate is served
+// in place of the Google certificates.
+func testTokens(t *testing.T) func(uid string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testProjectID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"test": string(cert)})
+	}))
+	endpoint := firebase.CertsAPIEndpoint
+	firebase.CertsAPIEndpoint = srv.URL
+	viper.Set(config.FirebaseProjectID, testProjectID)
+	t.Cleanup(func() {
+		firebase.CertsAPIEndpoint = endpoint
+		srv.Close()
+	})
+
+	return func(uid string) string {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"aud":       testProjectID,
+			"iss":       "https://securetoken.google.com/" + testProjectID,
+			"sub":       uid,
+			"auth_time": now.Add(-time.Minute).Unix(),
+			"iat":       now.Add(-time.Minute).Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "test"
+
+		signed, err := token.SignedString(key)
+		require.Nil(t, err)
+		return signed
+	}
+}
+
+// testFactory serves handlers whose services only go through the store.
+type testFactory struct{}
+
+func (f testFactory) DB(context.Context) *sql.DB          { return nil }
+func (f testFactory) FirebaseApp(context.Context) *fb.App { return nil }
+
+// appService returns an event service whose store knows the app user 5,
+// signed in with the firebase uid "uid-5", and the published event 7, along
+// with the store.
+func appService(seated bool) (*event.Event, *store.Memory) {
+	mem := store.NewMemory()
+	uid := "uid-5"
+	mem.PutUser(model.User{UserID: 5, PhoneFirebaseID: &uid, IsActive: true, IsRegistered: true})
+
+	published := event.Published
+	pe := model.PublicEvent{PublicEventID: 7, BusinessUserID: 3, Status: &published}
+	if seated {
+		pe.VenueID = 11
+	}
+	mem.PutPublicEvent(pe)
+
+	return event.NewEvent(nil, vault.Vault{}, mem.Store(), nil, nil), mem
+}
+
+func authBody(t *testing.T, token string, userID int64) *bytes.Buffer {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"auth": map[string]interface{}{"token_id": token, "user_id": userID}},
+	})
+	require.Nil(t, err)
+	return &body
+}
+
+func TestReservationRoutes(t *testing.T) {
+	token := testTokens(t)
+	seated, _ := appService(true)
+	service, mem := appService(false)
+	mem.PutReservation(model.Reservation{ReservationID: "r1", UserID: 5, Status: event.ReservationActive})
+	mem.PutReservation(model.Reservation{ReservationID: "r2", UserID: 9, Status: event.ReservationActive})
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/public_event/{publicEventID}/reservations", CreateReservation(seated, testFactory{}, nil)).Methods(http.MethodPost)
+	r.HandleFunc("/v1/public_event/{publicEventID}/reservations/{reservationID}", DeleteReservation(service, testFactory{})).Methods(http.MethodDelete)
+
+	send := func(method, path string, body *bytes.Buffer) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, body))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/v1/public_event/7/reservations", authBody(t, "not a token", 5)))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/v1/public_event/7/reservations", authBody(t, token("unknown"), 5)))
+
+	// The seated event needs a seat, which is only checked once the user is
+	// authenticated.
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/v1/public_event/7/reservations", authBody(t, token("uid-5"), 5)))
+
+	// The reservation is released for the user of the token, not the one sent.
+	assert.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/v1/public_event/7/reservations/r2", authBody(t, token("uid-5"), 9)))
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "/v1/public_event/7/reservations/r1", authBody(t, token("uid-5"), 9)))
+
+	res, ok, err := mem.Store().Reservations.Get(context.Background(), "r1")
+	require.Nil(t, err)
+	require.True(t, ok)
+	assert.Equal(t, event.ReservationReleased, res.Status)
+
+	res, ok, err = mem.Store().Reservations.Get(context.Background(), "r2")
+	require.Nil(t, err)
+	require.True(t, ok)
+	assert.Equal(t, event.ReservationActive, res.Status)
+}
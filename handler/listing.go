@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/factory"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func RepriceListing(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := resaleListingRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		l, err := service.RepriceListing(ctx, listingID, userID, "", askPrice(req))
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "repriceListing: unable to reprice listing: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleListing: l, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func CancelListing(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := resaleListingRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		l, err := service.CancelListing(ctx, listingID, userID, "")
+		if err != nil {
+			if e, ok := err.(response.ErrorResponse); ok {
+				e.Send(ctx, w)
+				return
+			}
+			response.SomethingWrong().Send(ctx, w)
+			logger.Errorf(ctx, "cancelListing: unable to cancel listing: %+v", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleListing: l, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func RepriceMarketplaceListing(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := resaleListingRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		l, err := service.RepriceListing(ctx, listingID, 0, wallet, askPrice(req))
+		if err != nil {
+			sendMarketplaceError(ctx, w, "repriceMarketplaceListing: unable to reprice listing", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleListing: l},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func CancelMarketplaceListing(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		l, err := service.CancelListing(ctx, listingID, 0, wallet)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "cancelMarketplaceListing: unable to cancel listing", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleListing: l},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func resaleListingRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.ResaleListingRequest, bool) {
+	var req model.ResaleListingRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		response.BadRequest("invalid request body", fmt.Sprintf("resaleListingRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// verifyAppAuth checks the firebase token app users send with listing, offer
+// and auction requests, and returns the id of the app user it was issued to.
+func verifyAppAuth(ctx context.Context, w http.ResponseWriter, service *event.Event, auth *model.Auth) (int64, bool) {
+	if auth == nil {
+		response.BadRequest("invalid request body", "verifyAppAuth: auth is required").Send(ctx, w)
+		return 0, false
+	}
+
+	return appUser(ctx, w, service, auth)
+}
+
+func askPrice(req *model.ResaleListingRequest) int64 {
+	if req.Data.Listing == nil {
+		return 0
+	}
+	return int64(req.Data.Listing.AskPrice)
+}
+
+func listingIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	listingIDString := mux.Vars(r)["listingID"]
+
+	listingID, err := strconv.ParseInt(listingIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid listing id: %v", listingIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return listingID, true
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppListingAuth(t *testing.T) {
+	token := testTokens(t)
+	service, _ := appService(false)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/listings/{listingID}", CancelListing(service, testFactory{})).Methods(http.MethodDelete)
+
+	send := func(body *bytes.Buffer) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/v1/listings/4", body))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, send(bytes.NewBufferString(`{"data":{}}`)))
+	assert.Equal(t, http.StatusUnauthorized, send(authBody(t, "not a token", 5)))
+	assert.Equal(t, http.StatusUnauthorized, send(authBody(t, token("unknown"), 5)))
+
+	// A valid token gets past the auth check to the listing, which the store
+	// does not hold.
+	assert.Equal(t, http.StatusNotFound, send(authBody(t, token("uid-5"), 5)))
+}
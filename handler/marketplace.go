@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func CreateMarketplace(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req model.MarketplaceRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Marketplace == nil {
+			response.BadRequest("invalid request body", fmt.Sprintf("createMarketplace: error unmarshalling request body: %+v", err)).Send(ctx, w)
+			return
+		}
+
+		m, err := service.Create(ctx, req.Data.Marketplace)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "createMarketplace: unable to create marketplace", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusCreated,
+			NoStore:    true,
+		}.Send(w)
+	}
+}
+
+func GetMarketplaces(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		ms, err := service.List(ctx)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaces: unable to list marketplaces", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplaces: ms},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func GetMarketplace(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, err := service.Get(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplace: unable to get marketplace", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func UpdateMarketplace(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		var req model.MarketplaceRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Data.Marketplace == nil {
+			response.BadRequest("invalid request body", fmt.Sprintf("updateMarketplace: error unmarshalling request body: %+v", err)).Send(ctx, w)
+			return
+		}
+		req.Data.Marketplace.MarketPlaceID = marketplaceID
+
+		m, err := service.Update(ctx, req.Data.Marketplace)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "updateMarketplace: unable to update marketplace", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func DeactivateMarketplace(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, err := service.Deactivate(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "deactivateMarketplace: unable to deactivate marketplace", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func RotateMarketplaceKey(service *marketplace.Marketplace, grace time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := decodeMarketplaceKeyRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		k, err := service.RotateAccessKey(ctx, marketplaceID, req.Data.Scopes, grace)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "rotateMarketplaceKey: unable to rotate access key", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{MarketplaceKey: k},
+			StatusCode: http.StatusCreated,
+			NoStore:    true,
+		}.Send(w)
+	}
+}
+
+func CreateMarketplaceKey(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := decodeMarketplaceKeyRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		if req.Data.ExpiresIn < 0 {
+			response.InvalidData(fmt.Sprintf("createMarketplaceKey: invalid expires_in: %d", req.Data.ExpiresIn)).Send(ctx, w)
+			return
+		}
+
+		k, err := service.IssueKey(ctx, marketplaceID, req.Data.Scopes, time.Duration(req.Data.ExpiresIn)*time.Second)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "createMarketplaceKey: unable to issue access key", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{MarketplaceKey: k},
+			StatusCode: http.StatusCreated,
+			NoStore:    true,
+		}.Send(w)
+	}
+}
+
+func GetMarketplaceKeys(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		ks, err := service.ListKeys(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceKeys: unable to list access keys", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{MarketplaceKeys: ks},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func GetMarketplaceTicketMoves(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		mvs, err := service.TicketMoves(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceTicketMoves: unable to list ticket moves", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{TicketMoves: mvs},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func RevokeMarketplaceKey(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		err := service.RevokeKey(ctx, marketplaceID, mux.Vars(r)["keyID"])
+		if err != nil {
+			sendMarketplaceError(ctx, w, "revokeMarketplaceKey: unable to revoke access key", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func SetMarketplacePublicKey(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		var req model.MarketplacePublicKeyRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			response.BadRequest("invalid request body", fmt.Sprintf("setMarketplacePublicKey: error unmarshalling request body: %+v", err)).Send(ctx, w)
+			return
+		}
+
+		m, err := service.SetPublicKey(ctx, marketplaceID, req.Data.PublicKey)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "setMarketplacePublicKey: unable to set public key", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// PutMarketplacePublicKey lets a marketplace register the public key its users'
+// wallets are sealed to with a request signed by one of its own keys.
+func PutMarketplacePublicKey(service *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req model.MarketplacePublicKeyRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			response.BadRequest("invalid request body", fmt.Sprintf("putMarketplacePublicKey: error unmarshalling request body: %+v", err)).Send(ctx, w)
+			return
+		}
+
+		cred, err := service.Authorize(ctx, "", marketplace.ScopeUsers)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "putMarketplacePublicKey", err)
+			return
+		}
+
+		m, err := service.SetPublicKey(ctx, cred.Marketplace.MarketPlaceID, req.Data.PublicKey)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "putMarketplacePublicKey: unable to set public key", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// decodeMarketplaceKeyRequest reads the optional body of the key requests.
+func decodeMarketplaceKeyRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.MarketplaceKeyRequest, bool) {
+	var req model.MarketplaceKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		response.BadRequest("invalid request body", fmt.Sprintf("decodeMarketplaceKeyRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func marketplaceIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	marketplaceIDString := mux.Vars(r)["marketplaceID"]
+
+	marketplaceID, err := strconv.ParseInt(marketplaceIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid marketplace id: %v", marketplaceIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return marketplaceID, true
+}
+
+func sendMarketplaceError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	if e, ok := err.(response.ErrorResponse); ok {
+		e.Send(ctx, w)
+		return
+	}
+
+	logger.Errorf(ctx, "%s: %+v", msg, err)
+	response.SomethingWrong().Send(ctx, w)
+}
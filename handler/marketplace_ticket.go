@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/factory"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/session"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/gorilla/mux"
+)
+
+func GetMarketplaceEvents(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		pes, err := service.MarketplaceEvents(ctx)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceEvents: unable to list events", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{PublicEvents: pes},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func GetMarketplaceTickets(service *event.Event, marketplaceService *marketplace.Marketplace) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		m, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		claims, _ := session.ClaimsFrom(ctx)
+		userID, err := marketplaceService.LinkedUser(ctx, m, claims.UserMarketplaceID())
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceTickets: unable to find linked user", err)
+			return
+		}
+
+		ets, err := service.WalletTickets(ctx, wallet, userID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceTickets: unable to list tickets", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{EventTickets: ets},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func BuyMarketplaceTicket(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory, client *redis.Client, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		req, ok := marketplaceTicketRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		et, err := service.BuyForWallet(ctx, client, m, wallet, req.Data.Ticket, ttl)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "buyMarketplaceTicket: unable to buy ticket", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{EventTicket: et},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func ResellMarketplaceTicket(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory, listingTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		eventTicketID, ok := eventTicketIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := marketplaceTicketRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		et, l, err := service.ResellForWallet(ctx, wallet, eventTicketID, req.Data.Ticket.PriceToResell, req.Data.Ticket.ListingExpiresAt, listingTTL)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "resellMarketplaceTicket: unable to resell ticket", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{EventTicket: et, ResaleListing: l},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func TransferMarketplaceTicket(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		eventTicketID, ok := eventTicketIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := marketplaceTicketRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		to := req.Data.To
+		if to == nil || to.PhoneNumber == "" {
+			response.InvalidData("transferMarketplaceTicket: to_user_marketplace phone number is required").Send(ctx, w)
+			return
+		}
+
+		m, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		toWallet, err := marketplaceService.RecipientWallet(ctx, m, to.PhoneCountryCode+to.PhoneNumber)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "transferMarketplaceTicket: unable to find recipient", err)
+			return
+		}
+
+		et, err := service.TransferForWallet(ctx, m, wallet, eventTicketID, toWallet)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "transferMarketplaceTicket: unable to transfer ticket", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{EventTicket: et},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func BridgeMarketplaceTicket(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		eventTicketID, ok := eventTicketIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		claims, _ := session.ClaimsFrom(ctx)
+		sharedWallet, err := marketplaceService.SharedWallet(ctx, m, claims.UserMarketplaceID())
+		if err != nil {
+			sendMarketplaceError(ctx, w, "bridgeMarketplaceTicket: unable to find shared wallet", err)
+			return
+		}
+
+		et, err := service.BridgeToSharedWallet(ctx, m, wallet, sharedWallet, eventTicketID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "bridgeMarketplaceTicket: unable to bridge ticket", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{EventTicket: et},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// sessionWallet returns the marketplace and the wallet of the user of the
+// session the request carries.
+func sessionWallet(ctx context.Context, w http.ResponseWriter, service *marketplace.Marketplace) (*model.Marketplace, string, bool) {
+	claims, ok := session.ClaimsFrom(ctx)
+	if !ok {
+		response.Unauthorized().Send(ctx, w)
+		return nil, "", false
+	}
+
+	m, wallet, err := service.UserWallet(ctx, claims.MarketplaceID, claims.UserMarketplaceID())
+	if err != nil {
+		sendMarketplaceError(ctx, w, "sessionWallet", err)
+		return nil, "", false
+	}
+
+	return m, wallet, true
+}
+
+func marketplaceTicketRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.MarketplaceTicketRequest, bool) {
+	var req model.MarketplaceTicketRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		response.BadRequest("invalid request body", fmt.Sprintf("marketplaceTicketRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return nil, false
+	}
+
+	if req.Data.Ticket == nil {
+		response.InvalidData("marketplaceTicketRequest: ticket is required").Send(ctx, w)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func eventTicketIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	eventTicketIDString := mux.Vars(r)["eventTicketID"]
+
+	eventTicketID, err := strconv.ParseInt(eventTicketIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid event ticket id: %v", eventTicketIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return eventTicketID, true
+}
@@ -4,17 +4,18 @@ import (
 	"encoding/json"
 	"eventers-marketplace-backend/factory"
 	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
 	"eventers-marketplace-backend/model"
 	"eventers-marketplace-backend/response"
-	"eventers-marketplace-backend/twilio"
+	"eventers-marketplace-backend/session"
 	"eventers-marketplace-backend/user"
 	"fmt"
+	"net"
 	"net/http"
-
-	"github.com/go-redis/redis"
+	"strings"
 )
 
-func CreateMarketPlaceUser(service *user.User, f factory.Factory, sender twilio.Sender, client *redis.Client, secret string) http.HandlerFunc {
+func CreateMarketPlaceUser(service *user.User, f factory.Factory, otp *user.OTP) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -25,7 +26,12 @@ func CreateMarketPlaceUser(service *user.User, f factory.Factory, sender twilio.
 			return
 		}
 
-		usr, auth, err := service.CreateMarketPlaceUser(ctx, f.DB(ctx), req.Data.User, req.Data.Auth, sender, client, secret)
+		ipAddress, err := clientIP(r)
+		if ipAddress == "" || err != nil {
+			logger.Infof(ctx, "createMarketPlaceUser: unable to resolve ip address from header, continuing: %+v", err)
+		}
+
+		usr, auth, err := service.CreateMarketPlaceUser(ctx, req.Data.User, req.Data.Auth, otp, ipAddress)
 		if err != nil {
 			logger.Errorf(ctx, "createMarketPlaceUser: unable to create user: %+v", err)
 			if e, ok := err.(response.ErrorResponse); ok {
@@ -33,6 +39,7 @@ func CreateMarketPlaceUser(service *user.User, f factory.Factory, sender twilio.
 				return
 			}
 			response.SomethingWrong().Send(ctx, w)
+			return
 		}
 
 		response.SuccessResponse{
@@ -45,7 +52,8 @@ func CreateMarketPlaceUser(service *user.User, f factory.Factory, sender twilio.
 	}
 }
 
-func VerifyMarketPlaceOTP(service *user.User, f factory.Factory, client *redis.Client) http.HandlerFunc {
+// VerifyMarketPlaceOTP verifies the OTP and starts a session for the user.
+func VerifyMarketPlaceOTP(service *user.User, f factory.Factory, otp *user.OTP, sessions *session.Sessions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
@@ -56,7 +64,17 @@ func VerifyMarketPlaceOTP(service *user.User, f factory.Factory, client *redis.C
 			return
 		}
 
-		user, err := service.VerifyMarketPlaceUserOTP(ctx, f.DB(ctx), client, req.Data.User, *req.Data.Auth)
+		if req.Data.Auth == nil {
+			response.InvalidData("verifyMarketPlaceOTP: auth is required").Send(ctx, w)
+			return
+		}
+
+		ipAddress, err := clientIP(r)
+		if ipAddress == "" || err != nil {
+			logger.Infof(ctx, "verifyMarketPlaceOTP: unable to resolve ip address from header, continuing: %+v", err)
+		}
+
+		user, err := service.VerifyMarketPlaceUserOTP(ctx, otp, *req.Data.Auth, ipAddress)
 		if err != nil {
 			if e, ok := err.(response.ErrorResponse); ok {
 				e.Send(ctx, w)
@@ -66,9 +84,103 @@ func VerifyMarketPlaceOTP(service *user.User, f factory.Factory, client *redis.C
 			return
 		}
 
+		token, err := sessions.Issue(ctx, user.MarketPlaceID, user.UserID)
+		if err != nil {
+			logger.Errorf(ctx, "verifyMarketPlaceOTP: unable to issue session: %+v", err)
+			response.SomethingWrong().Send(ctx, w)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{UserMarketplace: user, Session: token},
+			StatusCode: http.StatusOK,
+			NoStore:    true,
+		}.Send(w)
+	}
+}
+
+// RefreshMarketplaceSession swaps the refresh token of a marketplace user
+// session for new tokens.
+func RefreshMarketplaceSession(service *marketplace.Marketplace, sessions *session.Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, auth, ok := sessionRequest(w, r, service)
+		if !ok {
+			return
+		}
+
+		token, err := sessions.Refresh(ctx, marketplaceID, auth.RefreshToken)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "refreshMarketplaceSession", err)
+			return
+		}
+
 		response.SuccessResponse{
-			Data:       &response.Data{UserMarketplace: user},
+			Data:       &response.Data{Session: token},
 			StatusCode: http.StatusOK,
+			NoStore:    true,
 		}.Send(w)
 	}
 }
+
+// RevokeMarketplaceSession ends a marketplace user session.
+func RevokeMarketplaceSession(service *marketplace.Marketplace, sessions *session.Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, auth, ok := sessionRequest(w, r, service)
+		if !ok {
+			return
+		}
+
+		err := sessions.Revoke(ctx, marketplaceID, auth.RefreshToken)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "revokeMarketplaceSession", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// sessionRequest reads the refresh token of a session request and authorizes
+// the marketplace it was sent by.
+func sessionRequest(w http.ResponseWriter, r *http.Request, service *marketplace.Marketplace) (int64, *model.Auth, bool) {
+	ctx := r.Context()
+
+	var req model.CreateMarketPlaceUser
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		response.BadRequest("invalid request body", fmt.Sprintf("sessionRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return 0, nil, false
+	}
+
+	if req.Data.Auth == nil || req.Data.Auth.RefreshToken == "" {
+		response.InvalidData("sessionRequest: refresh_token is required").Send(ctx, w)
+		return 0, nil, false
+	}
+
+	cred, err := service.Authorize(ctx, req.Data.Auth.AccessKey, marketplace.ScopeUsers)
+	if err != nil {
+		sendMarketplaceError(ctx, w, "sessionRequest", err)
+		return 0, nil, false
+	}
+
+	return cred.Marketplace.MarketPlaceID, req.Data.Auth, true
+}
+
+// clientIP returns the address of the user a partner backend calls on behalf
+// of, which it forwards in X-Forwarded-For, or the address the request came
+// from.
+func clientIP(r *http.Request) (string, error) {
+	forward := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
+	if ip := net.ParseIP(forward); ip != nil {
+		return ip.String(), nil
+	}
+
+	return getIP(r)
+}
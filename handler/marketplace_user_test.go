@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPPrefersTheForwardedAddress(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/marketplace/user/connect", nil)
+	r.RemoteAddr = "198.51.100.7:41234"
+
+	ip, err := clientIP(r)
+	require.Nil(t, err)
+	assert.Equal(t, "198.51.100.7", ip)
+
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	ip, err = clientIP(r)
+	require.Nil(t, err)
+	assert.Equal(t, "203.0.113.9", ip)
+
+	r.Header.Set("X-Forwarded-For", "unknown")
+	ip, err = clientIP(r)
+	require.Nil(t, err)
+	assert.Equal(t, "198.51.100.7", ip)
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/event"
+	"eventers-marketplace-backend/factory"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/session"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func PlaceOffer(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := offerRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		o, err := service.PlaceOffer(ctx, nil, &model.Buyer{UserID: userID}, listingID, offerAmount(req))
+		if err != nil {
+			sendAppError(ctx, w, "placeOffer: unable to place offer", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffer: o, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func GetListingOffers(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := offerRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		os, err := service.ListingOffers(ctx, listingID, userID, "")
+		if err != nil {
+			sendAppError(ctx, w, "getListingOffers: unable to list offers", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffers: os, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func AcceptOffer(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return answerOffer(service, f, service.AcceptOffer, "acceptOffer: unable to accept offer")
+}
+
+func RejectOffer(service *event.Event, f factory.Factory) http.HandlerFunc {
+	return answerOffer(service, f, service.RejectOffer, "rejectOffer: unable to reject offer")
+}
+
+// offerAnswer accepts or rejects an offer on a listing of the seller.
+type offerAnswer func(ctx context.Context, listingID, offerID, userID int64, wallet string) (*model.ResaleOffer, error)
+
+func answerOffer(service *event.Event, f factory.Factory, answer offerAnswer, msg string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, offerID, ok := offerVars(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := offerRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		userID, ok := verifyAppAuth(ctx, w, service, req.Data.Auth)
+		if !ok {
+			return
+		}
+
+		o, err := answer(ctx, listingID, offerID, userID, "")
+		if err != nil {
+			sendAppError(ctx, w, msg, err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffer: o, Auth: &model.Auth{PushKey: req.Data.Auth.PushKey}},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func PlaceMarketplaceOffer(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		req, ok := offerRequest(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, b, ok := sessionBuyer(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		o, err := service.PlaceOffer(ctx, m, b, listingID, offerAmount(req))
+		if err != nil {
+			sendMarketplaceError(ctx, w, "placeMarketplaceOffer: unable to place offer", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffer: o},
+			StatusCode: http.StatusCreated,
+		}.Send(w)
+	}
+}
+
+func GetMarketplaceListingOffers(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, ok := listingIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		os, err := service.ListingOffers(ctx, listingID, 0, wallet)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getMarketplaceListingOffers: unable to list offers", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffers: os},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func AcceptMarketplaceOffer(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return answerMarketplaceOffer(marketplaceService, f, service.AcceptOffer, "acceptMarketplaceOffer: unable to accept offer")
+}
+
+func RejectMarketplaceOffer(service *event.Event, marketplaceService *marketplace.Marketplace, f factory.Factory) http.HandlerFunc {
+	return answerMarketplaceOffer(marketplaceService, f, service.RejectOffer, "rejectMarketplaceOffer: unable to reject offer")
+}
+
+func answerMarketplaceOffer(marketplaceService *marketplace.Marketplace, f factory.Factory, answer offerAnswer, msg string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		listingID, offerID, ok := offerVars(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		_, wallet, ok := sessionWallet(ctx, w, marketplaceService)
+		if !ok {
+			return
+		}
+
+		o, err := answer(ctx, listingID, offerID, 0, wallet)
+		if err != nil {
+			sendMarketplaceError(ctx, w, msg, err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{ResaleOffer: o},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+// sessionBuyer returns the marketplace of the session and its user as a buyer,
+// through the wallet the user holds tickets in.
+func sessionBuyer(ctx context.Context, w http.ResponseWriter, service *marketplace.Marketplace) (*model.Marketplace, *model.Buyer, bool) {
+	m, wallet, ok := sessionWallet(ctx, w, service)
+	if !ok {
+		return nil, nil, false
+	}
+
+	claims, _ := session.ClaimsFrom(ctx)
+	return m, &model.Buyer{
+		Wallet:            wallet,
+		MarketplaceID:     m.MarketPlaceID,
+		UserMarketplaceID: claims.UserMarketplaceID(),
+	}, true
+}
+
+// sendAppError sends client errors as they are and logs the others.
+func sendAppError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	if e, ok := err.(response.ErrorResponse); ok {
+		e.Send(ctx, w)
+		return
+	}
+
+	response.SomethingWrong().Send(ctx, w)
+	logger.Errorf(ctx, "%s: %+v", msg, err)
+}
+
+func offerRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (*model.OfferRequest, bool) {
+	var req model.OfferRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		response.BadRequest("invalid request body", fmt.Sprintf("offerRequest: error unmarshalling request body: %+v", err)).Send(ctx, w)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func offerAmount(req *model.OfferRequest) int64 {
+	if req.Data.Offer == nil {
+		return 0
+	}
+	return int64(req.Data.Offer.Amount)
+}
+
+func offerVars(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	listingID, ok := listingIDVar(ctx, w, r)
+	if !ok {
+		return 0, 0, false
+	}
+
+	offerIDString := mux.Vars(r)["offerID"]
+
+	offerID, err := strconv.ParseInt(offerIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid offer id: %v", offerIDString)).Send(ctx, w)
+		return 0, 0, false
+	}
+
+	return listingID, offerID, true
+}
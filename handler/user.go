@@ -55,7 +55,7 @@ func CreateUser(service *user.User, f factory.Factory) http.HandlerFunc {
 			logger.Infof(ctx, "create: unable to resolve ip address from header, continuing: %+v", err)
 		}
 
-		usr, auth, err := service.Create(ctx, f.DB(ctx), req.Data.User, req.Data.Auth, ipAddress)
+		usr, auth, err := service.Create(ctx, req.Data.User, req.Data.Auth, ipAddress)
 		if err != nil {
 			logger.Errorf(ctx, "create: unable to create user: %+v", err)
 			response.SomethingWrong().Send(ctx, w)
@@ -100,7 +100,7 @@ func VerifyUser(service *user.User, f factory.Factory) http.HandlerFunc {
 			logger.Infof(ctx, "create: unable to resolve ip address from header, continuing: %+v", err)
 		}
 
-		user, res, err := service.Verify(ctx, f.DB(ctx), req.Data.User, req.Data.Auth, ipAddress)
+		user, res, err := service.Verify(ctx, req.Data.User, req.Data.Auth, ipAddress)
 		if err != nil || res != nil {
 			response.SomethingWrong().Send(ctx, w)
 			return
@@ -173,7 +173,7 @@ func getIP(req *http.Request) (string, error) {
 	userIP := net.ParseIP(ip)
 
 	if userIP != nil {
-		return string(userIP), nil
+		return userIP.String(), nil
 	}
 	forward := req.Header.Get("X-Forwarded-For")
 
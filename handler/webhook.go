@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/webhook"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+func GetWebhookDeliveries(service *marketplace.Marketplace, hooks *webhook.Webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		cred, err := service.Authorize(ctx, "", marketplace.ScopeWebhooks)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getWebhookDeliveries", err)
+			return
+		}
+
+		limit := defaultDeliveryLimit
+		if l := r.URL.Query().Get("limit"); l != "" {
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit <= 0 || limit > maxDeliveryLimit {
+				response.InvalidData(fmt.Sprintf("invalid limit: %s, at most %d", l, maxDeliveryLimit)).Send(ctx, w)
+				return
+			}
+		}
+
+		ds, err := hooks.Deliveries(ctx, cred.Marketplace.MarketPlaceID, r.URL.Query().Get("status"), limit)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "getWebhookDeliveries: unable to list deliveries", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{WebhookDeliveries: ds},
+			StatusCode: http.StatusOK,
+		}.Send(w)
+	}
+}
+
+func ReplayWebhookDelivery(service *marketplace.Marketplace, hooks *webhook.Webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		deliveryID, ok := deliveryIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		cred, err := service.Authorize(ctx, "", marketplace.ScopeWebhooks)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "replayWebhookDelivery", err)
+			return
+		}
+
+		d, err := hooks.Replay(ctx, cred.Marketplace.MarketPlaceID, deliveryID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "replayWebhookDelivery: unable to replay delivery", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{WebhookDelivery: d},
+			StatusCode: http.StatusAccepted,
+		}.Send(w)
+	}
+}
+
+func RotateWebhookSecret(service *marketplace.Marketplace, hooks *webhook.Webhooks) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		marketplaceID, ok := marketplaceIDVar(ctx, w, r)
+		if !ok {
+			return
+		}
+
+		m, err := service.Get(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "rotateWebhookSecret: unable to get marketplace", err)
+			return
+		}
+
+		m.WebhookSecret, err = hooks.RotateSecret(ctx, marketplaceID)
+		if err != nil {
+			sendMarketplaceError(ctx, w, "rotateWebhookSecret: unable to rotate secret", err)
+			return
+		}
+
+		response.SuccessResponse{
+			Data:       &response.Data{Marketplace: m},
+			StatusCode: http.StatusCreated,
+			NoStore:    true,
+		}.Send(w)
+	}
+}
+
+func deliveryIDVar(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, bool) {
+	deliveryIDString := mux.Vars(r)["deliveryID"]
+
+	deliveryID, err := strconv.ParseInt(deliveryIDString, 10, 64)
+	if err != nil {
+		response.InvalidData(fmt.Sprintf("invalid delivery id: %v", deliveryIDString)).Send(ctx, w)
+		return 0, false
+	}
+
+	return deliveryID, true
+}
@@ -0,0 +1,533 @@
+package marketplace
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"eventers-marketplace-backend/codec"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes an access key can be granted.
+const (
+	ScopeUsers    = "users"
+	ScopeWebhooks = "webhooks"
+)
+
+// Scopes lists every scope. Keys issued without scopes get all of them.
+var Scopes = []string{ScopeUsers, ScopeWebhooks}
+
+// An access key reads keyPrefix, the key id, keySeparator and the secret. The
+// key id is stored in clear to find the key, the secret only as a salted hash.
+const (
+	keyPrefix    = "mk_"
+	keySeparator = "."
+	keyIDBytes   = 8
+	secretBytes  = 32
+	saltBytes    = 16
+	legacyPrefix = "legacy_"
+
+	publicKeyPrefix = "pk_"
+)
+
+// The key requests are signed with is derived from the secret, so that it is not
+// the credential itself. Partners derive it as
+//
+//	signing key = HMAC-SHA256(secret, signingKeyLabel)
+//
+// Wallet material is never encrypted with a key derived from the access key, it
+// is sealed to the public key the marketplace registered.
+const signingKeyLabel = "eventers-marketplace/request-signing/v1"
+
+// Signing keys are stored encrypted with the key encryption key of the server,
+// bound to the id of their access key. Keys stored before are plaintext hex
+// until RewrapKeys ran.
+const (
+	kekID   = "kek"
+	kekSize = 32
+)
+
+type credentialKey struct{}
+
+// Credential is a verified access key along with the marketplace it belongs to.
+type Credential struct {
+	Marketplace *model.Marketplace
+	KeyID       string
+	Scopes      []string
+}
+
+// Allow reports a Forbidden error unless the key was granted scope.
+func (c *Credential) Allow(scope string) error {
+	if !contains(c.Scopes, scope) {
+		return response.Forbidden(fmt.Sprintf("allow: key: %s lacks scope: %s", c.KeyID, scope))
+	}
+	return nil
+}
+
+// WithCredential returns a copy of ctx carrying the credential of a signed
+// request.
+func WithCredential(ctx context.Context, c *Credential) context.Context {
+	return context.WithValue(ctx, credentialKey{}, c)
+}
+
+// CredentialFrom returns the credential of the signed request ctx belongs to.
+func CredentialFrom(ctx context.Context) (*Credential, bool) {
+	c, ok := ctx.Value(credentialKey{}).(*Credential)
+	return c, ok
+}
+
+// SigningString is the message a partner signs with its signing key: the
+// method, the path with its query, the unix timestamp, the nonce and the hex
+// SHA-256 of the body, separated by newlines.
+func SigningString(method, path, timestamp, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(strings.Join([]string{method, path, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n"))
+}
+
+// WalletAAD is the additional data wallet material of a marketplace user is
+// sealed with, so that an envelope only opens for the user it was issued to.
+func WalletAAD(marketplaceID, userID int64) []byte {
+	return []byte(fmt.Sprintf("marketplace:%d/user:%d", marketplaceID, userID))
+}
+
+// SetPublicKey registers the base64 X25519 public key wallet material of the
+// marketplace's users is sealed to, replacing the previous one. Envelopes name
+// the key they were sealed to by its id.
+func (u *Marketplace) SetPublicKey(ctx context.Context, marketplaceID int64, publicKey string) (*model.Marketplace, error) {
+	m, err := u.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.IsActive {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("setPublicKey: marketplace: %d is deactivated", marketplaceID))
+	}
+
+	keyID, err := publicKeyID(publicKey)
+	if err != nil {
+		return nil, response.InvalidData(fmt.Sprintf("setPublicKey: %s", err))
+	}
+
+	err = u.store.Marketplaces.SetPublicKey(ctx, marketplaceID, keyID, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("setPublicKey: %w", err)
+	}
+
+	m.PublicKeyID = &keyID
+	m.PublicKey = &publicKey
+	return m, nil
+}
+
+// DecodePublicKey returns the X25519 public key registered for the marketplace.
+func DecodePublicKey(m *model.Marketplace) ([]byte, bool) {
+	if m.PublicKey == nil || m.PublicKeyID == nil || *m.PublicKey == "" {
+		return nil, false
+	}
+
+	k, err := base64.StdEncoding.DecodeString(*m.PublicKey)
+	if err != nil || len(k) != codec.KeySize {
+		return nil, false
+	}
+
+	return k, true
+}
+
+// IssueKey adds an access key to the marketplace. A ttl of zero issues a key
+// that does not expire.
+func (u *Marketplace) IssueKey(ctx context.Context, marketplaceID int64, scopes []string, ttl time.Duration) (*model.MarketplaceKey, error) {
+	m, err := u.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.IsActive {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("issueKey: marketplace: %d is deactivated", marketplaceID))
+	}
+
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().UTC().Add(ttl)
+		expiresAt = &t
+	}
+
+	k, err := u.issueKey(ctx, marketplaceID, scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// RotateAccessKey issues a new access key with the given scopes and lets every
+// other key of the marketplace expire once grace has passed.
+func (u *Marketplace) RotateAccessKey(ctx context.Context, marketplaceID int64, scopes []string, grace time.Duration) (*model.MarketplaceKey, error) {
+	k, err := u.IssueKey(ctx, marketplaceID, scopes, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.store.MarketplaceKeys.ExpireOthers(ctx, marketplaceID, k.KeyID, time.Now().UTC().Add(grace))
+	if err != nil {
+		return nil, fmt.Errorf("rotateAccessKey: %w", err)
+	}
+
+	return k, nil
+}
+
+// ListKeys returns the keys of the marketplace without their secrets.
+func (u *Marketplace) ListKeys(ctx context.Context, marketplaceID int64) ([]model.MarketplaceKey, error) {
+	_, err := u.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, err := u.store.MarketplaceKeys.List(ctx, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("listKeys: %w", err)
+	}
+
+	for i := range ks {
+		ks[i].SigningKey = ""
+	}
+
+	return ks, nil
+}
+
+// RevokeKey stops the key from working immediately.
+func (u *Marketplace) RevokeKey(ctx context.Context, marketplaceID int64, keyID string) error {
+	ok, err := u.store.MarketplaceKeys.Revoke(ctx, marketplaceID, keyID)
+	if err != nil {
+		return fmt.Errorf("revokeKey: %w", err)
+	}
+
+	if !ok {
+		return response.ResourceNotFound("marketplace key not found", fmt.Sprintf("revokeKey: marketplace_id: %d, key_id: %s", marketplaceID, keyID))
+	}
+
+	return nil
+}
+
+// Authorize returns the credential of the calling marketplace once it is
+// allowed scope. Signed requests were verified by the signature middleware,
+// other requests authenticate with the access key in their body, which only
+// marketplaces still allowing body keys may do.
+func (u *Marketplace) Authorize(ctx context.Context, accessKey, scope string) (*Credential, error) {
+	c, ok := CredentialFrom(ctx)
+	if !ok {
+		var err error
+		c, err = u.Authenticate(ctx, accessKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Marketplace.AllowBodyKey == nil || !*c.Marketplace.AllowBodyKey {
+			return nil, response.Forbidden(fmt.Sprintf("authorize: marketplace: %d has to sign its requests", c.Marketplace.MarketPlaceID))
+		}
+	}
+
+	err := c.Allow(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Authenticate verifies the access key. Unknown, expired and revoked keys as
+// well as keys of deactivated marketplaces are unauthorized.
+func (u *Marketplace) Authenticate(ctx context.Context, accessKey string) (*Credential, error) {
+	keyID, secret := parseAccessKey(accessKey)
+	if keyID == "" {
+		return nil, response.Unauthorized()
+	}
+
+	k, m, err := u.activeKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(k.Salt, secret)), []byte(k.SecretHash)) != 1 {
+		return nil, response.Unauthorized()
+	}
+
+	return credential(k, m), nil
+}
+
+// AuthenticateSignature verifies that signature is the hex HMAC-SHA256 of msg
+// under the signing key of the key. Keys issued before request signing have no
+// signing key and cannot sign.
+func (u *Marketplace) AuthenticateSignature(ctx context.Context, keyID string, msg []byte, signature string) (*Credential, error) {
+	k, m, err := u.activeKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := u.openSigningKey(k)
+	if err != nil {
+		return nil, fmt.Errorf("authenticateSignature: %w", err)
+	}
+
+	if len(key) == 0 {
+		return nil, response.Unauthorized()
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, response.Unauthorized()
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return nil, response.Unauthorized()
+	}
+
+	return credential(k, m), nil
+}
+
+// activeKey returns the key and its marketplace unless either can no longer be
+// used.
+func (u *Marketplace) activeKey(ctx context.Context, keyID string) (*model.MarketplaceKey, *model.Marketplace, error) {
+	k, ok, err := u.store.MarketplaceKeys.Get(ctx, keyID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("activeKey: %w", err)
+	}
+
+	if !ok || k.RevokedAt != nil || (k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now().UTC())) {
+		return nil, nil, response.Unauthorized()
+	}
+
+	m, ok, err := u.store.Marketplaces.Get(ctx, k.MarketPlaceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("activeKey: %w", err)
+	}
+
+	if !ok || !m.IsActive {
+		return nil, nil, response.Unauthorized()
+	}
+
+	return k, m, nil
+}
+
+func credential(k *model.MarketplaceKey, m *model.Marketplace) *Credential {
+	return &Credential{
+		Marketplace: m,
+		KeyID:       k.KeyID,
+		Scopes:      k.Scopes,
+	}
+}
+
+func (u *Marketplace) issueKey(ctx context.Context, marketplaceID int64, scopes []string, expiresAt *time.Time) (*model.MarketplaceKey, error) {
+	if len(scopes) == 0 {
+		scopes = Scopes
+	}
+
+	for _, scope := range scopes {
+		if !contains(Scopes, scope) {
+			return nil, response.InvalidData(fmt.Sprintf("issueKey: unknown scope: %s", scope))
+		}
+	}
+
+	keyID, err := randomHex(keyIDBytes)
+	if err != nil {
+		return nil, fmt.Errorf("issueKey: %w", err)
+	}
+	keyID = keyPrefix + keyID
+
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return nil, fmt.Errorf("issueKey: %w", err)
+	}
+
+	salt, err := randomHex(saltBytes)
+	if err != nil {
+		return nil, fmt.Errorf("issueKey: %w", err)
+	}
+
+	signing := signingKey(secret)
+	sealed, err := u.sealSigningKey(keyID, signing)
+	if err != nil {
+		return nil, fmt.Errorf("issueKey: %w", err)
+	}
+
+	k := &model.MarketplaceKey{
+		KeyID:         keyID,
+		MarketPlaceID: marketplaceID,
+		Salt:          salt,
+		SecretHash:    hashSecret(salt, secret),
+		SigningKey:    sealed,
+		Scopes:        scopes,
+		ExpiresAt:     expiresAt,
+	}
+
+	err = u.store.MarketplaceKeys.Create(ctx, k)
+	if err != nil {
+		return nil, fmt.Errorf("issueKey: %w", err)
+	}
+
+	k.AccessKey = keyID + keySeparator + secret
+	k.SigningKey = hex.EncodeToString(signing)
+	return k, nil
+}
+
+// RewrapKeys encrypts the signing keys still stored in plaintext with the key
+// encryption key and returns how many it encrypted.
+func (u *Marketplace) RewrapKeys(ctx context.Context) (int, error) {
+	ms, err := u.store.Marketplaces.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("rewrapKeys: %w", err)
+	}
+
+	n := 0
+	for _, m := range ms {
+		ks, err := u.store.MarketplaceKeys.List(ctx, m.MarketPlaceID)
+		if err != nil {
+			return n, fmt.Errorf("rewrapKeys: %w", err)
+		}
+
+		for _, k := range ks {
+			if k.SigningKey == "" || isSealed(k.SigningKey) {
+				continue
+			}
+
+			key, err := hex.DecodeString(k.SigningKey)
+			if err != nil {
+				return n, fmt.Errorf("rewrapKeys: key: %s: invalid signing key: %w", k.KeyID, err)
+			}
+
+			sealed, err := u.sealSigningKey(k.KeyID, key)
+			if err != nil {
+				return n, fmt.Errorf("rewrapKeys: %w", err)
+			}
+
+			ok, err := u.store.MarketplaceKeys.SetSigningKey(ctx, k.KeyID, k.SigningKey, sealed)
+			if err != nil {
+				return n, fmt.Errorf("rewrapKeys: %w", err)
+			}
+			if ok {
+				n++
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// ParseKeyEncryptionKey decodes the base64 key encryption key signing keys
+// are stored encrypted with.
+func ParseKeyEncryptionKey(kek string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(kek)
+	if err != nil || len(b) != kekSize {
+		return nil, fmt.Errorf("key encryption key has to be a base64 %d byte key", kekSize)
+	}
+
+	return b, nil
+}
+
+func (u *Marketplace) sealSigningKey(keyID string, key []byte) (string, error) {
+	sealed, err := codec.Encrypt(u.kek, kekID, key, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("sealSigningKey: key: %s: %w", keyID, err)
+	}
+
+	return sealed, nil
+}
+
+// openSigningKey returns the signing key of k, empty for keys issued before
+// request signing.
+func (u *Marketplace) openSigningKey(k *model.MarketplaceKey) ([]byte, error) {
+	if k.SigningKey == "" {
+		return nil, nil
+	}
+
+	if !isSealed(k.SigningKey) {
+		key, err := hex.DecodeString(k.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("openSigningKey: key: %s: invalid signing key: %w", k.KeyID, err)
+		}
+		return key, nil
+	}
+
+	key, err := codec.Decrypt(u.kek, k.SigningKey, []byte(k.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("openSigningKey: key: %s: %w", k.KeyID, err)
+	}
+
+	return key, nil
+}
+
+func isSealed(signingKey string) bool {
+	return strings.HasPrefix(signingKey, codec.Version1+".")
+}
+
+// parseAccessKey splits an access key into its key id and secret. Keys from
+// before key ids were introduced are looked up by the hash of the whole key.
+func parseAccessKey(accessKey string) (string, string) {
+	if accessKey == "" {
+		return "", ""
+	}
+
+	if !strings.HasPrefix(accessKey, keyPrefix) {
+		return legacyPrefix + hashSecret("", accessKey)[:16], accessKey
+	}
+
+	i := strings.Index(accessKey, keySeparator)
+	if i < 0 {
+		return "", ""
+	}
+
+	return accessKey[:i], accessKey[i+len(keySeparator):]
+}
+
+// publicKeyID validates the base64 X25519 public key and returns its id, the
+// start of its SHA-256.
+func publicKeyID(publicKey string) (string, error) {
+	k, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(k) != codec.KeySize {
+		return "", fmt.Errorf("public_key must be a base64 X25519 public key")
+	}
+
+	sum := sha256.Sum256(k)
+	return publicKeyPrefix + hex.EncodeToString(sum[:keyIDBytes]), nil
+}
+
+func hashSecret(salt, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func signingKey(secret string) []byte {
+	return deriveKey([]byte(secret), signingKeyLabel)
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("randomHex: error reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
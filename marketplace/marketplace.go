@@ -0,0 +1,224 @@
+package marketplace
+
+import (
+	"context"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/notify"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/webhook"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NewMarketplace returns the service managing marketplace partners. Signing
+// keys are stored encrypted with kek, see ParseKeyEncryptionKey.
+func NewMarketplace(st *store.Store, kek []byte) *Marketplace {
+	return &Marketplace{store: st, kek: kek}
+}
+
+// Marketplace manages the partner marketplaces allowed to call the API.
+type Marketplace struct {
+	store *store.Store
+	kek   []byte
+}
+
+// Create registers an active marketplace along with its first access key, which
+// holds every scope and does not expire. The key is only returned here. New
+// marketplaces have to sign their requests unless allow_body_key is set, and
+// may register their public key right away.
+func (u *Marketplace) Create(ctx context.Context, m *model.Marketplace) (*model.Marketplace, error) {
+	if m.AccessType == "" {
+		m.AccessType = constants.Interop
+	}
+
+	err := validate(m)
+	if err != nil {
+		return nil, response.InvalidData(fmt.Sprintf("create: %s", err))
+	}
+
+	var keyID string
+	if m.PublicKey != nil && *m.PublicKey != "" {
+		keyID, err = publicKeyID(*m.PublicKey)
+		if err != nil {
+			return nil, response.InvalidData(fmt.Sprintf("create: %s", err))
+		}
+	}
+
+	m.IsActive = true
+	if m.AllowBodyKey == nil {
+		allowBodyKey := false
+		m.AllowBodyKey = &allowBodyKey
+	}
+
+	id, err := u.store.Marketplaces.Create(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	m.MarketPlaceID = id
+
+	m.Key, err = u.issueKey(ctx, id, Scopes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m.PublicKeyID = nil
+	if keyID != "" {
+		err = u.store.Marketplaces.SetPublicKey(ctx, id, keyID, *m.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("create: %w", err)
+		}
+		m.PublicKeyID = &keyID
+	}
+
+	return m, nil
+}
+
+// Get returns the marketplace.
+func (u *Marketplace) Get(ctx context.Context, marketplaceID int64) (*model.Marketplace, error) {
+	m, ok, err := u.store.Marketplaces.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("marketplace not found", fmt.Sprintf("get: marketplace_id: %d", marketplaceID))
+	}
+
+	return m, nil
+}
+
+// Update changes the profile of the marketplace. Fields left empty in m keep
+// their value, empty allowed_origins, otp_channels and webhook_events lists
+// clear them.
+func (u *Marketplace) Update(ctx context.Context, m *model.Marketplace) (*model.Marketplace, error) {
+	existing, err := u.Get(ctx, m.MarketPlaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.MarketPlaceName != "" {
+		existing.MarketPlaceName = m.MarketPlaceName
+	}
+	if m.DisplayName != nil {
+		existing.DisplayName = m.DisplayName
+	}
+	if m.CallbackURL != nil {
+		existing.CallbackURL = m.CallbackURL
+	}
+	if m.AllowedOrigins != nil {
+		existing.AllowedOrigins = m.AllowedOrigins
+	}
+	if m.AccessType != "" {
+		if m.AccessType != existing.AccessType {
+			hasUsers, err := u.store.Marketplaces.HasUsers(ctx, m.MarketPlaceID)
+			if err != nil {
+				return nil, fmt.Errorf("update: %w", err)
+			}
+
+			// Wallets of the users live under a path that depends on the
+			// access type, so it is fixed once the first user exists.
+			if hasUsers {
+				return nil, response.InvalidStateTransition(fmt.Sprintf("update: access_type of marketplace: %d cannot change once it has users", m.MarketPlaceID))
+			}
+		}
+		existing.AccessType = m.AccessType
+	}
+	if m.AllowBodyKey != nil {
+		existing.AllowBodyKey = m.AllowBodyKey
+	}
+	if m.OTPChannels != nil {
+		existing.OTPChannels = m.OTPChannels
+	}
+	if m.WebhookEvents != nil {
+		existing.WebhookEvents = m.WebhookEvents
+	}
+
+	err = validate(existing)
+	if err != nil {
+		return nil, response.InvalidData(fmt.Sprintf("update: %s", err))
+	}
+
+	err = u.store.Marketplaces.Update(ctx, existing)
+	if err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	return existing, nil
+}
+
+// Deactivate stops the marketplace from calling the API. Its users and tickets
+// are kept.
+func (u *Marketplace) Deactivate(ctx context.Context, marketplaceID int64) (*model.Marketplace, error) {
+	m, err := u.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.IsActive {
+		return m, nil
+	}
+
+	err = u.store.Marketplaces.Deactivate(ctx, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("deactivate: %w", err)
+	}
+	m.IsActive = false
+
+	return m, nil
+}
+
+// List returns every marketplace.
+func (u *Marketplace) List(ctx context.Context) ([]model.Marketplace, error) {
+	ms, err := u.store.Marketplaces.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+
+	return ms, nil
+}
+
+func validate(m *model.Marketplace) error {
+	if strings.TrimSpace(m.MarketPlaceName) == "" {
+		return fmt.Errorf("market_place_name is required")
+	}
+
+	if m.AccessType != constants.Interop && m.AccessType != constants.NonInterop {
+		return fmt.Errorf("invalid access_type: %s", m.AccessType)
+	}
+
+	if m.CallbackURL != nil && *m.CallbackURL != "" {
+		cb, err := url.Parse(*m.CallbackURL)
+		if err != nil || cb.Scheme != "https" || cb.Host == "" {
+			return fmt.Errorf("callback_url must be an absolute https URL: %s", *m.CallbackURL)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, channel := range m.OTPChannels {
+		if !contains(notify.PhoneChannels, channel) || seen[channel] {
+			return fmt.Errorf("invalid otp channel: %s", channel)
+		}
+		seen[channel] = true
+	}
+
+	seen = make(map[string]bool)
+	for _, e := range m.WebhookEvents {
+		if !webhook.Valid(e) || seen[e] {
+			return fmt.Errorf("invalid webhook event: %s", e)
+		}
+		seen[e] = true
+	}
+
+	for i, origin := range m.AllowedOrigins {
+		o, err := url.Parse(origin)
+		if err != nil || (o.Scheme != "https" && o.Scheme != "http") || o.Host == "" || (o.Path != "" && o.Path != "/") || o.RawQuery != "" {
+			return fmt.Errorf("invalid allowed origin: %s", origin)
+		}
+		m.AllowedOrigins[i] = o.Scheme + "://" + o.Host
+	}
+
+	return nil
+}
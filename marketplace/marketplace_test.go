@@ -0,0 +1,296 @@
+package marketplace
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"eventers-marketplace-backend/codec"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKEK = make([]byte, kekSize)
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	u := NewMarketplace(store.NewMemory().Store(), testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+	assert.Equal(t, constants.Interop, m.AccessType)
+	require.NotNil(t, m.Key)
+	assert.Equal(t, Scopes, m.Key.Scopes)
+
+	cred, err := u.Authenticate(ctx, m.Key.AccessKey)
+	require.Nil(t, err)
+	assert.Equal(t, m.MarketPlaceID, cred.Marketplace.MarketPlaceID)
+	assert.Equal(t, m.Key.KeyID, cred.KeyID)
+
+	_, err = u.Authenticate(ctx, m.Key.KeyID+".wrong")
+	assert.Equal(t, response.Unauthorized(), err)
+
+	ks, err := u.ListKeys(ctx, m.MarketPlaceID)
+	require.Nil(t, err)
+	require.Len(t, ks, 1)
+	assert.Empty(t, ks[0].AccessKey)
+	assert.NotContains(t, m.Key.AccessKey, ks[0].SecretHash)
+}
+
+func TestParseLegacyAccessKey(t *testing.T) {
+	keyID, secret := parseAccessKey("0123456789abcdef0123456789abcdef")
+	assert.Equal(t, "legacy_"+hashSecret("", secret)[:16], keyID)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", secret)
+}
+
+func TestRotateAccessKeyGrace(t *testing.T) {
+	u := NewMarketplace(store.NewMemory().Store(), testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+
+	k, err := u.RotateAccessKey(ctx, m.MarketPlaceID, nil, time.Hour)
+	require.Nil(t, err)
+
+	_, err = u.Authenticate(ctx, m.Key.AccessKey)
+	assert.Nil(t, err)
+
+	_, err = u.RotateAccessKey(ctx, m.MarketPlaceID, nil, 0)
+	require.Nil(t, err)
+
+	_, err = u.Authenticate(ctx, m.Key.AccessKey)
+	assert.Equal(t, response.Unauthorized(), err)
+	_, err = u.Authenticate(ctx, k.AccessKey)
+	assert.Equal(t, response.Unauthorized(), err)
+
+	require.Nil(t, u.RevokeKey(ctx, m.MarketPlaceID, m.Key.KeyID))
+	assert.IsType(t, response.ErrorResponse{}, u.RevokeKey(ctx, m.MarketPlaceID, m.Key.KeyID))
+}
+
+func TestUpdateAndDeactivate(t *testing.T) {
+	st := store.NewMemory().Store()
+	u := NewMarketplace(st, testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner", AllowedOrigins: []string{"https://shop.example.com/"}})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"https://shop.example.com"}, m.AllowedOrigins)
+	key := m.Key.AccessKey
+
+	callback := "http://shop.example.com/hook"
+	_, err = u.Update(ctx, &model.Marketplace{MarketPlaceID: m.MarketPlaceID, CallbackURL: &callback})
+	assert.IsType(t, response.ErrorResponse{}, err)
+
+	m, err = u.Update(ctx, &model.Marketplace{MarketPlaceID: m.MarketPlaceID, AccessType: constants.NonInterop})
+	require.Nil(t, err)
+	assert.Equal(t, "partner", m.MarketPlaceName)
+	assert.Equal(t, constants.NonInterop, m.AccessType)
+
+	_, err = st.Marketplaces.CreateUser(ctx, m.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+	_, err = u.Update(ctx, &model.Marketplace{MarketPlaceID: m.MarketPlaceID, AccessType: constants.Interop})
+	assert.IsType(t, response.ErrorResponse{}, err)
+
+	m, err = u.Deactivate(ctx, m.MarketPlaceID)
+	require.Nil(t, err)
+	assert.False(t, m.IsActive)
+
+	_, err = u.Authenticate(ctx, key)
+	assert.Equal(t, response.Unauthorized(), err)
+}
+
+func TestAuthorizeSignedAndBodyKey(t *testing.T) {
+	u := NewMarketplace(store.NewMemory().Store(), testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+
+	_, err = u.Authorize(ctx, m.Key.AccessKey, ScopeUsers)
+	assert.IsType(t, response.ErrorResponse{}, err)
+	assert.Equal(t, "FORBIDDEN", err.(response.ErrorResponse).Status)
+
+	msg := SigningString("POST", "/v1/marketplace/user/connect", "1700000000", "nonce", []byte(`{"data":{}}`))
+	signingKey, err := hex.DecodeString(m.Key.SigningKey)
+	require.Nil(t, err)
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(msg)
+
+	cred, err := u.AuthenticateSignature(ctx, m.Key.KeyID, msg, hex.EncodeToString(mac.Sum(nil)))
+	require.Nil(t, err)
+	assert.Equal(t, m.Key.KeyID, cred.KeyID)
+
+	authorized, err := u.Authorize(WithCredential(ctx, cred), "", ScopeUsers)
+	require.Nil(t, err)
+	assert.Equal(t, m.MarketPlaceID, authorized.Marketplace.MarketPlaceID)
+
+	_, err = u.AuthenticateSignature(ctx, m.Key.KeyID, append(msg, '.'), hex.EncodeToString(mac.Sum(nil)))
+	assert.Equal(t, response.Unauthorized(), err)
+}
+
+func TestSetPublicKey(t *testing.T) {
+	u := NewMarketplace(store.NewMemory().Store(), testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner", AccessType: constants.NonInterop})
+	require.Nil(t, err)
+	_, ok := DecodePublicKey(m)
+	assert.False(t, ok)
+
+	_, err = u.SetPublicKey(ctx, m.MarketPlaceID, "not a key")
+	assert.IsType(t, response.ErrorResponse{}, err)
+
+	pub, _, err := codec.GenerateKey()
+	require.Nil(t, err)
+	m, err = u.SetPublicKey(ctx, m.MarketPlaceID, base64.StdEncoding.EncodeToString(pub))
+	require.Nil(t, err)
+	require.NotNil(t, m.PublicKeyID)
+	assert.True(t, strings.HasPrefix(*m.PublicKeyID, "pk_"))
+
+	m, err = u.Get(ctx, m.MarketPlaceID)
+	require.Nil(t, err)
+	k, ok := DecodePublicKey(m)
+	assert.True(t, ok)
+	assert.Equal(t, pub, k)
+}
+
+func TestUserWallet(t *testing.T) {
+	st := store.NewMemory().Store()
+	u := NewMarketplace(st, testKEK)
+	ctx := context.Background()
+
+	interop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "interop"})
+	require.Nil(t, err)
+	nonInterop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "non-interop", AccessType: constants.NonInterop})
+	require.Nil(t, err)
+
+	id, err := st.Marketplaces.CreateUser(ctx, interop.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+
+	_, _, err = u.UserWallet(ctx, interop.MarketPlaceID, id)
+	assert.Equal(t, response.Unauthorized(), err)
+
+	require.Nil(t, st.Marketplaces.ValidateUser(ctx, id))
+	_, wallet, err := u.UserWallet(ctx, interop.MarketPlaceID, id)
+	require.Nil(t, err)
+	assert.Equal(t, "+15550100/0", wallet)
+
+	_, _, err = u.UserWallet(ctx, nonInterop.MarketPlaceID, id)
+	assert.Equal(t, response.Unauthorized(), err)
+
+	assert.Equal(t, fmt.Sprintf("+15550100/%d", nonInterop.MarketPlaceID), WalletPath(nonInterop, "+15550100"))
+}
+
+func TestLinkedUserTickets(t *testing.T) {
+	mem := store.NewMemory()
+	st := mem.Store()
+	u := NewMarketplace(st, testKEK)
+	ctx := context.Background()
+
+	interop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "interop"})
+	require.Nil(t, err)
+	nonInterop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "non-interop", AccessType: constants.NonInterop})
+	require.Nil(t, err)
+
+	code, number := "+1", "5550100"
+	mem.PutUser(model.User{UserID: 7, PhoneCountryCode: &code, PhoneNumber: &number, IsActive: true})
+	mem.PutEventTicket(model.EventTicket{EventTicketID: 1, CurrentHolderID: 7})
+	mem.PutEventTicket(model.EventTicket{EventTicketID: 2, HolderWallet: "+15550100/0"})
+	mem.PutEventTicket(model.EventTicket{EventTicketID: 3, CurrentHolderID: 8})
+
+	id, err := st.Marketplaces.CreateUser(ctx, interop.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+	userID, err := u.LinkedUser(ctx, interop, id)
+	require.Nil(t, err)
+	assert.Equal(t, int64(7), userID)
+
+	ets, err := st.Tickets.ListByWallet(ctx, "+15550100/0", userID)
+	require.Nil(t, err)
+	require.Len(t, ets, 2)
+	assert.Equal(t, int64(1), ets[0].EventTicketID)
+	assert.Equal(t, int64(2), ets[1].EventTicketID)
+
+	id, err = st.Marketplaces.CreateUser(ctx, nonInterop.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+	userID, err = u.LinkedUser(ctx, nonInterop, id)
+	require.Nil(t, err)
+	assert.Zero(t, userID)
+}
+
+func TestPortability(t *testing.T) {
+	st := store.NewMemory().Store()
+	u := NewMarketplace(st, testKEK)
+	ctx := context.Background()
+
+	interop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "interop"})
+	require.Nil(t, err)
+	partner, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+	nonInterop, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "non-interop", AccessType: constants.NonInterop})
+	require.Nil(t, err)
+
+	assert.Nil(t, CanTrade(interop, nil))
+	assert.Nil(t, CanTrade(interop, partner))
+	assert.Nil(t, CanTrade(nonInterop, nonInterop))
+	assert.IsType(t, response.ErrorResponse{}, CanTrade(interop, nonInterop))
+	assert.IsType(t, response.ErrorResponse{}, CanTrade(nonInterop, interop))
+
+	assert.IsType(t, response.ErrorResponse{}, CanBridge(interop))
+	assert.Nil(t, CanBridge(nonInterop))
+
+	id, err := st.Marketplaces.CreateUser(ctx, nonInterop.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+	require.Nil(t, st.Marketplaces.ValidateUser(ctx, id))
+
+	wallet, err := u.SharedWallet(ctx, nonInterop, id)
+	require.Nil(t, err)
+	assert.Equal(t, "+15550100/0", wallet)
+
+	_, err = u.SharedWallet(ctx, interop, id)
+	assert.IsType(t, response.ErrorResponse{}, err)
+}
+
+func TestRewrapKeys(t *testing.T) {
+	st := store.NewMemory().Store()
+	u := NewMarketplace(st, testKEK)
+	ctx := context.Background()
+
+	m, err := u.Create(ctx, &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+
+	stored, _, err := st.MarketplaceKeys.Get(ctx, m.Key.KeyID)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(stored.SigningKey, codec.Version1+"."))
+	assert.NotContains(t, stored.SigningKey, m.Key.SigningKey)
+
+	ok, err := st.MarketplaceKeys.SetSigningKey(ctx, m.Key.KeyID, stored.SigningKey, m.Key.SigningKey)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	n, err := u.RewrapKeys(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	msg := SigningString("POST", "/v1/marketplace/user/connect", "1700000000", "nonce", nil)
+	signingKey, err := hex.DecodeString(m.Key.SigningKey)
+	require.Nil(t, err)
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(msg)
+
+	_, err = u.AuthenticateSignature(ctx, m.Key.KeyID, msg, hex.EncodeToString(mac.Sum(nil)))
+	assert.Nil(t, err)
+
+	_, err = NewMarketplace(st, []byte("another key encryption key 32 b.")).AuthenticateSignature(ctx, m.Key.KeyID, msg, hex.EncodeToString(mac.Sum(nil)))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,73 @@
+package marketplace
+
+import (
+	"context"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+)
+
+// CanTrade reports whether a user of the buyer marketplace may buy a ticket
+// listed by a user of the seller marketplace. Tickets listed in the app, where
+// seller is nil, and within a marketplace can always be bought. Across
+// marketplaces only INTEROP partners trade with each other, tickets of a
+// NON_INTEROP marketplace have to be bridged to the shared wallet first.
+func CanTrade(buyer, seller *model.Marketplace) error {
+	if seller == nil || seller.MarketPlaceID == buyer.MarketPlaceID {
+		return nil
+	}
+
+	if buyer.AccessType == constants.Interop && seller.AccessType == constants.Interop {
+		return nil
+	}
+
+	return response.Forbidden(fmt.Sprintf("canTrade: marketplace: %d cannot trade tickets of marketplace: %d", buyer.MarketPlaceID, seller.MarketPlaceID))
+}
+
+// CanBridge reports whether tickets of the marketplace may be bridged to the
+// shared wallet. INTEROP marketplaces hold tickets in the shared wallet
+// already.
+func CanBridge(m *model.Marketplace) error {
+	if m.AccessType != constants.NonInterop {
+		return response.InvalidStateTransition(fmt.Sprintf("canBridge: marketplace: %d is %s, its tickets are in the shared wallet", m.MarketPlaceID, m.AccessType))
+	}
+
+	return nil
+}
+
+// SharedWallet returns the wallet the user of a NON_INTEROP marketplace shares
+// with INTEROP marketplaces, the one tickets are bridged to.
+func (u *Marketplace) SharedWallet(ctx context.Context, m *model.Marketplace, userMarketplaceID int64) (string, error) {
+	err := CanBridge(m)
+	if err != nil {
+		return "", err
+	}
+
+	user, ok, err := u.store.Marketplaces.GetUserByID(ctx, userMarketplaceID)
+	if err != nil {
+		return "", fmt.Errorf("sharedWallet: %w", err)
+	}
+
+	if !ok || !user.IsValid || user.MarketPlaceID != m.MarketPlaceID {
+		return "", response.Unauthorized()
+	}
+
+	return fmt.Sprintf("%s/%s", user.PhoneNumber, interopWallet), nil
+}
+
+// TicketMoves returns the ticket moves into and out of the marketplace that
+// partners settle on.
+func (u *Marketplace) TicketMoves(ctx context.Context, marketplaceID int64) ([]model.MarketplaceTicketMove, error) {
+	_, err := u.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	mvs, err := u.store.MarketplaceMoves.ListByMarketplace(ctx, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("ticketMoves: %w", err)
+	}
+
+	return mvs, nil
+}
@@ -0,0 +1,92 @@
+package marketplace
+
+import (
+	"context"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+)
+
+// interopWallet is the wallet suffix shared by every INTEROP marketplace.
+const interopWallet = "0"
+
+// WalletPath returns the vault path, below the user path, of the wallet the
+// user with the phone number holds tickets of the marketplace in. Users of
+// INTEROP marketplaces share one wallet across them, users of NON_INTEROP
+// marketplaces get one per marketplace.
+func WalletPath(m *model.Marketplace, phoneNumber string) string {
+	if m.AccessType == constants.Interop {
+		return fmt.Sprintf("%s/%s", phoneNumber, interopWallet)
+	}
+	return fmt.Sprintf("%s/%d", phoneNumber, m.MarketPlaceID)
+}
+
+// UserWallet returns the marketplace and the wallet of its verified user. Users
+// of deactivated marketplaces are unauthorized.
+func (u *Marketplace) UserWallet(ctx context.Context, marketplaceID, userMarketplaceID int64) (*model.Marketplace, string, error) {
+	m, ok, err := u.store.Marketplaces.Get(ctx, marketplaceID)
+	if err != nil {
+		return nil, "", fmt.Errorf("userWallet: %w", err)
+	}
+
+	if !ok || !m.IsActive {
+		return nil, "", response.Unauthorized()
+	}
+
+	user, ok, err := u.store.Marketplaces.GetUserByID(ctx, userMarketplaceID)
+	if err != nil {
+		return nil, "", fmt.Errorf("userWallet: %w", err)
+	}
+
+	if !ok || !user.IsValid || user.MarketPlaceID != marketplaceID {
+		return nil, "", response.Unauthorized()
+	}
+
+	return m, WalletPath(m, user.PhoneNumber), nil
+}
+
+// LinkedUser returns the id of the active eventers user with the phone number
+// of the marketplace user, whose tickets INTEROP marketplaces see alongside the
+// shared wallet. It is zero for NON_INTEROP marketplaces and when there is no
+// such user.
+func (u *Marketplace) LinkedUser(ctx context.Context, m *model.Marketplace, userMarketplaceID int64) (int64, error) {
+	if m.AccessType != constants.Interop {
+		return 0, nil
+	}
+
+	mu, ok, err := u.store.Marketplaces.GetUserByID(ctx, userMarketplaceID)
+	if err != nil {
+		return 0, fmt.Errorf("linkedUser: %w", err)
+	}
+
+	if !ok {
+		return 0, nil
+	}
+
+	user, ok, err := u.store.Users.FindByActivePhone(ctx, mu.PhoneNumber)
+	if err != nil {
+		return 0, fmt.Errorf("linkedUser: %w", err)
+	}
+
+	if !ok {
+		return 0, nil
+	}
+
+	return user.UserID, nil
+}
+
+// RecipientWallet returns the wallet of the verified user of the marketplace
+// with the phone number.
+func (u *Marketplace) RecipientWallet(ctx context.Context, m *model.Marketplace, phoneNumber string) (string, error) {
+	user, ok, err := u.store.Marketplaces.GetUser(ctx, m.MarketPlaceID, phoneNumber)
+	if err != nil {
+		return "", fmt.Errorf("recipientWallet: %w", err)
+	}
+
+	if !ok || !user.IsValid {
+		return "", response.ResourceNotFound("recipient not found", fmt.Sprintf("recipientWallet: no verified user of marketplace: %d with the phone number", m.MarketPlaceID))
+	}
+
+	return WalletPath(m, phoneNumber), nil
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"eventers-marketplace-backend/response"
+	"net/http"
+	"strings"
+)
+
+const (
+	AuthorizationHeader = "Authorization"
+	BearerPrefix        = "Bearer "
+)
+
+// AdminAuth only lets through requests carrying the platform admin token as a
+// bearer token. Every request is rejected when no token is configured.
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization := r.Header.Get(AuthorizationHeader)
+			if token == "" || !strings.HasPrefix(authorization, BearerPrefix) {
+				response.Unauthorized().Send(r.Context(), w)
+				return
+			}
+
+			presented := strings.TrimPrefix(authorization, BearerPrefix)
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				response.Unauthorized().Send(r.Context(), w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), "admin")))
+		})
+	}
+}
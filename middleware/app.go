@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"eventers-marketplace-backend/firebase"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// AppAuth verifies the firebase token in the auth section of the body of app
+// requests and marks the request with its user. Requests without a valid token
+// are passed on unmarked, handlers reject them.
+func AppAuth(projectID string, interval time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			var req struct {
+				Data struct {
+					Auth *struct {
+						TokenID string `json:"token_id"`
+					} `json:"auth"`
+				} `json:"data"`
+			}
+
+			if json.Unmarshal(body, &req) != nil || req.Data.Auth == nil || req.Data.Auth.TokenID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			uid, ok := firebase.VerifyJWTIDToken(req.Data.Auth.TokenID, projectID, interval)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), fmt.Sprintf("app-%s", uid))))
+		})
+	}
+}
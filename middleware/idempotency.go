@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyInFlight       = "IN_FLIGHT"
+	idempotencyDone           = "DONE"
+	maxIdempotencyKeyLength   = 255
+	idempotencyContentTypeKey = "Content-Type"
+)
+
+type idempotencyRecord struct {
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency replays the stored response of a mutating request that is retried
+// with the same Idempotency-Key header. It runs after authentication and keys
+// are scoped to the authenticated principal of the request, requests without
+// one are passed on as they are. A retry that arrives while the first request
+// is still running gets a 409, and a key reused with a different request gets a
+// 422. Server errors and responses marked no-store, which carry secrets, are
+// not stored, so that the request can be retried.
+func Idempotency(client *redis.Client, ttl, lockTTL time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+			if idempotencyKey == "" || !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			principal, ok := principalFrom(ctx)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(idempotencyKey) > maxIdempotencyKeyLength {
+				response.InvalidData(fmt.Sprintf("idempotency: key longer than %d characters", maxIdempotencyKeyLength)).Send(ctx, w)
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				response.BadRequest("invalid request body", fmt.Sprintf("idempotency: error reading request body: %+v", err)).Send(ctx, w)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			key := fmt.Sprintf("idempotency-%s-%s", principal, idempotencyKey)
+			fingerprint := requestFingerprint(r, body)
+
+			inFlight, _ := json.Marshal(idempotencyRecord{Status: idempotencyInFlight, Fingerprint: fingerprint})
+			acquired, err := client.SetNX(key, inFlight, lockTTL).Result()
+			if err != nil {
+				logger.Errorf(ctx, "idempotency: error acquiring key: %+v", err)
+				response.SomethingWrong().Send(ctx, w)
+				return
+			}
+
+			if !acquired {
+				replay(w, r, client, key, fingerprint)
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode >= http.StatusInternalServerError || rec.Header().Get(response.CacheControlHeader) == response.NoStore {
+				client.Del(key)
+				return
+			}
+
+			done, _ := json.Marshal(idempotencyRecord{
+				Status:      idempotencyDone,
+				Fingerprint: fingerprint,
+				StatusCode:  rec.statusCode,
+				ContentType: rec.Header().Get(idempotencyContentTypeKey),
+				Body:        rec.body.Bytes(),
+			})
+			err = client.Set(key, done, ttl).Err()
+			if err != nil {
+				logger.Errorf(ctx, "idempotency: error storing response for key: %s: %+v", key, err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, client *redis.Client, key, fingerprint string) {
+	ctx := r.Context()
+
+	val, err := client.Get(key).Bytes()
+	if err == redis.Nil {
+		response.Conflict("request is being processed", "idempotency: key expired while in flight, retry the request").Send(ctx, w)
+		return
+	}
+
+	if err != nil {
+		logger.Errorf(ctx, "idempotency: error fetching key: %+v", err)
+		response.SomethingWrong().Send(ctx, w)
+		return
+	}
+
+	var stored idempotencyRecord
+	err = json.Unmarshal(val, &stored)
+	if err != nil {
+		logger.Errorf(ctx, "idempotency: error decoding stored response: %+v", err)
+		response.SomethingWrong().Send(ctx, w)
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		response.ErrorResponse{
+			StatusCode:  http.StatusUnprocessableEntity,
+			Success:     false,
+			Message:     "Idempotency-Key reused with a different request",
+			Status:      "IDEMPOTENCY_KEY_REUSED",
+			Description: "idempotency: fingerprint mismatch",
+		}.Send(ctx, w)
+		return
+	}
+
+	if stored.Status == idempotencyInFlight {
+		response.Conflict("request is being processed", "idempotency: request with the same key is in flight").Send(ctx, w)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set(idempotencyContentTypeKey, stored.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	return hash(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+type recorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"bufio"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the SET, GET and DEL commands the idempotency middleware
+// sends, from memory. Expiry is ignored.
+func fakeRedis(t *testing.T) *redis.Client {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+
+					mu.Lock()
+					reply := execute(values, args)
+					mu.Unlock()
+
+					_, err = io.WriteString(conn, reply)
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: l.Addr().String()})
+	t.Cleanup(func() {
+		client.Close()
+		l.Close()
+	})
+	return client
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		b := make([]byte, size+2)
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func execute(values map[string]string, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		for _, opt := range args[3:] {
+			if _, ok := values[args[1]]; ok && strings.ToUpper(opt) == "NX" {
+				return "$-1\r\n"
+			}
+		}
+		values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		v, ok := values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "DEL":
+		_, ok := values[args[1]]
+		delete(values, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// asPrincipal authenticates every request as the principal of its X-Principal
+// header, the way the authentication middlewares do, and leaves requests
+// without one unauthenticated.
+func asPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := r.Header.Get("X-Principal"); p != "" {
+			r = r.WithContext(withPrincipal(r.Context(), p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countingHandler answers with the status code, and the number of requests
+// it served so far as the body.
+func countingHandler(calls *int32, statusCode int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	})
+}
+
+func send(h http.Handler, principal, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPatch, "/v1/public_event", strings.NewReader(body))
+	if principal != "" {
+		r.Header.Set("X-Principal", principal)
+	}
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func idempotent(client *redis.Client, next http.Handler) http.Handler {
+	return asPrincipal(Idempotency(client, time.Hour, time.Minute)(next))
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	var calls int32
+	h := idempotent(fakeRedis(t), countingHandler(&calls, http.StatusCreated))
+
+	first := send(h, "user-5", "k1", `{"buy":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	retry := send(h, "user-5", "k1", `{"buy":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The key is bound to the request it was first sent with.
+	reused := send(h, "user-5", "k1", `{"buy":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyConflictWhileInFlight(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	h := idempotent(fakeRedis(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send(h, "user-5", "k1", `{}`) }()
+	<-started
+
+	assert.Equal(t, http.StatusConflict, send(h, "user-5", "k1", `{}`).Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencySkipsServerErrors(t *testing.T) {
+	var calls int32
+	h := idempotent(fakeRedis(t), countingHandler(&calls, http.StatusInternalServerError))
+
+	assert.Equal(t, http.StatusInternalServerError, send(h, "user-5", "k1", `{}`).Code)
+
+	retry := send(h, "user-5", "k1", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, retry.Code)
+	assert.Empty(t, retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencySkipsNoStoreResponses(t *testing.T) {
+	var calls int32
+	h := idempotent(fakeRedis(t), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set(response.CacheControlHeader, response.NoStore)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"secret":"s"}`))
+	}))
+
+	send(h, "user-5", "k1", `{}`)
+	retry := send(h, "user-5", "k1", `{}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Empty(t, retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyKeysArePerPrincipal(t *testing.T) {
+	var calls int32
+	h := idempotent(fakeRedis(t), countingHandler(&calls, http.StatusOK))
+
+	first := send(h, "user-5", "k1", `{}`)
+	other := send(h, "user-9", "k1", `{}`)
+	assert.Empty(t, other.Header().Get(IdempotentReplayedHeader))
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyPassesRequestsWithoutPrincipal(t *testing.T) {
+	var calls int32
+	// Without a principal nothing is stored, so no redis client is needed.
+	h := idempotent(nil, countingHandler(&calls, http.StatusOK))
+
+	send(h, "", "k1", `{}`)
+	retry := send(h, "", "k1", `{}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Empty(t, retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+// A buy retried by a client that timed out, while the first attempt is still
+// running and once it finished, moves the asset once.
+func TestIdempotencyRetriedBuySendsAssetOnce(t *testing.T) {
+	var sends int32
+	started, release := make(chan struct{}), make(chan struct{})
+	buy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&sends, 1) == 1 {
+			close(started)
+		}
+		<-release
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"event_ticket_id":1}`))
+	})
+	h := idempotent(fakeRedis(t), buy)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send(h, "user-5", "buy-1", `{"data":{"ticket":{"public_event_id":7}}}`) }()
+	<-started
+
+	assert.Equal(t, http.StatusConflict, send(h, "user-5", "buy-1", `{"data":{"ticket":{"public_event_id":7}}}`).Code)
+
+	close(release)
+	first := <-done
+	require.Equal(t, http.StatusOK, first.Code)
+
+	retry := send(h, "user-5", "buy-1", `{"data":{"ticket":{"public_event_id":7}}}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&sends))
+}
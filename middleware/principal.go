@@ -0,0 +1,17 @@
+package middleware
+
+import "context"
+
+type principalKey struct{}
+
+// withPrincipal returns a copy of ctx carrying the authenticated caller of the
+// request. Only authentication middlewares set it, once the caller is verified.
+func withPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// principalFrom returns the authenticated caller of the request ctx belongs to.
+func principalFrom(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	return p, ok && p != ""
+}
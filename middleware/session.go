@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/session"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MarketplaceSession only lets through requests carrying an access token of a
+// live marketplace user session as a bearer token. Verified requests carry the
+// token claims in their context.
+func MarketplaceSession(sessions *session.Sessions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			authorization := r.Header.Get(AuthorizationHeader)
+			if !strings.HasPrefix(authorization, BearerPrefix) {
+				response.Unauthorized().Send(ctx, w)
+				return
+			}
+
+			claims, err := sessions.Authenticate(ctx, strings.TrimPrefix(authorization, BearerPrefix))
+			if err != nil {
+				if e, ok := err.(response.ErrorResponse); ok {
+					e.Send(ctx, w)
+					return
+				}
+				logger.Errorf(ctx, "marketplaceSession: %+v", err)
+				response.SomethingWrong().Send(ctx, w)
+				return
+			}
+
+			ctx = withPrincipal(ctx, fmt.Sprintf("session-%d-%s", claims.MarketplaceID, claims.Subject))
+			next.ServeHTTP(w, r.WithContext(session.WithClaims(ctx, claims)))
+		})
+	}
+}
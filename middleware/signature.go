@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	MarketplaceKeyIDHeader     = "X-Marketplace-Key-Id"
+	MarketplaceTimestampHeader = "X-Marketplace-Timestamp"
+	MarketplaceNonceHeader     = "X-Marketplace-Nonce"
+	MarketplaceSignatureHeader = "X-Marketplace-Signature"
+	maxNonceLength             = 64
+)
+
+// MarketplaceSignature verifies requests a marketplace signed with its signing
+// key, see marketplace.SigningString. The timestamp has to lie within window of
+// the server clock and every nonce is only accepted once per key. Verified
+// requests carry the credential in their context. Unsigned requests are passed
+// on, their access key is checked by the service, and are not idempotent.
+func MarketplaceSignature(client *redis.Client, service *marketplace.Marketplace, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keyID := r.Header.Get(MarketplaceKeyIDHeader)
+			if keyID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			timestamp := r.Header.Get(MarketplaceTimestampHeader)
+			nonce := r.Header.Get(MarketplaceNonceHeader)
+			signature := r.Header.Get(MarketplaceSignatureHeader)
+			if nonce == "" || len(nonce) > maxNonceLength || signature == "" {
+				response.BadRequest("invalid request signature", "marketplaceSignature: nonce and signature are required").Send(ctx, w)
+				return
+			}
+
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				response.BadRequest("invalid request signature", fmt.Sprintf("marketplaceSignature: invalid timestamp: %s", timestamp)).Send(ctx, w)
+				return
+			}
+
+			skew := time.Since(time.Unix(seconds, 0))
+			if skew > window || skew < -window {
+				response.Unauthorized().Send(ctx, w)
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				response.BadRequest("invalid request body", fmt.Sprintf("marketplaceSignature: error reading request body: %+v", err)).Send(ctx, w)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			msg := marketplace.SigningString(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+			cred, err := service.AuthenticateSignature(ctx, keyID, msg, signature)
+			if err != nil {
+				if e, ok := err.(response.ErrorResponse); ok {
+					e.Send(ctx, w)
+					return
+				}
+				logger.Errorf(ctx, "marketplaceSignature: %+v", err)
+				response.SomethingWrong().Send(ctx, w)
+				return
+			}
+
+			// A nonce only has to be remembered for as long as its timestamp
+			// is accepted, which is up to window on either side of now.
+			fresh, err := client.SetNX(fmt.Sprintf("marketplace-nonce-%s-%s", keyID, nonce), timestamp, 2*window).Result()
+			if err != nil {
+				logger.Errorf(ctx, "marketplaceSignature: error storing nonce: %+v", err)
+				response.SomethingWrong().Send(ctx, w)
+				return
+			}
+
+			if !fresh {
+				response.Conflict("request was already received", fmt.Sprintf("marketplaceSignature: nonce: %s reused", nonce)).Send(ctx, w)
+				return
+			}
+
+			ctx = withPrincipal(ctx, fmt.Sprintf("marketplace-%d", cred.Marketplace.MarketPlaceID))
+			next.ServeHTTP(w, r.WithContext(marketplace.WithCredential(ctx, cred)))
+		})
+	}
+}
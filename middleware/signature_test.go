@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureRejectsReplayedIdempotentRequest(t *testing.T) {
+	service := marketplace.NewMarketplace(store.NewMemory().Store(), make([]byte, 32))
+	m, err := service.Create(context.Background(), &model.Marketplace{MarketPlaceName: "partner"})
+	require.Nil(t, err)
+
+	window := time.Minute
+	body := `{"data":{"user":{"phone_number":"5550100"}}}`
+	timestamp := strconv.FormatInt(time.Now().Add(-2*window).Unix(), 10)
+	signingKey, err := hex.DecodeString(m.Key.SigningKey)
+	require.Nil(t, err)
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(marketplace.SigningString(http.MethodPost, "/v1/marketplace/user/connect", timestamp, "nonce", []byte(body)))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/marketplace/user/connect", strings.NewReader(body))
+	r.Header.Set(MarketplaceKeyIDHeader, m.Key.KeyID)
+	r.Header.Set(MarketplaceTimestampHeader, timestamp)
+	r.Header.Set(MarketplaceNonceHeader, "nonce")
+	r.Header.Set(MarketplaceSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	r.Header.Set(IdempotencyKeyHeader, "retry-1")
+
+	// The captured request is replayed once its timestamp left the window. It
+	// has to be turned away before a stored response could be looked up, which
+	// with no redis client would panic.
+	var reached bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })
+	h := MarketplaceSignature(nil, service, window)(Idempotency(nil, time.Hour, time.Minute)(next))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.False(t, reached)
+}
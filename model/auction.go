@@ -0,0 +1,80 @@
+package model
+
+import "time"
+
+// Buyer is who makes an offer or a bid: a user of the app or, through its
+// wallet, a user of a marketplace.
+type Buyer struct {
+	UserID            int64  `json:"user_id,omitempty"`
+	Wallet            string `json:"wallet,omitempty"`
+	MarketplaceID     int64  `json:"marketplace_id,omitempty"`
+	UserMarketplaceID int64  `json:"user_marketplace_id,omitempty"`
+}
+
+// Resale offer statuses.
+const (
+	OfferPending  = "PENDING"
+	OfferAccepted = "ACCEPTED"
+	OfferRejected = "REJECTED"
+	OfferExpired  = "EXPIRED"
+)
+
+// Auction statuses.
+const (
+	AuctionOpen     = "OPEN"
+	AuctionSettling = "SETTLING"
+	AuctionSettled  = "SETTLED"
+	AuctionUnsold   = "UNSOLD"
+)
+
+// Auction bid statuses.
+const (
+	BidLeading = "LEADING"
+	BidOutbid  = "OUTBID"
+	BidWon     = "WON"
+	BidLost    = "LOST"
+)
+
+// ResaleOffer is a price a buyer offers for a listed ticket. Offers are PENDING
+// until the seller accepts or rejects them or the listing ends.
+type ResaleOffer struct {
+	OfferID       int64 `json:"offer_id,omitempty"`
+	ListingID     int64 `json:"listing_id,omitempty"`
+	EventTicketID int64 `json:"event_ticket_id,omitempty"`
+	Buyer
+	Amount    uint64     `json:"amount,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Auction sells a ticket of the organizer to the highest bidder once EndsAt
+// passes. The first bid has to reach ReservePrice, later ones have to beat the
+// leading bid by MinIncrement. Auctions are created for a ticket of the tier
+// and seat, like reservations.
+type Auction struct {
+	AuctionID     int64      `json:"auction_id,omitempty"`
+	PublicEventID int64      `json:"public_event_id,omitempty"`
+	EventTicketID int64      `json:"event_ticket_id,omitempty"`
+	TicketTierID  int64      `json:"ticket_tier_id,omitempty"`
+	SeatID        int64      `json:"seat_id,omitempty"`
+	ReservePrice  uint64     `json:"reserve_price,omitempty"`
+	MinIncrement  uint64     `json:"min_increment,omitempty"`
+	StartsAt      *time.Time `json:"starts_at,omitempty"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	LeadingBidID  int64      `json:"leading_bid_id,omitempty"`
+	LeadingAmount uint64     `json:"leading_amount,omitempty"`
+	ReservationID string     `json:"-"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+type AuctionBid struct {
+	BidID     int64 `json:"bid_id,omitempty"`
+	AuctionID int64 `json:"auction_id,omitempty"`
+	Buyer
+	Amount    uint64     `json:"amount,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
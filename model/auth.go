@@ -11,4 +11,16 @@ type Auth struct {
 	OTP            string  `json:"otp,omitempty"`
 	UserID         int64   `json:"user_id,omitempty"`
 	AccessKey      string  `json:"access_key,omitempty"`
+	VerificationID string  `json:"verification_id,omitempty"`
+	RefreshToken   string  `json:"refresh_token,omitempty"`
+}
+
+// SessionToken is issued to a marketplace user once their phone number is
+// verified. Expiries are in seconds.
+type SessionToken struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in,omitempty"`
 }
@@ -4,37 +4,276 @@ import (
 	"time"
 )
 
+// Public event statuses.
+const (
+	EventDraft       = "DRAFT"
+	EventPublished   = "PUBLISHED"
+	EventSalesClosed = "SALES_CLOSED"
+	EventCancelled   = "CANCELLED"
+	EventCompleted   = "COMPLETED"
+)
+
+// Event ticket statuses.
+const (
+	TicketActive    = "ACTIVE"
+	TicketResale    = "RESELL"
+	TicketRedeemed  = "REDEEM"
+	TicketCancelled = "CANCELLED"
+)
+
+// Reservation statuses.
+const (
+	ReservationActive    = "ACTIVE"
+	ReservationCompleted = "COMPLETED"
+	ReservationReleased  = "RELEASED"
+	ReservationExpired   = "EXPIRED"
+)
+
+// Resale listing statuses.
+const (
+	ListingOpen      = "OPEN"
+	ListingAccepted  = "ACCEPTED"
+	ListingSold      = "SOLD"
+	ListingCancelled = "CANCELLED"
+	ListingExpired   = "EXPIRED"
+)
+
+// Ticket move states and kinds.
+const (
+	MovePending        = "PENDING"
+	MoveChainSubmitted = "CHAIN_SUBMITTED"
+	MoveConfirmed      = "CONFIRMED"
+	MoveApplied        = "APPLIED"
+	MoveFailed         = "FAILED"
+
+	MoveSend      = "SEND"
+	MoveBuy       = "BUY"
+	MoveBuyResell = "BUY_RESELL"
+	MoveBridge    = "BRIDGE"
+)
+
 type PublicEvents struct {
 	PublicEvent PublicEvent `json:"public_event"`
 	EventTicket interface{} `json:"event_ticket"`
 }
 
+// PublicEventFilter narrows down and orders the catalogue of published events.
+// Events starting in [From, To) whose title or description contain Search and
+// whose lowest ticket price is within [MinPrice, MaxPrice] are returned, nil
+// bounds do not apply. Available keeps the events with tickets on sale, from
+// the organizer or resold, when true and the sold out ones when false. Pages of
+// Limit events start after Cursor, the NextCursor of the previous page.
+type PublicEventFilter struct {
+	From      *time.Time
+	To        *time.Time
+	Search    string
+	MinPrice  *uint64
+	MaxPrice  *uint64
+	Available *bool
+	Sort      string
+	Cursor    string
+	Limit     int
+}
+
+// PublicEventPage is a page of the catalogue. TotalCount counts every event
+// matching the filter, across pages.
+type PublicEventPage struct {
+	PublicEvents []PublicEvents `json:"public_events"`
+	TotalCount   int64          `json:"total_count"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+}
+
 type PublicEvent struct {
+	PublicEventID    int64         `json:"public_event_id,omitempty"`
+	DateTime         *time.Time    `json:"date_time,omitempty"`
+	EventTitle       *string       `json:"event_title,omitempty"`
+	EventDescription *string       `json:"event_description,omitempty"`
+	EventImage       *string       `json:"event_image,omitempty"`
+	TotalTickets     uint64        `json:"total_tickets,omitempty"`
+	TicketPrice      uint64        `json:"ticket_price,omitempty"`
+	FromPrice        uint64        `json:"from_price,omitempty"`
+	AvailableTickets uint64        `json:"available_tickets,omitempty"`
+	BusinessUserID   int64         `json:"business_user_id,omitempty"`
+	Status           *string       `json:"status,omitempty"`
+	VenueID          int64         `json:"venue_id,omitempty"`
+	TicketTiers      []TicketTier  `json:"ticket_tiers,omitempty"`
+	ResalePolicy     *ResalePolicy `json:"resale_policy,omitempty"`
+}
+
+// ResalePolicy limits how tickets of a public event are resold. Royalty and
+// platform fee are in basis points of the resale price, the markup in basis
+// points of the face value of the ticket tier. Resale closes CutoffMinutes
+// before the event starts and a ticket that changed hands MaxTransfers times
+// can no longer be resold. A nil cap or limit does not apply.
+type ResalePolicy struct {
+	MaxMarkupBps   *uint64 `json:"max_markup_bps,omitempty"`
+	RoyaltyBps     uint64  `json:"royalty_bps"`
+	PlatformFeeBps uint64  `json:"platform_fee_bps"`
+	CutoffMinutes  uint64  `json:"cutoff_minutes"`
+	MaxTransfers   *uint64 `json:"max_transfers,omitempty"`
+}
+
+// ResalePayout is how the price of a resold ticket is split between the
+// seller, the organizer and the platform when the purchase settles.
+type ResalePayout struct {
+	ResalePayoutID  int64      `json:"resale_payout_id"`
+	MoveID          int64      `json:"move_id"`
+	EventTicketID   int64      `json:"event_ticket_id"`
+	PublicEventID   int64      `json:"public_event_id"`
+	SellerUserID    int64      `json:"seller_user_id,omitempty"`
+	SellerWallet    string     `json:"seller_wallet,omitempty"`
+	OrganizerUserID int64      `json:"organizer_user_id"`
+	Gross           uint64     `json:"gross"`
+	Royalty         uint64     `json:"royalty"`
+	PlatformFee     uint64     `json:"platform_fee"`
+	SellerAmount    uint64     `json:"seller_amount"`
+	Status          string     `json:"status"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+}
+
+type TicketTier struct {
+	TicketTierID     int64      `json:"ticket_tier_id,omitempty"`
 	PublicEventID    int64      `json:"public_event_id,omitempty"`
-	DateTime         *time.Time `json:"date_time,omitempty"`
-	EventTitle       *string    `json:"event_title,omitempty"`
-	EventDescription *string    `json:"event_description,omitempty"`
-	EventImage       *string    `json:"event_image,omitempty"`
+	TierName         *string    `json:"tier_name,omitempty"`
 	TotalTickets     uint64     `json:"total_tickets,omitempty"`
 	TicketPrice      uint64     `json:"ticket_price,omitempty"`
+	SaleStart        *time.Time `json:"sale_start,omitempty"`
+	SaleEnd          *time.Time `json:"sale_end,omitempty"`
+	PerUserLimit     uint64     `json:"per_user_limit,omitempty"`
+	AvailableTickets uint64     `json:"available_tickets"`
 }
 
 type EventTicket struct {
-	EventTicketID     int64   `json:"event_ticket_id,omitempty"`
-	BusinessUserID    int64   `json:"business_user_id,omitempty"`
-	PublicEventID     int64   `json:"public_event_id,omitempty"`
-	AssetID           uint64  `json:"asset_id,omitempty"`
-	CurrentHolderID   int64   `json:"current_holder_id,omitempty"`
-	Status            *string `json:"status,omitempty"`
-	AvailableToResell *bool   `json:"available_to_resell,omitempty"`
-	Price             uint64  `json:"price,omitempty"`
+	EventTicketID       int64   `json:"event_ticket_id,omitempty"`
+	BusinessUserID      int64   `json:"business_user_id,omitempty"`
+	PublicEventID       int64   `json:"public_event_id,omitempty"`
+	AssetID             uint64  `json:"asset_id,omitempty"`
+	CurrentHolderID     int64   `json:"current_holder_id,omitempty"`
+	Status              *string `json:"status,omitempty"`
+	AvailableToResell   *bool   `json:"available_to_resell,omitempty"`
+	Price               uint64  `json:"price,omitempty"`
+	TicketTierID        int64   `json:"ticket_tier_id,omitempty"`
+	SeatID              int64   `json:"seat_id,omitempty"`
+	HolderWallet        string  `json:"holder_wallet,omitempty"`
+	HolderMarketplaceID int64   `json:"holder_marketplace_id,omitempty"`
+	TransferCount       uint64  `json:"transfer_count,omitempty"`
 }
 
 type Ticket struct {
 	EventTicketID int64   `json:"event_ticket_id,omitempty"`
 	PublicEventID int64   `json:"public_event_id,omitempty"`
+	TicketTierID  int64   `json:"ticket_tier_id,omitempty"`
+	SeatID        int64   `json:"seat_id,omitempty"`
+	ReservationID string  `json:"reservation_id,omitempty"`
 	FromUserID    int64   `json:"from_user_id,omitempty"`
 	ToUserID      int64   `json:"to_user_id,omitempty"`
 	Status        *string `json:"status,omitempty"`
 	PriceToResell int64   `json:"price_to_resell,omitempty"`
+	// ListingExpiresAt ends the resale listing earlier than the default.
+	ListingExpiresAt *time.Time `json:"listing_expires_at,omitempty"`
+}
+
+// TicketRefund is owed to the holder of a ticket sold for an event that was
+// cancelled. Refunds are PENDING until they are paid out.
+type TicketRefund struct {
+	TicketRefundID int64      `json:"ticket_refund_id,omitempty"`
+	EventTicketID  int64      `json:"event_ticket_id,omitempty"`
+	PublicEventID  int64      `json:"public_event_id,omitempty"`
+	UserID         int64      `json:"user_id,omitempty"`
+	Wallet         string     `json:"wallet,omitempty"`
+	Amount         uint64     `json:"amount,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+type Reservation struct {
+	ReservationID string     `json:"reservation_id,omitempty"`
+	EventTicketID int64      `json:"event_ticket_id,omitempty"`
+	PublicEventID int64      `json:"public_event_id,omitempty"`
+	TicketTierID  int64      `json:"ticket_tier_id,omitempty"`
+	SeatID        int64      `json:"seat_id,omitempty"`
+	UserID        int64      `json:"user_id,omitempty"`
+	Wallet        string     `json:"wallet,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// ResaleListing is a ticket put up for resale at AskPrice until ExpiresAt.
+// Listings are OPEN until the ticket is sold or the listing is cancelled or
+// expires.
+type ResaleListing struct {
+	ListingID     int64      `json:"listing_id,omitempty"`
+	EventTicketID int64      `json:"event_ticket_id,omitempty"`
+	PublicEventID int64      `json:"public_event_id,omitempty"`
+	SellerUserID  int64      `json:"seller_user_id,omitempty"`
+	SellerWallet  string     `json:"seller_wallet,omitempty"`
+	AskPrice      uint64     `json:"ask_price,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+type TicketMove struct {
+	MoveID            int64      `json:"move_id,omitempty"`
+	Kind              string     `json:"kind,omitempty"`
+	EventTicketID     int64      `json:"event_ticket_id,omitempty"`
+	AssetID           uint64     `json:"asset_id,omitempty"`
+	FromUserID        int64      `json:"from_user_id,omitempty"`
+	ToUserID          int64      `json:"to_user_id,omitempty"`
+	FromWallet        string     `json:"from_wallet,omitempty"`
+	ToWallet          string     `json:"to_wallet,omitempty"`
+	FromMarketplaceID int64      `json:"from_marketplace_id,omitempty"`
+	ToMarketplaceID   int64      `json:"to_marketplace_id,omitempty"`
+	ExpectedStatus    string     `json:"expected_status,omitempty"`
+	NewStatus         string     `json:"new_status,omitempty"`
+	ReservationID     *string    `json:"reservation_id,omitempty"`
+	Amount            *uint64    `json:"amount,omitempty"`
+	State             string     `json:"state,omitempty"`
+	Attempts          int        `json:"attempts,omitempty"`
+	Version           int        `json:"version,omitempty"`
+	LastError         *string    `json:"last_error,omitempty"`
+	SubmittedDate     *time.Time `json:"submitted_date,omitempty"`
+	UpdatedDate       *time.Time `json:"updated_date,omitempty"`
+}
+
+// MarketplaceTicketMove records a ticket move between two marketplaces, or
+// between a marketplace and the app, for partner settlement. Amount is the
+// price the ticket changed hands at, zero for transfers and bridges.
+type MarketplaceTicketMove struct {
+	MarketplaceMoveID int64      `json:"marketplace_move_id"`
+	MoveID            int64      `json:"move_id"`
+	EventTicketID     int64      `json:"event_ticket_id"`
+	Kind              string     `json:"kind"`
+	FromMarketplaceID int64      `json:"from_marketplace_id,omitempty"`
+	ToMarketplaceID   int64      `json:"to_marketplace_id,omitempty"`
+	FromWallet        string     `json:"from_wallet,omitempty"`
+	ToWallet          string     `json:"to_wallet,omitempty"`
+	Amount            uint64     `json:"amount,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+}
+
+// TicketReconciliation compares a ticket with the chain. InSync is set when the
+// holder recorded in the database is the one holding the asset.
+type TicketReconciliation struct {
+	EventTicket   EventTicket `json:"event_ticket"`
+	HolderAddress string      `json:"holder_address"`
+	HolderBalance uint64      `json:"holder_balance"`
+	InSync        bool        `json:"in_sync"`
+	LatestMove    *TicketMove `json:"latest_move,omitempty"`
+}
+
+// Custody is what a user holds according to the database and the chain.
+// UntrackedAssets are assets the account holds that back no ticket of the user.
+type Custody struct {
+	UserID          int64             `json:"user_id"`
+	Address         string            `json:"address"`
+	MicroAlgos      uint64            `json:"micro_algos"`
+	Tickets         []CustodyTicket   `json:"tickets"`
+	UntrackedAssets map[uint64]uint64 `json:"untracked_assets"`
+}
+
+type CustodyTicket struct {
+	EventTicket
+	OnChain bool `json:"on_chain"`
 }
@@ -17,3 +17,48 @@ type PublicEventUpdateReq struct {
 type PublicEventResponse struct {
 	Data interface{} `json:"data"`
 }
+
+type ReservationRequest struct {
+	Data struct {
+		Reservation *Reservation `json:"reservation,omitempty"`
+		Auth        *Auth        `json:"auth,omitempty" validate:"required"`
+	} `json:"data"`
+}
+
+// ResaleListingRequest reprices or cancels a resale listing. Auth is sent by
+// the app, marketplaces act for the user of their session.
+type ResaleListingRequest struct {
+	Data struct {
+		Listing *ResaleListing `json:"listing,omitempty"`
+		Auth    *Auth          `json:"auth,omitempty"`
+	} `json:"data"`
+}
+
+// OfferRequest places or answers an offer. Auth is sent by the app,
+// marketplaces act for the user of their session.
+type OfferRequest struct {
+	Data struct {
+		Offer *ResaleOffer `json:"offer,omitempty"`
+		Auth  *Auth        `json:"auth,omitempty"`
+	} `json:"data"`
+}
+
+// AuctionRequest creates an auction or places a bid on one. Auth is sent by
+// the app, marketplaces act for the user of their session.
+type AuctionRequest struct {
+	Data struct {
+		Auction *Auction    `json:"auction,omitempty"`
+		Bid     *AuctionBid `json:"bid,omitempty"`
+		Auth    *Auth       `json:"auth,omitempty"`
+	} `json:"data"`
+}
+
+// MarketplaceTicketRequest is sent by a marketplace on behalf of the user of
+// its session. Transfers name the receiving user of the marketplace by phone
+// number.
+type MarketplaceTicketRequest struct {
+	Data struct {
+		Ticket *Ticket          `json:"ticket,omitempty" validate:"required"`
+		To     *MarketplaceUser `json:"to_user_marketplace,omitempty"`
+	} `json:"data"`
+}
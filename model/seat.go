@@ -0,0 +1,51 @@
+package model
+
+import "fmt"
+
+type Venue struct {
+	VenueID   int64   `json:"venue_id,omitempty"`
+	VenueName *string `json:"venue_name,omitempty"`
+	Seats     []Seat  `json:"seats,omitempty"`
+}
+
+type Seat struct {
+	SeatID        int64  `json:"seat_id,omitempty"`
+	VenueID       int64  `json:"venue_id,omitempty"`
+	Section       string `json:"section"`
+	Row           string `json:"row"`
+	Number        string `json:"seat"`
+	Tier          string `json:"tier,omitempty"`
+	Status        string `json:"status,omitempty"`
+	EventTicketID int64  `json:"event_ticket_id,omitempty"`
+}
+
+// Label returns the human readable position of the seat, e.g. A-12-7.
+func (s Seat) Label() string {
+	return fmt.Sprintf("%s-%s-%s", s.Section, s.Row, s.Number)
+}
+
+type SeatMapFile struct {
+	VenueName string `json:"venue_name,omitempty"`
+	Format    string `json:"format,omitempty" validate:"required"`
+	Content   string `json:"content,omitempty" validate:"required"`
+}
+
+type SeatMapRequest struct {
+	Data struct {
+		SeatMap *SeatMapFile `json:"seat_map,omitempty" validate:"required"`
+		Auth    *Auth        `json:"auth,omitempty" validate:"required"`
+	} `json:"data"`
+}
+
+type SeatHold struct {
+	PublicEventID int64 `json:"public_event_id,omitempty"`
+	SeatID        int64 `json:"seat_id,omitempty"`
+	UserID        int64 `json:"user_id,omitempty"`
+	ExpiresIn     int64 `json:"expires_in,omitempty"`
+}
+
+type SeatHoldRequest struct {
+	Data struct {
+		Auth *Auth `json:"auth,omitempty" validate:"required"`
+	} `json:"data"`
+}
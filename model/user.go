@@ -87,11 +87,75 @@ type MarketplaceUser struct {
 	IsValid          bool   `json:"is_valid,omitempty"`
 	AccountAddress    string `json:"account_address,omitempty"`
 	AccountPassphrase string `json:"account_passphrase,omitempty"`
+	EmailAddress      string `json:"email_address,omitempty"`
+	Locale            string `json:"locale,omitempty"`
 }
 
+// OTPAudit records the outcome of sending or verifying an OTP.
+type OTPAudit struct {
+	OTPAuditID        int64      `json:"otp_audit_id"`
+	MarketPlaceID     int64      `json:"market_place_id"`
+	UserMarketplaceID int64      `json:"user_marketplace_id,omitempty"`
+	PhoneNumber       string     `json:"phone_number,omitempty"`
+	IPAddress         string     `json:"ip_address,omitempty"`
+	Event             string     `json:"event"`
+	Channel           string     `json:"channel,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+}
+
+// Marketplace is a partner allowed to call the marketplace API. Key is only
+// filled in when a key is issued. PublicKey is the base64 X25519 key wallet
+// material is sealed to. OTPChannels is the order of the phone channels OTPs are
+// delivered in, every one of them when empty. WebhookEvents are the events delivered to the
+// callback URL, signed with WebhookSecret which is only filled in when issued.
 type Marketplace struct {
-	MarketPlaceID   int64  `json:"market_place_id"`
-	MarketPlaceName string `json:"market_place_name"`
-	AccessKey       string `json:"access_key"`
-	AccessType      string `json:"access_type"`
+	MarketPlaceID   int64           `json:"market_place_id"`
+	MarketPlaceName string          `json:"market_place_name"`
+	DisplayName     *string         `json:"display_name,omitempty"`
+	CallbackURL     *string         `json:"callback_url,omitempty"`
+	AllowedOrigins  []string        `json:"allowed_origins,omitempty"`
+	AccessType      string          `json:"access_type"`
+	IsActive        bool            `json:"is_active"`
+	AllowBodyKey    *bool           `json:"allow_body_key,omitempty"`
+	PublicKeyID     *string         `json:"public_key_id,omitempty"`
+	PublicKey       *string         `json:"public_key,omitempty"`
+	OTPChannels     []string        `json:"otp_channels,omitempty"`
+	WebhookEvents   []string        `json:"webhook_events,omitempty"`
+	WebhookSecret   string          `json:"webhook_secret,omitempty"`
+	Key             *MarketplaceKey `json:"key,omitempty"`
+}
+
+// MarketplaceKey is an access key of a marketplace. Only a salted hash of the
+// secret and the signing key derived from it are stored, AccessKey is only set
+// when the key is issued.
+type MarketplaceKey struct {
+	KeyID         string     `json:"key_id"`
+	MarketPlaceID int64      `json:"market_place_id"`
+	Salt          string     `json:"-"`
+	SecretHash    string     `json:"-"`
+	SigningKey    string     `json:"signing_key,omitempty"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	AccessKey     string     `json:"access_key,omitempty"`
+}
+
+type MarketplaceKeyRequest struct {
+	Data struct {
+		Scopes    []string `json:"scopes,omitempty"`
+		ExpiresIn int64    `json:"expires_in,omitempty"`
+	} `json:"data"`
+}
+
+type MarketplacePublicKeyRequest struct {
+	Data struct {
+		PublicKey string `json:"public_key"`
+	} `json:"data"`
+}
+
+type MarketplaceRequest struct {
+	Data struct {
+		Marketplace *Marketplace `json:"marketplace,omitempty" validate:"required"`
+	} `json:"data"`
 }
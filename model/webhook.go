@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEvent is the body posted to the callback URL of a marketplace. ID is
+// shared by the deliveries of the event to every marketplace.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Webhook delivery statuses.
+const (
+	WebhookPending   = "PENDING"
+	WebhookDelivered = "DELIVERED"
+	WebhookDead      = "DEAD"
+)
+
+// WebhookDelivery is the delivery of an event to a marketplace. Deliveries are
+// retried until they are DELIVERED or, once out of attempts, DEAD.
+type WebhookDelivery struct {
+	DeliveryID     int64      `json:"delivery_id"`
+	MarketPlaceID  int64      `json:"market_place_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// MintSummary is the data of the event.minted webhook, sent once every ticket
+// of a published event was minted or failed to.
+type MintSummary struct {
+	PublicEventID int64 `json:"public_event_id"`
+	Minted        int   `json:"minted"`
+	Failed        int   `json:"failed"`
+}
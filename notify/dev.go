@@ -0,0 +1,8 @@
+//go:build dev
+// +build dev
+
+package notify
+
+// Dev reports whether this is a development build, the only one the log
+// channel may be configured in.
+const Dev = true
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type email struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewEmail returns the channel sending mail through the SMTP server at addr,
+// host:port. Without a username the server is used without authentication.
+func NewEmail(addr, username, password, from string) Channel {
+	e := &email{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		e.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return e
+}
+
+func (e *email) Name() string { return ChannelEmail }
+
+func (e *email) Send(ctx context.Context, to Recipient, m Message) (string, error) {
+	if to.Email == "" {
+		return "", ErrNoAddress
+	}
+
+	if strings.ContainsAny(to.Email, "\r\n") {
+		return "", fmt.Errorf("send: invalid email address: %q", to.Email)
+	}
+
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("send: error reading random bytes: %w", err)
+	}
+	host := e.from[strings.LastIndex(e.from, "@")+1:]
+	id := fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), host)
+
+	msg := strings.Join([]string{
+		"From: " + e.from,
+		"To: " + to.Email,
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Message-ID: " + id,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		m.Body,
+	}, "\r\n")
+
+	err = smtp.SendMail(e.addr, e.auth, e.from, []string{to.Email}, []byte(msg))
+	if err != nil {
+		return "", fmt.Errorf("send: error sending mail: %w", err)
+	}
+
+	return id, nil
+}
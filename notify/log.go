@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type logSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLog returns the channel writing messages to w as JSON lines instead of
+// delivering them, for local development. The server refuses to configure it
+// unless built with the dev tag.
+func NewLog(w io.Writer) Channel {
+	return &logSink{w: w}
+}
+
+func (l *logSink) Name() string { return ChannelLog }
+
+func (l *logSink) Send(ctx context.Context, to Recipient, m Message) (string, error) {
+	id := fmt.Sprintf("log-%d", time.Now().UnixNano())
+
+	b, err := json.Marshal(struct {
+		ID      string    `json:"id"`
+		Time    time.Time `json:"time"`
+		Phone   string    `json:"phone,omitempty"`
+		Email   string    `json:"email,omitempty"`
+		Locale  string    `json:"locale,omitempty"`
+		Subject string    `json:"subject"`
+		Body    string    `json:"body"`
+	}{id, time.Now().UTC(), to.Phone, to.Email, to.Locale, m.Subject, m.Body})
+	if err != nil {
+		return "", fmt.Errorf("send: error encoding message: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(b, '\n'))
+	if err != nil {
+		return "", fmt.Errorf("send: error writing message: %w", err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,106 @@
+// Package notify delivers messages to users over the configured channels.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Channel names.
+const (
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelEmail    = "email"
+	ChannelLog      = "log"
+)
+
+// Channels lists every channel name.
+var Channels = []string{ChannelSMS, ChannelWhatsApp, ChannelEmail, ChannelLog}
+
+// PhoneChannels lists the channels that deliver to the recipient's phone, the
+// only ones a code verifying the phone number may be sent over. The log channel
+// stands in for them in development builds.
+var PhoneChannels = []string{ChannelSMS, ChannelWhatsApp, ChannelLog}
+
+// ErrNoAddress is returned by a channel the recipient has no address for.
+var ErrNoAddress = errors.New("recipient has no address for channel")
+
+// Recipient is who a message is sent to. Channels use the address they need.
+type Recipient struct {
+	Phone  string
+	Email  string
+	Locale string
+}
+
+// Message is a rendered message.
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// Channel delivers messages. Send returns the id the provider gave the message.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, m Message) (string, error)
+}
+
+// Notifier sends templated messages over the first channel of an order that
+// delivers them.
+type Notifier struct {
+	channels map[string]Channel
+	order    []string
+}
+
+// New returns a notifier over channels. Messages sent without an order try the
+// channels of defaultOrder.
+func New(defaultOrder []string, channels ...Channel) *Notifier {
+	n := &Notifier{channels: make(map[string]Channel), order: defaultOrder}
+	for _, c := range channels {
+		n.channels[c.Name()] = c
+	}
+	return n
+}
+
+// Has reports whether the channel is configured.
+func (n *Notifier) Has(channel string) bool {
+	_, ok := n.channels[channel]
+	return ok
+}
+
+// Send renders template in the locale of the recipient and sends it over the
+// channels of order in turn, falling back to the next one when a channel is not
+// configured, the recipient has no address for it or delivery fails. It
+// returns the channel that delivered the message and the provider's id.
+func (n *Notifier) Send(ctx context.Context, order []string, to Recipient, template string, params interface{}) (string, string, error) {
+	m, err := Render(template, to.Locale, params)
+	if err != nil {
+		return "", "", fmt.Errorf("send: %w", err)
+	}
+
+	if len(order) == 0 {
+		order = n.order
+	}
+
+	var errs []string
+	for _, name := range order {
+		c, ok := n.channels[name]
+		if !ok {
+			continue
+		}
+
+		id, err := c.Send(ctx, to, m)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+
+		return name, id, nil
+	}
+
+	if len(errs) == 0 {
+		return "", "", fmt.Errorf("send: no channel of %v is configured", order)
+	}
+	return "", "", fmt.Errorf("send: every channel failed: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failing struct{ name string }
+
+func (f failing) Name() string { return f.name }
+
+func (f failing) Send(ctx context.Context, to Recipient, m Message) (string, error) {
+	return "", errors.New("unavailable")
+}
+
+func TestSendFallsBack(t *testing.T) {
+	var b bytes.Buffer
+	n := New([]string{ChannelSMS}, failing{ChannelSMS}, NewLog(&b))
+
+	channel, id, err := n.Send(context.Background(), []string{ChannelWhatsApp, ChannelSMS, ChannelLog}, Recipient{Phone: "+15550100", Locale: "es_MX"}, TemplateOTP, struct{ Code string }{"123456"})
+	require.Nil(t, err)
+	assert.Equal(t, ChannelLog, channel)
+
+	var logged struct {
+		ID   string `json:"id"`
+		Body string `json:"body"`
+	}
+	require.Nil(t, json.Unmarshal(b.Bytes(), &logged))
+	assert.Equal(t, id, logged.ID)
+	assert.Equal(t, "Tu código para verificar tu número en eventers es: 123456", logged.Body)
+
+	_, _, err = n.Send(context.Background(), nil, Recipient{Phone: "+15550100"}, TemplateOTP, struct{ Code string }{"123456"})
+	assert.NotNil(t, err)
+}
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	m, err := Render(TemplateOTP, "xx-YY", struct{ Code string }{"123456"})
+	require.Nil(t, err)
+	assert.Equal(t, "OTP to verify your number at eventers is: 123456", m.Body)
+
+	_, err = Render("unknown", "en", nil)
+	assert.NotNil(t, err)
+}
+
+func TestRenderAuctionEnded(t *testing.T) {
+	params := struct {
+		EventTitle string
+		Amount     uint64
+		Won        bool
+	}{"Jazz Night", 750, true}
+
+	m, err := Render(TemplateAuctionEnded, "en", params)
+	require.Nil(t, err)
+	assert.Equal(t, "You won the ticket for Jazz Night with your bid of 750.", m.Body)
+
+	params.Won = false
+	m, err = Render(TemplateAuctionEnded, "es-MX", params)
+	require.Nil(t, err)
+	assert.Equal(t, "La subasta de Jazz Night terminó en 750 y tu puja no ganó.", m.Body)
+}
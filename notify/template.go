@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Templates.
+const (
+	TemplateOTP           = "otp"
+	TemplateAuctionOutbid = "auction_outbid"
+	TemplateAuctionEnded  = "auction_ended"
+)
+
+// DefaultLocale is used when a template has no translation for the locale of
+// the recipient.
+const DefaultLocale = "en"
+
+type translation struct {
+	subject string
+	body    string
+}
+
+// templates maps template and locale to its text. Locales are lower case
+// language tags, regional ones fall back to their language.
+var templates = map[string]map[string]translation{
+	TemplateOTP: {
+		"en": {"Your eventers verification code", "OTP to verify your number at eventers is: {{.Code}}"},
+		"es": {"Tu código de verificación de eventers", "Tu código para verificar tu número en eventers es: {{.Code}}"},
+		"fr": {"Votre code de vérification eventers", "Votre code pour vérifier votre numéro sur eventers est : {{.Code}}"},
+		"de": {"Dein eventers Bestätigungscode", "Dein Code zur Bestätigung deiner Nummer bei eventers lautet: {{.Code}}"},
+		"pt": {"Seu código de verificação eventers", "Seu código para verificar seu número no eventers é: {{.Code}}"},
+		"hi": {"आपका eventers सत्यापन कोड", "eventers पर आपका नंबर सत्यापित करने का कोड है: {{.Code}}"},
+	},
+	TemplateAuctionOutbid: {
+		"en": {"You have been outbid on {{.EventTitle}}", "Your bid on a ticket for {{.EventTitle}} was outbid. Bid at least {{.MinimumBid}} to lead again."},
+		"es": {"Han superado tu puja en {{.EventTitle}}", "Han superado tu puja por una entrada para {{.EventTitle}}. Puja al menos {{.MinimumBid}} para volver a liderar."},
+		"fr": {"Votre enchère sur {{.EventTitle}} a été dépassée", "Votre enchère sur un billet pour {{.EventTitle}} a été dépassée. Enchérissez au moins {{.MinimumBid}} pour repasser en tête."},
+		"de": {"Du wurdest bei {{.EventTitle}} überboten", "Dein Gebot auf ein Ticket für {{.EventTitle}} wurde überboten. Biete mindestens {{.MinimumBid}}, um wieder vorne zu liegen."},
+		"pt": {"Seu lance em {{.EventTitle}} foi superado", "Seu lance em um ingresso para {{.EventTitle}} foi superado. Dê um lance de pelo menos {{.MinimumBid}} para voltar à liderança."},
+		"hi": {"{{.EventTitle}} पर आपकी बोली पीछे रह गई", "{{.EventTitle}} के टिकट पर आपकी बोली से ऊँची बोली लगी है। फिर से आगे आने के लिए कम से कम {{.MinimumBid}} की बोली लगाएँ।"},
+	},
+	TemplateAuctionEnded: {
+		"en": {"The auction for {{.EventTitle}} has ended", "{{if .Won}}You won the ticket for {{.EventTitle}} with your bid of {{.Amount}}.{{else}}The auction for {{.EventTitle}} ended at {{.Amount}} and your bid did not win.{{end}}"},
+		"es": {"La subasta de {{.EventTitle}} ha terminado", "{{if .Won}}Ganaste la entrada para {{.EventTitle}} con tu puja de {{.Amount}}.{{else}}La subasta de {{.EventTitle}} terminó en {{.Amount}} y tu puja no ganó.{{end}}"},
+		"fr": {"L'enchère pour {{.EventTitle}} est terminée", "{{if .Won}}Vous avez remporté le billet pour {{.EventTitle}} avec votre enchère de {{.Amount}}.{{else}}L'enchère pour {{.EventTitle}} s'est terminée à {{.Amount}} et votre enchère n'a pas gagné.{{end}}"},
+		"de": {"Die Auktion für {{.EventTitle}} ist beendet", "{{if .Won}}Du hast das Ticket für {{.EventTitle}} mit deinem Gebot von {{.Amount}} gewonnen.{{else}}Die Auktion für {{.EventTitle}} endete bei {{.Amount}} und dein Gebot hat nicht gewonnen.{{end}}"},
+		"pt": {"O leilão de {{.EventTitle}} terminou", "{{if .Won}}Você ganhou o ingresso para {{.EventTitle}} com seu lance de {{.Amount}}.{{else}}O leilão de {{.EventTitle}} terminou em {{.Amount}} e seu lance não venceu.{{end}}"},
+		"hi": {"{{.EventTitle}} की नीलामी समाप्त हो गई", "{{if .Won}}आपने {{.Amount}} की बोली से {{.EventTitle}} का टिकट जीत लिया।{{else}}{{.EventTitle}} की नीलामी {{.Amount}} पर समाप्त हुई और आपकी बोली नहीं जीती।{{end}}"},
+	},
+}
+
+// Render fills in the template in locale, or the closest locale it has.
+func Render(name, locale string, params interface{}) (Message, error) {
+	ts, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("render: unknown template: %s", name)
+	}
+
+	t := ts[matchLocale(ts, locale)]
+
+	subject, err := execute(t.subject, params)
+	if err != nil {
+		return Message{}, fmt.Errorf("render: %s: %w", name, err)
+	}
+
+	body, err := execute(t.body, params)
+	if err != nil {
+		return Message{}, fmt.Errorf("render: %s: %w", name, err)
+	}
+
+	return Message{Subject: subject, Body: body}, nil
+}
+
+func matchLocale(ts map[string]translation, locale string) string {
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if _, ok := ts[locale]; ok {
+		return locale
+	}
+
+	if i := strings.Index(locale, "-"); i > 0 {
+		if _, ok := ts[locale[:i]]; ok {
+			return locale[:i]
+		}
+	}
+
+	return DefaultLocale
+}
+
+func execute(text string, params interface{}) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, params)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
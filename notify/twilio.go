@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"eventers-marketplace-backend/twilio"
+)
+
+// whatsAppPrefix marks the addresses of the Twilio WhatsApp API.
+const whatsAppPrefix = "whatsapp:"
+
+type sms struct {
+	sender twilio.Sender
+}
+
+// NewSMS returns the channel sending text messages through Twilio.
+func NewSMS(sender twilio.Sender) Channel {
+	return &sms{sender: sender}
+}
+
+func (s *sms) Name() string { return ChannelSMS }
+
+func (s *sms) Send(ctx context.Context, to Recipient, m Message) (string, error) {
+	if to.Phone == "" {
+		return "", ErrNoAddress
+	}
+	return s.sender.Send(to.Phone, m.Body)
+}
+
+type whatsApp struct {
+	sender twilio.Sender
+}
+
+// NewWhatsApp returns the channel sending WhatsApp messages through the Twilio
+// WhatsApp API. The sender's from number is used as the WhatsApp sender.
+func NewWhatsApp(accountSID, authToken, url, from string) Channel {
+	return &whatsApp{sender: twilio.NewSender(accountSID, authToken, url, whatsAppPrefix+from)}
+}
+
+func (s *whatsApp) Name() string { return ChannelWhatsApp }
+
+func (s *whatsApp) Send(ctx context.Context, to Recipient, m Message) (string, error) {
+	if to.Phone == "" {
+		return "", ErrNoAddress
+	}
+	return s.sender.Send(whatsAppPrefix+to.Phone, m.Body)
+}
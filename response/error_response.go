@@ -55,6 +55,36 @@ func Unauthorized() ErrorResponse {
 	}
 }
 
+func Forbidden(description string) ErrorResponse {
+	return ErrorResponse{
+		StatusCode:  http.StatusForbidden,
+		Success:     false,
+		Message:     "Not allowed to perform this action",
+		Status:      "FORBIDDEN",
+		Description: description,
+	}
+}
+
+func Conflict(message, description string) ErrorResponse {
+	return ErrorResponse{
+		StatusCode:  http.StatusConflict,
+		Success:     false,
+		Message:     message,
+		Status:      "CONFLICT",
+		Description: description,
+	}
+}
+
+func InvalidStateTransition(description string) ErrorResponse {
+	return ErrorResponse{
+		StatusCode:  http.StatusConflict,
+		Success:     false,
+		Message:     "Invalid state transition",
+		Status:      "INVALID_STATE_TRANSITION",
+		Description: description,
+	}
+}
+
 func SomethingWrong() ErrorResponse {
 	return ErrorResponse{
 		StatusCode: http.StatusInternalServerError,
@@ -188,6 +218,25 @@ func OTPMismatch() ErrorResponse {
 	}
 }
 
+func OTPLocked() ErrorResponse {
+	return ErrorResponse{
+		Success:    false,
+		Message:    "Too many wrong OTPs entered, Please try again later",
+		Status:     "OTP_LOCKED",
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+func TooManyRequests(description string) ErrorResponse {
+	return ErrorResponse{
+		StatusCode:  http.StatusTooManyRequests,
+		Success:     false,
+		Message:     "Too many requests, Please try again later",
+		Status:      "TOO_MANY_REQUESTS",
+		Description: description,
+	}
+}
+
 func FirebaseUserNotFound() ErrorResponse {
 	return ErrorResponse{
 		Success: false,
@@ -6,19 +6,50 @@ import (
 	"net/http"
 )
 
+const (
+	CacheControlHeader = "Cache-Control"
+	NoStore            = "no-store"
+)
+
+// SuccessResponse is sent on success. Responses carrying secrets, such as keys
+// and tokens, set NoStore so that they are neither cached nor stored for replay.
 type SuccessResponse struct {
 	Data       interface{} `json:"data"`
 	StatusCode int         `json:"-"`
+	NoStore    bool        `json:"-"`
 }
 
 type Data struct {
-	User            *model.User            `json:"user,omitempty"`
-	UserMarketplace *model.MarketplaceUser `json:"user_marketplace,omiempty"`
-	PublicEvent     *model.PublicEvent     `json:"public_event,omitempty"`
-	Auth            *model.Auth            `json:"auth,omitempty"`
+	User              *model.User                   `json:"user,omitempty"`
+	UserMarketplace   *model.MarketplaceUser        `json:"user_marketplace,omiempty"`
+	PublicEvent       *model.PublicEvent            `json:"public_event,omitempty"`
+	PublicEvents      []model.PublicEvent           `json:"public_events,omitempty"`
+	EventTicket       *model.EventTicket            `json:"event_ticket,omitempty"`
+	EventTickets      []model.EventTicket           `json:"event_tickets,omitempty"`
+	Venue             *model.Venue                  `json:"venue,omitempty"`
+	SeatHold          *model.SeatHold               `json:"seat_hold,omitempty"`
+	Reservation       *model.Reservation            `json:"reservation,omitempty"`
+	ResaleListing     *model.ResaleListing          `json:"resale_listing,omitempty"`
+	ResaleOffer       *model.ResaleOffer            `json:"resale_offer,omitempty"`
+	ResaleOffers      []model.ResaleOffer           `json:"resale_offers,omitempty"`
+	Auction           *model.Auction                `json:"auction,omitempty"`
+	Auctions          []model.Auction               `json:"auctions,omitempty"`
+	AuctionBid        *model.AuctionBid             `json:"auction_bid,omitempty"`
+	Marketplace       *model.Marketplace            `json:"marketplace,omitempty"`
+	Marketplaces      []model.Marketplace           `json:"marketplaces,omitempty"`
+	MarketplaceKey    *model.MarketplaceKey         `json:"marketplace_key,omitempty"`
+	MarketplaceKeys   []model.MarketplaceKey        `json:"marketplace_keys,omitempty"`
+	TicketMoves       []model.MarketplaceTicketMove `json:"ticket_moves,omitempty"`
+	WebhookDelivery   *model.WebhookDelivery        `json:"webhook_delivery,omitempty"`
+	WebhookDeliveries []model.WebhookDelivery       `json:"webhook_deliveries,omitempty"`
+	Auth              *model.Auth                   `json:"auth,omitempty"`
+	Session           *model.SessionToken           `json:"session,omitempty"`
 }
 
 func (r SuccessResponse) Send(w http.ResponseWriter) {
+	if r.NoStore {
+		w.Header().Set(CacheControlHeader, NoStore)
+	}
 	w.WriteHeader(r.StatusCode)
 	json.NewEncoder(w).Encode(r)
 }
@@ -2,6 +2,8 @@ package router
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"eventers-marketplace-backend/algorand"
 	"eventers-marketplace-backend/config"
 	"eventers-marketplace-backend/event"
@@ -9,13 +11,20 @@ import (
 	"eventers-marketplace-backend/handler"
 	"eventers-marketplace-backend/healthcheck"
 	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
 	"eventers-marketplace-backend/middleware"
+	"eventers-marketplace-backend/notify"
 	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/session"
+	"eventers-marketplace-backend/store"
 	"eventers-marketplace-backend/twilio"
 	"eventers-marketplace-backend/user"
 	"eventers-marketplace-backend/vault"
+	"eventers-marketplace-backend/webhook"
 	"fmt"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
@@ -37,11 +46,7 @@ func Router(ctx context.Context) *mux.Router {
 
 	client := initializeRedis(ctx)
 
-	sender := twilio.NewSender(viper.GetString(
-		config.TwilioAccountSID),
-		viper.GetString(config.TwilioAuthToken),
-		viper.GetString(config.TwilioURL),
-		viper.GetString(config.TwilioFrom))
+	notifier := initializeNotifier(ctx)
 
 	vault, err := vault.New(
 		viper.GetString(config.VaultToken),
@@ -67,30 +72,222 @@ func Router(ctx context.Context) *mux.Router {
 		viper.GetUint64(config.SeedAlgo),
 	)
 
-	userService := user.NewUser(algo, *vault)
-	eventService := event.NewEvent(algo, *vault)
 	f := factory.NewFactory()
+	st := store.NewMySQL(f.DB(ctx))
+	marketplaceService := marketplace.NewMarketplace(st, initializeKeyEncryptionKey(ctx))
+	userService := user.NewUser(algo, *vault, st, marketplaceService)
+	hooks := webhook.New(st, &http.Client{}, webhook.Policy{
+		MaxAttempts: viper.GetInt(config.WebhookMaxAttempts),
+		Backoff:     time.Duration(viper.GetInt(config.WebhookBackoff)) * time.Second,
+		MaxBackoff:  time.Duration(viper.GetInt(config.WebhookMaxBackoff)) * time.Second,
+		Timeout:     time.Duration(viper.GetInt(config.WebhookTimeout)) * time.Second,
+		BatchSize:   viper.GetInt(config.WebhookBatchSize),
+	})
+	eventService := event.NewEvent(algo, *vault, st, hooks, notifier)
+	otp := user.NewOTP(client, notifier, st.OTPAudits, user.OTPPolicy{
+		Length:      viper.GetInt(config.OTPLength),
+		TTL:         time.Duration(viper.GetInt(config.OTPTTL)) * time.Second,
+		MaxAttempts: viper.GetInt(config.OTPMaxAttempts),
+		Lockout:     time.Duration(viper.GetInt(config.OTPLockout)) * time.Second,
+		Cooldown:    time.Duration(viper.GetInt(config.OTPCooldown)) * time.Second,
+		MaxCooldown: time.Duration(viper.GetInt(config.OTPMaxCooldown)) * time.Second,
+		PhoneQuota:  viper.GetInt(config.OTPPhoneQuota),
+		UserQuota:   viper.GetInt(config.OTPUserQuota),
+		IPQuota:     viper.GetInt(config.OTPIPQuota),
+		QuotaWindow: time.Duration(viper.GetInt(config.OTPQuotaWindow)) * time.Second,
+	})
+	sessions := session.New(
+		client,
+		initializeSessionKey(ctx),
+		viper.GetString(config.SessionIssuer),
+		time.Duration(viper.GetInt(config.SessionAccessTTL))*time.Second,
+		time.Duration(viper.GetInt(config.SessionRefreshTTL))*time.Second,
+	)
+
+	go eventService.ReleaseExpiredReservations(ctx, time.Duration(viper.GetInt(config.ReservationSweep))*time.Second)
+	go eventService.ExpireListings(ctx, time.Duration(viper.GetInt(config.ListingSweep))*time.Second)
+	go eventService.SettleAuctions(ctx, time.Duration(viper.GetInt(config.AuctionSweep))*time.Second)
+	go eventService.RecoverMoves(
+		ctx,
+		time.Duration(viper.GetInt(config.MoveRecoverySweep))*time.Second,
+		time.Duration(viper.GetInt(config.MoveRecoveryAge))*time.Second,
+	)
+
+	go hooks.Run(ctx, time.Duration(viper.GetInt(config.WebhookSweep))*time.Second)
+
+	// Idempotency runs after the authentication of each subrouter, so that
+	// stored responses are keyed on a verified principal and signed requests
+	// are checked for replay before a stored response is looked up.
+	idempotency := middleware.Idempotency(
+		client,
+		time.Duration(viper.GetInt(config.IdempotencyTTL))*time.Second,
+		time.Duration(viper.GetInt(config.IdempotencyLockTTL))*time.Second,
+	)
+	appAuth := middleware.AppAuth(viper.GetString(config.FirebaseProjectID), time.Duration(viper.GetInt(config.JWTOfflineInterval)))
+	signature := middleware.MarketplaceSignature(client, marketplaceService, time.Duration(viper.GetInt(config.SignatureWindow))*time.Second)
 
 	r.HandleFunc("/healthcheck", healthcheck.Self).Methods(http.MethodGet)
 	baseRouter := r.PathPrefix("/v1").Subrouter()
 
 	userRouter := baseRouter.PathPrefix("/user").Subrouter()
+	userRouter.Use(appAuth, idempotency)
 	userRouter.HandleFunc("/connect", handler.CreateUser(userService, f)).Methods(http.MethodPost)
 	userRouter.HandleFunc("/connect/verify", handler.VerifyUser(userService, f)).Methods(http.MethodPost)
 
 	marketPlaceRouter := baseRouter.PathPrefix("/marketplace/user").Subrouter()
-	marketPlaceRouter.HandleFunc("/connect", handler.CreateMarketPlaceUser(userService, f, sender, client, viper.GetString(config.Secret))).Methods(http.MethodPost)
-	marketPlaceRouter.HandleFunc("/verifyotp", handler.VerifyMarketPlaceOTP(userService, f, client)).Methods(http.MethodPost)
+	marketPlaceRouter.Use(signature, idempotency)
+	marketPlaceRouter.HandleFunc("/connect", handler.CreateMarketPlaceUser(userService, f, otp)).Methods(http.MethodPost)
+	marketPlaceRouter.HandleFunc("/verifyotp", handler.VerifyMarketPlaceOTP(userService, f, otp, sessions)).Methods(http.MethodPost)
+	marketPlaceRouter.HandleFunc("/token/refresh", handler.RefreshMarketplaceSession(marketplaceService, sessions)).Methods(http.MethodPost)
+	marketPlaceRouter.HandleFunc("/token/revoke", handler.RevokeMarketplaceSession(marketplaceService, sessions)).Methods(http.MethodPost)
+
+	keyRouter := baseRouter.PathPrefix("/marketplace/public_key").Subrouter()
+	keyRouter.Use(signature, idempotency)
+	keyRouter.HandleFunc("", handler.PutMarketplacePublicKey(marketplaceService)).Methods(http.MethodPut)
+
+	webhookRouter := baseRouter.PathPrefix("/marketplace/webhooks").Subrouter()
+	webhookRouter.Use(signature, idempotency)
+	webhookRouter.HandleFunc("/deliveries", handler.GetWebhookDeliveries(marketplaceService, hooks)).Methods(http.MethodGet)
+	webhookRouter.HandleFunc("/deliveries/{deliveryID}/replay", handler.ReplayWebhookDelivery(marketplaceService, hooks)).Methods(http.MethodPost)
+
+	marketplaceTicketRouter := baseRouter.PathPrefix("/marketplace").Subrouter()
+	marketplaceTicketRouter.Use(middleware.MarketplaceSession(sessions), idempotency)
+	marketplaceTicketRouter.HandleFunc("/events", handler.GetMarketplaceEvents(eventService, f)).Methods(http.MethodGet)
+	marketplaceTicketRouter.HandleFunc("/tickets", handler.GetMarketplaceTickets(eventService, marketplaceService)).Methods(http.MethodGet)
+	marketplaceTicketRouter.HandleFunc("/tickets", handler.BuyMarketplaceTicket(eventService, marketplaceService, f, client, time.Duration(viper.GetInt(config.ReservationTTL))*time.Second)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/tickets/{eventTicketID}/resell", handler.ResellMarketplaceTicket(eventService, marketplaceService, f, time.Duration(viper.GetInt(config.ListingTTL))*time.Second)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/tickets/{eventTicketID}/transfer", handler.TransferMarketplaceTicket(eventService, marketplaceService, f)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/tickets/{eventTicketID}/bridge", handler.BridgeMarketplaceTicket(eventService, marketplaceService, f)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}", handler.RepriceMarketplaceListing(eventService, marketplaceService, f)).Methods(http.MethodPatch)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}", handler.CancelMarketplaceListing(eventService, marketplaceService, f)).Methods(http.MethodDelete)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}/offers", handler.PlaceMarketplaceOffer(eventService, marketplaceService, f)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}/offers", handler.GetMarketplaceListingOffers(eventService, marketplaceService, f)).Methods(http.MethodGet)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}/offers/{offerID}/accept", handler.AcceptMarketplaceOffer(eventService, marketplaceService, f)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/listings/{listingID}/offers/{offerID}/reject", handler.RejectMarketplaceOffer(eventService, marketplaceService, f)).Methods(http.MethodPost)
+	marketplaceTicketRouter.HandleFunc("/auctions/{auctionID}", handler.GetAuction(eventService, f)).Methods(http.MethodGet)
+	marketplaceTicketRouter.HandleFunc("/auctions/{auctionID}/bids", handler.PlaceMarketplaceBid(eventService, marketplaceService, f)).Methods(http.MethodPost)
+
+	adminRouter := baseRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.AdminAuth(viper.GetString(config.AdminToken)), idempotency)
+	adminRouter.HandleFunc("/marketplaces", handler.CreateMarketplace(marketplaceService)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/marketplaces", handler.GetMarketplaces(marketplaceService)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}", handler.GetMarketplace(marketplaceService)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}", handler.UpdateMarketplace(marketplaceService)).Methods(http.MethodPatch)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}", handler.DeactivateMarketplace(marketplaceService)).Methods(http.MethodDelete)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/access_key", handler.RotateMarketplaceKey(marketplaceService, time.Duration(viper.GetInt(config.KeyRotationGrace))*time.Second)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/public_key", handler.SetMarketplacePublicKey(marketplaceService)).Methods(http.MethodPut)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/keys", handler.CreateMarketplaceKey(marketplaceService)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/keys", handler.GetMarketplaceKeys(marketplaceService)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/keys/{keyID}", handler.RevokeMarketplaceKey(marketplaceService)).Methods(http.MethodDelete)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/webhook_secret", handler.RotateWebhookSecret(marketplaceService, hooks)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/marketplaces/{marketplaceID}/ticket_moves", handler.GetMarketplaceTicketMoves(marketplaceService)).Methods(http.MethodGet)
 
 	publicEventRouter := baseRouter.PathPrefix("/public_event").Subrouter()
+	publicEventRouter.Use(appAuth, idempotency)
 	publicEventRouter.HandleFunc("", handler.PublicEvent(eventService, f)).Methods(http.MethodPost)
-	publicEventRouter.HandleFunc("", handler.UpdatePublicEvent(eventService, f)).Methods(http.MethodPatch)
-	publicEventRouter.HandleFunc("", handler.GetPublicEvents(eventService, f)).Methods(http.MethodGet)
+	publicEventRouter.HandleFunc("", handler.UpdatePublicEvent(eventService, f, client, time.Duration(viper.GetInt(config.ListingTTL))*time.Second)).Methods(http.MethodPatch)
+	publicEventRouter.HandleFunc("", handler.GetCatalogue(eventService, f)).Methods(http.MethodGet)
 	publicEventRouter.HandleFunc("/{userID}", handler.GetPublicEvent(eventService, f)).Methods(http.MethodGet)
+	publicEventRouter.HandleFunc("/{publicEventID}", handler.EditPublicEvent(eventService, f)).Methods(http.MethodPut)
+	publicEventRouter.HandleFunc("/{publicEventID}", handler.DeletePublicEvent(eventService, f)).Methods(http.MethodDelete)
+	publicEventRouter.HandleFunc("/{publicEventID}/seat_map", handler.AttachSeatMap(eventService, f)).Methods(http.MethodPost)
+	publicEventRouter.HandleFunc("/{publicEventID}/seats", handler.GetSeats(eventService, f, client)).Methods(http.MethodGet)
+	publicEventRouter.HandleFunc("/{publicEventID}/seats/{seatID}/hold", handler.HoldSeat(eventService, f, client)).Methods(http.MethodPost)
+	publicEventRouter.HandleFunc("/{publicEventID}/reservations", handler.CreateReservation(eventService, f, client)).Methods(http.MethodPost)
+	publicEventRouter.HandleFunc("/{publicEventID}/reservations/{reservationID}", handler.DeleteReservation(eventService, f)).Methods(http.MethodDelete)
+	publicEventRouter.HandleFunc("/{publicEventID}/auctions", handler.CreateAuction(eventService, f)).Methods(http.MethodPost)
+	publicEventRouter.HandleFunc("/{publicEventID}/auctions", handler.GetEventAuctions(eventService, f)).Methods(http.MethodGet)
+
+	listingRouter := baseRouter.PathPrefix("/listings").Subrouter()
+	listingRouter.Use(appAuth, idempotency)
+	listingRouter.HandleFunc("/{listingID}", handler.RepriceListing(eventService, f)).Methods(http.MethodPatch)
+	listingRouter.HandleFunc("/{listingID}", handler.CancelListing(eventService, f)).Methods(http.MethodDelete)
+	listingRouter.HandleFunc("/{listingID}/offers", handler.PlaceOffer(eventService, f)).Methods(http.MethodPost)
+	listingRouter.HandleFunc("/{listingID}/offers", handler.GetListingOffers(eventService, f)).Methods(http.MethodGet)
+	listingRouter.HandleFunc("/{listingID}/offers/{offerID}/accept", handler.AcceptOffer(eventService, f)).Methods(http.MethodPost)
+	listingRouter.HandleFunc("/{listingID}/offers/{offerID}/reject", handler.RejectOffer(eventService, f)).Methods(http.MethodPost)
+
+	auctionRouter := baseRouter.PathPrefix("/auctions").Subrouter()
+	auctionRouter.Use(appAuth, idempotency)
+	auctionRouter.HandleFunc("/{auctionID}", handler.GetAuction(eventService, f)).Methods(http.MethodGet)
+	auctionRouter.HandleFunc("/{auctionID}/bids", handler.PlaceBid(eventService, f)).Methods(http.MethodPost)
 
 	return r
 }
 
+// initializeNotifier registers the delivery channels that are configured.
+func initializeNotifier(ctx context.Context) *notify.Notifier {
+	channels := []notify.Channel{
+		notify.NewSMS(twilio.NewSender(
+			viper.GetString(config.TwilioAccountSID),
+			viper.GetString(config.TwilioAuthToken),
+			viper.GetString(config.TwilioURL),
+			viper.GetString(config.TwilioFrom))),
+	}
+
+	if from := viper.GetString(config.TwilioWhatsApp); from != "" {
+		channels = append(channels, notify.NewWhatsApp(
+			viper.GetString(config.TwilioAccountSID),
+			viper.GetString(config.TwilioAuthToken),
+			viper.GetString(config.TwilioURL),
+			from))
+	}
+
+	if addr := viper.GetString(config.SMTPAddress); addr != "" {
+		channels = append(channels, notify.NewEmail(
+			addr,
+			viper.GetString(config.SMTPUsername),
+			viper.GetString(config.SMTPPassword),
+			viper.GetString(config.SMTPFrom)))
+	}
+
+	if path := viper.GetString(config.NotifyLogPath); path != "" {
+		if !notify.Dev {
+			logger.Fatalf(ctx, "initializeNotifier: %s is only allowed in dev builds", config.NotifyLogPath)
+		}
+
+		w := os.Stdout
+		if path != "-" {
+			var err error
+			w, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				logger.Fatalf(ctx, "initializeNotifier: error opening notify log: %s", err)
+			}
+		}
+		channels = append(channels, notify.NewLog(w))
+	}
+
+	return notify.New(viper.GetStringSlice(config.NotifyChannels), channels...)
+}
+
+// initializeKeyEncryptionKey reads the key marketplace signing keys are stored
+// encrypted with. The server does not start without one.
+func initializeKeyEncryptionKey(ctx context.Context) []byte {
+	kek, err := marketplace.ParseKeyEncryptionKey(viper.GetString(config.MarketplaceKeyEncryptionKey))
+	if err != nil {
+		logger.Fatalf(ctx, "initializeKeyEncryptionKey: %s: %s", config.MarketplaceKeyEncryptionKey, err)
+	}
+
+	return kek
+}
+
+// initializeSessionKey reads the base64 seed of the key session access tokens
+// are signed with. The server does not start without one, so that every
+// instance verifies the tokens of the others and sessions survive a restart.
+func initializeSessionKey(ctx context.Context) ed25519.PrivateKey {
+	seed := viper.GetString(config.SessionSigningKey)
+	if seed == "" {
+		logger.Fatalf(ctx, "initializeSessionKey: %s is not configured", config.SessionSigningKey)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(b) != ed25519.SeedSize {
+		logger.Fatalf(ctx, "initializeSessionKey: signing key has to be a base64 %d byte seed", ed25519.SeedSize)
+	}
+
+	return ed25519.NewKeyFromSeed(b)
+}
+
 func initializeRedis(ctx context.Context) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr:     viper.GetString(config.RedisAddress),
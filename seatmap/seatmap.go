@@ -0,0 +1,147 @@
+package seatmap
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"eventers-marketplace-backend/model"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+
+	labelSeparator = "-"
+)
+
+// Map is the JSON representation of a seat map.
+type Map struct {
+	VenueName string    `json:"venue_name"`
+	Sections  []Section `json:"sections"`
+}
+
+type Section struct {
+	Name string `json:"name"`
+	Tier string `json:"tier"`
+	Rows []Row  `json:"rows"`
+}
+
+type Row struct {
+	Label string   `json:"label"`
+	Seats []string `json:"seats"`
+}
+
+// Parse reads a seat map in the given format and returns its seats. CSV files
+// have a header with section, row and seat columns and an optional tier column.
+func Parse(r io.Reader, format string) ([]model.Seat, error) {
+	var seats []model.Seat
+	var err error
+
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		seats, err = parseJSON(r)
+	case FormatCSV:
+		seats, err = parseCSV(r)
+	default:
+		return nil, fmt.Errorf("parse: unsupported seat map format: %s", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(seats) == 0 {
+		return nil, fmt.Errorf("parse: seat map has no seats")
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range seats {
+		if s.Section == "" || s.Row == "" || s.Number == "" {
+			return nil, fmt.Errorf("parse: seat with empty section, row or number: %+v", s)
+		}
+
+		// Labels join the parts with a separator, which would make
+		// different seats share a label if it appeared in a part.
+		if strings.Contains(s.Section+s.Row+s.Number, labelSeparator) {
+			return nil, fmt.Errorf("parse: seat section, row or number contains %q: %+v", labelSeparator, s)
+		}
+
+		key := s.Label()
+		if seen[key] {
+			return nil, fmt.Errorf("parse: duplicate seat: %s", key)
+		}
+		seen[key] = true
+	}
+
+	return seats, nil
+}
+
+func parseJSON(r io.Reader) ([]model.Seat, error) {
+	var m Map
+	err := json.NewDecoder(r).Decode(&m)
+	if err != nil {
+		return nil, fmt.Errorf("parseJSON: error decoding seat map: %w", err)
+	}
+
+	var seats []model.Seat
+	for _, section := range m.Sections {
+		for _, row := range section.Rows {
+			for _, number := range row.Seats {
+				seats = append(seats, model.Seat{
+					Section: strings.TrimSpace(section.Name),
+					Row:     strings.TrimSpace(row.Label),
+					Number:  strings.TrimSpace(number),
+					Tier:    strings.TrimSpace(section.Tier),
+				})
+			}
+		}
+	}
+
+	return seats, nil
+}
+
+func parseCSV(r io.Reader) ([]model.Seat, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("parseCSV: error reading header: %w", err)
+	}
+
+	idx := map[string]int{"tier": -1}
+	for i, h := range header {
+		idx[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	for _, col := range []string{"section", "row", "seat"} {
+		if _, ok := idx[col]; !ok {
+			return nil, fmt.Errorf("parseCSV: missing column: %s", col)
+		}
+	}
+
+	var seats []model.Seat
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parseCSV: error reading record: %w", err)
+		}
+
+		seat := model.Seat{
+			Section: strings.TrimSpace(record[idx["section"]]),
+			Row:     strings.TrimSpace(record[idx["row"]]),
+			Number:  strings.TrimSpace(record[idx["seat"]]),
+		}
+		if idx["tier"] >= 0 {
+			seat.Tier = strings.TrimSpace(record[idx["tier"]])
+		}
+		seats = append(seats, seat)
+	}
+
+	return seats, nil
+}
@@ -0,0 +1,52 @@
+package seatmap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSON(t *testing.T) {
+	in := `{"venue_name": "Hall", "sections": [{"name": "A", "tier": "VIP", "rows": [{"label": "1", "seats": ["1", "2"]}]}]}`
+
+	seats, err := Parse(strings.NewReader(in), FormatJSON)
+	require.Nil(t, err)
+
+	require.Len(t, seats, 2)
+	assert.Equal(t, "A-1-2", seats[1].Label())
+	assert.Equal(t, "VIP", seats[1].Tier)
+}
+
+func TestParseCSV(t *testing.T) {
+	in := "section,row,seat\nA,1,1\nA,1,2\nB,3,7\n"
+
+	seats, err := Parse(strings.NewReader(in), FormatCSV)
+	require.Nil(t, err)
+
+	require.Len(t, seats, 3)
+	assert.Equal(t, "B-3-7", seats[2].Label())
+	assert.Equal(t, "", seats[2].Tier)
+}
+
+func TestParseFailsForDuplicateSeat(t *testing.T) {
+	in := "section,row,seat,tier\nA,1,1,VIP\nA,1,1,VIP\n"
+
+	_, err := Parse(strings.NewReader(in), FormatCSV)
+	assert.NotNil(t, err)
+}
+
+func TestParseFailsForSeparatorInSeat(t *testing.T) {
+	in := "section,row,seat\nA-1,2,3\nA,1-2,3\n"
+
+	_, err := Parse(strings.NewReader(in), FormatCSV)
+	assert.NotNil(t, err)
+}
+
+func TestParseFailsForMissingColumn(t *testing.T) {
+	in := "section,seat\nA,1\n"
+
+	_, err := Parse(strings.NewReader(in), FormatCSV)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,329 @@
+// Package session issues the tokens marketplace users authenticate with once
+// their phone number is verified: short-lived EdDSA signed access tokens and
+// refresh tokens, both scoped to the user and the marketplace.
+package session
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-redis/redis"
+)
+
+// TokenType is the type of the access tokens.
+const TokenType = "Bearer"
+
+const (
+	sessionIDBytes     = 16
+	refreshSecretBytes = 32
+	refreshSeparator   = "."
+)
+
+type claimsKey struct{}
+
+// Claims are the claims of an access token. The subject is the user
+// marketplace id.
+type Claims struct {
+	MarketplaceID int64  `json:"mkt"`
+	SessionID     string `json:"sid"`
+	jwt.StandardClaims
+}
+
+// UserMarketplaceID returns the marketplace user the token was issued to.
+func (c *Claims) UserMarketplaceID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+// WithClaims returns a copy of ctx carrying the claims of a verified token.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFrom returns the claims of the access token the request carried.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
+
+// record is what is kept of a session. Only the hash of the current refresh
+// token is stored.
+type record struct {
+	MarketplaceID     int64  `json:"marketplace_id"`
+	UserMarketplaceID int64  `json:"user_marketplace_id"`
+	RefreshHash       string `json:"refresh_hash"`
+
+	// raw is the stored value the record was read from.
+	raw []byte
+}
+
+// swapScript replaces the session at KEYS[1] with ARGV[2] for ARGV[3]
+// milliseconds as long as it still is ARGV[1], so that two refreshes with the
+// same token cannot both succeed.
+var swapScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// Sessions issues, refreshes, verifies and revokes sessions. Sessions are kept
+// in Redis for as long as their refresh token is valid, and an access token is
+// only accepted while its session is.
+type Sessions struct {
+	client     *redis.Client
+	key        ed25519.PrivateKey
+	keyID      string
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// New returns the sessions signing access tokens with key.
+func New(client *redis.Client, key ed25519.PrivateKey, issuer string, accessTTL, refreshTTL time.Duration) *Sessions {
+	return &Sessions{
+		client:     client,
+		key:        key,
+		keyID:      KeyID(key.Public().(ed25519.PublicKey)),
+		issuer:     issuer,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// KeyID is the id of the public key tokens are verified with, the start of its
+// SHA-256.
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PublicKey returns the key access tokens are verified with.
+func (s *Sessions) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Issue starts a session for the marketplace user.
+func (s *Sessions) Issue(ctx context.Context, marketplaceID, userMarketplaceID int64) (*model.SessionToken, error) {
+	sessionID, err := randomHex(sessionIDBytes)
+	if err != nil {
+		return nil, fmt.Errorf("issue: %w", err)
+	}
+
+	t, b, err := s.tokens(&record{MarketplaceID: marketplaceID, UserMarketplaceID: userMarketplaceID}, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("issue: %w", err)
+	}
+
+	err = s.client.Set(sessionKey(sessionID), b, s.refreshTTL).Err()
+	if err != nil {
+		return nil, fmt.Errorf("issue: error saving session: %w", err)
+	}
+
+	return t, nil
+}
+
+// Refresh swaps a refresh token of the marketplace for new tokens. Each
+// refresh token is only accepted once, presenting one that was already used,
+// also by a concurrent refresh, revokes the session.
+func (s *Sessions) Refresh(ctx context.Context, marketplaceID int64, refreshToken string) (*model.SessionToken, error) {
+	sessionID, secret := parseRefreshToken(refreshToken)
+	if sessionID == "" {
+		return nil, response.Unauthorized()
+	}
+
+	r, ok, err := s.get(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: %w", err)
+	}
+
+	if !ok || r.MarketplaceID != marketplaceID {
+		return nil, response.Unauthorized()
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(r.RefreshHash)) != 1 {
+		return nil, s.revokeReused(sessionID)
+	}
+
+	t, b, err := s.tokens(r, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: %w", err)
+	}
+
+	swapped, err := swapScript.Run(s.client, []string{sessionKey(sessionID)}, r.raw, b, int64(s.refreshTTL/time.Millisecond)).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("refresh: error saving session: %w", err)
+	}
+
+	if swapped != 1 {
+		return nil, s.revokeReused(sessionID)
+	}
+
+	return t, nil
+}
+
+// Revoke ends the session of the refresh token of the marketplace. Its access
+// tokens stop working right away.
+func (s *Sessions) Revoke(ctx context.Context, marketplaceID int64, refreshToken string) error {
+	sessionID, secret := parseRefreshToken(refreshToken)
+	if sessionID == "" {
+		return response.Unauthorized()
+	}
+
+	r, ok, err := s.get(sessionID)
+	if err != nil {
+		return fmt.Errorf("revoke: %w", err)
+	}
+
+	if !ok || r.MarketplaceID != marketplaceID || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(r.RefreshHash)) != 1 {
+		return response.Unauthorized()
+	}
+
+	err = s.client.Del(sessionKey(sessionID)).Err()
+	if err != nil {
+		return fmt.Errorf("revoke: error deleting session: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate verifies the access token and that its session was not revoked.
+func (s *Sessions) Authenticate(ctx context.Context, accessToken string) (*Claims, error) {
+	var c Claims
+	t, err := jwt.ParseWithClaims(accessToken, &c, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != SigningMethodEdDSA {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return s.PublicKey(), nil
+	})
+	if err != nil || !t.Valid || !c.VerifyIssuer(s.issuer, true) {
+		return nil, response.Unauthorized()
+	}
+
+	r, ok, err := s.get(c.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("authenticate: %w", err)
+	}
+
+	if !ok || r.MarketplaceID != c.MarketplaceID || r.UserMarketplaceID != c.UserMarketplaceID() {
+		return nil, response.Unauthorized()
+	}
+
+	return &c, nil
+}
+
+// revokeReused ends the session a used refresh token was presented for.
+func (s *Sessions) revokeReused(sessionID string) error {
+	err := s.client.Del(sessionKey(sessionID)).Err()
+	if err != nil {
+		return fmt.Errorf("revokeReused: error revoking session: %w", err)
+	}
+
+	return response.Unauthorized()
+}
+
+// tokens signs a new access token for the session and replaces its refresh
+// token. It returns the tokens and the record to store for the session.
+func (s *Sessions) tokens(r *record, sessionID string) (*model.SessionToken, []byte, error) {
+	now := time.Now().UTC()
+
+	jti, err := randomHex(sessionIDBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tokens: %w", err)
+	}
+
+	token := jwt.NewWithClaims(SigningMethodEdDSA, &Claims{
+		MarketplaceID: r.MarketplaceID,
+		SessionID:     sessionID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(r.UserMarketplaceID, 10),
+			Audience:  fmt.Sprintf("marketplace:%d", r.MarketplaceID),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(s.accessTTL).Unix(),
+		},
+	})
+	token.Header["kid"] = s.keyID
+
+	accessToken, err := token.SignedString(s.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tokens: error signing access token: %w", err)
+	}
+
+	secret, err := randomHex(refreshSecretBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tokens: %w", err)
+	}
+
+	next := *r
+	next.RefreshHash = hashSecret(secret)
+	b, _ := json.Marshal(&next)
+
+	return &model.SessionToken{
+		AccessToken:      accessToken,
+		TokenType:        TokenType,
+		ExpiresIn:        int64(s.accessTTL / time.Second),
+		RefreshToken:     sessionID + refreshSeparator + secret,
+		RefreshExpiresIn: int64(s.refreshTTL / time.Second),
+	}, b, nil
+}
+
+func (s *Sessions) get(sessionID string) (*record, bool, error) {
+	b, err := s.client.Get(sessionKey(sessionID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get: error reading session: %w", err)
+	}
+
+	r := record{raw: b}
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, false, fmt.Errorf("get: error decoding session: %w", err)
+	}
+
+	return &r, true, nil
+}
+
+func sessionKey(sessionID string) string {
+	return "marketplace-session-" + sessionID
+}
+
+// parseRefreshToken splits a refresh token into its session id and secret.
+func parseRefreshToken(refreshToken string) (string, string) {
+	i := strings.Index(refreshToken, refreshSeparator)
+	if i <= 0 {
+		return "", ""
+	}
+	return refreshToken[:i], refreshToken[i+len(refreshSeparator):]
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("randomHex: error reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
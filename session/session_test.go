@@ -0,0 +1,69 @@
+package session
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningMethodEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+
+	token, err := jwt.NewWithClaims(SigningMethodEdDSA, jwt.StandardClaims{Subject: "1"}).SignedString(privateKey)
+	require.Nil(t, err)
+
+	var c jwt.StandardClaims
+	_, err = jwt.ParseWithClaims(token, &c, func(*jwt.Token) (interface{}, error) { return publicKey, nil })
+	require.Nil(t, err)
+	assert.Equal(t, "1", c.Subject)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+	_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return otherKey, nil })
+	assert.NotNil(t, err)
+}
+
+func TestAuthenticateRejectsForeignTokens(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+	s := New(nil, privateKey, "eventers-marketplace", time.Minute, time.Hour)
+
+	claims := &Claims{
+		MarketplaceID:  1,
+		SessionID:      "session",
+		StandardClaims: jwt.StandardClaims{Issuer: "eventers-marketplace", Subject: "2"},
+	}
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	require.Nil(t, err)
+	_, err = s.Authenticate(context.Background(), hmac)
+	assert.NotNil(t, err)
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+	forged, err := jwt.NewWithClaims(SigningMethodEdDSA, claims).SignedString(otherKey)
+	require.Nil(t, err)
+	_, err = s.Authenticate(context.Background(), forged)
+	assert.NotNil(t, err)
+
+	claims.Issuer = "someone-else"
+	issued, err := jwt.NewWithClaims(SigningMethodEdDSA, claims).SignedString(privateKey)
+	require.Nil(t, err)
+	_, err = s.Authenticate(context.Background(), issued)
+	assert.NotNil(t, err)
+}
+
+func TestParseRefreshToken(t *testing.T) {
+	sessionID, secret := parseRefreshToken("abc.def")
+	assert.Equal(t, "abc", sessionID)
+	assert.Equal(t, "def", secret)
+
+	sessionID, _ = parseRefreshToken("abc")
+	assert.Equal(t, "", sessionID)
+}
@@ -0,0 +1,2249 @@
+package store
+
+import (
+	"context"
+	"eventers-marketplace-backend/model"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory keeps the rows of every repository in maps. It is safe for concurrent
+// use and is meant for tests. Transactions run one at a time and roll back by
+// restoring the rows they started with, so writes made outside of a
+// transaction while one runs are lost when it rolls back.
+type Memory struct {
+	mu   sync.Mutex
+	txMu sync.Mutex
+	memoryRows
+	nextID int64
+}
+
+type memoryRows struct {
+	users            map[int64]model.User
+	events           map[int64]model.PublicEvent
+	tiers            map[int64]model.TicketTier
+	resalePolicies   map[int64]model.ResalePolicy
+	venues           map[int64]string
+	seats            map[int64]model.Seat
+	tickets          map[int64]model.EventTicket
+	reservations     map[string]model.Reservation
+	refunds          []model.TicketRefund
+	listings         map[int64]model.ResaleListing
+	offers           map[int64]model.ResaleOffer
+	auctions         map[int64]model.Auction
+	bids             map[int64]model.AuctionBid
+	moves            map[int64]ticketMove
+	payouts          []model.ResalePayout
+	marketplaces     map[int64]model.Marketplace
+	marketplaceUsers map[int64]marketplaceUser
+	marketplaceKeys  map[string]model.MarketplaceKey
+	otpAudits        []model.OTPAudit
+	marketplaceMoves []model.MarketplaceTicketMove
+	webhookSecrets   map[int64]string
+	webhooks         map[int64]model.WebhookDelivery
+}
+
+type marketplaceUser struct {
+	model.MarketplaceUser
+	phoneNumber string
+}
+
+// ticketMove is a move along with when it was created, which moves are not
+// read with.
+type ticketMove struct {
+	model.TicketMove
+	createdAt time.Time
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{memoryRows: memoryRows{
+		users:            make(map[int64]model.User),
+		events:           make(map[int64]model.PublicEvent),
+		tiers:            make(map[int64]model.TicketTier),
+		resalePolicies:   make(map[int64]model.ResalePolicy),
+		venues:           make(map[int64]string),
+		seats:            make(map[int64]model.Seat),
+		tickets:          make(map[int64]model.EventTicket),
+		reservations:     make(map[string]model.Reservation),
+		listings:         make(map[int64]model.ResaleListing),
+		offers:           make(map[int64]model.ResaleOffer),
+		auctions:         make(map[int64]model.Auction),
+		bids:             make(map[int64]model.AuctionBid),
+		moves:            make(map[int64]ticketMove),
+		marketplaces:     make(map[int64]model.Marketplace),
+		marketplaceUsers: make(map[int64]marketplaceUser),
+		marketplaceKeys:  make(map[string]model.MarketplaceKey),
+		webhookSecrets:   make(map[int64]string),
+		webhooks:         make(map[int64]model.WebhookDelivery),
+	}}
+}
+
+// Store returns the repositories reading and writing m.
+func (m *Memory) Store() *Store {
+	s := m.repos()
+	s.tx = m.tx
+	return s
+}
+
+func (m *Memory) repos() *Store {
+	return &Store{
+		Users:            memoryUsers{m},
+		Events:           memoryEvents{m},
+		Tiers:            memoryTiers{m},
+		ResalePolicies:   memoryResalePolicies{m},
+		Venues:           memoryVenues{m},
+		Tickets:          memoryTickets{m},
+		Reservations:     memoryReservations{m},
+		Refunds:          memoryRefunds{m},
+		Listings:         memoryListings{m},
+		Offers:           memoryOffers{m},
+		Auctions:         memoryAuctions{m},
+		Moves:            memoryMoves{m},
+		Payouts:          memoryPayouts{m},
+		Marketplaces:     memoryMarketplaces{m},
+		MarketplaceKeys:  memoryMarketplaceKeys{m},
+		OTPAudits:        memoryOTPAudits{m},
+		MarketplaceMoves: memoryMarketplaceMoves{m},
+		Webhooks:         memoryWebhooks{m},
+	}
+}
+
+// tx runs fn on the rows and restores them when fn fails. Tx on the store
+// passed to fn runs inline, like it does on a MySQL transaction.
+func (m *Memory) tx(ctx context.Context, fn func(tx *Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	saved := m.memoryRows.clone()
+	m.mu.Unlock()
+
+	err := fn(m.repos())
+	if err != nil {
+		m.mu.Lock()
+		m.memoryRows = saved
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// clone copies the rows. Rows are copied by value, so the fields they point to
+// are shared with the copy.
+func (r *memoryRows) clone() memoryRows {
+	c := memoryRows{
+		users:            make(map[int64]model.User, len(r.users)),
+		events:           make(map[int64]model.PublicEvent, len(r.events)),
+		tiers:            make(map[int64]model.TicketTier, len(r.tiers)),
+		resalePolicies:   make(map[int64]model.ResalePolicy, len(r.resalePolicies)),
+		venues:           make(map[int64]string, len(r.venues)),
+		seats:            make(map[int64]model.Seat, len(r.seats)),
+		tickets:          make(map[int64]model.EventTicket, len(r.tickets)),
+		reservations:     make(map[string]model.Reservation, len(r.reservations)),
+		refunds:          append([]model.TicketRefund(nil), r.refunds...),
+		listings:         make(map[int64]model.ResaleListing, len(r.listings)),
+		offers:           make(map[int64]model.ResaleOffer, len(r.offers)),
+		auctions:         make(map[int64]model.Auction, len(r.auctions)),
+		bids:             make(map[int64]model.AuctionBid, len(r.bids)),
+		moves:            make(map[int64]ticketMove, len(r.moves)),
+		payouts:          append([]model.ResalePayout(nil), r.payouts...),
+		marketplaces:     make(map[int64]model.Marketplace, len(r.marketplaces)),
+		marketplaceUsers: make(map[int64]marketplaceUser, len(r.marketplaceUsers)),
+		marketplaceKeys:  make(map[string]model.MarketplaceKey, len(r.marketplaceKeys)),
+		otpAudits:        append([]model.OTPAudit(nil), r.otpAudits...),
+		marketplaceMoves: append([]model.MarketplaceTicketMove(nil), r.marketplaceMoves...),
+		webhookSecrets:   make(map[int64]string, len(r.webhookSecrets)),
+		webhooks:         make(map[int64]model.WebhookDelivery, len(r.webhooks)),
+	}
+
+	for k, v := range r.users {
+		c.users[k] = v
+	}
+	for k, v := range r.events {
+		c.events[k] = v
+	}
+	for k, v := range r.tiers {
+		c.tiers[k] = v
+	}
+	for k, v := range r.resalePolicies {
+		c.resalePolicies[k] = v
+	}
+	for k, v := range r.venues {
+		c.venues[k] = v
+	}
+	for k, v := range r.seats {
+		c.seats[k] = v
+	}
+	for k, v := range r.tickets {
+		c.tickets[k] = v
+	}
+	for k, v := range r.reservations {
+		c.reservations[k] = v
+	}
+	for k, v := range r.listings {
+		c.listings[k] = v
+	}
+	for k, v := range r.offers {
+		c.offers[k] = v
+	}
+	for k, v := range r.auctions {
+		c.auctions[k] = v
+	}
+	for k, v := range r.bids {
+		c.bids[k] = v
+	}
+	for k, v := range r.moves {
+		c.moves[k] = v
+	}
+	for k, v := range r.marketplaces {
+		c.marketplaces[k] = v
+	}
+	for k, v := range r.marketplaceUsers {
+		c.marketplaceUsers[k] = v
+	}
+	for k, v := range r.marketplaceKeys {
+		c.marketplaceKeys[k] = v
+	}
+	for k, v := range r.webhookSecrets {
+		c.webhookSecrets[k] = v
+	}
+	for k, v := range r.webhooks {
+		c.webhooks[k] = v
+	}
+	return c
+}
+
+// PutUser adds or replaces a user.
+func (m *Memory) PutUser(u model.User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.UserID] = u
+}
+
+// PutPublicEvent adds or replaces a public event.
+func (m *Memory) PutPublicEvent(pe model.PublicEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[pe.PublicEventID] = pe
+}
+
+// PutEventTicket adds or replaces an event ticket.
+func (m *Memory) PutEventTicket(et model.EventTicket) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tickets[et.EventTicketID] = et
+}
+
+// PutReservation adds or replaces a reservation.
+func (m *Memory) PutReservation(r model.Reservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reservations[r.ReservationID] = r
+}
+
+// PutMarketplace adds or replaces a marketplace.
+func (m *Memory) PutMarketplace(mp model.Marketplace) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marketplaces[mp.MarketPlaceID] = mp
+}
+
+// PutMarketplaceMove records a move between marketplaces.
+func (m *Memory) PutMarketplaceMove(mv model.MarketplaceTicketMove) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marketplaceMoves = append(m.marketplaceMoves, mv)
+}
+
+type memoryUsers struct{ m *Memory }
+
+func (s memoryUsers) Get(ctx context.Context, userID int64) (*model.User, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	u, ok := s.m.users[userID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &u, true, nil
+}
+
+func (s memoryUsers) IDByFirebaseID(ctx context.Context, firebaseID string) (int64, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, u := range s.m.users {
+		if !u.IsActive || !u.IsRegistered {
+			continue
+		}
+
+		for _, f := range []*string{u.AFirebaseID, u.FBFirebaseID, u.GFirebaseID, u.PhoneFirebaseID} {
+			if f != nil && *f == firebaseID {
+				return id, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+func (s memoryUsers) FindByFirebaseID(ctx context.Context, provider string, firebaseID *string, state UserState) (*model.User, bool, error) {
+	cols, ok := identityColumns[provider]
+	if !ok {
+		return nil, false, fmt.Errorf("users.FindByFirebaseID: unknown provider: %s", provider)
+	}
+
+	return s.find(state, 0, func(u *model.User) bool { return equal(*userStringColumn(u, cols[0]), firebaseID) })
+}
+
+func (s memoryUsers) FindByEmail(ctx context.Context, provider string, email *string, state UserState) (*model.User, bool, error) {
+	cols, ok := identityColumns[provider]
+	if !ok || provider == ProviderPhone {
+		return nil, false, fmt.Errorf("users.FindByEmail: unknown provider: %s", provider)
+	}
+
+	return s.find(state, 0, func(u *model.User) bool { return equal(*userStringColumn(u, cols[1]), email) })
+}
+
+func (s memoryUsers) FindByPhone(ctx context.Context, countryCode, number *string, state UserState, except int64) (*model.User, bool, error) {
+	return s.find(state, except, func(u *model.User) bool {
+		return equal(u.PhoneCountryCode, countryCode) && equal(u.PhoneNumber, number)
+	})
+}
+
+func (s memoryUsers) FindPhoneOnly(ctx context.Context, countryCode, number *string) (*model.User, bool, error) {
+	return s.find(UnregisteredUser, 0, func(u *model.User) bool {
+		return equal(u.PhoneCountryCode, countryCode) && equal(u.PhoneNumber, number) && u.FBFirebaseID == nil && u.GFirebaseID == nil
+	})
+}
+
+func (s memoryUsers) FindByActivePhone(ctx context.Context, phoneNumber string) (*model.User, bool, error) {
+	return s.find(AnyUser, 0, func(u *model.User) bool {
+		return u.IsActive && u.PhoneCountryCode != nil && u.PhoneNumber != nil && *u.PhoneCountryCode+*u.PhoneNumber == phoneNumber
+	})
+}
+
+// find returns the user with the lowest id other than except in the state
+// that matches.
+func (s memoryUsers) find(state UserState, except int64, match func(u *model.User) bool) (*model.User, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	ids := make([]int64, 0, len(s.m.users))
+	for id := range s.m.users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if id == except {
+			continue
+		}
+
+		u := s.m.users[id]
+		if state.matches(&u) && match(&u) {
+			return &u, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s memoryUsers) Create(ctx context.Context, u *model.User) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := model.User{UserID: s.m.nextID}
+	err := setIdentity(&created, u)
+	if err != nil {
+		return 0, fmt.Errorf("users.Create: %w", err)
+	}
+
+	s.m.users[created.UserID] = created
+	return created.UserID, nil
+}
+
+func (s memoryUsers) SetIdentity(ctx context.Context, userID int64, u *model.User) error {
+	return s.update(userID, func(existing *model.User) error { return setIdentity(existing, u) })
+}
+
+func (s memoryUsers) SetPhone(ctx context.Context, userID int64, countryCode, number, firebaseID *string, active bool) error {
+	return s.update(userID, func(u *model.User) error {
+		u.PhoneCountryCode = copyString(countryCode)
+		u.PhoneNumber = copyString(number)
+		u.PhoneFirebaseID = copyString(firebaseID)
+		u.IsRegistered = true
+		u.IsActive = active
+		return nil
+	})
+}
+
+func (s memoryUsers) SetProfile(ctx context.Context, u *model.User) error {
+	return s.update(u.UserID, func(existing *model.User) error {
+		fields := profileFields(existing)
+		for i, f := range profileFields(u) {
+			if f.value != nil && strings.TrimSpace(*f.value) != "" {
+				*userStringColumn(existing, fields[i].column) = copyString(f.value)
+			}
+		}
+		return nil
+	})
+}
+
+func (s memoryUsers) update(userID int64, set func(u *model.User) error) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	u, ok := s.m.users[userID]
+	if !ok {
+		return fmt.Errorf("users.update: user: %d: %w", userID, ErrNotFound)
+	}
+
+	err := set(&u)
+	if err != nil {
+		return fmt.Errorf("users.update: %w", err)
+	}
+
+	s.m.users[userID] = u
+	return nil
+}
+
+func (s memoryUsers) Merge(ctx context.Context, provider string, from, to int64) error {
+	columns, ok := identityColumns[provider]
+	if !ok {
+		return fmt.Errorf("users.Merge: unknown provider: %s", provider)
+	}
+
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	f, ok := s.m.users[from]
+	if !ok {
+		return nil
+	}
+
+	t, ok := s.m.users[to]
+	if !ok || stringValue(f.PhoneCountryCode)+stringValue(f.PhoneNumber) != stringValue(t.PhoneCountryCode)+stringValue(t.PhoneNumber) {
+		return nil
+	}
+
+	for _, col := range append(columns[:len(columns):len(columns)], "phone_firebase_id") {
+		*userStringColumn(&t, col) = *userStringColumn(&f, col)
+	}
+
+	f.IsRegistered = true
+	f.IsActive = false
+	s.m.users[from] = f
+	s.m.users[to] = t
+	return nil
+}
+
+func (s memoryUsers) Phone(ctx context.Context, userID int64) (string, string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	u := s.m.users[userID]
+	return stringValue(u.PhoneCountryCode), stringValue(u.PhoneNumber), nil
+}
+
+// userStringColumn returns the field of the user backing the identity or
+// profile column.
+func userStringColumn(u *model.User, col string) **string {
+	fields := map[string]**string{
+		"first_name":         &u.FirstName,
+		"last_name":          &u.LastName,
+		"display_name":       &u.DisplayName,
+		"email_address":      &u.EmailAddress,
+		"city":               &u.City,
+		"state":              &u.State,
+		"country":            &u.Country,
+		"address":            &u.Address,
+		"pincode":            &u.Pincode,
+		"profile_pic":        &u.ProfilePic,
+		"a_firebase_id":      &u.AFirebaseID,
+		"a_email":            &u.AEmail,
+		"a_name":             &u.AName,
+		"a_image_url":        &u.AImageURL,
+		"fb_firebase_id":     &u.FBFirebaseID,
+		"fb_email":           &u.FBEmail,
+		"fb_name":            &u.FBName,
+		"fb_image_url":       &u.FBImageURL,
+		"g_firebase_id":      &u.GFirebaseID,
+		"g_email":            &u.GEmail,
+		"g_name":             &u.GName,
+		"g_image_url":        &u.GImageURL,
+		"phone_firebase_id":  &u.PhoneFirebaseID,
+		"phone_country_code": &u.PhoneCountryCode,
+		"phone_number":       &u.PhoneNumber,
+	}
+
+	return fields[col]
+}
+
+// setIdentity sets the columns identity returns for u on the user.
+func setIdentity(user *model.User, u *model.User) error {
+	columns, values, err := identity(u)
+	if err != nil {
+		return err
+	}
+
+	user.Provider = copyString(u.Provider)
+	for i, col := range columns {
+		switch col {
+		case "provider", "updated_date":
+		case "is_registered":
+			user.IsRegistered = values[i].(bool)
+		case "is_active":
+			user.IsActive = values[i].(bool)
+		default:
+			*userStringColumn(user, col) = copyString(values[i].(*string))
+		}
+	}
+	return nil
+}
+
+// matches reports whether the user is in the state.
+func (s UserState) matches(u *model.User) bool {
+	switch s {
+	case RegisteredUser:
+		return u.IsRegistered && u.IsActive
+	case UnregisteredUser:
+		return !u.IsRegistered && !u.IsActive
+	}
+	return true
+}
+
+// equal compares the column with the value the way MySQL does, a NULL on
+// either side matches nothing.
+func equal(column, value *string) bool {
+	return column != nil && value != nil && *column == *value
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	v := *s
+	return &v
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+type memoryEvents struct{ m *Memory }
+
+func (s memoryEvents) Get(ctx context.Context, publicEventID int64) (*model.PublicEvent, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	pe, ok := s.m.events[publicEventID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &pe, true, nil
+}
+
+func (s memoryEvents) List(ctx context.Context, status string) ([]model.PublicEvent, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var pes []model.PublicEvent
+	for _, pe := range s.m.events {
+		if status == "" || (pe.Status != nil && *pe.Status == status) {
+			pes = append(pes, pe)
+		}
+	}
+
+	sort.Slice(pes, func(i, j int) bool { return pes[i].PublicEventID < pes[j].PublicEventID })
+	return pes, nil
+}
+
+func (s memoryEvents) Create(ctx context.Context, pe *model.PublicEvent, tempAddress, tempPassphrase string) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *pe
+	created.PublicEventID = s.m.nextID
+	created.FromPrice = 0
+	created.AvailableTickets = 0
+	created.TicketTiers = nil
+	created.ResalePolicy = nil
+	created.Status = copyString(pe.Status)
+	s.m.events[created.PublicEventID] = created
+	return created.PublicEventID, nil
+}
+
+func (s memoryEvents) Update(ctx context.Context, pe *model.PublicEvent, from, to string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	existing, ok := s.m.events[pe.PublicEventID]
+	if !ok || stringValue(existing.Status) != from {
+		return fmt.Errorf("events.Update: public event: %d is not %s: %w", pe.PublicEventID, from, ErrNotFound)
+	}
+
+	if pe.DateTime != nil {
+		existing.DateTime = pe.DateTime
+	}
+	if pe.EventTitle != nil {
+		existing.EventTitle = copyString(pe.EventTitle)
+	}
+	if pe.EventDescription != nil {
+		existing.EventDescription = copyString(pe.EventDescription)
+	}
+	if pe.EventImage != nil {
+		existing.EventImage = copyString(pe.EventImage)
+	}
+	if pe.TotalTickets > 0 {
+		existing.TotalTickets = pe.TotalTickets
+	}
+	if pe.TicketPrice > 0 {
+		existing.TicketPrice = pe.TicketPrice
+	}
+	existing.Status = &to
+	s.m.events[pe.PublicEventID] = existing
+	return nil
+}
+
+func (s memoryEvents) SetVenue(ctx context.Context, publicEventID, venueID int64, totalTickets uint64, status string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	pe, ok := s.m.events[publicEventID]
+	if !ok || stringValue(pe.Status) != status {
+		return fmt.Errorf("events.SetVenue: public event: %d is not %s: %w", publicEventID, status, ErrNotFound)
+	}
+
+	pe.VenueID = venueID
+	pe.TotalTickets = totalTickets
+	s.m.events[publicEventID] = pe
+	return nil
+}
+
+func (s memoryEvents) Delete(ctx context.Context, publicEventID int64, status string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	pe, ok := s.m.events[publicEventID]
+	if !ok || stringValue(pe.Status) != status {
+		return fmt.Errorf("events.Delete: public event: %d is not %s: %w", publicEventID, status, ErrNotFound)
+	}
+
+	delete(s.m.events, publicEventID)
+	return nil
+}
+
+func (s memoryEvents) Catalogue(ctx context.Context, q *CatalogueQuery) ([]model.PublicEvent, int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	f := q.Filter
+	var pes []model.PublicEvent
+	for _, pe := range s.m.events {
+		if stringValue(pe.Status) != model.EventPublished {
+			continue
+		}
+
+		if f.From != nil && (pe.DateTime == nil || pe.DateTime.Before(*f.From)) {
+			continue
+		}
+
+		if f.To != nil && (pe.DateTime == nil || !pe.DateTime.Before(*f.To)) {
+			continue
+		}
+
+		if f.Search != "" && !contains(pe.EventTitle, f.Search) && !contains(pe.EventDescription, f.Search) {
+			continue
+		}
+
+		pe.FromPrice = pe.TicketPrice
+		first := true
+		for _, tt := range s.m.tiers {
+			if tt.PublicEventID == pe.PublicEventID && (first || tt.TicketPrice < pe.FromPrice) {
+				pe.FromPrice = tt.TicketPrice
+				first = false
+			}
+		}
+
+		var resale uint64
+		pe.AvailableTickets = 0
+		for _, et := range s.m.tickets {
+			if et.PublicEventID != pe.PublicEventID {
+				continue
+			}
+
+			switch {
+			case et.BusinessUserID == et.CurrentHolderID && stringValue(et.Status) == model.TicketActive:
+				pe.AvailableTickets++
+			case stringValue(et.Status) == model.TicketResale:
+				resale++
+			}
+		}
+
+		if f.MinPrice != nil && pe.FromPrice < *f.MinPrice {
+			continue
+		}
+
+		if f.MaxPrice != nil && pe.FromPrice > *f.MaxPrice {
+			continue
+		}
+
+		if f.Available != nil && *f.Available != (pe.AvailableTickets+resale > 0) {
+			continue
+		}
+
+		pes = append(pes, pe)
+	}
+
+	// before reports whether a sorts before b in ascending order.
+	before := func(a, b *model.PublicEvent) bool {
+		if q.ByPrice && a.FromPrice != b.FromPrice {
+			return a.FromPrice < b.FromPrice
+		}
+
+		if !q.ByPrice && !sortDate(a).Equal(sortDate(b)) {
+			return sortDate(a).Before(sortDate(b))
+		}
+
+		return a.PublicEventID < b.PublicEventID
+	}
+
+	sort.Slice(pes, func(i, j int) bool {
+		if q.Desc {
+			return before(&pes[j], &pes[i])
+		}
+		return before(&pes[i], &pes[j])
+	})
+
+	total := int64(len(pes))
+	if q.After != nil {
+		after := *q.After
+		after.DateTime = sortDatePtr(q.After)
+		i := sort.Search(len(pes), func(i int) bool {
+			if q.Desc {
+				return before(&pes[i], &after)
+			}
+			return before(&after, &pes[i])
+		})
+		pes = pes[i:]
+	}
+
+	if len(pes) > q.Limit {
+		pes = pes[:q.Limit]
+	}
+
+	for i := range pes {
+		pes[i].BusinessUserID = 0
+		pes[i].Status = nil
+		pes[i].VenueID = 0
+	}
+	return pes, total, nil
+}
+
+// sortDate is the date the event sorts on in the catalogue.
+func sortDate(pe *model.PublicEvent) time.Time {
+	return *sortDatePtr(pe)
+}
+
+func sortDatePtr(pe *model.PublicEvent) *time.Time {
+	if pe.DateTime == nil {
+		undated := Undated
+		return &undated
+	}
+	return pe.DateTime
+}
+
+// contains matches the column against the search the way LIKE does with a
+// case insensitive collation.
+func contains(column *string, search string) bool {
+	return column != nil && strings.Contains(strings.ToLower(*column), strings.ToLower(search))
+}
+
+type memoryTiers struct{ m *Memory }
+
+func (s memoryTiers) Create(ctx context.Context, publicEventID int64, tts []model.TicketTier) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.create(publicEventID, tts)
+	return nil
+}
+
+func (s memoryTiers) create(publicEventID int64, tts []model.TicketTier) {
+	for i := range tts {
+		s.m.nextID++
+		tts[i].TicketTierID = s.m.nextID
+		tts[i].PublicEventID = publicEventID
+
+		created := tts[i]
+		created.AvailableTickets = 0
+		s.m.tiers[created.TicketTierID] = created
+	}
+}
+
+func (s memoryTiers) Replace(ctx context.Context, publicEventID int64, tts []model.TicketTier) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, tt := range s.m.tiers {
+		if tt.PublicEventID == publicEventID {
+			delete(s.m.tiers, id)
+		}
+	}
+
+	s.create(publicEventID, tts)
+	return nil
+}
+
+func (s memoryTiers) List(ctx context.Context, publicEventID int64) ([]model.TicketTier, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var tts []model.TicketTier
+	for _, tt := range s.m.tiers {
+		if tt.PublicEventID == publicEventID {
+			tts = append(tts, tt)
+		}
+	}
+
+	sort.Slice(tts, func(i, j int) bool { return tts[i].TicketTierID < tts[j].TicketTierID })
+	return tts, nil
+}
+
+func (s memoryTiers) Availability(ctx context.Context, publicEventIDs []int64) (map[int64][]model.TicketTier, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	events := make(map[int64]bool)
+	for _, id := range publicEventIDs {
+		events[id] = true
+	}
+
+	var tts []model.TicketTier
+	for _, tt := range s.m.tiers {
+		if !events[tt.PublicEventID] {
+			continue
+		}
+
+		for _, et := range s.m.tickets {
+			if et.TicketTierID == tt.TicketTierID && et.BusinessUserID == et.CurrentHolderID && stringValue(et.Status) == model.TicketActive {
+				tt.AvailableTickets++
+			}
+		}
+		tts = append(tts, tt)
+	}
+
+	sort.Slice(tts, func(i, j int) bool { return tts[i].TicketTierID < tts[j].TicketTierID })
+	tiers := make(map[int64][]model.TicketTier)
+	for _, tt := range tts {
+		tiers[tt.PublicEventID] = append(tiers[tt.PublicEventID], tt)
+	}
+	return tiers, nil
+}
+
+func (s memoryTiers) SetTotal(ctx context.Context, ticketTierID int64, totalTickets uint64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	tt, ok := s.m.tiers[ticketTierID]
+	if ok {
+		tt.TotalTickets = totalTickets
+		s.m.tiers[ticketTierID] = tt
+	}
+	return nil
+}
+
+func (s memoryTiers) Lock(ctx context.Context, publicEventID, ticketTierID int64) (*model.TicketTier, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	tt, ok := s.m.tiers[ticketTierID]
+	if !ok || tt.PublicEventID != publicEventID {
+		return nil, false, nil
+	}
+	return &tt, true, nil
+}
+
+func (s memoryTiers) Purchases(ctx context.Context, ticketTierID, userID int64, wallet, except string, now time.Time) (uint64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var n uint64
+	for _, et := range s.m.tickets {
+		if et.TicketTierID == ticketTierID && et.CurrentHolderID == userID && et.HolderWallet == wallet {
+			n++
+		}
+	}
+
+	for _, r := range s.m.reservations {
+		et, ok := s.m.tickets[r.EventTicketID]
+		if ok && et.TicketTierID == ticketTierID && r.UserID == userID && r.Wallet == wallet && r.Status == model.ReservationActive &&
+			r.ExpiresAt.After(now) && r.ReservationID != except {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memoryResalePolicies struct{ m *Memory }
+
+func (s memoryResalePolicies) Get(ctx context.Context, publicEventID int64) (*model.ResalePolicy, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	p, ok := s.m.resalePolicies[publicEventID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &p, true, nil
+}
+
+func (s memoryResalePolicies) Save(ctx context.Context, publicEventID int64, p *model.ResalePolicy) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.resalePolicies[publicEventID] = *p
+	return nil
+}
+
+type memoryVenues struct{ m *Memory }
+
+func (s memoryVenues) Create(ctx context.Context, name string, seats []model.Seat) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	venueID := s.m.nextID
+	s.m.venues[venueID] = name
+	for _, seat := range seats {
+		s.m.nextID++
+		s.m.seats[s.m.nextID] = model.Seat{
+			SeatID:  s.m.nextID,
+			VenueID: venueID,
+			Section: seat.Section,
+			Row:     seat.Row,
+			Number:  seat.Number,
+			Tier:    seat.Tier,
+		}
+	}
+	return venueID, nil
+}
+
+func (s memoryVenues) Seats(ctx context.Context, venueID int64) ([]model.Seat, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var seats []model.Seat
+	for _, seat := range s.m.seats {
+		if seat.VenueID == venueID {
+			seats = append(seats, seat)
+		}
+	}
+
+	sort.Slice(seats, func(i, j int) bool { return seats[i].SeatID < seats[j].SeatID })
+	return seats, nil
+}
+
+type memoryTickets struct{ m *Memory }
+
+func (s memoryTickets) Get(ctx context.Context, eventTicketID int64) (*model.EventTicket, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	et, ok := s.m.tickets[eventTicketID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &et, true, nil
+}
+
+func (s memoryTickets) ListByEvent(ctx context.Context, publicEventID int64) ([]model.EventTicket, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ets []model.EventTicket
+	for _, et := range s.m.tickets {
+		if et.PublicEventID == publicEventID {
+			ets = append(ets, et)
+		}
+	}
+
+	sort.Slice(ets, func(i, j int) bool { return ets[i].EventTicketID < ets[j].EventTicketID })
+	return ets, nil
+}
+
+func (s memoryTickets) ListByHolder(ctx context.Context, userID int64) ([]model.EventTicket, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ets []model.EventTicket
+	for _, et := range s.m.tickets {
+		if et.CurrentHolderID == userID {
+			ets = append(ets, et)
+		}
+	}
+
+	sort.Slice(ets, func(i, j int) bool { return ets[i].EventTicketID < ets[j].EventTicketID })
+	return ets, nil
+}
+
+func (s memoryTickets) ListByWallet(ctx context.Context, wallet string, userID int64) ([]model.EventTicket, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ets []model.EventTicket
+	for _, et := range s.m.tickets {
+		if et.HolderWallet == wallet || (userID > 0 && et.HolderWallet == "" && et.CurrentHolderID == userID) {
+			ets = append(ets, et)
+		}
+	}
+
+	sort.Slice(ets, func(i, j int) bool { return ets[i].EventTicketID < ets[j].EventTicketID })
+	return ets, nil
+}
+
+func (s memoryTickets) ListResale(ctx context.Context, publicEventIDs []int64) ([]model.EventTicket, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	events := make(map[int64]bool)
+	for _, id := range publicEventIDs {
+		events[id] = true
+	}
+
+	var ets []model.EventTicket
+	for _, et := range s.m.tickets {
+		if events[et.PublicEventID] && stringValue(et.Status) == model.TicketResale {
+			ets = append(ets, et)
+		}
+	}
+
+	sort.Slice(ets, func(i, j int) bool {
+		if ets[i].PublicEventID != ets[j].PublicEventID {
+			return ets[i].PublicEventID < ets[j].PublicEventID
+		}
+		if ets[i].Price != ets[j].Price {
+			return ets[i].Price < ets[j].Price
+		}
+		return ets[i].EventTicketID < ets[j].EventTicketID
+	})
+	return ets, nil
+}
+
+func (s memoryTickets) Create(ctx context.Context, et *model.EventTicket) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *et
+	created.EventTicketID = s.m.nextID
+	created.Status = copyString(et.Status)
+	s.m.tickets[created.EventTicketID] = created
+	return created.EventTicketID, nil
+}
+
+func (s memoryTickets) Available(ctx context.Context, publicEventID, ticketTierID, seatID int64) (*model.EventTicket, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.first(func(et *model.EventTicket) bool { return isAvailable(et, publicEventID, ticketTierID, seatID) })
+}
+
+func (s memoryTickets) Claim(ctx context.Context, publicEventID, ticketTierID, seatID int64, now time.Time) (*model.EventTicket, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.first(func(et *model.EventTicket) bool {
+		if !isAvailable(et, publicEventID, ticketTierID, seatID) {
+			return false
+		}
+
+		for _, r := range s.m.reservations {
+			if r.EventTicketID == et.EventTicketID && r.Status == model.ReservationActive && r.ExpiresAt.After(now) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// first returns the ticket with the lowest id that matches.
+func (s memoryTickets) first(match func(et *model.EventTicket) bool) (*model.EventTicket, bool, error) {
+	var found *model.EventTicket
+	for _, et := range s.m.tickets {
+		et := et
+		if match(&et) && (found == nil || et.EventTicketID < found.EventTicketID) {
+			found = &et
+		}
+	}
+	return found, found != nil, nil
+}
+
+// isAvailable reports whether the ticket is an active ticket of the event held
+// by the organizer, of the tier and the seat when they are set.
+func isAvailable(et *model.EventTicket, publicEventID, ticketTierID, seatID int64) bool {
+	return et.BusinessUserID == et.CurrentHolderID && stringValue(et.Status) == model.TicketActive && et.PublicEventID == publicEventID &&
+		(ticketTierID == 0 || et.TicketTierID == ticketTierID) && (seatID == 0 || et.SeatID == seatID)
+}
+
+func (s memoryTickets) FaceValue(ctx context.Context, eventTicketID int64) (uint64, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	et, ok := s.m.tickets[eventTicketID]
+	if !ok {
+		return 0, false, nil
+	}
+
+	pe, ok := s.m.events[et.PublicEventID]
+	if !ok {
+		return 0, false, nil
+	}
+
+	if tt, ok := s.m.tiers[et.TicketTierID]; ok {
+		return tt.TicketPrice, true, nil
+	}
+	return pe.TicketPrice, true, nil
+}
+
+func (s memoryTickets) PutOnResale(ctx context.Context, et *model.EventTicket, price uint64) (bool, error) {
+	return s.update(et.EventTicketID, func(t *model.EventTicket) bool {
+		if t.CurrentHolderID != et.CurrentHolderID || t.HolderWallet != et.HolderWallet || stringValue(t.Status) != model.TicketActive {
+			return false
+		}
+
+		resale := true
+		t.Price = price
+		t.Status = statusOf(model.TicketResale)
+		t.AvailableToResell = &resale
+		return true
+	})
+}
+
+func (s memoryTickets) SetResalePrice(ctx context.Context, eventTicketID int64, price uint64) (bool, error) {
+	return s.update(eventTicketID, func(t *model.EventTicket) bool {
+		if stringValue(t.Status) != model.TicketResale {
+			return false
+		}
+
+		t.Price = price
+		return true
+	})
+}
+
+func (s memoryTickets) TakeOffResale(ctx context.Context, eventTicketID int64) error {
+	_, err := s.update(eventTicketID, func(t *model.EventTicket) bool {
+		if stringValue(t.Status) != model.TicketResale {
+			return false
+		}
+
+		resale := false
+		t.Status = statusOf(model.TicketActive)
+		t.AvailableToResell = &resale
+		return true
+	})
+	return err
+}
+
+func (s memoryTickets) SetOrganizerPrice(ctx context.Context, et *model.EventTicket, price uint64) (bool, error) {
+	return s.update(et.EventTicketID, func(t *model.EventTicket) bool {
+		if t.CurrentHolderID != et.BusinessUserID || t.HolderWallet != "" || stringValue(t.Status) != model.TicketActive {
+			return false
+		}
+
+		t.Price = price
+		return true
+	})
+}
+
+func (s memoryTickets) Redeem(ctx context.Context, eventTicketID int64) (bool, error) {
+	return s.update(eventTicketID, func(t *model.EventTicket) bool {
+		status := stringValue(t.Status)
+		if status != model.TicketActive && status != model.TicketResale {
+			return false
+		}
+
+		t.Status = statusOf(model.TicketRedeemed)
+		return true
+	})
+}
+
+func (s memoryTickets) Cancel(ctx context.Context, et *model.EventTicket) error {
+	_, err := s.update(et.EventTicketID, func(t *model.EventTicket) bool {
+		resale := false
+		t.CurrentHolderID = et.BusinessUserID
+		t.HolderWallet = ""
+		t.HolderMarketplaceID = 0
+		t.Status = statusOf(model.TicketCancelled)
+		t.AvailableToResell = &resale
+		return true
+	})
+	return err
+}
+
+func (s memoryTickets) Move(ctx context.Context, mv *model.TicketMove) (bool, error) {
+	return s.update(mv.EventTicketID, func(t *model.EventTicket) bool {
+		if t.CurrentHolderID != mv.FromUserID || t.HolderWallet != mv.FromWallet || stringValue(t.Status) != mv.ExpectedStatus {
+			return false
+		}
+
+		resale := false
+		t.CurrentHolderID = mv.ToUserID
+		t.HolderWallet = mv.ToWallet
+		t.HolderMarketplaceID = mv.ToMarketplaceID
+		t.Status = statusOf(mv.NewStatus)
+		t.AvailableToResell = &resale
+		return true
+	})
+}
+
+func (s memoryTickets) CountTransfer(ctx context.Context, eventTicketID int64) error {
+	_, err := s.update(eventTicketID, func(t *model.EventTicket) bool {
+		t.TransferCount++
+		return true
+	})
+	return err
+}
+
+// update applies set to the ticket and keeps it when set reports a change.
+func (s memoryTickets) update(eventTicketID int64, set func(t *model.EventTicket) bool) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	et, ok := s.m.tickets[eventTicketID]
+	if !ok || !set(&et) {
+		return false, nil
+	}
+
+	s.m.tickets[eventTicketID] = et
+	return true, nil
+}
+
+func statusOf(status string) *string {
+	return &status
+}
+
+type memoryReservations struct{ m *Memory }
+
+func (s memoryReservations) Create(ctx context.Context, r *model.Reservation) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.reservations[r.ReservationID]; ok {
+		return fmt.Errorf("reservations.Create: duplicate reservation: %s", r.ReservationID)
+	}
+
+	created := *r
+	created.TicketTierID = 0
+	created.SeatID = 0
+	s.m.reservations[r.ReservationID] = created
+	return nil
+}
+
+func (s memoryReservations) Get(ctx context.Context, reservationID string) (*model.Reservation, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	r, ok := s.m.reservations[reservationID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &r, true, nil
+}
+
+func (s memoryReservations) Release(ctx context.Context, reservationID string, userID int64, now time.Time) (bool, error) {
+	return s.update(reservationID, func(r *model.Reservation) bool {
+		if r.UserID != userID || r.Status != model.ReservationActive {
+			return false
+		}
+
+		r.Status = model.ReservationReleased
+		return true
+	}), nil
+}
+
+func (s memoryReservations) SetStatus(ctx context.Context, reservationID, from, to string, now time.Time) (bool, error) {
+	return s.update(reservationID, func(r *model.Reservation) bool {
+		if r.Status != from {
+			return false
+		}
+
+		r.Status = to
+		return true
+	}), nil
+}
+
+func (s memoryReservations) Complete(ctx context.Context, reservationID string, now time.Time) error {
+	s.update(reservationID, func(r *model.Reservation) bool {
+		r.Status = model.ReservationCompleted
+		return true
+	})
+	return nil
+}
+
+func (s memoryReservations) Expire(ctx context.Context, now time.Time) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var n int64
+	for id, r := range s.m.reservations {
+		if r.Status == model.ReservationActive && !r.ExpiresAt.After(now) {
+			r.Status = model.ReservationExpired
+			s.m.reservations[id] = r
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s memoryReservations) Extend(ctx context.Context, reservationID string, until, now time.Time) error {
+	s.update(reservationID, func(r *model.Reservation) bool {
+		if r.Status != model.ReservationActive || !r.ExpiresAt.After(now) {
+			return false
+		}
+
+		r.ExpiresAt = &until
+		return true
+	})
+	return nil
+}
+
+func (s memoryReservations) Reassign(ctx context.Context, reservationID string, userID int64, wallet string, now time.Time) error {
+	s.update(reservationID, func(r *model.Reservation) bool {
+		r.UserID = userID
+		r.Wallet = wallet
+		return true
+	})
+	return nil
+}
+
+// update applies set to the reservation and keeps it when set reports a
+// change.
+func (s memoryReservations) update(reservationID string, set func(r *model.Reservation) bool) bool {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	r, ok := s.m.reservations[reservationID]
+	if !ok || !set(&r) {
+		return false
+	}
+
+	s.m.reservations[reservationID] = r
+	return true
+}
+
+type memoryRefunds struct{ m *Memory }
+
+func (s memoryRefunds) Create(ctx context.Context, r *model.TicketRefund) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *r
+	created.TicketRefundID = s.m.nextID
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	s.m.refunds = append(s.m.refunds, created)
+	return created.TicketRefundID, nil
+}
+
+type memoryListings struct{ m *Memory }
+
+func (s memoryListings) Create(ctx context.Context, l *model.ResaleListing) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *l
+	created.ListingID = s.m.nextID
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	created.UpdatedAt = &now
+	s.m.listings[created.ListingID] = created
+	return created.ListingID, nil
+}
+
+func (s memoryListings) Get(ctx context.Context, listingID int64) (*model.ResaleListing, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	l, ok := s.m.listings[listingID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &l, true, nil
+}
+
+func (s memoryListings) Open(ctx context.Context, eventTicketID int64, now time.Time) (*model.ResaleListing, bool, error) {
+	ls := s.list(func(l *model.ResaleListing) bool {
+		return l.EventTicketID == eventTicketID && l.Status == model.ListingOpen && l.ExpiresAt.After(now)
+	})
+	if len(ls) == 0 {
+		return nil, false, nil
+	}
+	return &ls[0], true, nil
+}
+
+func (s memoryListings) Expired(ctx context.Context, now time.Time) ([]model.ResaleListing, error) {
+	return s.list(func(l *model.ResaleListing) bool {
+		return l.Status == model.ListingOpen && !l.ExpiresAt.After(now)
+	}), nil
+}
+
+func (s memoryListings) Accepted(ctx context.Context, before time.Time) ([]model.ResaleListing, error) {
+	return s.list(func(l *model.ResaleListing) bool {
+		return l.Status == model.ListingAccepted && !l.UpdatedAt.After(before)
+	}), nil
+}
+
+func (s memoryListings) SetPrice(ctx context.Context, listingID int64, price uint64, now time.Time) (bool, error) {
+	return s.update(listingID, now, func(l *model.ResaleListing) bool {
+		if l.Status != model.ListingOpen {
+			return false
+		}
+
+		l.AskPrice = price
+		return true
+	}), nil
+}
+
+func (s memoryListings) SetStatus(ctx context.Context, listingID int64, from, to string, now time.Time) (bool, error) {
+	return s.update(listingID, now, func(l *model.ResaleListing) bool {
+		if l.Status != from {
+			return false
+		}
+
+		l.Status = to
+		return true
+	}), nil
+}
+
+func (s memoryListings) Close(ctx context.Context, eventTicketID int64, status string, now time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, l := range s.m.listings {
+		if l.EventTicketID == eventTicketID && (l.Status == model.ListingOpen || l.Status == model.ListingAccepted) {
+			l.Status = status
+			l.UpdatedAt = &now
+			s.m.listings[id] = l
+		}
+	}
+	return nil
+}
+
+// list returns the listings that match by id.
+func (s memoryListings) list(match func(l *model.ResaleListing) bool) []model.ResaleListing {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ls []model.ResaleListing
+	for _, l := range s.m.listings {
+		if match(&l) {
+			ls = append(ls, l)
+		}
+	}
+
+	sort.Slice(ls, func(i, j int) bool { return ls[i].ListingID < ls[j].ListingID })
+	return ls
+}
+
+// update applies set to the listing and keeps it, updated at now, when set
+// reports a change.
+func (s memoryListings) update(listingID int64, now time.Time, set func(l *model.ResaleListing) bool) bool {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	l, ok := s.m.listings[listingID]
+	if !ok || !set(&l) {
+		return false
+	}
+
+	l.UpdatedAt = &now
+	s.m.listings[listingID] = l
+	return true
+}
+
+type memoryOffers struct{ m *Memory }
+
+func (s memoryOffers) Create(ctx context.Context, o *model.ResaleOffer) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *o
+	created.OfferID = s.m.nextID
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	created.UpdatedAt = &now
+	s.m.offers[created.OfferID] = created
+	return created.OfferID, nil
+}
+
+func (s memoryOffers) Get(ctx context.Context, offerID int64) (*model.ResaleOffer, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	o, ok := s.m.offers[offerID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &o, true, nil
+}
+
+func (s memoryOffers) Pending(ctx context.Context, listingID int64) ([]model.ResaleOffer, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var os []model.ResaleOffer
+	for _, o := range s.m.offers {
+		if o.ListingID == listingID && o.Status == model.OfferPending {
+			os = append(os, o)
+		}
+	}
+
+	sort.Slice(os, func(i, j int) bool {
+		if os[i].Amount != os[j].Amount {
+			return os[i].Amount > os[j].Amount
+		}
+		return os[i].OfferID < os[j].OfferID
+	})
+	return os, nil
+}
+
+func (s memoryOffers) SetStatus(ctx context.Context, offerID int64, from, to string, now time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	o, ok := s.m.offers[offerID]
+	if !ok || o.Status != from {
+		return false, nil
+	}
+
+	o.Status = to
+	o.UpdatedAt = &now
+	s.m.offers[offerID] = o
+	return true, nil
+}
+
+func (s memoryOffers) Reopen(ctx context.Context, listingID int64, now time.Time) error {
+	s.setStatus(func(o *model.ResaleOffer) bool { return o.ListingID == listingID && o.Status == model.OfferAccepted }, model.OfferPending, now)
+	return nil
+}
+
+func (s memoryOffers) Expire(ctx context.Context, eventTicketID int64, now time.Time) error {
+	s.setStatus(func(o *model.ResaleOffer) bool {
+		return o.EventTicketID == eventTicketID && o.Status == model.OfferPending
+	}, model.OfferExpired, now)
+	return nil
+}
+
+// setStatus moves the offers that match to status.
+func (s memoryOffers) setStatus(match func(o *model.ResaleOffer) bool, status string, now time.Time) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, o := range s.m.offers {
+		if match(&o) {
+			o.Status = status
+			o.UpdatedAt = &now
+			s.m.offers[id] = o
+		}
+	}
+}
+
+type memoryAuctions struct{ m *Memory }
+
+func (s memoryAuctions) Create(ctx context.Context, a *model.Auction) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *a
+	created.AuctionID = s.m.nextID
+	created.LeadingBidID = 0
+	created.LeadingAmount = 0
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	created.UpdatedAt = &now
+	s.m.auctions[created.AuctionID] = created
+	return created.AuctionID, nil
+}
+
+func (s memoryAuctions) Get(ctx context.Context, auctionID int64) (*model.Auction, bool, error) {
+	as := s.list(func(a *model.Auction) bool { return a.AuctionID == auctionID })
+	if len(as) == 0 {
+		return nil, false, nil
+	}
+	return &as[0], true, nil
+}
+
+func (s memoryAuctions) Lock(ctx context.Context, auctionID int64) (*model.Auction, bool, error) {
+	return s.Get(ctx, auctionID)
+}
+
+func (s memoryAuctions) ListByEvent(ctx context.Context, publicEventID int64) ([]model.Auction, error) {
+	as := s.list(func(a *model.Auction) bool { return a.PublicEventID == publicEventID })
+	sort.Slice(as, func(i, j int) bool { return as[i].AuctionID > as[j].AuctionID })
+	return as, nil
+}
+
+func (s memoryAuctions) Due(ctx context.Context, now time.Time) ([]model.Auction, error) {
+	return s.list(func(a *model.Auction) bool {
+		return (a.Status == model.AuctionOpen && !a.EndsAt.After(now)) || a.Status == model.AuctionSettling
+	}), nil
+}
+
+// list returns the auctions that match by id, with the tier and seat of their
+// ticket.
+func (s memoryAuctions) list(match func(a *model.Auction) bool) []model.Auction {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var as []model.Auction
+	for _, a := range s.m.auctions {
+		et, ok := s.m.tickets[a.EventTicketID]
+		if !ok {
+			continue
+		}
+
+		a.TicketTierID = et.TicketTierID
+		a.SeatID = et.SeatID
+		if match(&a) {
+			as = append(as, a)
+		}
+	}
+
+	sort.Slice(as, func(i, j int) bool { return as[i].AuctionID < as[j].AuctionID })
+	return as
+}
+
+func (s memoryAuctions) SetStatus(ctx context.Context, auctionID int64, from, to string, now time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	a, ok := s.m.auctions[auctionID]
+	if !ok || a.Status != from {
+		return false, nil
+	}
+
+	a.Status = to
+	a.UpdatedAt = &now
+	s.m.auctions[auctionID] = a
+	return true, nil
+}
+
+func (s memoryAuctions) Lead(ctx context.Context, b *model.AuctionBid, now time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	a, ok := s.m.auctions[b.AuctionID]
+	if ok {
+		a.LeadingBidID = b.BidID
+		a.LeadingAmount = b.Amount
+		a.UpdatedAt = &now
+		s.m.auctions[b.AuctionID] = a
+	}
+	return nil
+}
+
+func (s memoryAuctions) CreateBid(ctx context.Context, b *model.AuctionBid) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *b
+	created.BidID = s.m.nextID
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	s.m.bids[created.BidID] = created
+	return created.BidID, nil
+}
+
+func (s memoryAuctions) GetBid(ctx context.Context, bidID int64) (*model.AuctionBid, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	b, ok := s.m.bids[bidID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &b, true, nil
+}
+
+func (s memoryAuctions) Bids(ctx context.Context, auctionID int64) ([]model.AuctionBid, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var bs []model.AuctionBid
+	for _, b := range s.m.bids {
+		if b.AuctionID == auctionID {
+			bs = append(bs, b)
+		}
+	}
+
+	sort.Slice(bs, func(i, j int) bool { return bs[i].BidID > bs[j].BidID })
+	return bs, nil
+}
+
+func (s memoryAuctions) SetBidStatus(ctx context.Context, bidID int64, status string, now time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	b, ok := s.m.bids[bidID]
+	if ok {
+		b.Status = status
+		s.m.bids[bidID] = b
+	}
+	return nil
+}
+
+func (s memoryAuctions) SetBidsStatus(ctx context.Context, auctionID int64, status string, now time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, b := range s.m.bids {
+		if b.AuctionID == auctionID {
+			b.Status = status
+			s.m.bids[id] = b
+		}
+	}
+	return nil
+}
+
+type memoryMoves struct{ m *Memory }
+
+func (s memoryMoves) Create(ctx context.Context, mv *model.TicketMove) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	now := time.Now().UTC()
+	created := ticketMove{TicketMove: *mv, createdAt: now}
+	created.MoveID = s.m.nextID
+	created.Attempts = 0
+	created.Version = 0
+	created.LastError = nil
+	created.SubmittedDate = nil
+	created.UpdatedDate = &now
+	s.m.moves[created.MoveID] = created
+	return created.MoveID, nil
+}
+
+func (s memoryMoves) SetState(ctx context.Context, mv *model.TicketMove, state string, lastError *string, submitted *time.Time, now time.Time) (bool, error) {
+	return s.update(mv, now, func(t *model.TicketMove) {
+		t.State = state
+		t.LastError = copyString(lastError)
+		t.SubmittedDate = submitted
+	}), nil
+}
+
+func (s memoryMoves) Claim(ctx context.Context, mv *model.TicketMove, now time.Time) (bool, error) {
+	return s.update(mv, now, func(t *model.TicketMove) {
+		t.Attempts++
+	}), nil
+}
+
+// update applies set to the move, and bumps its version, as long as it is
+// still in the state and at the version mv was read in.
+func (s memoryMoves) update(mv *model.TicketMove, now time.Time, set func(t *model.TicketMove)) bool {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	t, ok := s.m.moves[mv.MoveID]
+	if !ok || t.State != mv.State || t.Version != mv.Version {
+		return false
+	}
+
+	set(&t.TicketMove)
+	t.Version++
+	t.UpdatedDate = &now
+	s.m.moves[mv.MoveID] = t
+	return true
+}
+
+func (s memoryMoves) Stale(ctx context.Context, before time.Time) ([]model.TicketMove, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var mvs []model.TicketMove
+	for _, mv := range s.m.moves {
+		switch mv.State {
+		case model.MovePending, model.MoveChainSubmitted, model.MoveConfirmed:
+			if !mv.UpdatedDate.After(before) {
+				mvs = append(mvs, mv.TicketMove)
+			}
+		}
+	}
+
+	sort.Slice(mvs, func(i, j int) bool { return mvs[i].MoveID < mvs[j].MoveID })
+	return mvs, nil
+}
+
+func (s memoryMoves) Latest(ctx context.Context, eventTicketID int64) (*model.TicketMove, bool, error) {
+	return s.latest(func(mv *ticketMove) bool { return mv.EventTicketID == eventTicketID })
+}
+
+func (s memoryMoves) LatestByReservation(ctx context.Context, reservationID string) (*model.TicketMove, bool, error) {
+	return s.latest(func(mv *ticketMove) bool { return mv.ReservationID != nil && *mv.ReservationID == reservationID })
+}
+
+func (s memoryMoves) LatestOfferSale(ctx context.Context, eventTicketID int64, since time.Time) (*model.TicketMove, bool, error) {
+	return s.latest(func(mv *ticketMove) bool {
+		return mv.EventTicketID == eventTicketID && mv.Kind == model.MoveBuyResell && mv.Amount != nil && !mv.createdAt.Before(since)
+	})
+}
+
+// latest returns the move with the highest id that matches.
+func (s memoryMoves) latest(match func(mv *ticketMove) bool) (*model.TicketMove, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var found *model.TicketMove
+	for _, mv := range s.m.moves {
+		mv := mv
+		if match(&mv) && (found == nil || mv.MoveID > found.MoveID) {
+			found = &mv.TicketMove
+		}
+	}
+	return found, found != nil, nil
+}
+
+type memoryPayouts struct{ m *Memory }
+
+func (s memoryPayouts) Create(ctx context.Context, p *model.ResalePayout) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *p
+	created.ResalePayoutID = s.m.nextID
+	if created.Status == "" {
+		created.Status = "PENDING"
+	}
+	now := time.Now().UTC()
+	created.CreatedAt = &now
+	s.m.payouts = append(s.m.payouts, created)
+	return created.ResalePayoutID, nil
+}
+
+type memoryMarketplaces struct{ m *Memory }
+
+func (s memoryMarketplaces) Create(ctx context.Context, mp *model.Marketplace) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	created := *mp
+	created.MarketPlaceID = s.m.nextID
+	created.Key = nil
+	s.m.marketplaces[created.MarketPlaceID] = created
+	return created.MarketPlaceID, nil
+}
+
+func (s memoryMarketplaces) Get(ctx context.Context, marketplaceID int64) (*model.Marketplace, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	mp, ok := s.m.marketplaces[marketplaceID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &mp, true, nil
+}
+
+func (s memoryMarketplaces) List(ctx context.Context) ([]model.Marketplace, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ms []model.Marketplace
+	for _, mp := range s.m.marketplaces {
+		ms = append(ms, mp)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].MarketPlaceID < ms[j].MarketPlaceID })
+	return ms, nil
+}
+
+func (s memoryMarketplaces) Update(ctx context.Context, mp *model.Marketplace) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	existing, ok := s.m.marketplaces[mp.MarketPlaceID]
+	if !ok {
+		return nil
+	}
+
+	existing.MarketPlaceName = mp.MarketPlaceName
+	existing.DisplayName = mp.DisplayName
+	existing.CallbackURL = mp.CallbackURL
+	existing.AllowedOrigins = append([]string(nil), mp.AllowedOrigins...)
+	existing.AccessType = mp.AccessType
+	existing.AllowBodyKey = mp.AllowBodyKey
+	existing.OTPChannels = append([]string(nil), mp.OTPChannels...)
+	existing.WebhookEvents = append([]string(nil), mp.WebhookEvents...)
+	s.m.marketplaces[mp.MarketPlaceID] = existing
+	return nil
+}
+
+func (s memoryMarketplaces) SetPublicKey(ctx context.Context, marketplaceID int64, keyID, publicKey string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	mp, ok := s.m.marketplaces[marketplaceID]
+	if !ok {
+		return nil
+	}
+
+	mp.PublicKeyID = &keyID
+	mp.PublicKey = &publicKey
+	s.m.marketplaces[marketplaceID] = mp
+	return nil
+}
+
+func (s memoryMarketplaces) WebhookSecret(ctx context.Context, marketplaceID int64) (string, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	return s.m.webhookSecrets[marketplaceID], nil
+}
+
+func (s memoryMarketplaces) SetWebhookSecret(ctx context.Context, marketplaceID int64, secret string) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.webhookSecrets[marketplaceID] = secret
+	return nil
+}
+
+func (s memoryMarketplaces) Deactivate(ctx context.Context, marketplaceID int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	mp, ok := s.m.marketplaces[marketplaceID]
+	if !ok {
+		return nil
+	}
+
+	mp.IsActive = false
+	s.m.marketplaces[marketplaceID] = mp
+	return nil
+}
+
+func (s memoryMarketplaces) GetUser(ctx context.Context, marketplaceID int64, phoneNumber string) (*model.MarketplaceUser, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, u := range s.m.marketplaceUsers {
+		if u.MarketPlaceID == marketplaceID && u.phoneNumber == phoneNumber {
+			mu := u.MarketplaceUser
+			return &mu, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (s memoryMarketplaces) GetUserByID(ctx context.Context, userMarketplaceID int64) (*model.MarketplaceUser, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	u, ok := s.m.marketplaceUsers[userMarketplaceID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	mu := u.MarketplaceUser
+	mu.PhoneNumber = u.phoneNumber
+	return &mu, true, nil
+}
+
+func (s memoryMarketplaces) CreateUser(ctx context.Context, marketplaceID int64, phoneNumber string) (int64, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, u := range s.m.marketplaceUsers {
+		if u.MarketPlaceID == marketplaceID && u.phoneNumber == phoneNumber {
+			return 0, fmt.Errorf("marketplaces.CreateUser: duplicate user for marketplace: %d", marketplaceID)
+		}
+	}
+
+	s.m.nextID++
+	id := s.m.nextID
+	s.m.marketplaceUsers[id] = marketplaceUser{
+		MarketplaceUser: model.MarketplaceUser{UserID: id, MarketPlaceID: marketplaceID},
+		phoneNumber:     phoneNumber,
+	}
+	return id, nil
+}
+
+func (s memoryMarketplaces) ValidateUser(ctx context.Context, userMarketplaceID int64) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	u, ok := s.m.marketplaceUsers[userMarketplaceID]
+	if !ok {
+		return fmt.Errorf("marketplaces.ValidateUser: 0 rows affected for user: %d", userMarketplaceID)
+	}
+
+	u.IsValid = true
+	s.m.marketplaceUsers[userMarketplaceID] = u
+	return nil
+}
+
+func (s memoryMarketplaces) HasUsers(ctx context.Context, marketplaceID int64) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for _, u := range s.m.marketplaceUsers {
+		if u.MarketPlaceID == marketplaceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryMarketplaceKeys struct{ m *Memory }
+
+func (s memoryMarketplaceKeys) Create(ctx context.Context, k *model.MarketplaceKey) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.marketplaceKeys[k.KeyID]; ok {
+		return fmt.Errorf("marketplaceKeys.Create: duplicate key: %s", k.KeyID)
+	}
+
+	created := *k
+	created.AccessKey = ""
+	if created.CreatedAt == nil {
+		now := time.Now().UTC()
+		created.CreatedAt = &now
+	}
+	s.m.marketplaceKeys[k.KeyID] = created
+	return nil
+}
+
+func (s memoryMarketplaceKeys) Get(ctx context.Context, keyID string) (*model.MarketplaceKey, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	k, ok := s.m.marketplaceKeys[keyID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &k, true, nil
+}
+
+func (s memoryMarketplaceKeys) List(ctx context.Context, marketplaceID int64) ([]model.MarketplaceKey, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ks []model.MarketplaceKey
+	for _, k := range s.m.marketplaceKeys {
+		if k.MarketPlaceID == marketplaceID {
+			ks = append(ks, k)
+		}
+	}
+
+	sort.Slice(ks, func(i, j int) bool {
+		if ks[i].CreatedAt.Equal(*ks[j].CreatedAt) {
+			return ks[i].KeyID < ks[j].KeyID
+		}
+		return ks[i].CreatedAt.Before(*ks[j].CreatedAt)
+	})
+	return ks, nil
+}
+
+func (s memoryMarketplaceKeys) ExpireOthers(ctx context.Context, marketplaceID int64, keyID string, before time.Time) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	for id, k := range s.m.marketplaceKeys {
+		if k.MarketPlaceID != marketplaceID || id == keyID || k.RevokedAt != nil {
+			continue
+		}
+
+		if k.ExpiresAt == nil || k.ExpiresAt.After(before) {
+			expiresAt := before
+			k.ExpiresAt = &expiresAt
+			s.m.marketplaceKeys[id] = k
+		}
+	}
+	return nil
+}
+
+func (s memoryMarketplaceKeys) Revoke(ctx context.Context, marketplaceID int64, keyID string) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	k, ok := s.m.marketplaceKeys[keyID]
+	if !ok || k.MarketPlaceID != marketplaceID || k.RevokedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	s.m.marketplaceKeys[keyID] = k
+	return true, nil
+}
+
+func (s memoryMarketplaceKeys) SetSigningKey(ctx context.Context, keyID, old, signingKey string) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	k, ok := s.m.marketplaceKeys[keyID]
+	if !ok || k.SigningKey != old {
+		return false, nil
+	}
+
+	k.SigningKey = signingKey
+	s.m.marketplaceKeys[keyID] = k
+	return true, nil
+}
+
+type memoryOTPAudits struct{ m *Memory }
+
+func (s memoryOTPAudits) Record(ctx context.Context, a *model.OTPAudit) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	recorded := *a
+	recorded.OTPAuditID = s.m.nextID
+	now := time.Now().UTC()
+	recorded.CreatedAt = &now
+	s.m.otpAudits = append(s.m.otpAudits, recorded)
+	return nil
+}
+
+func (s memoryOTPAudits) ListByUser(ctx context.Context, marketplaceID, userMarketplaceID int64) ([]model.OTPAudit, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var as []model.OTPAudit
+	for _, a := range s.m.otpAudits {
+		if a.MarketPlaceID == marketplaceID && a.UserMarketplaceID == userMarketplaceID {
+			as = append(as, a)
+		}
+	}
+	return as, nil
+}
+
+type memoryMarketplaceMoves struct{ m *Memory }
+
+func (s memoryMarketplaceMoves) Record(ctx context.Context, mv *model.MarketplaceTicketMove) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	recorded := *mv
+	recorded.MarketplaceMoveID = s.m.nextID
+	now := time.Now().UTC()
+	recorded.CreatedAt = &now
+	s.m.marketplaceMoves = append(s.m.marketplaceMoves, recorded)
+	return nil
+}
+
+func (s memoryMarketplaceMoves) ListByMarketplace(ctx context.Context, marketplaceID int64) ([]model.MarketplaceTicketMove, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var mvs []model.MarketplaceTicketMove
+	for _, mv := range s.m.marketplaceMoves {
+		if mv.FromMarketplaceID == marketplaceID || mv.ToMarketplaceID == marketplaceID {
+			mvs = append(mvs, mv)
+		}
+	}
+	return mvs, nil
+}
+
+type memoryWebhooks struct{ m *Memory }
+
+func (s memoryWebhooks) Enqueue(ctx context.Context, d *model.WebhookDelivery) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	s.m.nextID++
+	d.DeliveryID = s.m.nextID
+	now := time.Now().UTC()
+	d.CreatedAt = &now
+	s.m.webhooks[d.DeliveryID] = *d
+	return nil
+}
+
+func (s memoryWebhooks) Due(ctx context.Context, now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ds []model.WebhookDelivery
+	for _, d := range s.m.webhooks {
+		if d.Status == model.WebhookPending && !d.NextAttemptAt.After(now) {
+			ds = append(ds, d)
+		}
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i].NextAttemptAt.Before(*ds[j].NextAttemptAt) })
+	if len(ds) > limit {
+		ds = ds[:limit]
+	}
+	return ds, nil
+}
+
+func (s memoryWebhooks) Claim(ctx context.Context, deliveryID int64, now, until time.Time) (bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	d, ok := s.m.webhooks[deliveryID]
+	if !ok || d.Status != model.WebhookPending || d.NextAttemptAt.After(now) {
+		return false, nil
+	}
+
+	d.NextAttemptAt = &until
+	s.m.webhooks[deliveryID] = d
+	return true, nil
+}
+
+func (s memoryWebhooks) Update(ctx context.Context, d *model.WebhookDelivery) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.webhooks[d.DeliveryID]; ok {
+		s.m.webhooks[d.DeliveryID] = *d
+	}
+	return nil
+}
+
+func (s memoryWebhooks) Get(ctx context.Context, marketplaceID, deliveryID int64) (*model.WebhookDelivery, bool, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	d, ok := s.m.webhooks[deliveryID]
+	if !ok || d.MarketPlaceID != marketplaceID {
+		return nil, false, nil
+	}
+	return &d, true, nil
+}
+
+func (s memoryWebhooks) List(ctx context.Context, marketplaceID int64, status string, limit int) ([]model.WebhookDelivery, error) {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	var ds []model.WebhookDelivery
+	for _, d := range s.m.webhooks {
+		if d.MarketPlaceID == marketplaceID && (status == "" || d.Status == status) {
+			ds = append(ds, d)
+		}
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i].DeliveryID > ds[j].DeliveryID })
+	if len(ds) > limit {
+		ds = ds[:limit]
+	}
+	return ds, nil
+}
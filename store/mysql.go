@@ -0,0 +1,2873 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"eventers-marketplace-backend/model"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// querier prepares the queries of the repositories, on the database or on a
+// transaction.
+type querier interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// NewMySQL returns a store backed by the MySQL database.
+func NewMySQL(db *sql.DB) *Store {
+	s := mysqlStore(db)
+	s.tx = func(ctx context.Context, fn func(tx *Store) error) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("tx: error begining db transaction: %w", err)
+		}
+
+		err = fn(mysqlStore(tx))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("tx: could not commit transaction: %w", err)
+		}
+
+		return nil
+	}
+	return s
+}
+
+// mysqlStore returns the repositories reading and writing through q. Tx on the
+// store runs fn inline, which keeps it in the transaction of q.
+func mysqlStore(q querier) *Store {
+	return &Store{
+		Users:            &mysqlUsers{db: q},
+		Events:           &mysqlEvents{db: q},
+		Tiers:            &mysqlTiers{db: q},
+		ResalePolicies:   &mysqlResalePolicies{db: q},
+		Venues:           &mysqlVenues{db: q},
+		Tickets:          &mysqlTickets{db: q},
+		Reservations:     &mysqlReservations{db: q},
+		Refunds:          &mysqlRefunds{db: q},
+		Listings:         &mysqlListings{db: q},
+		Offers:           &mysqlOffers{db: q},
+		Auctions:         &mysqlAuctions{db: q},
+		Moves:            &mysqlMoves{db: q},
+		Payouts:          &mysqlPayouts{db: q},
+		Marketplaces:     &mysqlMarketplaces{db: q},
+		MarketplaceKeys:  &mysqlMarketplaceKeys{db: q},
+		OTPAudits:        &mysqlOTPAudits{db: q},
+		MarketplaceMoves: &mysqlMarketplaceMoves{db: q},
+		Webhooks:         &mysqlWebhooks{db: q},
+	}
+}
+
+type mysqlUsers struct {
+	db querier
+}
+
+func (s *mysqlUsers) Get(ctx context.Context, userID int64) (*model.User, bool, error) {
+	query := `SELECT first_name, last_name, display_name, email_address, city, state, country, address, pincode,
+			fb_firebase_id, fb_email, fb_name, fb_image_url, g_firebase_id, g_email, g_name, g_image_url,
+			a_firebase_id, a_email, a_name, a_image_url, phone_firebase_id, phone_country_code, phone_number,
+			provider, profile_pic from Users where user_id=?`
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("users.Get: error preparing query to fetch user for the id: %d: %w", userID, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("users.Get: error executing query to fetch user for the id: %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	user := model.User{UserID: userID}
+	if rows.Next() {
+		err := rows.Scan(
+			&user.FirstName,
+			&user.LastName,
+			&user.DisplayName,
+			&user.EmailAddress,
+			&user.City,
+			&user.State,
+			&user.Country,
+			&user.Address,
+			&user.Pincode,
+			&user.FBFirebaseID,
+			&user.FBEmail,
+			&user.FBName,
+			&user.FBImageURL,
+			&user.GFirebaseID,
+			&user.GEmail,
+			&user.GName,
+			&user.GImageURL,
+			&user.AFirebaseID,
+			&user.AEmail,
+			&user.AName,
+			&user.AImageURL,
+			&user.PhoneFirebaseID,
+			&user.PhoneCountryCode,
+			&user.PhoneNumber,
+			&user.Provider,
+			&user.ProfilePic,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("users.Get: error while scanning row: %s", err)
+		}
+		return &user, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *mysqlUsers) IDByFirebaseID(ctx context.Context, firebaseID string) (int64, bool, error) {
+	query := "SELECT user_id FROM Users WHERE (a_firebase_id=? OR fb_firebase_id=? OR g_firebase_id=? OR phone_firebase_id=?) AND is_active = 1 AND is_registered = 1;"
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, false, fmt.Errorf("users.IDByFirebaseID: error preparing query to fetch user id: %s: %w", firebaseID, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, firebaseID, firebaseID, firebaseID, firebaseID)
+	if err != nil {
+		return 0, false, fmt.Errorf("users.IDByFirebaseID: error executing query to fetch user for the id: %s: %w", firebaseID, err)
+	}
+	defer rows.Close()
+
+	var userID int64
+	if rows.Next() {
+		err := rows.Scan(&userID)
+		if err != nil {
+			return 0, false, fmt.Errorf("users.IDByFirebaseID: error while scanning row: %s", err)
+		}
+		return userID, true, nil
+	}
+
+	return 0, false, nil
+}
+
+const userQuery = `SELECT user_id, first_name, last_name, display_name, email_address, city, state, country, address, pincode,
+			fb_firebase_id, fb_email, fb_name, fb_image_url, g_firebase_id, g_email, g_name, g_image_url,
+			a_firebase_id, a_email, a_name, a_image_url, phone_firebase_id, phone_country_code, phone_number,
+			provider, profile_pic, is_registered, is_active FROM Users`
+
+func (s *mysqlUsers) FindByFirebaseID(ctx context.Context, provider string, firebaseID *string, state UserState) (*model.User, bool, error) {
+	cols, ok := identityColumns[provider]
+	if !ok {
+		return nil, false, fmt.Errorf("users.FindByFirebaseID: unknown provider: %s", provider)
+	}
+
+	if firebaseID == nil {
+		return nil, false, nil
+	}
+
+	u, ok, err := s.find(ctx, userQuery+` WHERE `+cols[0]+` = ?`+state.condition(), []interface{}{*firebaseID})
+	if err != nil {
+		return nil, false, fmt.Errorf("users.FindByFirebaseID: %s: %w", provider, err)
+	}
+
+	return u, ok, nil
+}
+
+func (s *mysqlUsers) FindByEmail(ctx context.Context, provider string, email *string, state UserState) (*model.User, bool, error) {
+	cols, ok := identityColumns[provider]
+	if !ok || provider == ProviderPhone {
+		return nil, false, fmt.Errorf("users.FindByEmail: unknown provider: %s", provider)
+	}
+
+	if email == nil {
+		return nil, false, nil
+	}
+
+	u, ok, err := s.find(ctx, userQuery+` WHERE `+cols[1]+` = ?`+state.condition(), []interface{}{*email})
+	if err != nil {
+		return nil, false, fmt.Errorf("users.FindByEmail: %s: %w", provider, err)
+	}
+
+	return u, ok, nil
+}
+
+func (s *mysqlUsers) FindByPhone(ctx context.Context, countryCode, number *string, state UserState, except int64) (*model.User, bool, error) {
+	if countryCode == nil || number == nil {
+		return nil, false, nil
+	}
+
+	u, ok, err := s.find(ctx, userQuery+` WHERE phone_country_code = ? AND phone_number = ? AND user_id <> ?`+state.condition(), []interface{}{*countryCode, *number, except})
+	if err != nil {
+		return nil, false, fmt.Errorf("users.FindByPhone: %w", err)
+	}
+
+	return u, ok, nil
+}
+
+func (s *mysqlUsers) FindPhoneOnly(ctx context.Context, countryCode, number *string) (*model.User, bool, error) {
+	if countryCode == nil || number == nil {
+		return nil, false, nil
+	}
+
+	u, ok, err := s.find(ctx, userQuery+` WHERE phone_country_code = ? AND phone_number = ? AND fb_firebase_id IS NULL AND g_firebase_id IS NULL`+UnregisteredUser.condition(), []interface{}{*countryCode, *number})
+	if err != nil {
+		return nil, false, fmt.Errorf("users.FindPhoneOnly: %w", err)
+	}
+
+	return u, ok, nil
+}
+
+func (s *mysqlUsers) FindByActivePhone(ctx context.Context, phoneNumber string) (*model.User, bool, error) {
+	u, ok, err := s.find(ctx, userQuery+` WHERE active_phone = ?`, []interface{}{phoneNumber})
+	if err != nil {
+		return nil, false, fmt.Errorf("users.FindByActivePhone: %w", err)
+	}
+
+	return u, ok, nil
+}
+
+func (s *mysqlUsers) find(ctx context.Context, query string, args []interface{}) (*model.User, bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var u model.User
+	if rows.Next() {
+		err := rows.Scan(
+			&u.UserID,
+			&u.FirstName,
+			&u.LastName,
+			&u.DisplayName,
+			&u.EmailAddress,
+			&u.City,
+			&u.State,
+			&u.Country,
+			&u.Address,
+			&u.Pincode,
+			&u.FBFirebaseID,
+			&u.FBEmail,
+			&u.FBName,
+			&u.FBImageURL,
+			&u.GFirebaseID,
+			&u.GEmail,
+			&u.GName,
+			&u.GImageURL,
+			&u.AFirebaseID,
+			&u.AEmail,
+			&u.AName,
+			&u.AImageURL,
+			&u.PhoneFirebaseID,
+			&u.PhoneCountryCode,
+			&u.PhoneNumber,
+			&u.Provider,
+			&u.ProfilePic,
+			&u.IsRegistered,
+			&u.IsActive,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("error while scanning row: %s", err)
+		}
+		return &u, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *mysqlUsers) Create(ctx context.Context, u *model.User) (int64, error) {
+	columns, values, err := identity(u)
+	if err != nil {
+		return 0, fmt.Errorf("users.Create: %w", err)
+	}
+
+	params := make([]string, len(columns))
+	for i := range params {
+		params[i] = "?"
+	}
+
+	query := fmt.Sprintf(`INSERT INTO Users (%s) VALUES (%s);`, strings.Join(columns, ", "), strings.Join(params, ", "))
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("users.Create: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, values...)
+	if err != nil {
+		return 0, fmt.Errorf("users.Create: unable to insert user: %s", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("users.Create: unable to get last insert id: %s", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlUsers) SetIdentity(ctx context.Context, userID int64, u *model.User) error {
+	columns, values, err := identity(u)
+	if err != nil {
+		return fmt.Errorf("users.SetIdentity: %w", err)
+	}
+
+	err = s.update(ctx, userID, columns, values)
+	if err != nil {
+		return fmt.Errorf("users.SetIdentity: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlUsers) SetPhone(ctx context.Context, userID int64, countryCode, number, firebaseID *string, active bool) error {
+	err := s.update(ctx, userID,
+		[]string{"phone_country_code", "phone_number", "phone_firebase_id", "is_registered", "is_active"},
+		[]interface{}{countryCode, number, firebaseID, true, active},
+	)
+	if err != nil {
+		return fmt.Errorf("users.SetPhone: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlUsers) SetProfile(ctx context.Context, u *model.User) error {
+	var columns []string
+	var values []interface{}
+	for _, f := range profileFields(u) {
+		if f.value != nil && strings.TrimSpace(*f.value) != "" {
+			columns = append(columns, f.column)
+			values = append(values, *f.value)
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	err := s.update(ctx, u.UserID, columns, values)
+	if err != nil {
+		return fmt.Errorf("users.SetProfile: %w", err)
+	}
+
+	return nil
+}
+
+// update sets columns of the user to values. The column names come from this
+// package only.
+func (s *mysqlUsers) update(ctx context.Context, userID int64, columns []string, values []interface{}) error {
+	var set []string
+	for _, col := range columns {
+		set = append(set, fmt.Sprintf("%s = ?", col))
+	}
+
+	query := fmt.Sprintf(`UPDATE Users SET %s WHERE user_id = ?;`, strings.Join(set, ", "))
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, append(values, userID)...)
+	if err != nil {
+		return fmt.Errorf("unable to execute query: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to get rows affected: %s", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("%d rows affected: err: %w", rowsAffected, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *mysqlUsers) Merge(ctx context.Context, provider string, from, to int64) error {
+	columns, ok := identityColumns[provider]
+	if !ok {
+		return fmt.Errorf("users.Merge: unknown provider: %s", provider)
+	}
+
+	if provider != ProviderPhone {
+		columns = append(columns[:len(columns):len(columns)], "phone_firebase_id")
+	}
+
+	var cols []string
+	for _, v := range columns {
+		cols = append(cols, fmt.Sprintf("u1.%s = u2.%s", v, v))
+	}
+
+	query := fmt.Sprintf(`
+					UPDATE Users u1
+					INNER JOIN Users u2
+					ON u2.phone_country_code = u1.phone_country_code
+					AND u2.phone_number 	 = u1.phone_number
+					SET %s , u2.is_registered = 1, u2.is_active = 0
+					WHERE u1.user_id = ? and u2.user_id = ?
+				`, strings.Join(cols, ", "))
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("users.Merge: error preparing query to copy: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, to, from)
+	if err != nil {
+		return fmt.Errorf("users.Merge: error executing query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlUsers) Phone(ctx context.Context, userID int64) (string, string, error) {
+	stmt, err := s.db.PrepareContext(ctx, "SELECT phone_country_code, phone_number FROM Users WHERE user_id = ?")
+	if err != nil {
+		return "", "", fmt.Errorf("users.Phone: error preparing query for phone number: %s", err)
+	}
+	defer stmt.Close()
+
+	var phoneCountryCode sql.NullString
+	var phoneNumber sql.NullString
+	err = stmt.QueryRowContext(ctx, userID).Scan(&phoneCountryCode, &phoneNumber)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("users.Phone: error scanning phone number: %s", err)
+	}
+
+	return phoneCountryCode.String, phoneNumber.String, nil
+}
+
+// identityColumns lists the columns of the identity of each provider, the
+// firebase id first and, for social providers, the email second.
+var identityColumns = map[string][]string{
+	ProviderApple:    {"a_firebase_id", "a_email", "a_name", "a_image_url"},
+	ProviderFacebook: {"fb_firebase_id", "fb_email", "fb_name", "fb_image_url"},
+	ProviderGoogle:   {"g_firebase_id", "g_email", "g_name", "g_image_url"},
+	ProviderPhone:    {"phone_firebase_id", "phone_country_code", "phone_number"},
+}
+
+// identity returns the columns Create and SetIdentity write and their values.
+// Users of an unknown provider are stored as phone users.
+func identity(u *model.User) ([]string, []interface{}, error) {
+	if u.Provider == nil {
+		return nil, nil, fmt.Errorf("identity: provider is required")
+	}
+
+	columns := []string{"provider"}
+	values := []interface{}{*u.Provider}
+
+	switch *u.Provider {
+	case ProviderApple:
+		columns = append(columns, identityColumns[ProviderApple]...)
+		values = append(values, u.AFirebaseID, u.AEmail, u.AName, u.AImageURL)
+	case ProviderFacebook:
+		columns = append(columns, identityColumns[ProviderFacebook]...)
+		values = append(values, u.FBFirebaseID, u.FBEmail, u.FBName, u.FBImageURL)
+	case ProviderGoogle:
+		columns = append(columns, identityColumns[ProviderGoogle]...)
+		values = append(values, u.GFirebaseID, u.GEmail, u.GName, u.GImageURL)
+	default:
+		columns = append(columns, identityColumns[ProviderPhone]...)
+		columns = append(columns, "is_registered", "is_active")
+		values = append(values, u.PhoneFirebaseID, u.PhoneCountryCode, u.PhoneNumber, true, true)
+	}
+
+	columns = append(columns, "updated_date")
+	values = append(values, time.Now())
+
+	return columns, values, nil
+}
+
+type profileField struct {
+	column string
+	value  *string
+}
+
+// profileFields returns the profile fields of the user SetProfile writes.
+func profileFields(u *model.User) []profileField {
+	return []profileField{
+		{"first_name", u.FirstName},
+		{"last_name", u.LastName},
+		{"display_name", u.DisplayName},
+		{"email_address", u.EmailAddress},
+		{"city", u.City},
+		{"state", u.State},
+		{"country", u.Country},
+		{"address", u.Address},
+		{"pincode", u.Pincode},
+		{"profile_pic", u.ProfilePic},
+	}
+}
+
+// condition returns the conditions of the state to append to a where clause.
+func (s UserState) condition() string {
+	switch s {
+	case RegisteredUser:
+		return ` AND is_registered = 1 AND is_active = 1`
+	case UnregisteredUser:
+		return ` AND is_registered = 0 AND is_active = 0`
+	}
+	return ``
+}
+
+type mysqlEvents struct {
+	db querier
+}
+
+const publicEventQuery = `SELECT public_event_id, date_time, event_title, event_description, event_image, total_tickets,
+				ticket_price, business_user_id, status, IFNULL(venue_id, 0) FROM Public_Event`
+
+func (s *mysqlEvents) Get(ctx context.Context, publicEventID int64) (*model.PublicEvent, bool, error) {
+	pes, err := s.list(ctx, publicEventQuery+` WHERE public_event_id = ?`, publicEventID)
+	if err != nil {
+		return nil, false, fmt.Errorf("events.Get: %w", err)
+	}
+
+	if len(pes) == 0 {
+		return nil, false, nil
+	}
+
+	return &pes[0], true, nil
+}
+
+// List returns the public events with the status, or every event when status
+// is empty.
+func (s *mysqlEvents) List(ctx context.Context, status string) ([]model.PublicEvent, error) {
+	query := publicEventQuery
+	var args []interface{}
+	if status != "" {
+		query = query + ` WHERE status = ?`
+		args = append(args, status)
+	}
+
+	pes, err := s.list(ctx, query+` ORDER BY public_event_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("events.List: %w", err)
+	}
+
+	return pes, nil
+}
+
+func (s *mysqlEvents) list(ctx context.Context, query string, args ...interface{}) ([]model.PublicEvent, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var pes []model.PublicEvent
+	for rows.Next() {
+		var pe model.PublicEvent
+		var businessUserID sql.NullInt64
+		err := rows.Scan(
+			&pe.PublicEventID,
+			&pe.DateTime,
+			&pe.EventTitle,
+			&pe.EventDescription,
+			&pe.EventImage,
+			&pe.TotalTickets,
+			&pe.TicketPrice,
+			&businessUserID,
+			&pe.Status,
+			&pe.VenueID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		pe.BusinessUserID = businessUserID.Int64
+		pes = append(pes, pe)
+	}
+
+	return pes, nil
+}
+
+func (s *mysqlEvents) Create(ctx context.Context, pe *model.PublicEvent, tempAddress, tempPassphrase string) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Public_Event (date_time, event_title, event_description, event_image, total_tickets, ticket_price,
+			temp_account_address, temp_security_paraphrase, business_user_id, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		pe.DateTime, pe.EventTitle, pe.EventDescription, pe.EventImage, pe.TotalTickets, pe.TicketPrice, tempAddress, tempPassphrase, pe.BusinessUserID, pe.Status)
+	if err != nil {
+		return 0, fmt.Errorf("events.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlEvents) Update(ctx context.Context, pe *model.PublicEvent, from, to string) error {
+	var set []string
+	var args []interface{}
+	for _, f := range eventFields(pe) {
+		set = append(set, f.column+" = ?")
+		args = append(args, f.value)
+	}
+	set = append(set, "status = ?", "updated_date = CURRENT_TIMESTAMP")
+	args = append(args, to, pe.PublicEventID, from)
+
+	n, err := updateRows(ctx, s.db, `UPDATE Public_Event SET `+strings.Join(set, ", ")+` WHERE public_event_id = ? AND status = ?;`, args...)
+	if err != nil {
+		return fmt.Errorf("events.Update: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("events.Update: public event: %d is not %s: %w", pe.PublicEventID, from, ErrNotFound)
+	}
+
+	return nil
+}
+
+type eventField struct {
+	column string
+	value  interface{}
+}
+
+// eventFields returns the editable columns set on pe.
+func eventFields(pe *model.PublicEvent) []eventField {
+	var fs []eventField
+	if pe.DateTime != nil {
+		fs = append(fs, eventField{"date_time", pe.DateTime})
+	}
+	if pe.EventTitle != nil {
+		fs = append(fs, eventField{"event_title", pe.EventTitle})
+	}
+	if pe.EventDescription != nil {
+		fs = append(fs, eventField{"event_description", pe.EventDescription})
+	}
+	if pe.EventImage != nil {
+		fs = append(fs, eventField{"event_image", pe.EventImage})
+	}
+	if pe.TotalTickets > 0 {
+		fs = append(fs, eventField{"total_tickets", pe.TotalTickets})
+	}
+	if pe.TicketPrice > 0 {
+		fs = append(fs, eventField{"ticket_price", pe.TicketPrice})
+	}
+	return fs
+}
+
+func (s *mysqlEvents) SetVenue(ctx context.Context, publicEventID, venueID int64, totalTickets uint64, status string) error {
+	n, err := updateRows(ctx, s.db, `UPDATE Public_Event SET venue_id = ?, total_tickets = ?, updated_date = CURRENT_TIMESTAMP
+			WHERE public_event_id = ? AND status = ?;`, venueID, totalTickets, publicEventID, status)
+	if err != nil {
+		return fmt.Errorf("events.SetVenue: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("events.SetVenue: public event: %d is not %s: %w", publicEventID, status, ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *mysqlEvents) Delete(ctx context.Context, publicEventID int64, status string) error {
+	n, err := updateRows(ctx, s.db, `DELETE FROM Public_Event WHERE public_event_id = ? AND status = ?;`, publicEventID, status)
+	if err != nil {
+		return fmt.Errorf("events.Delete: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("events.Delete: public event: %d is not %s: %w", publicEventID, status, ErrNotFound)
+	}
+
+	return nil
+}
+
+// catalogueQuery lists the published events with their lowest ticket price and
+// the tickets on sale from the organizer and on resale. Events without a date
+// sort on Undated.
+const catalogueQuery = `SELECT pe.public_event_id, pe.date_time, IFNULL(pe.date_time, CAST('9999-12-31 23:59:59' AS DATETIME)) AS sort_date,
+			pe.event_title, pe.event_description, pe.event_image, IFNULL(pe.total_tickets, 0) AS total_tickets, IFNULL(pe.ticket_price, 0) AS ticket_price,
+			IFNULL((SELECT MIN(tt.ticket_price) FROM Ticket_Tier tt WHERE tt.public_event_id = pe.public_event_id), IFNULL(pe.ticket_price, 0)) AS from_price,
+			(SELECT COUNT(et.event_ticket_id) FROM Event_Tickets et WHERE et.public_event_id = pe.public_event_id
+				AND et.business_user_id = et.current_holder_id AND et.status = 'ACTIVE') AS available_tickets,
+			(SELECT COUNT(et.event_ticket_id) FROM Event_Tickets et WHERE et.public_event_id = pe.public_event_id
+				AND et.status = 'RESELL') AS resale_tickets
+			FROM Public_Event pe WHERE pe.status = 'PUBLISHED'`
+
+func (s *mysqlEvents) Catalogue(ctx context.Context, q *CatalogueQuery) ([]model.PublicEvent, int64, error) {
+	inner, outer, args := catalogueConditions(q.Filter)
+	from := ` FROM (` + catalogueQuery + inner + `) e WHERE 1 = 1` + outer
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT COUNT(e.public_event_id)`+from+`;`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("events.Catalogue: error preparing count query: %w", err)
+	}
+	defer stmt.Close()
+
+	var total int64
+	err = stmt.QueryRowContext(ctx, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("events.Catalogue: error counting public events: %w", err)
+	}
+
+	column := "e.sort_date"
+	if q.ByPrice {
+		column = "e.from_price"
+	}
+
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if q.After != nil {
+		var v interface{} = q.After.FromPrice
+		if !q.ByPrice {
+			v = Undated
+			if q.After.DateTime != nil {
+				v = *q.After.DateTime
+			}
+		}
+		from += fmt.Sprintf(` AND (%s %s ? OR (%s = ? AND e.public_event_id %s ?))`, column, op, column, op)
+		args = append(args, v, v, q.After.PublicEventID)
+	}
+
+	stmt, err = s.db.PrepareContext(ctx, `SELECT e.public_event_id, e.date_time, e.event_title, e.event_description, e.event_image, e.total_tickets,
+			e.ticket_price, e.from_price, e.available_tickets`+from+fmt.Sprintf(` ORDER BY %s %s, e.public_event_id %s LIMIT ?;`, column, dir, dir))
+	if err != nil {
+		return nil, 0, fmt.Errorf("events.Catalogue: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, append(args, q.Limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("events.Catalogue: error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var pes []model.PublicEvent
+	for rows.Next() {
+		var pe model.PublicEvent
+		err := rows.Scan(
+			&pe.PublicEventID,
+			&pe.DateTime,
+			&pe.EventTitle,
+			&pe.EventDescription,
+			&pe.EventImage,
+			&pe.TotalTickets,
+			&pe.TicketPrice,
+			&pe.FromPrice,
+			&pe.AvailableTickets,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("events.Catalogue: error while scanning row: %s", err)
+		}
+		pes = append(pes, pe)
+	}
+
+	return pes, total, nil
+}
+
+// catalogueConditions returns the conditions of the filter on the events and
+// on the columns the catalogue query derives, with their arguments.
+func catalogueConditions(f *model.PublicEventFilter) (string, string, []interface{}) {
+	var inner, outer string
+	var args []interface{}
+
+	if f.From != nil {
+		inner += ` AND pe.date_time >= ?`
+		args = append(args, *f.From)
+	}
+
+	if f.To != nil {
+		inner += ` AND pe.date_time < ?`
+		args = append(args, *f.To)
+	}
+
+	if f.Search != "" {
+		like := "%" + escapeLike(f.Search) + "%"
+		inner += ` AND (pe.event_title LIKE ? OR pe.event_description LIKE ?)`
+		args = append(args, like, like)
+	}
+
+	if f.MinPrice != nil {
+		outer += ` AND e.from_price >= ?`
+		args = append(args, *f.MinPrice)
+	}
+
+	if f.MaxPrice != nil {
+		outer += ` AND e.from_price <= ?`
+		args = append(args, *f.MaxPrice)
+	}
+
+	if f.Available != nil {
+		if *f.Available {
+			outer += ` AND e.available_tickets + e.resale_tickets > 0`
+		} else {
+			outer += ` AND e.available_tickets + e.resale_tickets = 0`
+		}
+	}
+
+	return inner, outer, args
+}
+
+// escapeLike escapes the wildcards of LIKE in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type mysqlTiers struct {
+	db querier
+}
+
+const ticketTierQuery = `SELECT ticket_tier_id, public_event_id, tier_name, total_tickets, ticket_price, sale_start, sale_end,
+			per_user_limit FROM Ticket_Tier`
+
+func (s *mysqlTiers) Create(ctx context.Context, publicEventID int64, tts []model.TicketTier) error {
+	for i := range tts {
+		tt := &tts[i]
+		tt.PublicEventID = publicEventID
+		id, err := insertRow(ctx, s.db, `INSERT INTO Ticket_Tier (public_event_id, tier_name, total_tickets, ticket_price, sale_start, sale_end, per_user_limit)
+				VALUES (?, ?, ?, ?, ?, ?, ?);`, tt.PublicEventID, tt.TierName, tt.TotalTickets, tt.TicketPrice, tt.SaleStart, tt.SaleEnd, tt.PerUserLimit)
+		if err != nil {
+			return fmt.Errorf("tiers.Create: tier: %s: %w", stringValue(tt.TierName), err)
+		}
+		tt.TicketTierID = id
+	}
+
+	return nil
+}
+
+func (s *mysqlTiers) Replace(ctx context.Context, publicEventID int64, tts []model.TicketTier) error {
+	_, err := updateRows(ctx, s.db, `DELETE FROM Ticket_Tier WHERE public_event_id = ?;`, publicEventID)
+	if err != nil {
+		return fmt.Errorf("tiers.Replace: %w", err)
+	}
+
+	err = s.Create(ctx, publicEventID, tts)
+	if err != nil {
+		return fmt.Errorf("tiers.Replace: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlTiers) List(ctx context.Context, publicEventID int64) ([]model.TicketTier, error) {
+	tts, err := s.list(ctx, ticketTierQuery+` WHERE public_event_id = ? ORDER BY ticket_tier_id;`, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("tiers.List: %w", err)
+	}
+
+	return tts, nil
+}
+
+func (s *mysqlTiers) Availability(ctx context.Context, publicEventIDs []int64) (map[int64][]model.TicketTier, error) {
+	tiers := make(map[int64][]model.TicketTier)
+	if len(publicEventIDs) == 0 {
+		return tiers, nil
+	}
+
+	args := make([]interface{}, len(publicEventIDs))
+	for i := range publicEventIDs {
+		args[i] = publicEventIDs[i]
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT tt.ticket_tier_id, tt.public_event_id, tt.tier_name, tt.total_tickets, tt.ticket_price, tt.sale_start,
+			tt.sale_end, tt.per_user_limit, COUNT(et.event_ticket_id) FROM Ticket_Tier tt
+			LEFT JOIN Event_Tickets et ON et.ticket_tier_id = tt.ticket_tier_id
+				AND et.business_user_id = et.current_holder_id AND et.status = 'ACTIVE'
+			WHERE tt.public_event_id IN (?`+strings.Repeat(", ?", len(args)-1)+`)
+			GROUP BY tt.ticket_tier_id ORDER BY tt.ticket_tier_id;`)
+	if err != nil {
+		return nil, fmt.Errorf("tiers.Availability: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("tiers.Availability: error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tt model.TicketTier
+		err := rows.Scan(
+			&tt.TicketTierID,
+			&tt.PublicEventID,
+			&tt.TierName,
+			&tt.TotalTickets,
+			&tt.TicketPrice,
+			&tt.SaleStart,
+			&tt.SaleEnd,
+			&tt.PerUserLimit,
+			&tt.AvailableTickets,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("tiers.Availability: error while scanning row: %s", err)
+		}
+		tiers[tt.PublicEventID] = append(tiers[tt.PublicEventID], tt)
+	}
+
+	return tiers, nil
+}
+
+func (s *mysqlTiers) SetTotal(ctx context.Context, ticketTierID int64, totalTickets uint64) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Ticket_Tier SET total_tickets = ? WHERE ticket_tier_id = ?;`, totalTickets, ticketTierID)
+	if err != nil {
+		return fmt.Errorf("tiers.SetTotal: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlTiers) Lock(ctx context.Context, publicEventID, ticketTierID int64) (*model.TicketTier, bool, error) {
+	tts, err := s.list(ctx, ticketTierQuery+` WHERE ticket_tier_id = ? AND public_event_id = ? FOR UPDATE;`, ticketTierID, publicEventID)
+	if err != nil {
+		return nil, false, fmt.Errorf("tiers.Lock: %w", err)
+	}
+
+	if len(tts) == 0 {
+		return nil, false, nil
+	}
+
+	return &tts[0], true, nil
+}
+
+func (s *mysqlTiers) Purchases(ctx context.Context, ticketTierID, userID int64, wallet, except string, now time.Time) (uint64, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT
+			(SELECT COUNT(event_ticket_id) FROM Event_Tickets WHERE ticket_tier_id = ? AND current_holder_id = ? AND holder_wallet = ?),
+			(SELECT COUNT(tr.reservation_id) FROM Ticket_Reservation tr
+				INNER JOIN Event_Tickets et ON et.event_ticket_id = tr.event_ticket_id
+				WHERE et.ticket_tier_id = ? AND tr.user_id = ? AND tr.wallet = ? AND tr.status = ? AND tr.expires_at > ?
+				AND tr.reservation_id <> ? LOCK IN SHARE MODE);`)
+	if err != nil {
+		return 0, fmt.Errorf("tiers.Purchases: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	var held, reserved uint64
+	err = stmt.QueryRowContext(ctx, ticketTierID, userID, wallet, ticketTierID, userID, wallet, model.ReservationActive, now, except).Scan(&held, &reserved)
+	if err != nil {
+		return 0, fmt.Errorf("tiers.Purchases: error counting tickets: %w", err)
+	}
+
+	return held + reserved, nil
+}
+
+func (s *mysqlTiers) list(ctx context.Context, query string, args ...interface{}) ([]model.TicketTier, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var tts []model.TicketTier
+	for rows.Next() {
+		var tt model.TicketTier
+		err := rows.Scan(
+			&tt.TicketTierID,
+			&tt.PublicEventID,
+			&tt.TierName,
+			&tt.TotalTickets,
+			&tt.TicketPrice,
+			&tt.SaleStart,
+			&tt.SaleEnd,
+			&tt.PerUserLimit,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		tts = append(tts, tt)
+	}
+
+	return tts, nil
+}
+
+type mysqlResalePolicies struct {
+	db querier
+}
+
+func (s *mysqlResalePolicies) Get(ctx context.Context, publicEventID int64) (*model.ResalePolicy, bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT max_markup_bps, royalty_bps, platform_fee_bps, cutoff_minutes, max_transfers FROM Resale_Policy
+			WHERE public_event_id = ?;`)
+	if err != nil {
+		return nil, false, fmt.Errorf("resalePolicies.Get: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, publicEventID)
+	if err != nil {
+		return nil, false, fmt.Errorf("resalePolicies.Get: error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, false, nil
+	}
+
+	var p model.ResalePolicy
+	var maxMarkup, maxTransfers sql.NullInt64
+	err = rows.Scan(&maxMarkup, &p.RoyaltyBps, &p.PlatformFeeBps, &p.CutoffMinutes, &maxTransfers)
+	if err != nil {
+		return nil, false, fmt.Errorf("resalePolicies.Get: error while scanning row: %s", err)
+	}
+
+	if maxMarkup.Valid {
+		v := uint64(maxMarkup.Int64)
+		p.MaxMarkupBps = &v
+	}
+	if maxTransfers.Valid {
+		v := uint64(maxTransfers.Int64)
+		p.MaxTransfers = &v
+	}
+
+	return &p, true, nil
+}
+
+func (s *mysqlResalePolicies) Save(ctx context.Context, publicEventID int64, p *model.ResalePolicy) error {
+	_, err := updateRows(ctx, s.db, `INSERT INTO Resale_Policy (public_event_id, max_markup_bps, royalty_bps, platform_fee_bps, cutoff_minutes, max_transfers)
+				VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE max_markup_bps = VALUES(max_markup_bps), royalty_bps = VALUES(royalty_bps),
+				platform_fee_bps = VALUES(platform_fee_bps), cutoff_minutes = VALUES(cutoff_minutes), max_transfers = VALUES(max_transfers),
+				updated_date = CURRENT_TIMESTAMP;`, publicEventID, p.MaxMarkupBps, p.RoyaltyBps, p.PlatformFeeBps, p.CutoffMinutes, p.MaxTransfers)
+	if err != nil {
+		return fmt.Errorf("resalePolicies.Save: %w", err)
+	}
+
+	return nil
+}
+
+type mysqlVenues struct {
+	db querier
+}
+
+func (s *mysqlVenues) Create(ctx context.Context, name string, seats []model.Seat) (int64, error) {
+	venueID, err := insertRow(ctx, s.db, `INSERT INTO Venue (venue_name) VALUES (?);`, name)
+	if err != nil {
+		return 0, fmt.Errorf("venues.Create: %w", err)
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO Venue_Seat (venue_id, section_name, row_label, seat_number, tier_name) VALUES (?, ?, ?, ?, ?);`)
+	if err != nil {
+		return 0, fmt.Errorf("venues.Create: error preparing seat query: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, seat := range seats {
+		_, err := stmt.ExecContext(ctx, venueID, seat.Section, seat.Row, seat.Number, seat.Tier)
+		if err != nil {
+			return 0, fmt.Errorf("venues.Create: unable to insert seat: %s: %s", seat.Label(), err)
+		}
+	}
+
+	return venueID, nil
+}
+
+func (s *mysqlVenues) Seats(ctx context.Context, venueID int64) ([]model.Seat, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT seat_id, venue_id, section_name, row_label, seat_number, IFNULL(tier_name, '') FROM Venue_Seat
+			WHERE venue_id = ? ORDER BY seat_id;`)
+	if err != nil {
+		return nil, fmt.Errorf("venues.Seats: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, venueID)
+	if err != nil {
+		return nil, fmt.Errorf("venues.Seats: error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var seats []model.Seat
+	for rows.Next() {
+		var seat model.Seat
+		err := rows.Scan(&seat.SeatID, &seat.VenueID, &seat.Section, &seat.Row, &seat.Number, &seat.Tier)
+		if err != nil {
+			return nil, fmt.Errorf("venues.Seats: error while scanning row: %s", err)
+		}
+		seats = append(seats, seat)
+	}
+
+	return seats, nil
+}
+
+type mysqlTickets struct {
+	db querier
+}
+
+const eventTicketQuery = `SELECT event_ticket_id, business_user_id, public_event_id, asset_id, current_holder_id, status,
+				available_to_resell, price, IFNULL(ticket_tier_id, 0), IFNULL(seat_id, 0), holder_wallet, IFNULL(holder_marketplace_id, 0),
+				transfer_count FROM Event_Tickets`
+
+func (s *mysqlTickets) Get(ctx context.Context, eventTicketID int64) (*model.EventTicket, bool, error) {
+	ets, err := s.list(ctx, eventTicketQuery+` WHERE event_ticket_id = ?`, eventTicketID)
+	if err != nil {
+		return nil, false, fmt.Errorf("tickets.Get: %w", err)
+	}
+
+	if len(ets) == 0 {
+		return nil, false, nil
+	}
+
+	return &ets[0], true, nil
+}
+
+func (s *mysqlTickets) ListByEvent(ctx context.Context, publicEventID int64) ([]model.EventTicket, error) {
+	ets, err := s.list(ctx, eventTicketQuery+` WHERE public_event_id = ?`, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("tickets.ListByEvent: %w", err)
+	}
+
+	return ets, nil
+}
+
+func (s *mysqlTickets) ListByHolder(ctx context.Context, userID int64) ([]model.EventTicket, error) {
+	ets, err := s.list(ctx, eventTicketQuery+` WHERE current_holder_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tickets.ListByHolder: %w", err)
+	}
+
+	return ets, nil
+}
+
+func (s *mysqlTickets) ListByWallet(ctx context.Context, wallet string, userID int64) ([]model.EventTicket, error) {
+	ets, err := s.list(ctx, eventTicketQuery+` WHERE holder_wallet = ? OR (? > 0 AND holder_wallet = '' AND current_holder_id = ?) ORDER BY event_ticket_id`, wallet, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("tickets.ListByWallet: %w", err)
+	}
+
+	return ets, nil
+}
+
+func (s *mysqlTickets) list(ctx context.Context, query string, args ...interface{}) ([]model.EventTicket, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var ets []model.EventTicket
+	for rows.Next() {
+		var et model.EventTicket
+		err := rows.Scan(
+			&et.EventTicketID,
+			&et.BusinessUserID,
+			&et.PublicEventID,
+			&et.AssetID,
+			&et.CurrentHolderID,
+			&et.Status,
+			&et.AvailableToResell,
+			&et.Price,
+			&et.TicketTierID,
+			&et.SeatID,
+			&et.HolderWallet,
+			&et.HolderMarketplaceID,
+			&et.TransferCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		ets = append(ets, et)
+	}
+
+	return ets, nil
+}
+
+func (s *mysqlTickets) ListResale(ctx context.Context, publicEventIDs []int64) ([]model.EventTicket, error) {
+	if len(publicEventIDs) == 0 {
+		return nil, nil
+	}
+
+	args := []interface{}{model.TicketResale}
+	for _, id := range publicEventIDs {
+		args = append(args, id)
+	}
+
+	ets, err := s.list(ctx, eventTicketQuery+` WHERE status = ? AND public_event_id IN (?`+strings.Repeat(", ?", len(publicEventIDs)-1)+`)
+			ORDER BY public_event_id, price, event_ticket_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("tickets.ListResale: %w", err)
+	}
+
+	return ets, nil
+}
+
+func (s *mysqlTickets) Create(ctx context.Context, et *model.EventTicket) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Event_Tickets (business_user_id, public_event_id, asset_id, current_holder_id, status, price,
+			ticket_tier_id, seat_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		et.BusinessUserID, et.PublicEventID, et.AssetID, et.CurrentHolderID, et.Status, et.Price, et.TicketTierID, nullInt64(et.SeatID))
+	if err != nil {
+		return 0, fmt.Errorf("tickets.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+// available returns the condition on an active ticket of the event held by
+// the organizer, of the tier and the seat when they are set.
+func available(publicEventID, ticketTierID, seatID int64) (string, []interface{}) {
+	cond := ` WHERE business_user_id = current_holder_id AND status = ? AND public_event_id = ?`
+	args := []interface{}{model.TicketActive, publicEventID}
+	if ticketTierID > 0 {
+		cond += ` AND ticket_tier_id = ?`
+		args = append(args, ticketTierID)
+	}
+
+	if seatID > 0 {
+		cond += ` AND seat_id = ?`
+		args = append(args, seatID)
+	}
+
+	return cond, args
+}
+
+func (s *mysqlTickets) Available(ctx context.Context, publicEventID, ticketTierID, seatID int64) (*model.EventTicket, bool, error) {
+	cond, args := available(publicEventID, ticketTierID, seatID)
+	ets, err := s.list(ctx, eventTicketQuery+cond+` LIMIT 1`, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("tickets.Available: %w", err)
+	}
+
+	if len(ets) == 0 {
+		return nil, false, nil
+	}
+
+	return &ets[0], true, nil
+}
+
+func (s *mysqlTickets) Claim(ctx context.Context, publicEventID, ticketTierID, seatID int64, now time.Time) (*model.EventTicket, bool, error) {
+	cond, args := available(publicEventID, ticketTierID, seatID)
+	ets, err := s.list(ctx, eventTicketQuery+` et`+cond+` AND NOT EXISTS (SELECT 1 FROM Ticket_Reservation tr
+				WHERE tr.event_ticket_id = et.event_ticket_id AND tr.status = ? AND tr.expires_at > ?)
+			LIMIT 1 FOR UPDATE SKIP LOCKED`, append(args, model.ReservationActive, now)...)
+	if err != nil {
+		return nil, false, fmt.Errorf("tickets.Claim: %w", err)
+	}
+
+	if len(ets) == 0 {
+		return nil, false, nil
+	}
+
+	return &ets[0], true, nil
+}
+
+func (s *mysqlTickets) FaceValue(ctx context.Context, eventTicketID int64) (uint64, bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT IFNULL(tt.ticket_price, IFNULL(pe.ticket_price, 0)) FROM Event_Tickets et
+			INNER JOIN Public_Event pe ON pe.public_event_id = et.public_event_id
+			LEFT JOIN Ticket_Tier tt ON tt.ticket_tier_id = et.ticket_tier_id
+			WHERE et.event_ticket_id = ?;`)
+	if err != nil {
+		return 0, false, fmt.Errorf("tickets.FaceValue: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	var face uint64
+	err = stmt.QueryRowContext(ctx, eventTicketID).Scan(&face)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, fmt.Errorf("tickets.FaceValue: error executing query: %w", err)
+	}
+
+	return face, true, nil
+}
+
+func (s *mysqlTickets) PutOnResale(ctx context.Context, et *model.EventTicket, price uint64) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET price = ?, status = ?, available_to_resell = ?
+			WHERE event_ticket_id = ? AND current_holder_id = ? AND holder_wallet = ? AND status = ?;`,
+		price, model.TicketResale, true, et.EventTicketID, et.CurrentHolderID, et.HolderWallet, model.TicketActive)
+	if err != nil {
+		return false, fmt.Errorf("tickets.PutOnResale: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlTickets) SetResalePrice(ctx context.Context, eventTicketID int64, price uint64) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET price = ? WHERE event_ticket_id = ? AND status = ?;`, price, eventTicketID, model.TicketResale)
+	if err != nil {
+		return false, fmt.Errorf("tickets.SetResalePrice: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlTickets) TakeOffResale(ctx context.Context, eventTicketID int64) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET status = ?, available_to_resell = ? WHERE event_ticket_id = ? AND status = ?;`,
+		model.TicketActive, false, eventTicketID, model.TicketResale)
+	if err != nil {
+		return fmt.Errorf("tickets.TakeOffResale: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlTickets) SetOrganizerPrice(ctx context.Context, et *model.EventTicket, price uint64) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET price = ?
+			WHERE event_ticket_id = ? AND current_holder_id = ? AND holder_wallet = '' AND status = ?;`,
+		price, et.EventTicketID, et.BusinessUserID, model.TicketActive)
+	if err != nil {
+		return false, fmt.Errorf("tickets.SetOrganizerPrice: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlTickets) Redeem(ctx context.Context, eventTicketID int64) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET status = ? WHERE event_ticket_id = ? AND status IN (?, ?);`,
+		model.TicketRedeemed, eventTicketID, model.TicketActive, model.TicketResale)
+	if err != nil {
+		return false, fmt.Errorf("tickets.Redeem: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlTickets) Cancel(ctx context.Context, et *model.EventTicket) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET current_holder_id = ?, holder_wallet = '', holder_marketplace_id = NULL, status = ?,
+			available_to_resell = ? WHERE event_ticket_id = ?;`, et.BusinessUserID, model.TicketCancelled, false, et.EventTicketID)
+	if err != nil {
+		return fmt.Errorf("tickets.Cancel: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlTickets) Move(ctx context.Context, mv *model.TicketMove) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET current_holder_id = ?, holder_wallet = ?, holder_marketplace_id = ?, status = ?,
+			available_to_resell = ? WHERE event_ticket_id = ? AND current_holder_id = ? AND holder_wallet = ? AND status = ?;`,
+		mv.ToUserID, mv.ToWallet, nullInt64(mv.ToMarketplaceID), mv.NewStatus, false, mv.EventTicketID, mv.FromUserID, mv.FromWallet, mv.ExpectedStatus)
+	if err != nil {
+		return false, fmt.Errorf("tickets.Move: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlTickets) CountTransfer(ctx context.Context, eventTicketID int64) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Event_Tickets SET transfer_count = transfer_count + 1 WHERE event_ticket_id = ?;`, eventTicketID)
+	if err != nil {
+		return fmt.Errorf("tickets.CountTransfer: %w", err)
+	}
+
+	return nil
+}
+
+type mysqlReservations struct {
+	db querier
+}
+
+func (s *mysqlReservations) Create(ctx context.Context, r *model.Reservation) error {
+	_, err := insertRow(ctx, s.db, `INSERT INTO Ticket_Reservation (reservation_id, event_ticket_id, public_event_id, user_id, wallet, status, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?);`, r.ReservationID, r.EventTicketID, r.PublicEventID, r.UserID, r.Wallet, r.Status, r.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("reservations.Create: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlReservations) Get(ctx context.Context, reservationID string) (*model.Reservation, bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT reservation_id, event_ticket_id, public_event_id, user_id, wallet, status, expires_at
+			FROM Ticket_Reservation WHERE reservation_id = ?;`)
+	if err != nil {
+		return nil, false, fmt.Errorf("reservations.Get: error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	var r model.Reservation
+	err = stmt.QueryRowContext(ctx, reservationID).Scan(&r.ReservationID, &r.EventTicketID, &r.PublicEventID, &r.UserID, &r.Wallet, &r.Status, &r.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("reservations.Get: error executing query: %w", err)
+	}
+
+	return &r, true, nil
+}
+
+func (s *mysqlReservations) Release(ctx context.Context, reservationID string, userID int64, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET status = ?, updated_date = ? WHERE reservation_id = ? AND user_id = ? AND status = ?;`,
+		model.ReservationReleased, now, reservationID, userID, model.ReservationActive)
+	if err != nil {
+		return false, fmt.Errorf("reservations.Release: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlReservations) SetStatus(ctx context.Context, reservationID, from, to string, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET status = ?, updated_date = ? WHERE reservation_id = ? AND status = ?;`,
+		to, now, reservationID, from)
+	if err != nil {
+		return false, fmt.Errorf("reservations.SetStatus: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlReservations) Complete(ctx context.Context, reservationID string, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET status = ?, updated_date = ? WHERE reservation_id = ?;`,
+		model.ReservationCompleted, now, reservationID)
+	if err != nil {
+		return fmt.Errorf("reservations.Complete: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlReservations) Expire(ctx context.Context, now time.Time) (int64, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET status = ?, updated_date = ? WHERE status = ? AND expires_at <= ?;`,
+		model.ReservationExpired, now, model.ReservationActive, now)
+	if err != nil {
+		return 0, fmt.Errorf("reservations.Expire: %w", err)
+	}
+
+	return n, nil
+}
+
+func (s *mysqlReservations) Extend(ctx context.Context, reservationID string, until, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET expires_at = ?, updated_date = ?
+			WHERE reservation_id = ? AND status = ? AND expires_at > ?;`, until, now, reservationID, model.ReservationActive, now)
+	if err != nil {
+		return fmt.Errorf("reservations.Extend: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlReservations) Reassign(ctx context.Context, reservationID string, userID int64, wallet string, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Ticket_Reservation SET user_id = ?, wallet = ?, updated_date = ? WHERE reservation_id = ?;`,
+		userID, wallet, now, reservationID)
+	if err != nil {
+		return fmt.Errorf("reservations.Reassign: %w", err)
+	}
+
+	return nil
+}
+
+type mysqlRefunds struct {
+	db querier
+}
+
+func (s *mysqlRefunds) Create(ctx context.Context, r *model.TicketRefund) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Ticket_Refund (event_ticket_id, public_event_id, user_id, wallet, amount, status) VALUES (?, ?, ?, ?, ?, ?);`,
+		r.EventTicketID, r.PublicEventID, r.UserID, r.Wallet, r.Amount, r.Status)
+	if err != nil {
+		return 0, fmt.Errorf("refunds.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+type mysqlListings struct {
+	db querier
+}
+
+const resaleListingQuery = `SELECT listing_id, event_ticket_id, public_event_id, seller_user_id, seller_wallet, ask_price, status, expires_at,
+			created_date, updated_date FROM Resale_Listings`
+
+func (s *mysqlListings) Create(ctx context.Context, l *model.ResaleListing) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Resale_Listings (event_ticket_id, public_event_id, seller_user_id, seller_wallet, ask_price, status, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?);`, l.EventTicketID, l.PublicEventID, l.SellerUserID, l.SellerWallet, l.AskPrice, l.Status, l.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("listings.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlListings) Get(ctx context.Context, listingID int64) (*model.ResaleListing, bool, error) {
+	ls, err := s.list(ctx, resaleListingQuery+` WHERE listing_id = ?`, listingID)
+	if err != nil {
+		return nil, false, fmt.Errorf("listings.Get: %w", err)
+	}
+
+	if len(ls) == 0 {
+		return nil, false, nil
+	}
+
+	return &ls[0], true, nil
+}
+
+func (s *mysqlListings) Open(ctx context.Context, eventTicketID int64, now time.Time) (*model.ResaleListing, bool, error) {
+	ls, err := s.list(ctx, resaleListingQuery+` WHERE event_ticket_id = ? AND status = ? AND expires_at > ?`, eventTicketID, model.ListingOpen, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("listings.Open: %w", err)
+	}
+
+	if len(ls) == 0 {
+		return nil, false, nil
+	}
+
+	return &ls[0], true, nil
+}
+
+func (s *mysqlListings) Expired(ctx context.Context, now time.Time) ([]model.ResaleListing, error) {
+	ls, err := s.list(ctx, resaleListingQuery+` WHERE status = ? AND expires_at <= ? ORDER BY listing_id`, model.ListingOpen, now)
+	if err != nil {
+		return nil, fmt.Errorf("listings.Expired: %w", err)
+	}
+
+	return ls, nil
+}
+
+func (s *mysqlListings) Accepted(ctx context.Context, before time.Time) ([]model.ResaleListing, error) {
+	ls, err := s.list(ctx, resaleListingQuery+` WHERE status = ? AND updated_date <= ? ORDER BY listing_id`, model.ListingAccepted, before)
+	if err != nil {
+		return nil, fmt.Errorf("listings.Accepted: %w", err)
+	}
+
+	return ls, nil
+}
+
+func (s *mysqlListings) SetPrice(ctx context.Context, listingID int64, price uint64, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Resale_Listings SET ask_price = ?, updated_date = ? WHERE listing_id = ? AND status = ?;`,
+		price, now, listingID, model.ListingOpen)
+	if err != nil {
+		return false, fmt.Errorf("listings.SetPrice: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlListings) SetStatus(ctx context.Context, listingID int64, from, to string, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Resale_Listings SET status = ?, updated_date = ? WHERE listing_id = ? AND status = ?;`,
+		to, now, listingID, from)
+	if err != nil {
+		return false, fmt.Errorf("listings.SetStatus: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlListings) Close(ctx context.Context, eventTicketID int64, status string, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Resale_Listings SET status = ?, updated_date = ? WHERE event_ticket_id = ? AND status IN (?, ?);`,
+		status, now, eventTicketID, model.ListingOpen, model.ListingAccepted)
+	if err != nil {
+		return fmt.Errorf("listings.Close: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlListings) list(ctx context.Context, query string, args ...interface{}) ([]model.ResaleListing, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var ls []model.ResaleListing
+	for rows.Next() {
+		var l model.ResaleListing
+		err := rows.Scan(
+			&l.ListingID,
+			&l.EventTicketID,
+			&l.PublicEventID,
+			&l.SellerUserID,
+			&l.SellerWallet,
+			&l.AskPrice,
+			&l.Status,
+			&l.ExpiresAt,
+			&l.CreatedAt,
+			&l.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
+
+type mysqlOffers struct {
+	db querier
+}
+
+const resaleOfferQuery = `SELECT offer_id, listing_id, event_ticket_id, buyer_user_id, buyer_wallet, IFNULL(buyer_marketplace_id, 0),
+			IFNULL(buyer_user_marketplace_id, 0), amount, status, created_date, updated_date FROM Resale_Offers`
+
+func (s *mysqlOffers) Create(ctx context.Context, o *model.ResaleOffer) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Resale_Offers (listing_id, event_ticket_id, buyer_user_id, buyer_wallet, buyer_marketplace_id,
+			buyer_user_marketplace_id, amount, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		o.ListingID, o.EventTicketID, o.UserID, o.Wallet, nullInt64(o.MarketplaceID), nullInt64(o.UserMarketplaceID), o.Amount, o.Status)
+	if err != nil {
+		return 0, fmt.Errorf("offers.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlOffers) Get(ctx context.Context, offerID int64) (*model.ResaleOffer, bool, error) {
+	os, err := s.list(ctx, resaleOfferQuery+` WHERE offer_id = ?`, offerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("offers.Get: %w", err)
+	}
+
+	if len(os) == 0 {
+		return nil, false, nil
+	}
+
+	return &os[0], true, nil
+}
+
+func (s *mysqlOffers) Pending(ctx context.Context, listingID int64) ([]model.ResaleOffer, error) {
+	os, err := s.list(ctx, resaleOfferQuery+` WHERE listing_id = ? AND status = ? ORDER BY amount DESC, offer_id`, listingID, model.OfferPending)
+	if err != nil {
+		return nil, fmt.Errorf("offers.Pending: %w", err)
+	}
+
+	return os, nil
+}
+
+func (s *mysqlOffers) SetStatus(ctx context.Context, offerID int64, from, to string, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Resale_Offers SET status = ?, updated_date = ? WHERE offer_id = ? AND status = ?;`, to, now, offerID, from)
+	if err != nil {
+		return false, fmt.Errorf("offers.SetStatus: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlOffers) Reopen(ctx context.Context, listingID int64, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Resale_Offers SET status = ?, updated_date = ? WHERE listing_id = ? AND status = ?;`,
+		model.OfferPending, now, listingID, model.OfferAccepted)
+	if err != nil {
+		return fmt.Errorf("offers.Reopen: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlOffers) Expire(ctx context.Context, eventTicketID int64, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Resale_Offers SET status = ?, updated_date = ? WHERE event_ticket_id = ? AND status = ?;`,
+		model.OfferExpired, now, eventTicketID, model.OfferPending)
+	if err != nil {
+		return fmt.Errorf("offers.Expire: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlOffers) list(ctx context.Context, query string, args ...interface{}) ([]model.ResaleOffer, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var os []model.ResaleOffer
+	for rows.Next() {
+		var o model.ResaleOffer
+		err := rows.Scan(
+			&o.OfferID,
+			&o.ListingID,
+			&o.EventTicketID,
+			&o.UserID,
+			&o.Wallet,
+			&o.MarketplaceID,
+			&o.UserMarketplaceID,
+			&o.Amount,
+			&o.Status,
+			&o.CreatedAt,
+			&o.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		os = append(os, o)
+	}
+
+	return os, nil
+}
+
+type mysqlAuctions struct {
+	db querier
+}
+
+const auctionQuery = `SELECT a.auction_id, a.public_event_id, a.event_ticket_id, IFNULL(et.ticket_tier_id, 0), IFNULL(et.seat_id, 0),
+			a.reserve_price, a.min_increment, a.starts_at, a.ends_at, a.status, IFNULL(a.leading_bid_id, 0), IFNULL(a.leading_amount, 0),
+			a.reservation_id, a.created_date, a.updated_date FROM Auctions a
+			INNER JOIN Event_Tickets et ON et.event_ticket_id = a.event_ticket_id`
+
+const auctionBidQuery = `SELECT bid_id, auction_id, buyer_user_id, buyer_wallet, IFNULL(buyer_marketplace_id, 0),
+			IFNULL(buyer_user_marketplace_id, 0), amount, status, created_date FROM Auction_Bids`
+
+func (s *mysqlAuctions) Create(ctx context.Context, a *model.Auction) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Auctions (public_event_id, event_ticket_id, reservation_id, reserve_price, min_increment, starts_at, ends_at, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?);`, a.PublicEventID, a.EventTicketID, a.ReservationID, a.ReservePrice, a.MinIncrement, a.StartsAt, a.EndsAt, a.Status)
+	if err != nil {
+		return 0, fmt.Errorf("auctions.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlAuctions) Get(ctx context.Context, auctionID int64) (*model.Auction, bool, error) {
+	as, err := s.list(ctx, auctionQuery+` WHERE a.auction_id = ?`, auctionID)
+	if err != nil {
+		return nil, false, fmt.Errorf("auctions.Get: %w", err)
+	}
+
+	if len(as) == 0 {
+		return nil, false, nil
+	}
+
+	return &as[0], true, nil
+}
+
+// Lock locks the row with FOR UPDATE OF, which leaves the joined ticket
+// unlocked and needs MySQL 8 like the SKIP LOCKED of tickets.Claim.
+func (s *mysqlAuctions) Lock(ctx context.Context, auctionID int64) (*model.Auction, bool, error) {
+	as, err := s.list(ctx, auctionQuery+` WHERE a.auction_id = ? FOR UPDATE OF a`, auctionID)
+	if err != nil {
+		return nil, false, fmt.Errorf("auctions.Lock: %w", err)
+	}
+
+	if len(as) == 0 {
+		return nil, false, nil
+	}
+
+	return &as[0], true, nil
+}
+
+func (s *mysqlAuctions) ListByEvent(ctx context.Context, publicEventID int64) ([]model.Auction, error) {
+	as, err := s.list(ctx, auctionQuery+` WHERE a.public_event_id = ? ORDER BY a.auction_id DESC`, publicEventID)
+	if err != nil {
+		return nil, fmt.Errorf("auctions.ListByEvent: %w", err)
+	}
+
+	return as, nil
+}
+
+func (s *mysqlAuctions) Due(ctx context.Context, now time.Time) ([]model.Auction, error) {
+	as, err := s.list(ctx, auctionQuery+` WHERE (a.status = ? AND a.ends_at <= ?) OR a.status = ? ORDER BY a.auction_id`,
+		model.AuctionOpen, now, model.AuctionSettling)
+	if err != nil {
+		return nil, fmt.Errorf("auctions.Due: %w", err)
+	}
+
+	return as, nil
+}
+
+func (s *mysqlAuctions) SetStatus(ctx context.Context, auctionID int64, from, to string, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Auctions SET status = ?, updated_date = ? WHERE auction_id = ? AND status = ?;`, to, now, auctionID, from)
+	if err != nil {
+		return false, fmt.Errorf("auctions.SetStatus: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlAuctions) Lead(ctx context.Context, b *model.AuctionBid, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Auctions SET leading_bid_id = ?, leading_amount = ?, updated_date = ? WHERE auction_id = ?;`,
+		b.BidID, b.Amount, now, b.AuctionID)
+	if err != nil {
+		return fmt.Errorf("auctions.Lead: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlAuctions) CreateBid(ctx context.Context, b *model.AuctionBid) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Auction_Bids (auction_id, buyer_user_id, buyer_wallet, buyer_marketplace_id, buyer_user_marketplace_id, amount, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?);`, b.AuctionID, b.UserID, b.Wallet, nullInt64(b.MarketplaceID), nullInt64(b.UserMarketplaceID), b.Amount, b.Status)
+	if err != nil {
+		return 0, fmt.Errorf("auctions.CreateBid: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlAuctions) GetBid(ctx context.Context, bidID int64) (*model.AuctionBid, bool, error) {
+	bs, err := s.listBids(ctx, auctionBidQuery+` WHERE bid_id = ?`, bidID)
+	if err != nil {
+		return nil, false, fmt.Errorf("auctions.GetBid: %w", err)
+	}
+
+	if len(bs) == 0 {
+		return nil, false, nil
+	}
+
+	return &bs[0], true, nil
+}
+
+func (s *mysqlAuctions) Bids(ctx context.Context, auctionID int64) ([]model.AuctionBid, error) {
+	bs, err := s.listBids(ctx, auctionBidQuery+` WHERE auction_id = ? ORDER BY bid_id DESC`, auctionID)
+	if err != nil {
+		return nil, fmt.Errorf("auctions.Bids: %w", err)
+	}
+
+	return bs, nil
+}
+
+func (s *mysqlAuctions) SetBidStatus(ctx context.Context, bidID int64, status string, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Auction_Bids SET status = ?, updated_date = ? WHERE bid_id = ?;`, status, now, bidID)
+	if err != nil {
+		return fmt.Errorf("auctions.SetBidStatus: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlAuctions) SetBidsStatus(ctx context.Context, auctionID int64, status string, now time.Time) error {
+	_, err := updateRows(ctx, s.db, `UPDATE Auction_Bids SET status = ?, updated_date = ? WHERE auction_id = ?;`, status, now, auctionID)
+	if err != nil {
+		return fmt.Errorf("auctions.SetBidsStatus: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlAuctions) list(ctx context.Context, query string, args ...interface{}) ([]model.Auction, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var as []model.Auction
+	for rows.Next() {
+		var a model.Auction
+		err := rows.Scan(
+			&a.AuctionID,
+			&a.PublicEventID,
+			&a.EventTicketID,
+			&a.TicketTierID,
+			&a.SeatID,
+			&a.ReservePrice,
+			&a.MinIncrement,
+			&a.StartsAt,
+			&a.EndsAt,
+			&a.Status,
+			&a.LeadingBidID,
+			&a.LeadingAmount,
+			&a.ReservationID,
+			&a.CreatedAt,
+			&a.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		as = append(as, a)
+	}
+
+	return as, nil
+}
+
+func (s *mysqlAuctions) listBids(ctx context.Context, query string, args ...interface{}) ([]model.AuctionBid, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var bs []model.AuctionBid
+	for rows.Next() {
+		var b model.AuctionBid
+		err := rows.Scan(
+			&b.BidID,
+			&b.AuctionID,
+			&b.UserID,
+			&b.Wallet,
+			&b.MarketplaceID,
+			&b.UserMarketplaceID,
+			&b.Amount,
+			&b.Status,
+			&b.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+		bs = append(bs, b)
+	}
+
+	return bs, nil
+}
+
+type mysqlMoves struct {
+	db querier
+}
+
+const ticketMoveQuery = `SELECT move_id, kind, event_ticket_id, asset_id, from_user_id, to_user_id, from_wallet, to_wallet, IFNULL(from_marketplace_id, 0),
+			IFNULL(to_marketplace_id, 0), expected_status, new_status, reservation_id, amount, state, attempts, version, last_error, submitted_date,
+			updated_date FROM Ticket_Move`
+
+func (s *mysqlMoves) Create(ctx context.Context, mv *model.TicketMove) (int64, error) {
+	var reservationID, amount interface{}
+	if mv.ReservationID != nil {
+		reservationID = *mv.ReservationID
+	}
+	if mv.Amount != nil {
+		amount = *mv.Amount
+	}
+
+	id, err := insertRow(ctx, s.db, `INSERT INTO Ticket_Move (kind, event_ticket_id, asset_id, from_user_id, to_user_id, from_wallet, to_wallet,
+			from_marketplace_id, to_marketplace_id, expected_status, new_status, reservation_id, amount, state) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		mv.Kind, mv.EventTicketID, mv.AssetID, mv.FromUserID, mv.ToUserID, mv.FromWallet, mv.ToWallet, nullInt64(mv.FromMarketplaceID),
+		nullInt64(mv.ToMarketplaceID), mv.ExpectedStatus, mv.NewStatus, reservationID, amount, mv.State)
+	if err != nil {
+		return 0, fmt.Errorf("moves.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlMoves) SetState(ctx context.Context, mv *model.TicketMove, state string, lastError *string, submitted *time.Time, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Ticket_Move SET state = ?, last_error = ?, submitted_date = ?, updated_date = ?, version = version + 1
+			WHERE move_id = ? AND state = ? AND version = ?;`, state, lastError, submitted, now, mv.MoveID, mv.State, mv.Version)
+	if err != nil {
+		return false, fmt.Errorf("moves.SetState: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlMoves) Claim(ctx context.Context, mv *model.TicketMove, now time.Time) (bool, error) {
+	n, err := updateRows(ctx, s.db, `UPDATE Ticket_Move SET attempts = attempts + 1, version = version + 1, updated_date = ?
+			WHERE move_id = ? AND state = ? AND version = ?;`, now, mv.MoveID, mv.State, mv.Version)
+	if err != nil {
+		return false, fmt.Errorf("moves.Claim: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+func (s *mysqlMoves) Stale(ctx context.Context, before time.Time) ([]model.TicketMove, error) {
+	mvs, err := s.list(ctx, ticketMoveQuery+` WHERE state IN (?, ?, ?) AND updated_date <= ? ORDER BY move_id`,
+		model.MovePending, model.MoveChainSubmitted, model.MoveConfirmed, before)
+	if err != nil {
+		return nil, fmt.Errorf("moves.Stale: %w", err)
+	}
+
+	return mvs, nil
+}
+
+func (s *mysqlMoves) Latest(ctx context.Context, eventTicketID int64) (*model.TicketMove, bool, error) {
+	mv, ok, err := s.latest(ctx, ` WHERE event_ticket_id = ?`, eventTicketID)
+	if err != nil {
+		return nil, false, fmt.Errorf("moves.Latest: %w", err)
+	}
+
+	return mv, ok, nil
+}
+
+func (s *mysqlMoves) LatestByReservation(ctx context.Context, reservationID string) (*model.TicketMove, bool, error) {
+	mv, ok, err := s.latest(ctx, ` WHERE reservation_id = ?`, reservationID)
+	if err != nil {
+		return nil, false, fmt.Errorf("moves.LatestByReservation: %w", err)
+	}
+
+	return mv, ok, nil
+}
+
+func (s *mysqlMoves) LatestOfferSale(ctx context.Context, eventTicketID int64, since time.Time) (*model.TicketMove, bool, error) {
+	mv, ok, err := s.latest(ctx, ` WHERE event_ticket_id = ? AND kind = ? AND amount IS NOT NULL AND created_date >= ?`, eventTicketID, model.MoveBuyResell, since)
+	if err != nil {
+		return nil, false, fmt.Errorf("moves.LatestOfferSale: %w", err)
+	}
+
+	return mv, ok, nil
+}
+
+func (s *mysqlMoves) latest(ctx context.Context, cond string, args ...interface{}) (*model.TicketMove, bool, error) {
+	mvs, err := s.list(ctx, ticketMoveQuery+cond+` ORDER BY move_id DESC LIMIT 1`, args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(mvs) == 0 {
+		return nil, false, nil
+	}
+
+	return &mvs[0], true, nil
+}
+
+func (s *mysqlMoves) list(ctx context.Context, query string, args ...interface{}) ([]model.TicketMove, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rows.Close()
+
+	var mvs []model.TicketMove
+	for rows.Next() {
+		var mv model.TicketMove
+		var reservationID, lastError sql.NullString
+		var amount sql.NullInt64
+		err := rows.Scan(
+			&mv.MoveID,
+			&mv.Kind,
+			&mv.EventTicketID,
+			&mv.AssetID,
+			&mv.FromUserID,
+			&mv.ToUserID,
+			&mv.FromWallet,
+			&mv.ToWallet,
+			&mv.FromMarketplaceID,
+			&mv.ToMarketplaceID,
+			&mv.ExpectedStatus,
+			&mv.NewStatus,
+			&reservationID,
+			&amount,
+			&mv.State,
+			&mv.Attempts,
+			&mv.Version,
+			&lastError,
+			&mv.SubmittedDate,
+			&mv.UpdatedDate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+
+		if reservationID.Valid {
+			mv.ReservationID = &reservationID.String
+		}
+		if amount.Valid {
+			a := uint64(amount.Int64)
+			mv.Amount = &a
+		}
+		if lastError.Valid {
+			mv.LastError = &lastError.String
+		}
+		mvs = append(mvs, mv)
+	}
+
+	return mvs, nil
+}
+
+type mysqlPayouts struct {
+	db querier
+}
+
+func (s *mysqlPayouts) Create(ctx context.Context, p *model.ResalePayout) (int64, error) {
+	id, err := insertRow(ctx, s.db, `INSERT INTO Resale_Payout (move_id, event_ticket_id, public_event_id, seller_user_id, seller_wallet, organizer_user_id,
+			gross, royalty, platform_fee, seller_amount) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		p.MoveID, p.EventTicketID, p.PublicEventID, p.SellerUserID, p.SellerWallet, p.OrganizerUserID, p.Gross, p.Royalty, p.PlatformFee, p.SellerAmount)
+	if err != nil {
+		return 0, fmt.Errorf("payouts.Create: %w", err)
+	}
+
+	return id, nil
+}
+
+type mysqlMarketplaces struct {
+	db querier
+}
+
+const marketplaceQuery = `SELECT marketplace_id, marketplace_name, display_name, callback_url, allowed_origins, access_type, is_active, allow_body_key, public_key_id, public_key, otp_channels, webhook_events FROM Marketplace`
+
+func (s *mysqlMarketplaces) Create(ctx context.Context, m *model.Marketplace) (int64, error) {
+	origins, err := encodeOrigins(m.AllowedOrigins)
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.Create: %w", err)
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO Marketplace (marketplace_name, display_name, callback_url, allowed_origins, access_type, is_active, allow_body_key, otp_channels, webhook_events)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.Create: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, m.MarketPlaceName, m.DisplayName, m.CallbackURL, origins, m.AccessType, m.IsActive, m.AllowBodyKey != nil && *m.AllowBodyKey, encodeList(m.OTPChannels), encodeList(m.WebhookEvents))
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.Create: unable to insert marketplace: %s", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.Create: unable to get last insert id: %s", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlMarketplaces) Get(ctx context.Context, marketplaceID int64) (*model.Marketplace, bool, error) {
+	ms, err := s.list(ctx, marketplaceQuery+` WHERE marketplace_id = ?`, marketplaceID)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaces.Get: %w", err)
+	}
+
+	if len(ms) == 0 {
+		return nil, false, nil
+	}
+
+	return &ms[0], true, nil
+}
+
+func (s *mysqlMarketplaces) List(ctx context.Context) ([]model.Marketplace, error) {
+	ms, err := s.list(ctx, marketplaceQuery+` ORDER BY marketplace_id`)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaces.List: %w", err)
+	}
+
+	return ms, nil
+}
+
+func (s *mysqlMarketplaces) Update(ctx context.Context, m *model.Marketplace) error {
+	origins, err := encodeOrigins(m.AllowedOrigins)
+	if err != nil {
+		return fmt.Errorf("marketplaces.Update: %w", err)
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace SET marketplace_name = ?, display_name = ?, callback_url = ?, allowed_origins = ?,
+			access_type = ?, allow_body_key = ?, otp_channels = ?, webhook_events = ?, updated_date = CURRENT_TIMESTAMP WHERE marketplace_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("marketplaces.Update: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, m.MarketPlaceName, m.DisplayName, m.CallbackURL, origins, m.AccessType, m.AllowBodyKey != nil && *m.AllowBodyKey, encodeList(m.OTPChannels), encodeList(m.WebhookEvents), m.MarketPlaceID)
+	if err != nil {
+		return fmt.Errorf("marketplaces.Update: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaces) SetPublicKey(ctx context.Context, marketplaceID int64, keyID, publicKey string) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace SET public_key_id = ?, public_key = ?, updated_date = CURRENT_TIMESTAMP WHERE marketplace_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("marketplaces.SetPublicKey: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, keyID, publicKey, marketplaceID)
+	if err != nil {
+		return fmt.Errorf("marketplaces.SetPublicKey: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaces) WebhookSecret(ctx context.Context, marketplaceID int64) (string, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT webhook_secret FROM Marketplace WHERE marketplace_id = ?`)
+	if err != nil {
+		return "", fmt.Errorf("marketplaces.WebhookSecret: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	var secret sql.NullString
+	err = stmt.QueryRowContext(ctx, marketplaceID).Scan(&secret)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("marketplaces.WebhookSecret: error executing query: %s", err)
+	}
+
+	return secret.String, nil
+}
+
+func (s *mysqlMarketplaces) SetWebhookSecret(ctx context.Context, marketplaceID int64, secret string) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace SET webhook_secret = ?, updated_date = CURRENT_TIMESTAMP WHERE marketplace_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("marketplaces.SetWebhookSecret: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, secret, marketplaceID)
+	if err != nil {
+		return fmt.Errorf("marketplaces.SetWebhookSecret: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaces) Deactivate(ctx context.Context, marketplaceID int64) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace SET is_active = 0, updated_date = CURRENT_TIMESTAMP WHERE marketplace_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("marketplaces.Deactivate: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, marketplaceID)
+	if err != nil {
+		return fmt.Errorf("marketplaces.Deactivate: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaces) list(ctx context.Context, query string, args ...interface{}) ([]model.Marketplace, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var ms []model.Marketplace
+	for rows.Next() {
+		var m model.Marketplace
+		var origins, otpChannels, webhookEvents sql.NullString
+		var allowBodyKey bool
+		err := rows.Scan(
+			&m.MarketPlaceID,
+			&m.MarketPlaceName,
+			&m.DisplayName,
+			&m.CallbackURL,
+			&origins,
+			&m.AccessType,
+			&m.IsActive,
+			&allowBodyKey,
+			&m.PublicKeyID,
+			&m.PublicKey,
+			&otpChannels,
+			&webhookEvents,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+
+		m.AllowBodyKey = &allowBodyKey
+		if otpChannels.String != "" {
+			m.OTPChannels = strings.Split(otpChannels.String, ",")
+		}
+		if webhookEvents.String != "" {
+			m.WebhookEvents = strings.Split(webhookEvents.String, ",")
+		}
+
+		if origins.Valid && origins.String != "" {
+			err = json.Unmarshal([]byte(origins.String), &m.AllowedOrigins)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding allowed origins of marketplace: %d: %s", m.MarketPlaceID, err)
+			}
+		}
+		ms = append(ms, m)
+	}
+
+	return ms, nil
+}
+
+func (s *mysqlMarketplaces) GetUser(ctx context.Context, marketplaceID int64, phoneNumber string) (*model.MarketplaceUser, bool, error) {
+	query := `SELECT user_marketplace_id, marketplace_id, is_valid FROM User_Marketplace WHERE user_phone_number = ? and marketplace_id = ?`
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaces.GetUser: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, phoneNumber, marketplaceID)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaces.GetUser: error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var u model.MarketplaceUser
+	if rows.Next() {
+		err := rows.Scan(
+			&u.UserID,
+			&u.MarketPlaceID,
+			&u.IsValid,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("marketplaces.GetUser: error while scanning row: %s", err)
+		}
+		return &u, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *mysqlMarketplaces) GetUserByID(ctx context.Context, userMarketplaceID int64) (*model.MarketplaceUser, bool, error) {
+	query := `SELECT user_marketplace_id, marketplace_id, user_phone_number, is_valid FROM User_Marketplace WHERE user_marketplace_id = ?`
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaces.GetUserByID: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userMarketplaceID)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaces.GetUserByID: error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var u model.MarketplaceUser
+	if rows.Next() {
+		err := rows.Scan(
+			&u.UserID,
+			&u.MarketPlaceID,
+			&u.PhoneNumber,
+			&u.IsValid,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("marketplaces.GetUserByID: error while scanning row: %s", err)
+		}
+		return &u, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (s *mysqlMarketplaces) CreateUser(ctx context.Context, marketplaceID int64, phoneNumber string) (int64, error) {
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO User_Marketplace (marketplace_id, user_phone_number) VALUES (?, ?);`)
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.CreateUser: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, marketplaceID, phoneNumber)
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.CreateUser: unable to insert user: %s", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("marketplaces.CreateUser: unable to get last insert id: %s", err)
+	}
+
+	return id, nil
+}
+
+func (s *mysqlMarketplaces) ValidateUser(ctx context.Context, userMarketplaceID int64) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE User_Marketplace SET is_valid = ? WHERE user_marketplace_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("marketplaces.ValidateUser: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, true, userMarketplaceID)
+	if err != nil {
+		return fmt.Errorf("marketplaces.ValidateUser: unable to execute query: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("marketplaces.ValidateUser: unable to get rows affected: %s", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("marketplaces.ValidateUser: %d rows affected for user: %d", rowsAffected, userMarketplaceID)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaces) HasUsers(ctx context.Context, marketplaceID int64) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT EXISTS (SELECT 1 FROM User_Marketplace WHERE marketplace_id = ?);`)
+	if err != nil {
+		return false, fmt.Errorf("marketplaces.HasUsers: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	var exists bool
+	err = stmt.QueryRowContext(ctx, marketplaceID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("marketplaces.HasUsers: error querying users of marketplace: %d: %s", marketplaceID, err)
+	}
+
+	return exists, nil
+}
+
+type mysqlMarketplaceKeys struct {
+	db querier
+}
+
+const marketplaceKeyQuery = `SELECT key_id, marketplace_id, salt, secret_hash, signing_key, scopes, expires_date, revoked_date, created_date FROM Marketplace_Key`
+
+func (s *mysqlMarketplaceKeys) Create(ctx context.Context, k *model.MarketplaceKey) error {
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO Marketplace_Key (key_id, marketplace_id, salt, secret_hash, signing_key, scopes, expires_date) VALUES (?, ?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return fmt.Errorf("marketplaceKeys.Create: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, k.KeyID, k.MarketPlaceID, k.Salt, k.SecretHash, k.SigningKey, strings.Join(k.Scopes, ","), k.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("marketplaceKeys.Create: unable to insert key: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaceKeys) Get(ctx context.Context, keyID string) (*model.MarketplaceKey, bool, error) {
+	ks, err := s.list(ctx, marketplaceKeyQuery+` WHERE key_id = ?`, keyID)
+	if err != nil {
+		return nil, false, fmt.Errorf("marketplaceKeys.Get: %w", err)
+	}
+
+	if len(ks) == 0 {
+		return nil, false, nil
+	}
+
+	return &ks[0], true, nil
+}
+
+func (s *mysqlMarketplaceKeys) List(ctx context.Context, marketplaceID int64) ([]model.MarketplaceKey, error) {
+	ks, err := s.list(ctx, marketplaceKeyQuery+` WHERE marketplace_id = ? ORDER BY created_date, key_id`, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaceKeys.List: %w", err)
+	}
+
+	return ks, nil
+}
+
+func (s *mysqlMarketplaceKeys) ExpireOthers(ctx context.Context, marketplaceID int64, keyID string, before time.Time) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace_Key SET expires_date = ?
+			WHERE marketplace_id = ? AND key_id <> ? AND revoked_date IS NULL AND (expires_date IS NULL OR expires_date > ?);`)
+	if err != nil {
+		return fmt.Errorf("marketplaceKeys.ExpireOthers: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, before, marketplaceID, keyID, before)
+	if err != nil {
+		return fmt.Errorf("marketplaceKeys.ExpireOthers: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaceKeys) Revoke(ctx context.Context, marketplaceID int64, keyID string) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace_Key SET revoked_date = CURRENT_TIMESTAMP
+			WHERE marketplace_id = ? AND key_id = ? AND revoked_date IS NULL;`)
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.Revoke: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, marketplaceID, keyID)
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.Revoke: unable to execute query: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.Revoke: unable to get rows affected: %s", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (s *mysqlMarketplaceKeys) SetSigningKey(ctx context.Context, keyID, old, signingKey string) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Marketplace_Key SET signing_key = ? WHERE key_id = ? AND signing_key = ?;`)
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.SetSigningKey: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, signingKey, keyID, old)
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.SetSigningKey: unable to execute query: %s", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("marketplaceKeys.SetSigningKey: unable to get rows affected: %s", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (s *mysqlMarketplaceKeys) list(ctx context.Context, query string, args ...interface{}) ([]model.MarketplaceKey, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var ks []model.MarketplaceKey
+	for rows.Next() {
+		var k model.MarketplaceKey
+		var scopes string
+		var signingKey sql.NullString
+		err := rows.Scan(
+			&k.KeyID,
+			&k.MarketPlaceID,
+			&k.Salt,
+			&k.SecretHash,
+			&signingKey,
+			&scopes,
+			&k.ExpiresAt,
+			&k.RevokedAt,
+			&k.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+
+		if scopes != "" {
+			k.Scopes = strings.Split(scopes, ",")
+		}
+		k.SigningKey = signingKey.String
+		ks = append(ks, k)
+	}
+
+	return ks, nil
+}
+
+type mysqlOTPAudits struct {
+	db querier
+}
+
+func (s *mysqlOTPAudits) Record(ctx context.Context, a *model.OTPAudit) error {
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO OTP_Audit (marketplace_id, user_marketplace_id, phone_number, ip_address, event, channel)
+			VALUES (?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return fmt.Errorf("otpAudits.Record: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, a.MarketPlaceID, nullInt64(a.UserMarketplaceID), nullString(a.PhoneNumber), nullString(a.IPAddress), a.Event, nullString(a.Channel))
+	if err != nil {
+		return fmt.Errorf("otpAudits.Record: unable to insert audit: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlOTPAudits) ListByUser(ctx context.Context, marketplaceID, userMarketplaceID int64) ([]model.OTPAudit, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT otp_audit_id, marketplace_id, user_marketplace_id, phone_number, ip_address, event, channel, created_date
+			FROM OTP_Audit WHERE marketplace_id = ? AND user_marketplace_id = ? ORDER BY otp_audit_id`)
+	if err != nil {
+		return nil, fmt.Errorf("otpAudits.ListByUser: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, marketplaceID, userMarketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("otpAudits.ListByUser: error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var as []model.OTPAudit
+	for rows.Next() {
+		var a model.OTPAudit
+		var userMarketplaceID sql.NullInt64
+		var phoneNumber, ipAddress, channel sql.NullString
+		err := rows.Scan(&a.OTPAuditID, &a.MarketPlaceID, &userMarketplaceID, &phoneNumber, &ipAddress, &a.Event, &channel, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("otpAudits.ListByUser: error while scanning row: %s", err)
+		}
+
+		a.UserMarketplaceID = userMarketplaceID.Int64
+		a.PhoneNumber = phoneNumber.String
+		a.IPAddress = ipAddress.String
+		a.Channel = channel.String
+		as = append(as, a)
+	}
+
+	return as, nil
+}
+
+type mysqlMarketplaceMoves struct {
+	db querier
+}
+
+func (s *mysqlMarketplaceMoves) Record(ctx context.Context, mv *model.MarketplaceTicketMove) error {
+	_, err := insertRow(ctx, s.db, `INSERT INTO Marketplace_Ticket_Move (move_id, event_ticket_id, kind, from_marketplace_id, to_marketplace_id, from_wallet, to_wallet, amount)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?);`, mv.MoveID, mv.EventTicketID, mv.Kind, nullInt64(mv.FromMarketplaceID), nullInt64(mv.ToMarketplaceID),
+		mv.FromWallet, mv.ToWallet, nullInt64(int64(mv.Amount)))
+	if err != nil {
+		return fmt.Errorf("marketplaceMoves.Record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlMarketplaceMoves) ListByMarketplace(ctx context.Context, marketplaceID int64) ([]model.MarketplaceTicketMove, error) {
+	stmt, err := s.db.PrepareContext(ctx, `SELECT marketplace_move_id, move_id, event_ticket_id, kind, from_marketplace_id, to_marketplace_id,
+			from_wallet, to_wallet, amount, created_date FROM Marketplace_Ticket_Move
+			WHERE from_marketplace_id = ? OR to_marketplace_id = ? ORDER BY marketplace_move_id`)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaceMoves.ListByMarketplace: error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, marketplaceID, marketplaceID)
+	if err != nil {
+		return nil, fmt.Errorf("marketplaceMoves.ListByMarketplace: error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var mvs []model.MarketplaceTicketMove
+	for rows.Next() {
+		var mv model.MarketplaceTicketMove
+		var fromMarketplaceID, toMarketplaceID, amount sql.NullInt64
+		err := rows.Scan(&mv.MarketplaceMoveID, &mv.MoveID, &mv.EventTicketID, &mv.Kind, &fromMarketplaceID, &toMarketplaceID,
+			&mv.FromWallet, &mv.ToWallet, &amount, &mv.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("marketplaceMoves.ListByMarketplace: error while scanning row: %s", err)
+		}
+
+		mv.FromMarketplaceID = fromMarketplaceID.Int64
+		mv.ToMarketplaceID = toMarketplaceID.Int64
+		mv.Amount = uint64(amount.Int64)
+		mvs = append(mvs, mv)
+	}
+
+	return mvs, nil
+}
+
+type mysqlWebhooks struct {
+	db querier
+}
+
+const webhookDeliveryQuery = `SELECT delivery_id, marketplace_id, event_id, event_type, payload, status, attempts, next_attempt_date,
+			last_status_code, last_error, delivered_date, created_date FROM Webhook_Delivery`
+
+func (s *mysqlWebhooks) Enqueue(ctx context.Context, d *model.WebhookDelivery) error {
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO Webhook_Delivery (marketplace_id, event_id, event_type, payload, status, next_attempt_date)
+			VALUES (?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		return fmt.Errorf("webhooks.Enqueue: unable to prepare query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, d.MarketPlaceID, d.EventID, d.EventType, d.Payload, d.Status, d.NextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("webhooks.Enqueue: unable to insert delivery: %s", err)
+	}
+
+	d.DeliveryID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("webhooks.Enqueue: unable to get last insert id: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlWebhooks) Due(ctx context.Context, now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	ds, err := s.list(ctx, webhookDeliveryQuery+` WHERE status = ? AND next_attempt_date <= ? ORDER BY next_attempt_date LIMIT ?`, model.WebhookPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks.Due: %w", err)
+	}
+
+	return ds, nil
+}
+
+func (s *mysqlWebhooks) Claim(ctx context.Context, deliveryID int64, now, until time.Time) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Webhook_Delivery SET next_attempt_date = ?, updated_date = CURRENT_TIMESTAMP
+			WHERE delivery_id = ? AND status = ? AND next_attempt_date <= ?;`)
+	if err != nil {
+		return false, fmt.Errorf("webhooks.Claim: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, until, deliveryID, model.WebhookPending, now)
+	if err != nil {
+		return false, fmt.Errorf("webhooks.Claim: unable to execute query: %s", err)
+	}
+
+	updatedRows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("webhooks.Claim: unable to get rows affected: %s", err)
+	}
+
+	return updatedRows == 1, nil
+}
+
+func (s *mysqlWebhooks) Update(ctx context.Context, d *model.WebhookDelivery) error {
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE Webhook_Delivery SET status = ?, attempts = ?, next_attempt_date = ?, last_status_code = ?,
+			last_error = ?, delivered_date = ?, updated_date = CURRENT_TIMESTAMP WHERE delivery_id = ?;`)
+	if err != nil {
+		return fmt.Errorf("webhooks.Update: error preparing update query: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, d.Status, d.Attempts, d.NextAttemptAt, nullInt64(int64(d.LastStatusCode)), nullString(d.LastError), d.DeliveredAt, d.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("webhooks.Update: unable to execute query: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mysqlWebhooks) Get(ctx context.Context, marketplaceID, deliveryID int64) (*model.WebhookDelivery, bool, error) {
+	ds, err := s.list(ctx, webhookDeliveryQuery+` WHERE marketplace_id = ? AND delivery_id = ?`, marketplaceID, deliveryID)
+	if err != nil {
+		return nil, false, fmt.Errorf("webhooks.Get: %w", err)
+	}
+
+	if len(ds) == 0 {
+		return nil, false, nil
+	}
+
+	return &ds[0], true, nil
+}
+
+func (s *mysqlWebhooks) List(ctx context.Context, marketplaceID int64, status string, limit int) ([]model.WebhookDelivery, error) {
+	q := webhookDeliveryQuery + ` WHERE marketplace_id = ?`
+	args := []interface{}{marketplaceID}
+	if status != "" {
+		q += ` AND status = ?`
+		args = append(args, status)
+	}
+	args = append(args, limit)
+
+	ds, err := s.list(ctx, q+` ORDER BY delivery_id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks.List: %w", err)
+	}
+
+	return ds, nil
+}
+
+func (s *mysqlWebhooks) list(ctx context.Context, query string, args ...interface{}) ([]model.WebhookDelivery, error) {
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %s", err)
+	}
+	defer rows.Close()
+
+	var ds []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		var lastStatusCode sql.NullInt64
+		var lastError sql.NullString
+		err := rows.Scan(&d.DeliveryID, &d.MarketPlaceID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&lastStatusCode, &lastError, &d.DeliveredAt, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning row: %s", err)
+		}
+
+		d.LastStatusCode = int(lastStatusCode.Int64)
+		d.LastError = lastError.String
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+func nullInt64(i int64) interface{} {
+	if i == 0 {
+		return nil
+	}
+	return i
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// encodeList stores a list of names as a comma separated string.
+func encodeList(names []string) interface{} {
+	if len(names) == 0 {
+		return nil
+	}
+	return strings.Join(names, ",")
+}
+
+// encodeOrigins stores the allowed origins of a marketplace as a JSON array.
+func encodeOrigins(origins []string) (interface{}, error) {
+	if len(origins) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(origins)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding allowed origins: %s", err)
+	}
+	return string(b), nil
+}
+
+// insertRow runs the insert and returns the id of the inserted row.
+func insertRow(ctx context.Context, db querier, query string, args ...interface{}) (int64, error) {
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// updateRows runs the update or delete and returns the number of rows it
+// changed.
+func updateRows(ctx context.Context, db querier, query string, args ...interface{}) (int64, error) {
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error preparing query: %w", err)
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error executing query: %w", err)
+	}
+
+	return result.RowsAffected()
+}
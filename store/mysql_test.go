@@ -0,0 +1,20 @@
+package store
+
+import (
+	"eventers-marketplace-backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogueConditions(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	max := uint64(2000)
+	available := true
+
+	inner, outer, args := catalogueConditions(&model.PublicEventFilter{From: &from, Search: "50%_off", MaxPrice: &max, Available: &available})
+	assert.Equal(t, ` AND pe.date_time >= ? AND (pe.event_title LIKE ? OR pe.event_description LIKE ?)`, inner)
+	assert.Equal(t, ` AND e.from_price <= ? AND e.available_tickets + e.resale_tickets > 0`, outer)
+	assert.Equal(t, []interface{}{from, `%50\%\_off%`, `%50\%\_off%`, max}, args)
+}
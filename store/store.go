@@ -0,0 +1,405 @@
+// Package store holds the repositories the services read and write through. The
+// MySQL repositories back the running service and the in-memory ones let
+// services be exercised without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"eventers-marketplace-backend/model"
+	"time"
+)
+
+// ErrNotFound is returned by writes that matched no row.
+var ErrNotFound = errors.New("no record found")
+
+// Sign in providers of users.
+const (
+	ProviderApple    = "apple"
+	ProviderFacebook = "facebook"
+	ProviderGoogle   = "google"
+	ProviderPhone    = "phone"
+)
+
+// UserState is the registration of the users a lookup matches.
+type UserState int
+
+const (
+	// AnyUser matches users whatever their registration.
+	AnyUser UserState = iota
+	// RegisteredUser matches users that are registered and active.
+	RegisteredUser
+	// UnregisteredUser matches users that are neither registered nor active.
+	UnregisteredUser
+)
+
+// UserRepo reads and writes rows of the Users table. Users sign in with the
+// firebase identity of a provider and are matched on it, on the email of
+// another provider or on their phone number. Lookups on a nil value match no
+// user.
+type UserRepo interface {
+	Get(ctx context.Context, userID int64) (*model.User, bool, error)
+	IDByFirebaseID(ctx context.Context, firebaseID string) (int64, bool, error)
+	FindByFirebaseID(ctx context.Context, provider string, firebaseID *string, state UserState) (*model.User, bool, error)
+	// FindByEmail matches email against the email of the provider.
+	FindByEmail(ctx context.Context, provider string, email *string, state UserState) (*model.User, bool, error)
+	// FindByPhone returns a user other than except with the phone number, zero
+	// excepts no user.
+	FindByPhone(ctx context.Context, countryCode, number *string, state UserState, except int64) (*model.User, bool, error)
+	// FindPhoneOnly returns the unregistered user with the phone number that
+	// never signed in with facebook or google.
+	FindPhoneOnly(ctx context.Context, countryCode, number *string) (*model.User, bool, error)
+	// FindByActivePhone returns the active user with the full phone number.
+	FindByActivePhone(ctx context.Context, phoneNumber string) (*model.User, bool, error)
+	// Create inserts the user with the identity of its provider. Users of the
+	// phone provider are registered and active.
+	Create(ctx context.Context, u *model.User) (int64, error)
+	// SetIdentity sets the provider of u and its identity on the user as
+	// Create does. It returns ErrNotFound when no user was updated.
+	SetIdentity(ctx context.Context, userID int64, u *model.User) error
+	// SetPhone registers the user with the phone number. It returns
+	// ErrNotFound when no user was updated.
+	SetPhone(ctx context.Context, userID int64, countryCode, number, firebaseID *string, active bool) error
+	// SetProfile sets the profile fields of u that are not empty. It returns
+	// ErrNotFound when no user was updated.
+	SetProfile(ctx context.Context, u *model.User) error
+	// Merge sets the identity of the provider and the phone firebase id of user
+	// to to those of user from, when both have the same phone number, and
+	// deactivates from.
+	Merge(ctx context.Context, provider string, from, to int64) error
+	// Phone returns the phone country code and number of the user, empty when
+	// the user has none.
+	Phone(ctx context.Context, userID int64) (string, string, error)
+}
+
+// EventRepo reads and writes rows of the Public_Event table.
+type EventRepo interface {
+	Get(ctx context.Context, publicEventID int64) (*model.PublicEvent, bool, error)
+	List(ctx context.Context, status string) ([]model.PublicEvent, error)
+	// Create inserts the draft event of the organizer along with the temp
+	// account its tickets are minted by.
+	Create(ctx context.Context, pe *model.PublicEvent, tempAddress, tempPassphrase string) (int64, error)
+	// Update sets the details of pe that are set and moves the event to status
+	// to, as long as it is still in status from. It returns ErrNotFound when
+	// no event was updated.
+	Update(ctx context.Context, pe *model.PublicEvent, from, to string) error
+	// SetVenue seats the event at the venue while it is in status. It returns
+	// ErrNotFound when no event was updated.
+	SetVenue(ctx context.Context, publicEventID, venueID int64, totalTickets uint64, status string) error
+	// Delete removes the event while it is in status. It returns ErrNotFound
+	// when no event was deleted.
+	Delete(ctx context.Context, publicEventID int64, status string) error
+	// Catalogue returns a page of the published events along with the number
+	// of events matching the filter across pages.
+	Catalogue(ctx context.Context, q *CatalogueQuery) ([]model.PublicEvent, int64, error)
+}
+
+// Undated is the date events without one sort on in the catalogue, after
+// every dated event.
+var Undated = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// CatalogueQuery selects a page of Limit published events matching Filter.
+// Events are sorted on their date, or on their lowest ticket price when
+// ByPrice is set, and ties on their id. The page starts after the event After,
+// the last one of the previous page, when it is set. Events are returned with
+// FromPrice, their lowest ticket price, and AvailableTickets, the tickets the
+// organizer still holds.
+type CatalogueQuery struct {
+	Filter  *model.PublicEventFilter
+	ByPrice bool
+	Desc    bool
+	After   *model.PublicEvent
+	Limit   int
+}
+
+// TierRepo reads and writes rows of the Ticket_Tier table.
+type TierRepo interface {
+	// Create inserts the tiers of the event and sets their ids.
+	Create(ctx context.Context, publicEventID int64, tts []model.TicketTier) error
+	// Replace deletes the tiers of the event and creates tts in their place.
+	Replace(ctx context.Context, publicEventID int64, tts []model.TicketTier) error
+	List(ctx context.Context, publicEventID int64) ([]model.TicketTier, error)
+	// Availability returns the tiers of the events, by event, along with the
+	// number of tickets the organizer still holds in each.
+	Availability(ctx context.Context, publicEventIDs []int64) (map[int64][]model.TicketTier, error)
+	SetTotal(ctx context.Context, ticketTierID int64, totalTickets uint64) error
+	// Lock reads the tier of the event and locks it until the transaction
+	// ends, so that purchases of the tier are counted one after the other.
+	Lock(ctx context.Context, publicEventID, ticketTierID int64) (*model.TicketTier, bool, error)
+	// Purchases counts the tickets of the tier the user, or the marketplace
+	// wallet, holds and the ones it has reserved until after now, leaving out
+	// the reservation except. Reservations are read with a locking read, which
+	// sees the ones committed while waiting for Lock.
+	Purchases(ctx context.Context, ticketTierID, userID int64, wallet, except string, now time.Time) (uint64, error)
+}
+
+// ResalePolicyRepo reads and writes rows of the Resale_Policy table.
+type ResalePolicyRepo interface {
+	Get(ctx context.Context, publicEventID int64) (*model.ResalePolicy, bool, error)
+	// Save creates or replaces the resale policy of the event.
+	Save(ctx context.Context, publicEventID int64, p *model.ResalePolicy) error
+}
+
+// VenueRepo reads and writes rows of the Venue and Venue_Seat tables.
+type VenueRepo interface {
+	// Create inserts the venue with its seats.
+	Create(ctx context.Context, name string, seats []model.Seat) (int64, error)
+	Seats(ctx context.Context, venueID int64) ([]model.Seat, error)
+}
+
+// TicketRepo reads and writes rows of the Event_Tickets table. Tickets the
+// organizer holds have business_user_id as their holder and no wallet.
+type TicketRepo interface {
+	Get(ctx context.Context, eventTicketID int64) (*model.EventTicket, bool, error)
+	ListByEvent(ctx context.Context, publicEventID int64) ([]model.EventTicket, error)
+	ListByHolder(ctx context.Context, userID int64) ([]model.EventTicket, error)
+	// ListByWallet returns the tickets held by the marketplace wallet and, when
+	// userID is set, the ones held by the user the wallet is linked to.
+	ListByWallet(ctx context.Context, wallet string, userID int64) ([]model.EventTicket, error)
+	// ListResale returns the tickets of the events on resale, cheapest first.
+	ListResale(ctx context.Context, publicEventIDs []int64) ([]model.EventTicket, error)
+	Create(ctx context.Context, et *model.EventTicket) (int64, error)
+	// Available returns an active ticket of the event the organizer holds, of
+	// the tier and the seat when they are set.
+	Available(ctx context.Context, publicEventID, ticketTierID, seatID int64) (*model.EventTicket, bool, error)
+	// Claim returns a ticket like Available does that no reservation holds at
+	// now, and locks it until the transaction ends. Tickets locked by other
+	// transactions are skipped, so concurrent buyers never claim the same one.
+	Claim(ctx context.Context, publicEventID, ticketTierID, seatID int64, now time.Time) (*model.EventTicket, bool, error)
+	// FaceValue returns the price of the tier of the ticket, the ticket price
+	// of its event for tickets minted before tiers.
+	FaceValue(ctx context.Context, eventTicketID int64) (uint64, bool, error)
+	// PutOnResale puts the ticket on resale at price while it is active and
+	// still held by the holder and wallet of et.
+	PutOnResale(ctx context.Context, et *model.EventTicket, price uint64) (bool, error)
+	// SetResalePrice sets the price of the ticket while it is on resale.
+	SetResalePrice(ctx context.Context, eventTicketID int64, price uint64) (bool, error)
+	// TakeOffResale makes the ticket active again if it is still on resale.
+	TakeOffResale(ctx context.Context, eventTicketID int64) error
+	// SetOrganizerPrice sets the price of the ticket while it is active and
+	// held by the organizer of et.
+	SetOrganizerPrice(ctx context.Context, et *model.EventTicket, price uint64) (bool, error)
+	// Redeem redeems the ticket while it is active or on resale.
+	Redeem(ctx context.Context, eventTicketID int64) (bool, error)
+	// Cancel hands the ticket back to the organizer of et and cancels it.
+	Cancel(ctx context.Context, et *model.EventTicket) error
+	// Move hands the ticket over to the receiver of the move with its new
+	// status, as long as the sender still holds it with the expected status.
+	Move(ctx context.Context, mv *model.TicketMove) (bool, error)
+	// CountTransfer counts one more change of hands of the ticket.
+	CountTransfer(ctx context.Context, eventTicketID int64) error
+}
+
+// ReservationRepo reads and writes rows of the Ticket_Reservation table.
+type ReservationRepo interface {
+	// Create inserts the reservation under its id.
+	Create(ctx context.Context, r *model.Reservation) error
+	Get(ctx context.Context, reservationID string) (*model.Reservation, bool, error)
+	// Release releases the active reservation of the user.
+	Release(ctx context.Context, reservationID string, userID int64, now time.Time) (bool, error)
+	// SetStatus moves the reservation to status to while it is in status from.
+	SetStatus(ctx context.Context, reservationID, from, to string, now time.Time) (bool, error)
+	// Complete completes the reservation once its ticket is bought.
+	Complete(ctx context.Context, reservationID string, now time.Time) error
+	// Expire expires the active reservations that ran out at now and returns
+	// how many did.
+	Expire(ctx context.Context, now time.Time) (int64, error)
+	// Extend keeps the reservation until until while it is active and has not
+	// run out at now.
+	Extend(ctx context.Context, reservationID string, until, now time.Time) error
+	// Reassign hands the reservation over to the user or the marketplace
+	// wallet.
+	Reassign(ctx context.Context, reservationID string, userID int64, wallet string, now time.Time) error
+}
+
+// RefundRepo writes rows of the Ticket_Refund table.
+type RefundRepo interface {
+	Create(ctx context.Context, r *model.TicketRefund) (int64, error)
+}
+
+// ListingRepo reads and writes rows of the Resale_Listings table.
+type ListingRepo interface {
+	Create(ctx context.Context, l *model.ResaleListing) (int64, error)
+	Get(ctx context.Context, listingID int64) (*model.ResaleListing, bool, error)
+	// Open returns the open listing of the ticket that is still open at now.
+	Open(ctx context.Context, eventTicketID int64, now time.Time) (*model.ResaleListing, bool, error)
+	// Expired returns the open listings that ran out at now.
+	Expired(ctx context.Context, now time.Time) ([]model.ResaleListing, error)
+	// Accepted returns the accepted listings last updated at before or
+	// earlier.
+	Accepted(ctx context.Context, before time.Time) ([]model.ResaleListing, error)
+	// SetPrice sets the ask price of the listing while it is open.
+	SetPrice(ctx context.Context, listingID int64, price uint64, now time.Time) (bool, error)
+	// SetStatus moves the listing to status to while it is in status from.
+	SetStatus(ctx context.Context, listingID int64, from, to string, now time.Time) (bool, error)
+	// Close moves the open or accepted listing of the ticket, if any, to
+	// status.
+	Close(ctx context.Context, eventTicketID int64, status string, now time.Time) error
+}
+
+// OfferRepo reads and writes rows of the Resale_Offers table.
+type OfferRepo interface {
+	Create(ctx context.Context, o *model.ResaleOffer) (int64, error)
+	Get(ctx context.Context, offerID int64) (*model.ResaleOffer, bool, error)
+	// Pending returns the pending offers on the listing, highest first.
+	Pending(ctx context.Context, listingID int64) ([]model.ResaleOffer, error)
+	// SetStatus moves the offer to status to while it is in status from.
+	SetStatus(ctx context.Context, offerID int64, from, to string, now time.Time) (bool, error)
+	// Reopen makes the accepted offers on the listing pending again.
+	Reopen(ctx context.Context, listingID int64, now time.Time) error
+	// Expire expires the pending offers on the ticket.
+	Expire(ctx context.Context, eventTicketID int64, now time.Time) error
+}
+
+// AuctionRepo reads and writes rows of the Auctions and Auction_Bids tables.
+// Auctions are read with the tier and seat of their ticket.
+type AuctionRepo interface {
+	Create(ctx context.Context, a *model.Auction) (int64, error)
+	Get(ctx context.Context, auctionID int64) (*model.Auction, bool, error)
+	// Lock reads the auction and locks it until the transaction ends, so that
+	// bids on it are placed one after the other.
+	Lock(ctx context.Context, auctionID int64) (*model.Auction, bool, error)
+	// ListByEvent returns the auctions of the event, latest first.
+	ListByEvent(ctx context.Context, publicEventID int64) ([]model.Auction, error)
+	// Due returns the open auctions that ended at now and the settling ones.
+	Due(ctx context.Context, now time.Time) ([]model.Auction, error)
+	// SetStatus moves the auction to status to while it is in status from.
+	SetStatus(ctx context.Context, auctionID int64, from, to string, now time.Time) (bool, error)
+	// Lead makes the bid the leading bid of its auction.
+	Lead(ctx context.Context, b *model.AuctionBid, now time.Time) error
+	CreateBid(ctx context.Context, b *model.AuctionBid) (int64, error)
+	GetBid(ctx context.Context, bidID int64) (*model.AuctionBid, bool, error)
+	// Bids returns the bids on the auction, latest first.
+	Bids(ctx context.Context, auctionID int64) ([]model.AuctionBid, error)
+	SetBidStatus(ctx context.Context, bidID int64, status string, now time.Time) error
+	// SetBidsStatus sets every bid on the auction to status.
+	SetBidsStatus(ctx context.Context, auctionID int64, status string, now time.Time) error
+}
+
+// MoveRepo reads and writes rows of the Ticket_Move table. Writes to a move are
+// fenced by its version: they only apply to the state and version the move was
+// read in, and bump the version.
+type MoveRepo interface {
+	// Create inserts the move in its state.
+	Create(ctx context.Context, mv *model.TicketMove) (int64, error)
+	// SetState moves mv to state with lastError and the date it was submitted
+	// to the chain.
+	SetState(ctx context.Context, mv *model.TicketMove, state string, lastError *string, submitted *time.Time, now time.Time) (bool, error)
+	// Claim counts one more attempt at the move.
+	Claim(ctx context.Context, mv *model.TicketMove, now time.Time) (bool, error)
+	// Stale returns the unfinished moves last updated at before or earlier.
+	Stale(ctx context.Context, before time.Time) ([]model.TicketMove, error)
+	// Latest returns the latest move of the ticket.
+	Latest(ctx context.Context, eventTicketID int64) (*model.TicketMove, bool, error)
+	// LatestByReservation returns the latest move made through the
+	// reservation.
+	LatestByReservation(ctx context.Context, reservationID string) (*model.TicketMove, bool, error)
+	// LatestOfferSale returns the latest resale of the ticket to an offer
+	// created at since or later.
+	LatestOfferSale(ctx context.Context, eventTicketID int64, since time.Time) (*model.TicketMove, bool, error)
+}
+
+// PayoutRepo writes rows of the Resale_Payout table.
+type PayoutRepo interface {
+	Create(ctx context.Context, p *model.ResalePayout) (int64, error)
+}
+
+// MarketplaceRepo reads and writes rows of the Marketplace and User_Marketplace
+// tables.
+type MarketplaceRepo interface {
+	Create(ctx context.Context, m *model.Marketplace) (int64, error)
+	Get(ctx context.Context, marketplaceID int64) (*model.Marketplace, bool, error)
+	List(ctx context.Context) ([]model.Marketplace, error)
+	Update(ctx context.Context, m *model.Marketplace) error
+	Deactivate(ctx context.Context, marketplaceID int64) error
+	SetPublicKey(ctx context.Context, marketplaceID int64, keyID, publicKey string) error
+	// WebhookSecret returns the secret webhooks to the marketplace are signed
+	// with, empty when none was issued.
+	WebhookSecret(ctx context.Context, marketplaceID int64) (string, error)
+	SetWebhookSecret(ctx context.Context, marketplaceID int64, secret string) error
+	GetUser(ctx context.Context, marketplaceID int64, phoneNumber string) (*model.MarketplaceUser, bool, error)
+	// GetUserByID returns the user with PhoneNumber set to the full phone
+	// number it was created with.
+	GetUserByID(ctx context.Context, userMarketplaceID int64) (*model.MarketplaceUser, bool, error)
+	CreateUser(ctx context.Context, marketplaceID int64, phoneNumber string) (int64, error)
+	ValidateUser(ctx context.Context, userMarketplaceID int64) error
+	// HasUsers reports whether any user was created for the marketplace.
+	HasUsers(ctx context.Context, marketplaceID int64) (bool, error)
+}
+
+// MarketplaceKeyRepo reads and writes rows of the Marketplace_Key table.
+type MarketplaceKeyRepo interface {
+	Create(ctx context.Context, k *model.MarketplaceKey) error
+	Get(ctx context.Context, keyID string) (*model.MarketplaceKey, bool, error)
+	List(ctx context.Context, marketplaceID int64) ([]model.MarketplaceKey, error)
+	// ExpireOthers makes every unrevoked key of the marketplace but keyID
+	// expire at before at the latest.
+	ExpireOthers(ctx context.Context, marketplaceID int64, keyID string, before time.Time) error
+	Revoke(ctx context.Context, marketplaceID int64, keyID string) (bool, error)
+	// SetSigningKey replaces the stored signing key of the key as long as it
+	// still is old.
+	SetSigningKey(ctx context.Context, keyID, old, signingKey string) (bool, error)
+}
+
+// OTPAuditRepo writes and reads rows of the OTP_Audit table.
+type OTPAuditRepo interface {
+	Record(ctx context.Context, a *model.OTPAudit) error
+	ListByUser(ctx context.Context, marketplaceID, userMarketplaceID int64) ([]model.OTPAudit, error)
+}
+
+// MarketplaceMoveRepo reads and writes rows of the Marketplace_Ticket_Move
+// table. Rows are recorded by the event service as it applies ticket moves.
+type MarketplaceMoveRepo interface {
+	Record(ctx context.Context, mv *model.MarketplaceTicketMove) error
+	// ListByMarketplace returns the moves into and out of the marketplace.
+	ListByMarketplace(ctx context.Context, marketplaceID int64) ([]model.MarketplaceTicketMove, error)
+}
+
+// WebhookRepo writes and reads rows of the Webhook_Delivery table.
+type WebhookRepo interface {
+	Enqueue(ctx context.Context, d *model.WebhookDelivery) error
+	// Due returns PENDING deliveries whose next attempt is at now or before.
+	Due(ctx context.Context, now time.Time, limit int) ([]model.WebhookDelivery, error)
+	// Claim postpones a due delivery to until. Only one worker wins the claim
+	// as it moves the next attempt past now.
+	Claim(ctx context.Context, deliveryID int64, now, until time.Time) (bool, error)
+	Update(ctx context.Context, d *model.WebhookDelivery) error
+	Get(ctx context.Context, marketplaceID, deliveryID int64) (*model.WebhookDelivery, bool, error)
+	// List returns the latest deliveries to the marketplace, of any status
+	// when status is empty.
+	List(ctx context.Context, marketplaceID int64, status string, limit int) ([]model.WebhookDelivery, error)
+}
+
+// Store groups the repositories of the service.
+type Store struct {
+	Users            UserRepo
+	Events           EventRepo
+	Tiers            TierRepo
+	ResalePolicies   ResalePolicyRepo
+	Venues           VenueRepo
+	Tickets          TicketRepo
+	Reservations     ReservationRepo
+	Refunds          RefundRepo
+	Listings         ListingRepo
+	Offers           OfferRepo
+	Auctions         AuctionRepo
+	Moves            MoveRepo
+	Payouts          PayoutRepo
+	Marketplaces     MarketplaceRepo
+	MarketplaceKeys  MarketplaceKeyRepo
+	OTPAudits        OTPAuditRepo
+	MarketplaceMoves MarketplaceMoveRepo
+	Webhooks         WebhookRepo
+
+	tx func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// Tx runs fn with repositories that read and write in a single transaction,
+// which commits when fn returns nil and rolls back otherwise. Tx on the store
+// passed to fn runs in the same transaction.
+func (s *Store) Tx(ctx context.Context, fn func(tx *Store) error) error {
+	if s.tx == nil {
+		return fn(s)
+	}
+	return s.tx(ctx, fn)
+}
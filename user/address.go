@@ -37,7 +37,11 @@ func (u *User) userAddress(addressPath string) (*algorand.Account, bool, error)
 	path := fmt.Sprintf("%s/%s", u.Vault.UserPath, addressPath)
 	secret, err := u.Vault.Logical().Read(path)
 	if err != nil {
-		return nil, false, fmt.Errorf("userAddress: could not get account of user: %d", addressPath)
+		return nil, false, fmt.Errorf("userAddress: could not get account of user: %s", addressPath)
+	}
+
+	if secret == nil {
+		return nil, false, nil
 	}
 
 	accountAddress, accountAddressOK := secret.Data[constants.AccountAddress]
@@ -2,195 +2,191 @@ package user
 
 import (
 	"context"
-	"database/sql"
 	"eventers-marketplace-backend/codec"
+	"eventers-marketplace-backend/constants"
 	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/marketplace"
 	"eventers-marketplace-backend/model"
 	"eventers-marketplace-backend/response"
-	"eventers-marketplace-backend/twilio"
 	"fmt"
-	"strings"
-	"time"
-
-	"github.com/go-redis/redis"
-	"github.com/pquerna/otp/totp"
 )
 
 const (
-	interop    = "INTEROP"
-	noninterop = "NON_INTEROP"
-	otpMessage = "OTP to verify your number at eventers is: %s"
-	otp_sent   = "OTP_SUCCESSFULLY_SENT"
+	otp_sent = "OTP_SUCCESSFULLY_SENT"
 )
 
-func (u *User) CreateMarketPlaceUser(ctx context.Context, db *sql.DB, eu *model.MarketplaceUser, a *model.Auth, sender twilio.Sender, client *redis.Client, secret string) (*model.MarketplaceUser, *model.Auth, error) {
+func (u *User) CreateMarketPlaceUser(ctx context.Context, eu *model.MarketplaceUser, a *model.Auth, otp *OTP, ip string) (*model.MarketplaceUser, *model.Auth, error) {
 
-	m, ok, err := MarketPlaceExists(db, []interface{}{"access_key"}, []interface{}{a.AccessKey})
+	cred, err := u.authenticateMarketplace(ctx, a.AccessKey)
 	if err != nil {
-		return nil, nil, response.SomethingWrong()
+		return nil, nil, err
 	}
-	if !ok {
-		return nil, nil, response.Unauthorized()
+	m := cred.Marketplace
+
+	err = requirePublicKey(m)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	user, ok, err := MarketPlaceUserExists(db, []interface{}{"user_phone_number", "marketplace_id"}, []interface{}{formatPhoneNumber(eu), m.MarketPlaceID})
+	user, ok, err := u.Store.Marketplaces.GetUser(ctx, m.MarketPlaceID, formatPhoneNumber(eu))
 	if err != nil {
 		return nil, nil, response.SomethingWrong()
 	}
 
 	if !ok {
-
-		query := `INSERT INTO User_Marketplace (marketplace_id, user_phone_number) VALUES (?, ?);`
-
-		// Execute query
-		stmt, err := db.Prepare(query)
-		if err != nil {
-			return nil, nil, fmt.Errorf("CreateMarketPlaceUser: unable to prepare query: %s", err)
-		}
-		defer stmt.Close()
-
-		result, err := stmt.Exec(m.MarketPlaceID, formatPhoneNumber(eu))
-		if err != nil {
-			logger.Errorf(ctx, "CreateMarketPlaceUser: unable to get last insert id: %+v", err)
-			return nil, nil, response.SomethingWrong()
-		}
-
-		// Get user_id of the user
-		id, err := result.LastInsertId()
+		id, err := u.Store.Marketplaces.CreateUser(ctx, m.MarketPlaceID, formatPhoneNumber(eu))
 		if err != nil {
-			logger.Errorf(ctx, "CreateMarketPlaceUser: unable to get last insert id: %+v", err)
+			logger.Errorf(ctx, "CreateMarketPlaceUser: %+v", err)
 			return nil, nil, response.SomethingWrong()
 		}
 
 		eu.UserID = id
 
-		if sendOTP(sender, client, id, secret, formatPhoneNumber(eu)) != nil {
-			logger.Errorf(ctx, "CreateMarketPlaceUser: error sending otp: %+v", err)
-			return nil, nil, response.SomethingWrong()
+		verificationID, err := sendOTP(ctx, otp, m, eu, ip)
+		if err != nil {
+			return nil, nil, err
 		}
-		return eu, &model.Auth{Status: otp_sent}, nil
+		return eu, &model.Auth{Status: otp_sent, VerificationID: verificationID}, nil
 	}
 
 	eu.UserID = user.UserID
-	if user.IsValid && m.AccessType == interop {
+	if user.IsValid && m.AccessType == constants.Interop {
 		return eu, nil, nil
 	}
 
-	if sendOTP(sender, client, user.UserID, secret, formatPhoneNumber(eu)) != nil {
-		logger.Errorf(ctx, "CreateMarketPlaceUser: error sending otp: %+v", err)
-		return nil, nil, response.SomethingWrong()
+	verificationID, err := sendOTP(ctx, otp, m, eu, ip)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return eu, &model.Auth{Status: otp_sent}, nil
+	return eu, &model.Auth{Status: otp_sent, VerificationID: verificationID}, nil
 }
 
-func sendOTP(sender twilio.Sender, client *redis.Client, userMarketplaceID int64, secret, phoneNumber string) error {
-	otp, err := totp.GenerateCode(secret, time.Now().UTC())
+func sendOTP(ctx context.Context, otp *OTP, m *model.Marketplace, eu *model.MarketplaceUser, ip string) (string, error) {
+	verificationID, err := otp.Send(ctx, m, eu, ip)
 	if err != nil {
-		return fmt.Errorf("sendOTP: unable to generate otp: %s", err)
+		if _, ok := err.(response.ErrorResponse); ok {
+			return "", err
+		}
+		logger.Errorf(ctx, "CreateMarketPlaceUser: error sending otp: %+v", err)
+		return "", response.SomethingWrong()
 	}
 
-	sid, err := sender.Send(phoneNumber, fmt.Sprintf(otpMessage, otp))
+	return verificationID, nil
+}
+
+// VerifyMarketPlaceUserOTP checks the OTP sent for the verification id that
+// connect returned. The user is the one the verification was issued for, never
+// one named by the client.
+func (u *User) VerifyMarketPlaceUserOTP(ctx context.Context, otp *OTP, auth model.Auth, ip string) (*model.MarketplaceUser, error) {
+	cred, err := u.authenticateMarketplace(ctx, auth.AccessKey)
 	if err != nil {
-		return fmt.Errorf("sendOTP: unable to send otp to: %s: %s", phoneNumber, err)
+		return nil, err
 	}
+	m := cred.Marketplace
 
-	err = client.Set(fmt.Sprintf("marketplace-%d", userMarketplaceID), otp, time.Minute*5).Err()
+	err = requirePublicKey(m)
 	if err != nil {
-		return fmt.Errorf("sendOTP: unable to save otp into the db for mobile: %s, sid: %v : %s", phoneNumber, sid, err)
+		return nil, err
 	}
 
-	return nil
-}
+	if auth.VerificationID == "" || auth.OTP == "" {
+		return nil, response.InvalidData("verifyMarketPlaceUserOTP: verification_id and otp are required")
+	}
 
-func (u *User) VerifyMarketPlaceUserOTP(ctx context.Context, db *sql.DB, client *redis.Client, eu *model.MarketplaceUser, auth model.Auth) (*model.MarketplaceUser, error) {
-	m, ok, err := MarketPlaceExists(db, []interface{}{"access_key"}, []interface{}{auth.AccessKey})
+	v, err := otp.Verify(ctx, m, auth.VerificationID, auth.OTP, ip)
 	if err != nil {
+		if _, ok := err.(response.ErrorResponse); ok {
+			return nil, err
+		}
+		logger.Errorf(ctx, "VerifyMarketPlaceUserOTP: %+v", err)
 		return nil, response.SomethingWrong()
 	}
-	if !ok {
-		return nil, response.Unauthorized()
+
+	eu := &model.MarketplaceUser{
+		MarketPlaceID:    m.MarketPlaceID,
+		PhoneCountryCode: v.PhoneCountryCode,
+		PhoneNumber:      v.PhoneNumber,
 	}
 
-	user, ok, err := MarketPlaceUserExists(db, []interface{}{"user_phone_number", "marketplace_id"}, []interface{}{formatPhoneNumber(eu), m.MarketPlaceID})
+	user, ok, err := u.Store.Marketplaces.GetUser(ctx, m.MarketPlaceID, formatPhoneNumber(eu))
 	if err != nil {
 		return nil, response.SomethingWrong()
 	}
 
-	if !ok {
+	if !ok || user.UserID != v.UserMarketplaceID {
 		return nil, response.UserNotExist()
 	}
+	eu.UserID = user.UserID
 
-	key := client.Get(fmt.Sprintf("marketplace-%d", eu.UserID))
-	if key.Err() != nil {
-		return nil, response.OTPExpired()
+	err = u.activateUser(ctx, cred, eu, user.IsValid)
+	if err != nil {
+		logger.Errorf(ctx, "verifyMarketPlaceUserOTP: %+v", err)
+		return nil, response.SomethingWrong()
 	}
 
-	if key.Val() != auth.OTP {
-		return nil, response.OTPMismatch()
-	}
+	return eu, nil
+}
 
-	path := fmt.Sprintf("%s", formatPhoneNumber(eu))
-	if m.AccessType == interop {
-		path = path + "/0"
-	} else {
-		path = fmt.Sprintf("%s/%v", path, m.MarketPlaceID)
-	}
+// activateUser gives the verified user of the marketplace a wallet, validates
+// the user and seals the wallet for non-interop marketplaces. Interop
+// marketplaces share the wallet of a phone, see marketplace.WalletPath, so a
+// user verifying with another of them keeps the account already stored there.
+func (u *User) activateUser(ctx context.Context, cred *marketplace.Credential, eu *model.MarketplaceUser, valid bool) error {
+	m := cred.Marketplace
+	path := marketplace.WalletPath(m, formatPhoneNumber(eu))
+
+	if !valid {
+		_, ok, err := u.userAddress(path)
+		if err != nil {
+			return fmt.Errorf("activateUser: %w", err)
+		}
 
-	if user.IsValid {
-		if m.AccessType == noninterop {
-			err = u.encryptKeys(eu, m, path)
+		if !ok {
+			err = saveAddress(ctx, u.Vault, u.Algo, path)
 			if err != nil {
-				logger.Errorf(ctx, "verifyMarketPlaceUserOTP: could no encrypt private key: %+v", err)
-				return nil, response.SomethingWrong()
+				return fmt.Errorf("activateUser: unable to save private key to vault: %w", err)
 			}
 		}
-		return eu, nil
-	}
 
-	err = saveAddress(ctx, u.Vault, u.Algo, path)
-	if err != nil {
-		logger.Errorf(ctx, "unable to save private key to vault: %+v", err)
-		return nil, response.SomethingWrong()
+		err = u.Store.Marketplaces.ValidateUser(ctx, eu.UserID)
+		if err != nil {
+			return fmt.Errorf("activateUser: %w", err)
+		}
 	}
 
-	query := `UPDATE User_Marketplace SET is_valid = ? WHERE user_marketplace_id = ?;`
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		logger.Errorf(ctx, "VerifyMarketPlaceUserOTP: error preparing update query: %+v", err)
-		return nil, response.SomethingWrong()
+	if m.AccessType == constants.NonInterop {
+		err := u.encryptKeys(eu, cred, path)
+		if err != nil {
+			return fmt.Errorf("activateUser: could no encrypt private key: %w", err)
+		}
 	}
-	defer stmt.Close()
 
-	result, err := stmt.Exec(true, eu.UserID)
-	if err != nil {
-		logger.Errorf(ctx, "VerifyMarketPlaceUserOTP: unable to execute query: %+v", err)
-		return nil, response.SomethingWrong()
-	}
+	return nil
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		logger.Errorf(ctx, "VerifyMarketPlaceUserOTP: unable to get rows affected: %s", err)
-		return nil, response.SomethingWrong()
+// requirePublicKey refuses a non-interop marketplace that has not registered
+// the public key its users' wallets are sealed to.
+func requirePublicKey(m *model.Marketplace) error {
+	if m.AccessType != constants.NonInterop {
+		return nil
 	}
 
-	if rowsAffected != 1 {
-		logger.Errorf(ctx, "update: %d rows affected: err: %+v", rowsAffected, NoRecordFound)
-		return nil, response.SomethingWrong()
-	}
-	if m.AccessType == noninterop {
-		err = u.encryptKeys(eu, m, path)
-		if err != nil {
-			logger.Errorf(ctx, "verifyMarketPlaceUserOTP: could no encrypt private key: %+v", err)
-			return nil, response.SomethingWrong()
-		}
+	if _, ok := marketplace.DecodePublicKey(m); !ok {
+		return response.InvalidStateTransition(fmt.Sprintf("requirePublicKey: marketplace: %d has no public key registered", m.MarketPlaceID))
 	}
-
-	return eu, nil
+	return nil
 }
 
-func (u *User) encryptKeys(eu *model.MarketplaceUser, m *model.Marketplace, path string) error {
+// encryptKeys returns the user's wallet to a non-interop marketplace. Wallet
+// material is sealed to the public key the marketplace registered, so that only
+// its backend can read it.
+func (u *User) encryptKeys(eu *model.MarketplaceUser, cred *marketplace.Credential, path string) error {
+	publicKey, ok := marketplace.DecodePublicKey(cred.Marketplace)
+	if !ok {
+		return fmt.Errorf("encryptKey: marketplace: %d has no public key registered", cred.Marketplace.MarketPlaceID)
+	}
+
 	account, ok, err := u.userAddress(path)
 	if err != nil {
 		return fmt.Errorf("encryptKey: error fetching user address: %w", err)
@@ -199,12 +195,17 @@ func (u *User) encryptKeys(eu *model.MarketplaceUser, m *model.Marketplace, path
 		return fmt.Errorf("encryptKey: user account does not exist on vault: %w", err)
 	}
 
-	encryptedAddress, err := codec.Encrypt([]byte(m.AccessKey), []byte( account.AccountAddress))
+	aad := marketplace.WalletAAD(cred.Marketplace.MarketPlaceID, eu.UserID)
+	encrypt := func(text []byte) (string, error) {
+		return codec.Seal(publicKey, *cred.Marketplace.PublicKeyID, text, aad)
+	}
+
+	encryptedAddress, err := encrypt([]byte(account.AccountAddress))
 	if err != nil {
 		return fmt.Errorf("encryptKey: could no encrypt account address: %w", err)
 	}
 
-	encryptedPassphrase, err := codec.Encrypt([]byte(m.AccessKey), []byte( account.SecurityPassphrase))
+	encryptedPassphrase, err := encrypt([]byte(account.SecurityPassphrase))
 	if err != nil {
 		return fmt.Errorf("encryptKey: could no encrypt passphrase: %w", err)
 	}
@@ -213,83 +214,18 @@ func (u *User) encryptKeys(eu *model.MarketplaceUser, m *model.Marketplace, path
 	return nil
 }
 
-func MarketPlaceExists(db *sql.DB, columns, values []interface{}) (*model.Marketplace, bool, error) {
-	var withPlaceholder []string
-	for _, col := range columns {
-		withPlaceholder = append(withPlaceholder, fmt.Sprintf("%s = ?", col))
-	}
-
-	query := fmt.Sprintf(
-		`SELECT marketplace_id, marketplace_name, access_key, access_type  FROM Marketplace WHERE %s`,
-		strings.Join(withPlaceholder, " and "),
-	)
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return nil, false, fmt.Errorf("MarketPlaceExists: error preparing query for %#v: %s", columns, err)
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(values...)
+// authenticateMarketplace verifies the access key of the calling marketplace.
+func (u *User) authenticateMarketplace(ctx context.Context, accessKey string) (*marketplace.Credential, error) {
+	cred, err := u.Marketplaces.Authorize(ctx, accessKey, marketplace.ScopeUsers)
 	if err != nil {
-		return nil, false, fmt.Errorf("MarketPlaceExists: error executing query for %#v: %s", columns, err)
-	}
-
-	var u model.Marketplace
-	if rows.Next() {
-		err := rows.Scan(
-			&u.MarketPlaceID,
-			&u.MarketPlaceName,
-			&u.AccessKey,
-			&u.AccessType,
-		)
-		if err != nil {
-			return nil, false, fmt.Errorf("MarketPlaceExists: error while scanning row: %s", err)
+		if _, ok := err.(response.ErrorResponse); ok {
+			return nil, err
 		}
-		return &u, true, nil
-	}
-	defer rows.Close()
-
-	return nil, false, nil
-}
-
-func MarketPlaceUserExists(db *sql.DB, columns, values []interface{}) (*model.MarketplaceUser, bool, error) {
-	var withPlaceholder []string
-	for _, col := range columns {
-		withPlaceholder = append(withPlaceholder, fmt.Sprintf("%s = ?", col))
-	}
-
-	query := fmt.Sprintf(
-		`SELECT user_marketplace_id, marketplace_id, is_valid FROM User_Marketplace WHERE %s`,
-		strings.Join(withPlaceholder, " and "),
-	)
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return nil, false, fmt.Errorf("MarketPlaceUserExists: error preparing query for %#v: %s", columns, err)
-	}
-	defer stmt.Close()
-
-	rows, err := stmt.Query(values...)
-	if err != nil {
-		return nil, false, fmt.Errorf("MarketPlaceUserExists: error executing query for %#v: %s", columns, err)
-	}
-
-	var u model.MarketplaceUser
-	if rows.Next() {
-		err := rows.Scan(
-			&u.UserID,
-			&u.MarketPlaceID,
-			&u.IsValid,
-		)
-		if err != nil {
-			return nil, false, fmt.Errorf("MarketPlaceUserExists: error while scanning row: %s", err)
-		}
-		return &u, true, nil
+		logger.Errorf(ctx, "authenticateMarketplace: %+v", err)
+		return nil, response.SomethingWrong()
 	}
-	defer rows.Close()
 
-	return nil, false, nil
+	return cred, nil
 }
 
 func formatPhoneNumber(u *model.MarketplaceUser) string {
@@ -0,0 +1,170 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/algorand"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/marketplace"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"eventers-marketplace-backend/vault"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUser(st *store.Store) *User {
+	return NewUser(nil, vault.Vault{}, st, marketplace.NewMarketplace(st, make([]byte, 32)))
+}
+
+func TestCreateMarketPlaceUserUnknownAccessKey(t *testing.T) {
+	u := newUser(store.NewMemory().Store())
+
+	_, _, err := u.CreateMarketPlaceUser(context.Background(), &model.MarketplaceUser{}, &model.Auth{AccessKey: "unknown"}, nil, "")
+	assert.Equal(t, response.Unauthorized(), err)
+}
+
+func TestCreateMarketPlaceUserValidInteropUser(t *testing.T) {
+	st := store.NewMemory().Store()
+	ctx := context.Background()
+
+	allowBodyKey := true
+	m, err := marketplace.NewMarketplace(st, make([]byte, 32)).Create(ctx, &model.Marketplace{MarketPlaceName: "partner", AccessType: constants.Interop, AllowBodyKey: &allowBodyKey})
+	require.Nil(t, err)
+
+	id, err := st.Marketplaces.CreateUser(ctx, m.MarketPlaceID, "+15550100")
+	require.Nil(t, err)
+	require.Nil(t, st.Marketplaces.ValidateUser(ctx, id))
+
+	u := newUser(st)
+	eu := &model.MarketplaceUser{PhoneCountryCode: "+1", PhoneNumber: "5550100"}
+
+	usr, auth, err := u.CreateMarketPlaceUser(ctx, eu, &model.Auth{AccessKey: m.Key.AccessKey}, nil, "")
+	require.Nil(t, err)
+	assert.Nil(t, auth)
+	assert.Equal(t, id, usr.UserID)
+}
+
+func TestVerifyMarketPlaceUserOTPRequiresVerificationID(t *testing.T) {
+	st := store.NewMemory().Store()
+	ctx := context.Background()
+
+	allowBodyKey := true
+	m, err := marketplace.NewMarketplace(st, make([]byte, 32)).Create(ctx, &model.Marketplace{MarketPlaceName: "partner", AllowBodyKey: &allowBodyKey})
+	require.Nil(t, err)
+
+	u := newUser(st)
+	_, err = u.VerifyMarketPlaceUserOTP(ctx, nil, model.Auth{AccessKey: m.Key.AccessKey, OTP: "123456", UserID: 1}, "")
+	assert.IsType(t, response.ErrorResponse{}, err)
+	assert.Equal(t, "INVALID_DATA", err.(response.ErrorResponse).Status)
+}
+
+func TestCreateMarketPlaceUserRequiresPublicKey(t *testing.T) {
+	st := store.NewMemory().Store()
+	ctx := context.Background()
+
+	allowBodyKey := true
+	m, err := marketplace.NewMarketplace(st, make([]byte, 32)).Create(ctx, &model.Marketplace{MarketPlaceName: "partner", AccessType: constants.NonInterop, AllowBodyKey: &allowBodyKey})
+	require.Nil(t, err)
+
+	u := newUser(st)
+	eu := &model.MarketplaceUser{PhoneCountryCode: "+1", PhoneNumber: "5550100"}
+
+	_, _, err = u.CreateMarketPlaceUser(ctx, eu, &model.Auth{AccessKey: m.Key.AccessKey}, nil, "")
+	assert.IsType(t, response.ErrorResponse{}, err)
+	assert.Equal(t, "INVALID_STATE_TRANSITION", err.(response.ErrorResponse).Status)
+}
+
+// fakeAlgo generates numbered accounts and funds them without a network.
+type fakeAlgo struct {
+	algorand.Algo
+	generated int
+}
+
+func (f *fakeAlgo) GenerateAccount() (*algorand.Account, error) {
+	f.generated++
+	return &algorand.Account{
+		AccountAddress:     fmt.Sprintf("ADDRESS%d", f.generated),
+		PrivateKey:         fmt.Sprintf("key%d", f.generated),
+		SecurityPassphrase: fmt.Sprintf("passphrase%d", f.generated),
+	}, nil
+}
+
+func (f *fakeAlgo) Send(context.Context, *algorand.Account, uint64) error {
+	return nil
+}
+
+// fakeVault serves the key/value secrets engine from memory.
+func fakeVault(t *testing.T) vault.Vault {
+	var mu sync.Mutex
+	secrets := make(map[string]map[string]interface{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := secrets[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors":[]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		default:
+			var data map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&data)
+			secrets[path] = data
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	require.Nil(t, err)
+	return vault.Vault{UserPath: "users", Client: client}
+}
+
+func TestInteropMarketplacesShareTheWallet(t *testing.T) {
+	st := store.NewMemory().Store()
+	ctx := context.Background()
+	algo := &fakeAlgo{}
+	u := NewUser(algo, fakeVault(t), st, marketplace.NewMarketplace(st, make([]byte, 32)))
+
+	var wallets []*algorand.Account
+	for _, name := range []string{"first", "second"} {
+		m, err := u.Marketplaces.Create(ctx, &model.Marketplace{MarketPlaceName: name, AccessType: constants.Interop})
+		require.Nil(t, err)
+
+		eu := &model.MarketplaceUser{MarketPlaceID: m.MarketPlaceID, PhoneCountryCode: "+1", PhoneNumber: "5550100"}
+		eu.UserID, err = st.Marketplaces.CreateUser(ctx, m.MarketPlaceID, formatPhoneNumber(eu))
+		require.Nil(t, err)
+
+		err = u.activateUser(ctx, &marketplace.Credential{Marketplace: m}, eu, false)
+		require.Nil(t, err)
+
+		usr, ok, err := st.Marketplaces.GetUser(ctx, m.MarketPlaceID, formatPhoneNumber(eu))
+		require.Nil(t, err)
+		require.True(t, ok)
+		assert.True(t, usr.IsValid)
+
+		a, ok, err := u.userAddress(marketplace.WalletPath(m, formatPhoneNumber(eu)))
+		require.Nil(t, err)
+		require.True(t, ok)
+		wallets = append(wallets, a)
+	}
+
+	assert.Equal(t, 1, algo.generated)
+	assert.Equal(t, wallets[0], wallets[1])
+}
@@ -0,0 +1,392 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"eventers-marketplace-backend/constants"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/notify"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// OTPPolicy bounds how OTPs are sent and guessed.
+type OTPPolicy struct {
+	// Length is the number of digits of a code and TTL how long it is valid.
+	Length int
+	TTL    time.Duration
+	// MaxAttempts wrong codes lock verification for Lockout.
+	MaxAttempts int
+	Lockout     time.Duration
+	// Cooldown is the wait before a code can be resent, doubling with every
+	// send within QuotaWindow up to MaxCooldown.
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+	// PhoneQuota, UserQuota and IPQuota cap the codes sent to a phone number
+	// across marketplaces, to a marketplace user and asked for from a client
+	// address of a marketplace within QuotaWindow. Zero disables the quota.
+	PhoneQuota  int
+	UserQuota   int
+	IPQuota     int
+	QuotaWindow time.Duration
+}
+
+// OTP sends one-time codes and verifies them. Codes are random per request and
+// only a salted hash of them is kept in Redis. Every code is issued for an
+// opaque verification id, which is all a client can verify a code against, so
+// that it cannot point the check at another user.
+type OTP struct {
+	client   *redis.Client
+	notifier *notify.Notifier
+	audits   store.OTPAuditRepo
+	policy   OTPPolicy
+}
+
+// Verification is the marketplace user a verification id was issued for.
+type Verification struct {
+	MarketPlaceID     int64  `json:"market_place_id"`
+	UserMarketplaceID int64  `json:"user_marketplace_id"`
+	PhoneCountryCode  string `json:"phone_country_code"`
+	PhoneNumber       string `json:"phone_number"`
+}
+
+// otpCode is the hashed code sent for a verification.
+type otpCode struct {
+	VerificationID string `json:"verification_id"`
+	Salt           string `json:"salt"`
+	Hash           string `json:"hash"`
+}
+
+const verificationIDBytes = 24
+
+type otpQuota struct {
+	key   string
+	quota int
+}
+
+// quotas returns the send quotas of the user. Partner backends ask for codes
+// on behalf of their users from a few addresses, so the address quota counts
+// the client address per marketplace.
+func (o *OTP) quotas(m *model.Marketplace, eu *model.MarketplaceUser, phoneNumber, ip string) []otpQuota {
+	quotas := []otpQuota{
+		{"marketplace-otp-quota-phone-" + phoneNumber, o.policy.PhoneQuota},
+		{otpKey("quota", eu.UserID), o.policy.UserQuota},
+	}
+	if ip != "" {
+		quotas = append(quotas, otpQuota{fmt.Sprintf("marketplace-otp-quota-ip-%d-%s", m.MarketPlaceID, ip), o.policy.IPQuota})
+	}
+
+	return quotas
+}
+
+// NewOTP returns the OTP service.
+func NewOTP(client *redis.Client, n *notify.Notifier, audits store.OTPAuditRepo, policy OTPPolicy) *OTP {
+	return &OTP{client: client, notifier: n, audits: audits, policy: policy}
+}
+
+// Send delivers a new code to the user over the channels the marketplace
+// prefers and returns the verification id it is checked against. A new code
+// supersedes the verification of the previous one. Sending is refused while
+// verification is locked, during the resend cooldown and once a quota is used
+// up.
+func (o *OTP) Send(ctx context.Context, m *model.Marketplace, eu *model.MarketplaceUser, ip string) (string, error) {
+	phoneNumber := formatPhoneNumber(eu)
+	audit := &model.OTPAudit{MarketPlaceID: m.MarketPlaceID, UserMarketplaceID: eu.UserID, PhoneNumber: phoneNumber, IPAddress: ip}
+
+	locked, err := o.client.Exists(otpKey("lock", eu.UserID)).Result()
+	if err != nil {
+		return "", fmt.Errorf("send: error reading lock: %w", err)
+	}
+	if locked > 0 {
+		o.record(ctx, audit, constants.OTPLocked)
+		return "", response.OTPLocked()
+	}
+
+	wait, err := o.client.TTL(otpKey("cooldown", eu.UserID)).Result()
+	if err != nil {
+		return "", fmt.Errorf("send: error reading cooldown: %w", err)
+	}
+	if wait > 0 {
+		o.record(ctx, audit, constants.OTPCooldown)
+		return "", response.TooManyRequests(fmt.Sprintf("send: otp can be resent in %s", wait.Round(time.Second)))
+	}
+
+	for _, q := range o.quotas(m, eu, phoneNumber, ip) {
+		if q.quota <= 0 {
+			continue
+		}
+
+		n, err := o.count(q.key, o.policy.QuotaWindow)
+		if err != nil {
+			return "", fmt.Errorf("send: %w", err)
+		}
+		if n > int64(q.quota) {
+			o.record(ctx, audit, constants.OTPQuotaExceeded)
+			return "", response.TooManyRequests(fmt.Sprintf("send: %s used up", q.key))
+		}
+	}
+
+	code, err := generateCode(o.policy.Length)
+	if err != nil {
+		return "", fmt.Errorf("send: %w", err)
+	}
+
+	verificationID, err := randomHex(verificationIDBytes)
+	if err != nil {
+		return "", fmt.Errorf("send: %w", err)
+	}
+
+	salt, hash, err := hashCode(code)
+	if err != nil {
+		return "", fmt.Errorf("send: %w", err)
+	}
+
+	c, _ := json.Marshal(otpCode{VerificationID: verificationID, Salt: salt, Hash: hash})
+	v, _ := json.Marshal(Verification{
+		MarketPlaceID:     m.MarketPlaceID,
+		UserMarketplaceID: eu.UserID,
+		PhoneCountryCode:  eu.PhoneCountryCode,
+		PhoneNumber:       eu.PhoneNumber,
+	})
+
+	_, err = o.client.TxPipelined(func(p redis.Pipeliner) error {
+		p.Set(otpKey("code", eu.UserID), c, o.policy.TTL)
+		p.Set(verificationKey(verificationID), v, o.policy.TTL)
+		// Wrong attempts carry over to the new code until one is verified.
+		p.Expire(otpKey("attempts", eu.UserID), o.policy.TTL)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("send: error saving otp: %w", err)
+	}
+
+	// The code proves the user holds the phone, so it only goes to the phone.
+	to := notify.Recipient{Phone: phoneNumber, Locale: eu.Locale}
+	channel, _, err := o.notifier.Send(ctx, phoneChannels(m.OTPChannels), to, notify.TemplateOTP, struct{ Code string }{code})
+	if err != nil {
+		o.client.Del(otpKey("code", eu.UserID), verificationKey(verificationID))
+		o.record(ctx, audit, constants.OTPSendFailed)
+		return "", fmt.Errorf("send: unable to send otp to: %s: %w", phoneNumber, err)
+	}
+	audit.Channel = channel
+
+	sends, err := o.count(otpKey("sends", eu.UserID), o.policy.QuotaWindow)
+	if err != nil {
+		return "", fmt.Errorf("send: %w", err)
+	}
+
+	err = o.client.Set(otpKey("cooldown", eu.UserID), 1, cooldown(o.policy.Cooldown, o.policy.MaxCooldown, sends)).Err()
+	if err != nil {
+		return "", fmt.Errorf("send: error saving cooldown: %w", err)
+	}
+
+	o.record(ctx, audit, constants.OTPSent)
+	return verificationID, nil
+}
+
+// Verify checks code against the one sent for the verification of the
+// marketplace and returns who it was issued for. Unknown, superseded and other
+// marketplaces' verifications are expired. MaxAttempts wrong codes in a row
+// discard the code and lock verification of the user for Lockout.
+func (o *OTP) Verify(ctx context.Context, m *model.Marketplace, verificationID, code, ip string) (*Verification, error) {
+	audit := &model.OTPAudit{MarketPlaceID: m.MarketPlaceID, IPAddress: ip}
+
+	var v Verification
+	err := o.get(verificationKey(verificationID), &v)
+	if err == redis.Nil || (err == nil && v.MarketPlaceID != m.MarketPlaceID) {
+		o.record(ctx, audit, constants.OTPExpired)
+		return nil, response.OTPExpired()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify: error reading verification: %w", err)
+	}
+
+	userMarketplaceID := v.UserMarketplaceID
+	audit.UserMarketplaceID = userMarketplaceID
+	audit.PhoneNumber = v.PhoneCountryCode + v.PhoneNumber
+
+	locked, err := o.client.Exists(otpKey("lock", userMarketplaceID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("verify: error reading lock: %w", err)
+	}
+	if locked > 0 {
+		o.record(ctx, audit, constants.OTPLocked)
+		return nil, response.OTPLocked()
+	}
+
+	var c otpCode
+	err = o.get(otpKey("code", userMarketplaceID), &c)
+	if err == redis.Nil || (err == nil && c.VerificationID != verificationID) {
+		o.record(ctx, audit, constants.OTPExpired)
+		return nil, response.OTPExpired()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify: error reading otp: %w", err)
+	}
+
+	if !matchCode(c, code) {
+		attempts, err := o.count(otpKey("attempts", userMarketplaceID), o.policy.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("verify: %w", err)
+		}
+
+		if attempts < int64(o.policy.MaxAttempts) {
+			o.record(ctx, audit, constants.OTPMismatch)
+			return nil, response.OTPMismatch()
+		}
+
+		_, err = o.client.TxPipelined(func(p redis.Pipeliner) error {
+			p.Set(otpKey("lock", userMarketplaceID), 1, o.policy.Lockout)
+			p.Del(otpKey("code", userMarketplaceID), otpKey("attempts", userMarketplaceID), verificationKey(verificationID))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("verify: error locking otp: %w", err)
+		}
+
+		o.record(ctx, audit, constants.OTPLockedOut)
+		return nil, response.OTPLocked()
+	}
+
+	err = o.client.Del(
+		otpKey("code", userMarketplaceID),
+		otpKey("attempts", userMarketplaceID),
+		otpKey("sends", userMarketplaceID),
+		otpKey("cooldown", userMarketplaceID),
+		verificationKey(verificationID),
+	).Err()
+	if err != nil {
+		return nil, fmt.Errorf("verify: error clearing otp: %w", err)
+	}
+
+	o.record(ctx, audit, constants.OTPVerified)
+	return &v, nil
+}
+
+// phoneChannels returns the channels of order that deliver to the phone, all of
+// them when order has none.
+func phoneChannels(order []string) []string {
+	var channels []string
+	for _, c := range order {
+		for _, p := range notify.PhoneChannels {
+			if c == p {
+				channels = append(channels, c)
+			}
+		}
+	}
+
+	if len(channels) == 0 {
+		return notify.PhoneChannels
+	}
+	return channels
+}
+
+// count increments the counter at key, which expires window after it was
+// first incremented.
+func (o *OTP) count(key string, window time.Duration) (int64, error) {
+	n, err := o.client.Incr(key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("count: error incrementing %s: %w", key, err)
+	}
+
+	if n == 1 {
+		err = o.client.Expire(key, window).Err()
+		if err != nil {
+			return 0, fmt.Errorf("count: error expiring %s: %w", key, err)
+		}
+	}
+
+	return n, nil
+}
+
+// record writes the audit event. Failing to do so does not fail the request.
+func (o *OTP) record(ctx context.Context, a *model.OTPAudit, event string) {
+	a.Event = event
+	err := o.audits.Record(ctx, a)
+	if err != nil {
+		logger.Errorf(ctx, "otp: unable to record audit event: %s: %+v", event, err)
+	}
+}
+
+// get decodes the JSON value at key into v.
+func (o *OTP) get(key string, v interface{}) error {
+	b, err := o.client.Get(key).Bytes()
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("get: error decoding %s: %w", key, err)
+	}
+	return nil
+}
+
+func verificationKey(verificationID string) string {
+	return "marketplace-verification-" + verificationID
+}
+
+func otpKey(kind string, userMarketplaceID int64) string {
+	return fmt.Sprintf("marketplace-otp-%s-%d", kind, userMarketplaceID)
+}
+
+// cooldown doubles base for every send but the first, up to max.
+func cooldown(base, max time.Duration, sends int64) time.Duration {
+	d := base
+	for i := int64(1); i < sends && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		return max
+	}
+	return d
+}
+
+func generateCode(length int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", fmt.Errorf("generateCode: error reading random digit: %w", err)
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+	return b.String(), nil
+}
+
+// hashCode returns a random salt and the SHA-256 of salt and code.
+func hashCode(code string) (string, string, error) {
+	salt, err := randomHex(16)
+	if err != nil {
+		return "", "", fmt.Errorf("hashCode: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(salt + code))
+	return salt, hex.EncodeToString(sum[:]), nil
+}
+
+func matchCode(c otpCode, code string) bool {
+	sum := sha256.Sum256([]byte(c.Salt + code))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(c.Hash)) == 1
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("randomHex: error reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"eventers-marketplace-backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCooldownBacksOff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, cooldown(30*time.Second, 5*time.Minute, 1))
+	assert.Equal(t, 60*time.Second, cooldown(30*time.Second, 5*time.Minute, 2))
+	assert.Equal(t, 240*time.Second, cooldown(30*time.Second, 5*time.Minute, 4))
+	assert.Equal(t, 5*time.Minute, cooldown(30*time.Second, 5*time.Minute, 10))
+}
+
+func TestHashedCode(t *testing.T) {
+	code, err := generateCode(6)
+	require.Nil(t, err)
+	assert.Len(t, code, 6)
+
+	salt, hash, err := hashCode(code)
+	require.Nil(t, err)
+	c := otpCode{VerificationID: "v", Salt: salt, Hash: hash}
+	assert.NotContains(t, hash, code)
+	assert.True(t, matchCode(c, code))
+	assert.False(t, matchCode(c, code+"0"))
+	assert.False(t, matchCode(otpCode{}, code))
+}
+
+func TestOTPOnlyGoesToThePhone(t *testing.T) {
+	assert.Equal(t, []string{"whatsapp", "sms"}, phoneChannels([]string{"email", "whatsapp", "sms"}))
+	assert.Equal(t, []string{"sms", "whatsapp", "log"}, phoneChannels([]string{"email"}))
+	assert.Equal(t, []string{"sms", "whatsapp", "log"}, phoneChannels(nil))
+}
+
+func TestIPQuotaIsPerMarketplace(t *testing.T) {
+	o := NewOTP(nil, nil, nil, OTPPolicy{PhoneQuota: 10, UserQuota: 10, IPQuota: 50})
+	eu := &model.MarketplaceUser{UserID: 4}
+
+	keys := func(marketplaceID int64, ip string) map[string]int {
+		m := make(map[string]int)
+		for _, q := range o.quotas(&model.Marketplace{MarketPlaceID: marketplaceID}, eu, "+15550100", ip) {
+			m[q.key] = q.quota
+		}
+		return m
+	}
+
+	assert.Equal(t, 50, keys(1, "203.0.113.9")["marketplace-otp-quota-ip-1-203.0.113.9"])
+	assert.Equal(t, 50, keys(2, "203.0.113.9")["marketplace-otp-quota-ip-2-203.0.113.9"])
+	assert.NotContains(t, keys(2, "203.0.113.9"), "marketplace-otp-quota-ip-1-203.0.113.9")
+	assert.Len(t, keys(1, ""), 2)
+}
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"context"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCopiesIntoActiveUser(t *testing.T) {
+	mem := store.NewMemory()
+	u := newUser(mem.Store())
+	ctx := context.Background()
+
+	code, number := "+1", "5550100"
+	phoneID, fbID, newPhoneID := "phone-old", "fb-new", "phone-new"
+	provider := fb_provider
+	mem.PutUser(model.User{UserID: 1, PhoneCountryCode: &code, PhoneNumber: &number, PhoneFirebaseID: &phoneID, IsRegistered: true, IsActive: true})
+	mem.PutUser(model.User{UserID: 2, FBFirebaseID: &fbID, Provider: &provider})
+
+	usr, res, err := u.Verify(ctx, &model.User{UserID: 2, Provider: &provider, PhoneCountryCode: &code, PhoneNumber: &number, PhoneFirebaseID: &newPhoneID}, nil, "")
+	require.Nil(t, err)
+	require.Nil(t, res)
+	assert.Equal(t, int64(1), usr.UserID)
+	assert.Equal(t, fbID, *usr.FBFirebaseID)
+	assert.Equal(t, newPhoneID, *usr.PhoneFirebaseID)
+
+	merged, _, err := mem.Store().Users.Get(ctx, 2)
+	require.Nil(t, err)
+	assert.False(t, merged.IsActive)
+	assert.True(t, merged.IsRegistered)
+}
+
+func TestSocialSignInFindsUserByOtherEmail(t *testing.T) {
+	mem := store.NewMemory()
+	u := newUser(mem.Store())
+	ctx := context.Background()
+
+	code, number, email := "+1", "5550100", "ana@example.com"
+	mem.PutUser(model.User{UserID: 1, PhoneCountryCode: &code, PhoneNumber: &number, GEmail: &email})
+
+	provider, fbID := fb_provider, "fb-1"
+	usr, auth, err := u.Create(ctx, &model.User{Provider: &provider, FBFirebaseID: &fbID, FBEmail: &email}, &model.Auth{}, "")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), usr.UserID)
+	assert.Equal(t, number, *usr.PhoneNumber)
+	assert.Equal(t, mobile_number_verification, auth.Status)
+
+	linked, _, err := mem.Store().Users.Get(ctx, 1)
+	require.Nil(t, err)
+	assert.Equal(t, fbID, *linked.FBFirebaseID)
+	assert.Equal(t, email, *linked.GEmail)
+}
+
+func TestUpdateSetsOnlyTheGivenProfileFields(t *testing.T) {
+	mem := store.NewMemory()
+	u := newUser(mem.Store())
+	ctx := context.Background()
+
+	first, city, blank, email := "Ana", "Lisbon", " ", "ana@example.com"
+	mem.PutUser(model.User{UserID: 1, FirstName: &first, City: &city})
+
+	_, err := u.Update(ctx, &model.User{UserID: 1, City: &blank, EmailAddress: &email})
+	require.Nil(t, err)
+
+	usr, _, err := mem.Store().Users.Get(ctx, 1)
+	require.Nil(t, err)
+	assert.Equal(t, first, *usr.FirstName)
+	assert.Equal(t, city, *usr.City)
+	assert.Equal(t, email, *usr.EmailAddress)
+}
@@ -0,0 +1,422 @@
+// Package webhook delivers event and ticket changes to the callback URL of the
+// marketplaces subscribed to them. Deliveries are stored before they are sent,
+// retried with exponential backoff and kept as DEAD once out of attempts so
+// that partners can replay them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"eventers-marketplace-backend/logger"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/response"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Event types a marketplace can subscribe to.
+const (
+	EventPublished    = "event.published"
+	EventMinted       = "event.minted"
+	EventCancelled    = "event.cancelled"
+	TicketBought      = "ticket.bought"
+	TicketListed      = "ticket.listed"
+	TicketResold      = "ticket.resold"
+	TicketTransferred = "ticket.transferred"
+	TicketBridged     = "ticket.bridged"
+	TicketRedeemed    = "ticket.redeemed"
+	AuctionOutbid     = "auction.outbid"
+	AuctionEnded      = "auction.ended"
+)
+
+// Events lists every event type.
+var Events = []string{EventPublished, EventMinted, EventCancelled, TicketBought, TicketListed, TicketResold, TicketTransferred, TicketBridged, TicketRedeemed, AuctionOutbid, AuctionEnded}
+
+// Headers of a delivery. The signature header is "t=<unix time>,v1=<hex
+// HMAC-SHA256 of the time, a dot and the body>", see Sign.
+const (
+	SignatureHeader  = "X-Eventers-Signature"
+	EventIDHeader    = "X-Eventers-Event-Id"
+	EventTypeHeader  = "X-Eventers-Event-Type"
+	DeliveryIDHeader = "X-Eventers-Delivery-Id"
+)
+
+const (
+	secretBytes  = 32
+	eventIDBytes = 16
+	maxLastError = 1024
+	maxResponse  = 4096
+)
+
+// Policy bounds how deliveries are retried.
+type Policy struct {
+	// MaxAttempts failed attempts make a delivery DEAD.
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt, doubling with every
+	// attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds a single attempt, and BatchSize the deliveries attempted
+	// per sweep.
+	Timeout   time.Duration
+	BatchSize int
+}
+
+// Webhooks enqueues and delivers webhooks.
+type Webhooks struct {
+	store  *store.Store
+	client *http.Client
+	policy Policy
+}
+
+// New returns the webhooks sending deliveries with client.
+func New(st *store.Store, client *http.Client, policy Policy) *Webhooks {
+	return &Webhooks{store: st, client: client, policy: policy}
+}
+
+// Valid reports whether eventType is an event type.
+func Valid(eventType string) bool {
+	return contains(Events, eventType)
+}
+
+// Publish enqueues the event for the marketplaces subscribed to it, the
+// marketplaces of marketplaceIDs when some are given and every marketplace
+// otherwise. Zero ids stand for the app and are skipped.
+func (w *Webhooks) Publish(ctx context.Context, eventType string, data interface{}, marketplaceIDs ...int64) error {
+	ms, err := w.subscribers(ctx, eventType, marketplaceIDs)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	if len(ms) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("publish: error encoding data: %w", err)
+	}
+
+	id, err := randomHex(eventIDBytes)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(model.WebhookEvent{ID: "evt_" + id, Type: eventType, CreatedAt: now, Data: b})
+	if err != nil {
+		return fmt.Errorf("publish: error encoding event: %w", err)
+	}
+
+	for _, m := range ms {
+		err = w.store.Webhooks.Enqueue(ctx, &model.WebhookDelivery{
+			MarketPlaceID: m.MarketPlaceID,
+			EventID:       "evt_" + id,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        model.WebhookPending,
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			return fmt.Errorf("publish: marketplace: %d: %w", m.MarketPlaceID, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhooks) subscribers(ctx context.Context, eventType string, marketplaceIDs []int64) ([]model.Marketplace, error) {
+	var ms []model.Marketplace
+	if len(marketplaceIDs) == 0 {
+		var err error
+		ms, err = w.store.Marketplaces.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("subscribers: %w", err)
+		}
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range marketplaceIDs {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		m, ok, err := w.store.Marketplaces.Get(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("subscribers: %w", err)
+		}
+
+		if ok {
+			ms = append(ms, *m)
+		}
+	}
+
+	var subscribed []model.Marketplace
+	for _, m := range ms {
+		if m.IsActive && m.CallbackURL != nil && *m.CallbackURL != "" && contains(m.WebhookEvents, eventType) {
+			subscribed = append(subscribed, m)
+		}
+	}
+
+	return subscribed, nil
+}
+
+// Run attempts the due deliveries every interval until ctx is done.
+func (w *Webhooks) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := w.DeliverDue(ctx)
+			if err != nil {
+				logger.Errorf(ctx, "run: %+v", err)
+			}
+		}
+	}
+}
+
+// DeliverDue attempts the deliveries that are due and returns how many were
+// delivered.
+func (w *Webhooks) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	ds, err := w.store.Webhooks.Due(ctx, now, w.policy.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("deliverDue: %w", err)
+	}
+
+	delivered := 0
+	for i := range ds {
+		d := &ds[i]
+		ok, err := w.store.Webhooks.Claim(ctx, d.DeliveryID, now, now.Add(w.policy.Timeout+w.policy.Backoff))
+		if err != nil {
+			return delivered, fmt.Errorf("deliverDue: %w", err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		err = w.attempt(ctx, d)
+		if err != nil {
+			logger.Errorf(ctx, "deliverDue: delivery: %d: %+v", d.DeliveryID, err)
+			continue
+		}
+
+		if d.Status == model.WebhookDelivered {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// attempt posts the delivery once and records the outcome. Failed attempts are
+// retried after Backoff until MaxAttempts is reached.
+func (w *Webhooks) attempt(ctx context.Context, d *model.WebhookDelivery) error {
+	m, ok, err := w.store.Marketplaces.Get(ctx, d.MarketPlaceID)
+	if err != nil {
+		return fmt.Errorf("attempt: %w", err)
+	}
+
+	secret, err := w.store.Marketplaces.WebhookSecret(ctx, d.MarketPlaceID)
+	if err != nil {
+		return fmt.Errorf("attempt: %w", err)
+	}
+
+	d.Attempts++
+	var statusCode int
+	switch {
+	case !ok || !m.IsActive || m.CallbackURL == nil || *m.CallbackURL == "":
+		err = fmt.Errorf("marketplace has no callback url")
+	case secret == "":
+		err = fmt.Errorf("marketplace has no webhook secret")
+	default:
+		statusCode, err = w.post(ctx, *m.CallbackURL, secret, d)
+	}
+
+	now := time.Now().UTC()
+	d.LastStatusCode = statusCode
+	d.LastError = ""
+	switch {
+	case err == nil:
+		d.Status = model.WebhookDelivered
+		d.DeliveredAt = &now
+	case d.Attempts >= w.policy.MaxAttempts:
+		d.Status = model.WebhookDead
+		d.LastError = truncate(err.Error())
+	default:
+		next := now.Add(Backoff(w.policy, d.Attempts))
+		d.NextAttemptAt = &next
+		d.LastError = truncate(err.Error())
+	}
+
+	err = w.store.Webhooks.Update(ctx, d)
+	if err != nil {
+		return fmt.Errorf("attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (w *Webhooks) post(ctx context.Context, url, secret string, d *model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.policy.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(d.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("post: error creating request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%s,v1=%s", timestamp, Sign(secret, timestamp, []byte(d.Payload))))
+	req.Header.Set(EventIDHeader, d.EventID)
+	req.Header.Set(EventTypeHeader, d.EventType)
+	req.Header.Set(DeliveryIDHeader, strconv.FormatInt(d.DeliveryID, 10))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponse))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("post: callback url answered %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256, keyed with secret, of the timestamp, a dot
+// and the payload.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait after the attempt-th failed attempt.
+func Backoff(p Policy, attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return wait
+}
+
+// Deliveries returns the latest deliveries to the marketplace, of the status
+// when one is given.
+func (w *Webhooks) Deliveries(ctx context.Context, marketplaceID int64, status string, limit int) ([]model.WebhookDelivery, error) {
+	if status != "" && status != model.WebhookPending && status != model.WebhookDelivered && status != model.WebhookDead {
+		return nil, response.InvalidData(fmt.Sprintf("deliveries: invalid status: %s", status))
+	}
+
+	ds, err := w.store.Webhooks.List(ctx, marketplaceID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("deliveries: %w", err)
+	}
+
+	return ds, nil
+}
+
+// Replay sends a delivery to the marketplace again, with a fresh set of
+// attempts. Deliveries still pending are left as they are.
+func (w *Webhooks) Replay(ctx context.Context, marketplaceID, deliveryID int64) (*model.WebhookDelivery, error) {
+	d, ok, err := w.store.Webhooks.Get(ctx, marketplaceID, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	if !ok {
+		return nil, response.ResourceNotFound("delivery not found", fmt.Sprintf("replay: delivery_id: %d", deliveryID))
+	}
+
+	if d.Status == model.WebhookPending {
+		return nil, response.InvalidStateTransition(fmt.Sprintf("replay: delivery: %d is still %s", deliveryID, d.Status))
+	}
+
+	now := time.Now().UTC()
+	d.Status = model.WebhookPending
+	d.Attempts = 0
+	d.NextAttemptAt = &now
+	d.DeliveredAt = nil
+
+	err = w.store.Webhooks.Update(ctx, d)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+
+	return d, nil
+}
+
+// RotateSecret issues a new secret for the marketplace to verify deliveries
+// with. The secret is only returned here.
+func (w *Webhooks) RotateSecret(ctx context.Context, marketplaceID int64) (string, error) {
+	_, ok, err := w.store.Marketplaces.Get(ctx, marketplaceID)
+	if err != nil {
+		return "", fmt.Errorf("rotateSecret: %w", err)
+	}
+
+	if !ok {
+		return "", response.ResourceNotFound("marketplace not found", fmt.Sprintf("rotateSecret: marketplace_id: %d", marketplaceID))
+	}
+
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return "", fmt.Errorf("rotateSecret: %w", err)
+	}
+
+	err = w.store.Marketplaces.SetWebhookSecret(ctx, marketplaceID, "whsec_"+secret)
+	if err != nil {
+		return "", fmt.Errorf("rotateSecret: %w", err)
+	}
+
+	return "whsec_" + secret, nil
+}
+
+func truncate(s string) string {
+	if len(s) > maxLastError {
+		return s[:maxLastError]
+	}
+	return s
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("randomHex: error reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"eventers-marketplace-backend/model"
+	"eventers-marketplace-backend/store"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishAndDeliver(t *testing.T) {
+	var status = http.StatusOK
+	var received []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	mem := store.NewMemory()
+	st := mem.Store()
+	ctx := context.Background()
+	callback := server.URL
+	mem.PutMarketplace(model.Marketplace{MarketPlaceID: 1, IsActive: true, CallbackURL: &callback, WebhookEvents: []string{TicketBought}})
+	mem.PutMarketplace(model.Marketplace{MarketPlaceID: 2, IsActive: true, CallbackURL: &callback, WebhookEvents: []string{EventPublished}})
+
+	w := New(st, server.Client(), Policy{MaxAttempts: 2, Backoff: time.Minute, MaxBackoff: time.Hour, Timeout: time.Second, BatchSize: 10})
+	secret, err := w.RotateSecret(ctx, 1)
+	require.Nil(t, err)
+
+	require.Nil(t, w.Publish(ctx, TicketBought, &model.EventTicket{EventTicketID: 7}, 1, 2, 0))
+
+	delivered, err := w.DeliverDue(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, delivered)
+	require.Len(t, received, 1)
+
+	var e model.WebhookEvent
+	require.Nil(t, json.Unmarshal([]byte(bodies[0]), &e))
+	assert.Equal(t, TicketBought, e.Type)
+	assert.JSONEq(t, `{"event_ticket_id":7}`, string(e.Data))
+	assert.Equal(t, e.ID, received[0].Header.Get(EventIDHeader))
+
+	parts := strings.Split(received[0].Header.Get(SignatureHeader), ",")
+	require.Len(t, parts, 2)
+	timestamp := strings.TrimPrefix(parts[0], "t=")
+	assert.Equal(t, "v1="+Sign(secret, timestamp, []byte(bodies[0])), parts[1])
+
+	ds, err := w.Deliveries(ctx, 1, model.WebhookDelivered, 10)
+	require.Nil(t, err)
+	require.Len(t, ds, 1)
+	assert.Equal(t, 1, ds[0].Attempts)
+	assert.Equal(t, http.StatusOK, ds[0].LastStatusCode)
+
+	_, err = w.Replay(ctx, 2, ds[0].DeliveryID)
+	assert.NotNil(t, err)
+
+	replayed, err := w.Replay(ctx, 1, ds[0].DeliveryID)
+	require.Nil(t, err)
+	assert.Equal(t, model.WebhookPending, replayed.Status)
+
+	status = http.StatusInternalServerError
+	delivered, err = w.DeliverDue(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 0, delivered)
+
+	ds, err = w.Deliveries(ctx, 1, model.WebhookPending, 10)
+	require.Nil(t, err)
+	require.Len(t, ds, 1)
+	assert.Equal(t, http.StatusInternalServerError, ds[0].LastStatusCode)
+	assert.True(t, ds[0].NextAttemptAt.After(time.Now().Add(50*time.Second)))
+
+	next := time.Now().UTC()
+	ds[0].NextAttemptAt = &next
+	require.Nil(t, st.Webhooks.Update(ctx, &ds[0]))
+
+	_, err = w.DeliverDue(ctx)
+	require.Nil(t, err)
+
+	ds, err = w.Deliveries(ctx, 1, model.WebhookDead, 10)
+	require.Nil(t, err)
+	require.Len(t, ds, 1)
+	assert.Equal(t, 2, ds[0].Attempts)
+	assert.Len(t, received, 3)
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+
+	for attempt, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		assert.Equal(t, want, Backoff(p, attempt+1), fmt.Sprintf("attempt: %d", attempt+1))
+	}
+}